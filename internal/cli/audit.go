@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/cloud"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+var (
+	auditContextFlag string
+	auditSinceFlag   string
+	auditActionFlag  string
+	auditLimitFlag   int
+	auditOutputFlag  string
+	auditVerifyFlag  bool
+)
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Query the local audit log",
+		Long: `Query the local audit log, oldest first.
+
+Every context switch, deactivation, logout, VPN and tunnel action is written
+to an append-only log in the state directory, whether or not ctx-cloud is
+configured. Each record holds the hash of the one before it, so editing or
+removing records breaks the chain. Use --verify to check it.
+
+--action matches the action or its prefix, so --action vpn shows both
+vpn.connect and vpn.disconnect.
+
+Examples:
+  ctx audit                                # Last 50 records
+  ctx audit --context prod --since 7d
+  ctx audit --action tunnel --since 2026-09-01 -o json
+  ctx audit --verify`,
+		Args: cobra.NoArgs,
+		RunE: runAudit,
+	}
+
+	cmd.Flags().StringVarP(&auditContextFlag, "context", "c", "", "Only show records for this context")
+	cmd.Flags().StringVar(&auditSinceFlag, "since", "", "Only show records since a duration ago (e.g. 24h, 7d) or a date")
+	cmd.Flags().StringVarP(&auditActionFlag, "action", "a", "", "Only show records of this action (e.g. switch, vpn, tunnel.up)")
+	cmd.Flags().IntVarP(&auditLimitFlag, "limit", "n", 50, "Number of records to show, newest kept (0 for all)")
+	cmd.Flags().StringVarP(&auditOutputFlag, "output", "o", "table", "Output format: table or json")
+	cmd.Flags().BoolVar(&auditVerifyFlag, "verify", false, "Check the hash chain of the audit log")
+
+	return cmd
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	if !slices.Contains([]string{"table", "json"}, auditOutputFlag) {
+		return fmt.Errorf("invalid output format %q (use table or json)", auditOutputFlag)
+	}
+
+	var since time.Time
+	if auditSinceFlag != "" {
+		var err error
+		if since, err = parseSince(auditSinceFlag, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+	log := cloud.NewAuditLog(mgr.StateDir())
+
+	if auditVerifyFlag {
+		count, err := log.Verify()
+		if err != nil {
+			return err
+		}
+		green := color.New(color.FgGreen)
+		green.Printf("✓ Audit log intact (%d records)\n", count)
+		return nil
+	}
+
+	records, err := log.Records()
+	if err != nil {
+		return err
+	}
+	records = filterAuditRecords(records, auditContextFlag, auditActionFlag, since)
+	if auditLimitFlag > 0 && len(records) > auditLimitFlag {
+		records = records[len(records)-auditLimitFlag:]
+	}
+
+	if auditOutputFlag == "json" {
+		if records == nil {
+			records = []cloud.AuditRecord{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No audit records found.")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"TIME", "ACTION", "CONTEXT", "ENVIRONMENT", "RESULT", "SESSION"})
+	table.SetBorder(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetTablePadding("  ")
+	table.SetNoWhiteSpace(true)
+
+	for _, record := range records {
+		result := "ok"
+		if !record.Success {
+			result = "failed"
+			if record.ErrorMessage != "" {
+				result += ": " + record.ErrorMessage
+			}
+		}
+		table.Append([]string{
+			record.Time.Local().Format(time.DateTime),
+			record.Action,
+			orDash(record.ContextName),
+			orDash(record.Environment),
+			result,
+			orDash(record.Session),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// recordAuditEvent writes an event to the local audit log. Failing to write
+// it doesn't fail the command.
+func recordAuditEvent(mgr *config.Manager, event *cloud.AuditEvent) {
+	if err := cloud.NewAuditLog(mgr.StateDir()).Append(mgr.SessionID(), event); err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(os.Stderr, "⚠ Failed to write audit log: %v\n", err)
+	}
+}
+
+// filterAuditRecords keeps the records of the given context and action since
+// the given time. Empty filters match everything.
+func filterAuditRecords(records []cloud.AuditRecord, contextName, action string, since time.Time) []cloud.AuditRecord {
+	var filtered []cloud.AuditRecord
+	for _, record := range records {
+		if contextName != "" && record.ContextName != contextName {
+			continue
+		}
+		if action != "" && record.Action != action && !strings.HasPrefix(record.Action, action+".") {
+			continue
+		}
+		if record.Time.Before(since) {
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+	return filtered
+}
+
+// parseSince parses --since as a duration before now, which may be given in
+// days (7d), or as a date.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := parseReportTime(s, false); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (use a duration like 24h or 7d, or a date)", s)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vlebo/ctx/internal/cloud"
+)
+
+func TestFilterAuditRecords(t *testing.T) {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	record := func(seq int64, action, contextName string, hours int) cloud.AuditRecord {
+		return cloud.AuditRecord{
+			Seq:        seq,
+			Time:       base.Add(time.Duration(hours) * time.Hour),
+			AuditEvent: cloud.AuditEvent{Action: action, ContextName: contextName},
+		}
+	}
+	records := []cloud.AuditRecord{
+		record(0, "switch", "dev", 0),
+		record(1, "vpn.connect", "prod", 1),
+		record(2, "vpn.disconnect", "prod", 2),
+		record(3, "vpnx", "prod", 3),
+		record(4, "tunnel.up", "dev", 4),
+	}
+
+	tests := []struct {
+		name        string
+		contextName string
+		action      string
+		since       time.Time
+		want        []int64
+	}{
+		{name: "no filters", want: []int64{0, 1, 2, 3, 4}},
+		{name: "context", contextName: "dev", want: []int64{0, 4}},
+		{name: "action prefix", action: "vpn", want: []int64{1, 2}},
+		{name: "exact action", action: "vpn.connect", want: []int64{1}},
+		{name: "since", since: base.Add(2 * time.Hour), want: []int64{2, 3, 4}},
+		{name: "combined", contextName: "prod", action: "vpn", since: base.Add(90 * time.Minute), want: []int64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterAuditRecords(records, tt.contextName, tt.action, tt.since)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d records, want %v", len(got), tt.want)
+			}
+			for i, record := range got {
+				if record.Seq != tt.want[i] {
+					t.Errorf("record %d: Seq = %d, want %d", i, record.Seq, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "24h", want: now.Add(-24 * time.Hour)},
+		{in: "90m", want: now.Add(-90 * time.Minute)},
+		{in: "7d", want: now.AddDate(0, 0, -7)},
+		{in: "2026-03-01", want: time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)},
+		{in: "yesterday", wantErr: true},
+		{in: "-3d", wantErr: true},
+		{in: "-24h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSince(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSince(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("parseSince(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,10 +5,14 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -34,8 +38,11 @@ ctx-cloud provides:
 	cmd.AddCommand(newCloudLogoutCmd())
 	cmd.AddCommand(newCloudStatusCmd())
 	cmd.AddCommand(newCloudConfigCmd())
-	cmd.AddCommand(newCloudSyncCmd())
+	cmd.AddCommand(newCloudPullCmd())
+	cmd.AddCommand(newCloudPushCmd())
+	cmd.AddCommand(newCloudDiffCmd())
 	cmd.AddCommand(newCloudListCmd())
+	cmd.AddCommand(newCloudHeartbeatCmd())
 
 	return cmd
 }
@@ -43,31 +50,65 @@ ctx-cloud provides:
 func newCloudLoginCmd() *cobra.Command {
 	var serverURL string
 	var apiKey string
+	var sso bool
+	var issuer string
+	var clientID string
+	var caFile, clientCert, clientKey string
 
 	cmd := &cobra.Command{
 		Use:   "login",
 		Short: "Configure ctx-cloud integration",
-		Long: `Configure ctx-cloud integration by providing your server URL and API key.
+		Long: `Configure ctx-cloud integration by providing your server URL and API key,
+or by logging in with your identity provider.
 
 You can obtain an API key from the ctx-cloud web dashboard:
   1. Log in to your ctx-cloud instance
   2. Go to Settings > API Keys
   3. Create a new API key with the required scopes
 
-Example:
-  ctx cloud login --server https://cloud.example.com --api-key sk_xxx`,
+With --sso, ctx shows a code and opens the identity provider's login page in
+the current context's browser, where you enter the code (OAuth 2.0 device
+flow). The issuer and client ID come from the server unless given. The
+tokens are kept in the system keychain and refreshed as needed, until
+'ctx cloud logout' revokes them.
+
+For a server behind a private CA, pass its CA certificates with --ca-file. A
+gateway that requires mutual TLS gets the client certificate and key given
+with --client-cert and --client-key. Requests go through the current
+context's proxy, or the HTTP_PROXY and HTTPS_PROXY environment variables.
+
+Examples:
+  ctx cloud login --server https://cloud.example.com --api-key sk_xxx
+  ctx cloud login --server https://cloud.example.com --sso
+  ctx cloud login --server https://ctx.corp.internal --ca-file ~/certs/corp-ca.pem`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCloudLogin(serverURL, apiKey)
+			if sso && apiKey != "" {
+				return fmt.Errorf("--sso and --api-key can't be used together")
+			}
+			if !sso && (issuer != "" || clientID != "") {
+				return fmt.Errorf("--issuer and --client-id require --sso")
+			}
+			login := &config.CloudConfig{ServerURL: serverURL}
+			if err := setCloudCertFiles(login, caFile, clientCert, clientKey); err != nil {
+				return err
+			}
+			return runCloudLogin(cmd.Context(), login, apiKey, sso, issuer, clientID)
 		},
 	}
 
 	cmd.Flags().StringVar(&serverURL, "server", "", "ctx-cloud server URL (e.g., https://cloud.example.com)")
 	cmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication")
+	cmd.Flags().BoolVar(&sso, "sso", false, "Log in with your identity provider instead of an API key")
+	cmd.Flags().StringVar(&issuer, "issuer", "", "OIDC issuer URL for --sso (default from the server)")
+	cmd.Flags().StringVar(&clientID, "client-id", "", "OAuth client ID for --sso (default from the server)")
+	cmd.Flags().StringVar(&caFile, "ca-file", "", "PEM file of CA certificates to trust for the server")
+	cmd.Flags().StringVar(&clientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	cmd.Flags().StringVar(&clientKey, "client-key", "", "PEM private key of the client certificate")
 
 	return cmd
 }
 
-func runCloudLogin(serverURL, apiKey string) error {
+func runCloudLogin(ctx context.Context, login *config.CloudConfig, apiKey string, sso bool, issuer, clientID string) error {
 	mgr, err := GetConfigManager()
 	if err != nil {
 		return err
@@ -76,6 +117,7 @@ func runCloudLogin(serverURL, apiKey string) error {
 	reader := bufio.NewReader(os.Stdin)
 
 	// Prompt for server URL if not provided
+	serverURL := login.ServerURL
 	if serverURL == "" {
 		fmt.Print("Enter ctx-cloud server URL: ")
 		input, err := reader.ReadString('\n')
@@ -93,7 +135,16 @@ func runCloudLogin(serverURL, apiKey string) error {
 		serverURL = "https://" + serverURL
 	}
 	// Remove trailing slash
-	serverURL = strings.TrimSuffix(serverURL, "/")
+	login.ServerURL = strings.TrimSuffix(serverURL, "/")
+
+	httpClient, err := cloudHTTPClient(mgr, login)
+	if err != nil {
+		return err
+	}
+
+	if sso {
+		return runCloudLoginSSO(ctx, mgr, login, httpClient, issuer, clientID)
+	}
 
 	// Prompt for API key if not provided
 	if apiKey == "" {
@@ -113,8 +164,9 @@ func runCloudLogin(serverURL, apiKey string) error {
 	yellow := color.New(color.FgYellow)
 	yellow.Print("• Testing connection... ")
 
-	client := cloud.NewClient(serverURL, apiKey)
-	if err := client.TestConnection(); err != nil {
+	client := cloud.NewClient(login.ServerURL, apiKey)
+	client.SetHTTPClient(httpClient)
+	if err := client.TestConnection(ctx); err != nil {
 		red := color.New(color.FgRed)
 		red.Println("failed")
 		return fmt.Errorf("connection test failed: %w", err)
@@ -123,11 +175,47 @@ func runCloudLogin(serverURL, apiKey string) error {
 	green := color.New(color.FgGreen)
 	green.Println("success")
 
-	// Save API key
+	// Save API key, replacing an SSO login
 	if err := mgr.SaveCloudAPIKey(apiKey); err != nil {
 		return fmt.Errorf("failed to save API key: %w", err)
 	}
+	if appConfig := mgr.GetAppConfig(); appConfig != nil && appConfig.Cloud != nil && appConfig.Cloud.SSO != nil {
+		if err := logoutCloudSSO(ctx, mgr, appConfig.Cloud); err != nil {
+			yellow.Fprintf(os.Stderr, "⚠ Failed to log out of SSO: %v\n", err)
+		}
+	}
 
+	return saveCloudLogin(mgr, login)
+}
+
+// setCloudCertFiles sets the CA and client certificate files of a cloud
+// config. The paths are made absolute, so they work from any directory.
+func setCloudCertFiles(cloudCfg *config.CloudConfig, caFile, clientCert, clientKey string) error {
+	paths := []struct {
+		value string
+		field *string
+	}{
+		{caFile, &cloudCfg.CAFile},
+		{clientCert, &cloudCfg.ClientCert},
+		{clientKey, &cloudCfg.ClientKey},
+	}
+	for _, p := range paths {
+		if p.value == "" {
+			*p.field = ""
+			continue
+		}
+		path, err := filepath.Abs(expandPath(p.value))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", p.value, err)
+		}
+		*p.field = path
+	}
+	return nil
+}
+
+// saveCloudLogin enables ctx-cloud in the app config after logging in, with
+// the server, TLS and SSO settings of login.
+func saveCloudLogin(mgr *config.Manager, login *config.CloudConfig) error {
 	// Update app config with cloud settings
 	appConfig := mgr.GetAppConfig()
 	if appConfig == nil {
@@ -135,17 +223,22 @@ func runCloudLogin(serverURL, apiKey string) error {
 	}
 
 	appConfig.Cloud = &config.CloudConfig{
-		ServerURL:         serverURL,
+		ServerURL:         login.ServerURL,
 		Enabled:           true,
 		SendAuditEvents:   true,
 		SendHeartbeat:     true,
 		HeartbeatInterval: 30,
+		SSO:               login.SSO,
+		CAFile:            login.CAFile,
+		ClientCert:        login.ClientCert,
+		ClientKey:         login.ClientKey,
 	}
 
 	if err := mgr.SaveAppConfig(appConfig); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
+	green := color.New(color.FgGreen)
 	fmt.Println()
 	green.Println("✓ ctx-cloud integration configured successfully!")
 	fmt.Println()
@@ -162,7 +255,7 @@ func newCloudLogoutCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "logout",
 		Short: "Remove ctx-cloud integration",
-		Long:  `Remove ctx-cloud integration by deleting the stored API key and disabling cloud features.`,
+		Long:  `Remove ctx-cloud integration by deleting the stored API key or revoking the SSO login, and disabling cloud features.`,
 		RunE:  runCloudLogout,
 	}
 }
@@ -178,10 +271,21 @@ func runCloudLogout(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to delete API key: %w", err)
 	}
 
-	// Disable cloud in config
+	// Revoke an SSO login, so its tokens can't be used anymore
 	appConfig := mgr.GetAppConfig()
+	var cloudCfg *config.CloudConfig
+	if appConfig != nil {
+		cloudCfg = appConfig.Cloud
+	}
+	if err := logoutCloudSSO(cmd.Context(), mgr, cloudCfg); err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(os.Stderr, "⚠ Failed to revoke SSO login: %v\n", err)
+	}
+
+	// Disable cloud in config
 	if appConfig != nil && appConfig.Cloud != nil {
 		appConfig.Cloud.Enabled = false
+		appConfig.Cloud.SSO = nil
 		if err := mgr.SaveAppConfig(appConfig); err != nil {
 			return fmt.Errorf("failed to save config: %w", err)
 		}
@@ -209,6 +313,8 @@ func runCloudStatus(cmd *cobra.Command, args []string) error {
 
 	appConfig := mgr.GetAppConfig()
 	apiKey := mgr.LoadCloudAPIKey()
+	ssoToken, ssoErr := cloudTokenStore{mgr: mgr}.LoadToken()
+	loggedIn := apiKey != "" || ssoToken != nil
 
 	green := color.New(color.FgGreen)
 	red := color.New(color.FgRed)
@@ -226,14 +332,15 @@ func runCloudStatus(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	pending, pendingErr := cloud.NewEventSpool(mgr.StateDir()).Pending()
 	cloud := appConfig.Cloud
 
 	// Status
 	fmt.Print("Status: ")
-	if cloud.Enabled && apiKey != "" {
+	if cloud.Enabled && loggedIn {
 		green.Println("Enabled")
-	} else if apiKey == "" {
-		red.Println("No API key")
+	} else if !loggedIn {
+		red.Println("Not logged in")
 	} else {
 		yellow.Println("Disabled")
 	}
@@ -245,18 +352,39 @@ func runCloudStatus(cmd *cobra.Command, args []string) error {
 	} else {
 		red.Println("Not set")
 	}
-
-	// API Key
-	fmt.Print("API Key: ")
-	if apiKey != "" {
-		// Show masked key
-		if len(apiKey) > 8 {
-			green.Printf("%s...%s\n", apiKey[:4], apiKey[len(apiKey)-4:])
-		} else {
-			green.Println("Configured")
+	if cloud.CAFile != "" {
+		fmt.Printf("CA File: %s\n", cloud.CAFile)
+	}
+	if cloud.ClientCert != "" {
+		fmt.Printf("Client Certificate: %s\n", cloud.ClientCert)
+	}
+
+	// SSO login or API key
+	if cloud.SSO != nil {
+		fmt.Print("Login: ")
+		switch {
+		case ssoErr != nil:
+			red.Printf("Unreadable (%v)\n", ssoErr)
+		case ssoToken == nil:
+			red.Println("Not logged in")
+		default:
+			green.Printf("SSO via %s\n", cloud.SSO.Issuer)
+			if !ssoToken.Expiry.IsZero() {
+				fmt.Printf("  Access Token Expires: %s\n", ssoToken.Expiry.Local().Format(time.DateTime))
+			}
 		}
 	} else {
-		red.Println("Not set")
+		fmt.Print("API Key: ")
+		if apiKey != "" {
+			// Show masked key
+			if len(apiKey) > 8 {
+				green.Printf("%s...%s\n", apiKey[:4], apiKey[len(apiKey)-4:])
+			} else {
+				green.Println("Configured")
+			}
+		} else {
+			red.Println("Not set")
+		}
 	}
 
 	fmt.Println()
@@ -266,14 +394,34 @@ func runCloudStatus(cmd *cobra.Command, args []string) error {
 	if cloud.SendHeartbeat {
 		fmt.Printf("  Heartbeat Interval: %ds\n", cloud.HeartbeatInterval)
 	}
+	printFeatureStatus("  Team Policies", cloud.PolicyKey != "")
+
+	// Audit events waiting to be sent
+	fmt.Println()
+	fmt.Print("Event Queue: ")
+	switch {
+	case pendingErr != nil:
+		red.Printf("Unreadable (%v)\n", pendingErr)
+	case len(pending) == 0:
+		green.Println("Empty")
+	default:
+		oldest := pending[0]
+		yellow.Printf("%d pending\n", len(pending))
+		fmt.Printf("  Oldest: %s event for '%s', queued %s (%s)\n", oldest.Event.Action, oldest.Event.ContextName,
+			oldest.QueuedAt.Local().Format(time.DateTime), formatAgo(oldest.QueuedAt))
+		if oldest.Attempts > 0 {
+			fmt.Printf("  Attempts: %d, next retry %s\n", oldest.Attempts, oldest.NextAttempt.Local().Format(time.DateTime))
+			fmt.Printf("  Last Error: %s\n", oldest.LastError)
+		}
+	}
 
 	// Test connection if configured
-	if cloud.Enabled && apiKey != "" && cloud.ServerURL != "" {
+	if cloud.Enabled && loggedIn && cloud.ServerURL != "" {
 		fmt.Println()
 		fmt.Print("Connection: ")
 		client := NewCloudClient(mgr)
 		if client != nil {
-			if err := client.TestConnection(); err != nil {
+			if err := client.TestConnection(cmd.Context()); err != nil {
 				red.Printf("Failed (%v)\n", err)
 			} else {
 				green.Println("OK")
@@ -307,13 +455,26 @@ func newCloudConfigCmd() *cobra.Command {
 Examples:
   ctx cloud config --audit-events=true
   ctx cloud config --heartbeat=false
-  ctx cloud config --heartbeat-interval=60`,
+  ctx cloud config --heartbeat-interval=60
+  ctx cloud config --policy-key=<base64 Ed25519 public key>
+  ctx cloud config --ca-file=~/certs/corp-ca.pem
+  ctx cloud config --client-cert=~/certs/me.pem --client-key=~/certs/me-key.pem
+
+Team policies from ctx-cloud are only enforced once the public key they're
+signed with is set with --policy-key. An empty key turns them off.
+
+The certificate files are checked before they're saved. An empty path
+removes the setting.`,
 		RunE: runCloudConfig,
 	}
 
 	cmd.Flags().Bool("audit-events", true, "Enable/disable audit event sending")
 	cmd.Flags().Bool("heartbeat", true, "Enable/disable heartbeat sending")
 	cmd.Flags().Int("heartbeat-interval", 30, "Heartbeat interval in seconds")
+	cmd.Flags().String("policy-key", "", "Public key the team policies are signed with")
+	cmd.Flags().String("ca-file", "", "PEM file of CA certificates to trust for the server")
+	cmd.Flags().String("client-cert", "", "PEM client certificate for mutual TLS")
+	cmd.Flags().String("client-key", "", "PEM private key of the client certificate")
 
 	return cmd
 }
@@ -345,6 +506,35 @@ func runCloudConfig(cmd *cobra.Command, args []string) error {
 		appConfig.Cloud.HeartbeatInterval = val
 	}
 
+	if cmd.Flags().Changed("policy-key") {
+		val, _ := cmd.Flags().GetString("policy-key")
+		if val != "" {
+			if _, err := cloud.ParsePolicyKey(val); err != nil {
+				return err
+			}
+		}
+		appConfig.Cloud.PolicyKey = val
+	}
+
+	if cmd.Flags().Changed("ca-file") || cmd.Flags().Changed("client-cert") || cmd.Flags().Changed("client-key") {
+		caFile, clientCert, clientKey := appConfig.Cloud.CAFile, appConfig.Cloud.ClientCert, appConfig.Cloud.ClientKey
+		if cmd.Flags().Changed("ca-file") {
+			caFile, _ = cmd.Flags().GetString("ca-file")
+		}
+		if cmd.Flags().Changed("client-cert") {
+			clientCert, _ = cmd.Flags().GetString("client-cert")
+		}
+		if cmd.Flags().Changed("client-key") {
+			clientKey, _ = cmd.Flags().GetString("client-key")
+		}
+		if err := setCloudCertFiles(appConfig.Cloud, caFile, clientCert, clientKey); err != nil {
+			return err
+		}
+		if _, err := cloudHTTPClient(mgr, appConfig.Cloud); err != nil {
+			return err
+		}
+	}
+
 	if err := mgr.SaveAppConfig(appConfig); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
@@ -359,112 +549,78 @@ func runCloudConfig(cmd *cobra.Command, args []string) error {
 // Returns nil if cloud integration is not configured or disabled.
 func NewCloudClient(mgr interface {
 	GetAppConfig() *config.AppConfig
+	GetCurrentContext() (*config.ContextConfig, error)
 	LoadCloudAPIKey() string
+	LoadCloudSSOToken() string
+	SaveCloudSSOToken(token string) error
+	StateStore() *config.StateStore
 }) *cloud.Client {
 	appConfig := mgr.GetAppConfig()
 	if appConfig == nil || appConfig.Cloud == nil || !appConfig.Cloud.Enabled {
 		return nil
 	}
 
-	apiKey := mgr.LoadCloudAPIKey()
-	if apiKey == "" {
+	httpClient, err := cloudHTTPClient(mgr, appConfig.Cloud)
+	if err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(os.Stderr, "⚠ ctx-cloud disabled: %v\n", err)
 		return nil
 	}
 
-	return cloud.NewClient(appConfig.Cloud.ServerURL, apiKey)
-}
-
-func newCloudSyncCmd() *cobra.Command {
-	var force bool
-
-	cmd := &cobra.Command{
-		Use:   "sync <context-name>",
-		Short: "Sync a shared context from ctx-cloud",
-		Long: `Download and save a shared context from the ctx-cloud server.
-
-This fetches the context configuration from the cloud and saves it locally,
-allowing you to use team-shared context configurations.
-
-If the context already exists locally, use --force to overwrite it.
-
-Examples:
-  ctx cloud sync my-team-context
-  ctx cloud sync my-team-context --force`,
-		Args: cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCloudSync(args[0], force)
-		},
+	var client *cloud.Client
+	if sso := appConfig.Cloud.SSO; sso != nil {
+		if mgr.LoadCloudSSOToken() == "" {
+			return nil
+		}
+		client = cloud.NewSSOClient(appConfig.Cloud.ServerURL, ssoOIDCConfig(sso, httpClient), cloudTokenStore{mgr: mgr})
+	} else {
+		apiKey := mgr.LoadCloudAPIKey()
+		if apiKey == "" {
+			return nil
+		}
+		client = cloud.NewClient(appConfig.Cloud.ServerURL, apiKey)
 	}
 
-	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite existing local context")
-
-	return cmd
+	client.SetHTTPClient(httpClient)
+	return client
 }
 
-func runCloudSync(contextName string, force bool) error {
-	mgr, err := GetConfigManager()
-	if err != nil {
-		return err
+// cloudHTTPClient returns the HTTP client for ctx-cloud, with the configured
+// CA and client certificates and the current context's proxy.
+func cloudHTTPClient(mgr interface {
+	GetCurrentContext() (*config.ContextConfig, error)
+}, cloudCfg *config.CloudConfig) (*http.Client, error) {
+	transport := cloud.TransportConfig{
+		CAFile:     expandPath(cloudCfg.CAFile),
+		ClientCert: expandPath(cloudCfg.ClientCert),
+		ClientKey:  expandPath(cloudCfg.ClientKey),
 	}
-
-	client := NewCloudClient(mgr)
-	if client == nil {
-		return fmt.Errorf("ctx-cloud not configured. Run 'ctx cloud login' first")
+	if ctx, err := mgr.GetCurrentContext(); err == nil && ctx != nil {
+		transport.Proxy = ctx.Proxy
 	}
+	return cloud.NewHTTPClient(transport)
+}
 
-	yellow := color.New(color.FgYellow)
-	green := color.New(color.FgGreen)
-
-	// Check if context already exists
-	if mgr.ContextExists(contextName) && !force {
-		return fmt.Errorf("context '%s' already exists locally. Use --force to overwrite", contextName)
-	}
-
-	// Fetch context from cloud
-	yellow.Printf("• Fetching context '%s' from cloud... ", contextName)
-
-	sharedCtx, err := client.SyncContext(contextName)
-	if err != nil {
-		fmt.Println()
-		return fmt.Errorf("failed to fetch context: %w", err)
-	}
-	if sharedCtx == nil {
-		fmt.Println()
-		return fmt.Errorf("context '%s' not found on server", contextName)
-	}
-
-	green.Println("done")
-
-	// Convert cloud config to local ContextConfig
-	ctxConfig, err := convertCloudContext(sharedCtx)
-	if err != nil {
-		return fmt.Errorf("failed to convert context: %w", err)
+// deliverAuditEvent queues an audit event for ctx-cloud and sends the queued
+// events that are due. Events that can't be sent now stay queued and are
+// retried by later commands.
+func deliverAuditEvent(mgr *config.Manager, client *cloud.Client, event *cloud.AuditEvent) error {
+	if err := queueAuditEvent(mgr, event); err != nil {
+		// Without the spool, at least try to send it once
+		return client.SendAuditEvent(context.Background(), event)
 	}
+	return flushAuditEvents(mgr, client)
+}
 
-	// Save context locally
-	yellow.Printf("• Saving context locally... ")
-
-	if err := mgr.SaveContext(ctxConfig); err != nil {
-		fmt.Println()
-		return fmt.Errorf("failed to save context: %w", err)
-	}
-
-	green.Println("done")
-
-	fmt.Println()
-	green.Printf("✓ Context '%s' synced successfully!\n", contextName)
-	fmt.Println()
-	fmt.Printf("  Version: %d\n", sharedCtx.Version)
-	if ctxConfig.Environment != "" {
-		fmt.Printf("  Environment: %s\n", ctxConfig.Environment)
-	}
-	if ctxConfig.Description != "" {
-		fmt.Printf("  Description: %s\n", ctxConfig.Description)
-	}
-	fmt.Println()
-	fmt.Printf("Use 'ctx use %s' to activate this context.\n", contextName)
+// queueAuditEvent adds an audit event to the spool of events for ctx-cloud.
+func queueAuditEvent(mgr *config.Manager, event *cloud.AuditEvent) error {
+	return cloud.NewEventSpool(mgr.StateDir()).Enqueue(event)
+}
 
-	return nil
+// flushAuditEvents sends the queued audit events that are due to ctx-cloud.
+func flushAuditEvents(mgr *config.Manager, client *cloud.Client) error {
+	_, err := cloud.NewEventSpool(mgr.StateDir()).Flush(context.Background(), client)
+	return err
 }
 
 // convertCloudContext converts a cloud SharedContext to a local ContextConfig.
@@ -495,7 +651,7 @@ func convertCloudContext(shared *cloud.SharedContext) (*config.ContextConfig, er
 	if shared.IsAbstract {
 		configMap["abstract"] = true
 	}
-	if shared.Extends != "" {
+	if len(shared.Extends) > 0 {
 		configMap["extends"] = shared.Extends
 	}
 
@@ -534,7 +690,7 @@ func runCloudList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("ctx-cloud not configured. Run 'ctx cloud login' first")
 	}
 
-	contexts, err := client.GetSharedContexts()
+	contexts, err := client.GetSharedContexts(cmd.Context())
 	if err != nil {
 		return fmt.Errorf("failed to fetch contexts: %w", err)
 	}
@@ -545,6 +701,12 @@ func runCloudList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	syncState := cloud.NewSyncState(mgr.StateDir())
+	if err := syncState.SetRemoteVersions(contexts); err != nil {
+		color.New(color.FgYellow).Fprintf(os.Stderr, "⚠ Failed to record remote versions: %v\n", err)
+	}
+	records, _ := syncState.Records()
+
 	fmt.Println("Available shared contexts:")
 	fmt.Println()
 
@@ -580,20 +742,26 @@ func runCloudList(cmd *cobra.Command, args []string) error {
 		}
 
 		// Extends info
-		if ctx.Extends != "" {
+		if len(ctx.Extends) > 0 {
 			fmt.Printf("    extends: %s\n", ctx.Extends)
 		}
 
 		// Check if synced locally
-		if mgr.ContextExists(ctx.Name) {
+		if record, ok := records[ctx.Name]; ok && mgr.ContextExists(ctx.Name) {
 			green.Print("    ✓ synced locally")
-			fmt.Printf(" (v%d)\n", ctx.Version)
+			fmt.Printf(" (v%d)", record.Version)
+			if status := syncStatus(mgr, records, ctx.Name); status != "" {
+				yellow.Printf(" - %s, v%d on server", status, ctx.Version)
+			}
+			fmt.Println()
+		} else if mgr.ContextExists(ctx.Name) {
+			yellow.Println("    • exists locally, not pulled")
 		}
 
 		fmt.Println()
 	}
 
-	fmt.Println("Use 'ctx cloud sync <name>' to download a context.")
+	fmt.Println("Use 'ctx cloud pull <name>' to download a context.")
 
 	return nil
 }
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/fatih/color"
+	"github.com/vlebo/ctx/internal/cloud"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// defaultSSOScopes are requested when the server doesn't advertise any.
+// offline_access gets a refresh token, so the login lasts.
+var defaultSSOScopes = []string{"openid", "offline_access"}
+
+// cloudTokenStore keeps the tokens of a ctx-cloud SSO login with the other
+// credentials of the config manager.
+type cloudTokenStore struct {
+	mgr interface {
+		LoadCloudSSOToken() string
+		SaveCloudSSOToken(token string) error
+		StateStore() *config.StateStore
+	}
+}
+
+// ssoTokenLock is the state file locked while an SSO token is refreshed.
+const ssoTokenLock = "cloud-sso.lock"
+
+func (s cloudTokenStore) LoadToken() (*cloud.OAuthToken, error) {
+	data := s.mgr.LoadCloudSSOToken()
+	if data == "" {
+		return nil, nil
+	}
+	var token cloud.OAuthToken
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return nil, fmt.Errorf("failed to parse saved SSO token: %w", err)
+	}
+	return &token, nil
+}
+
+func (s cloudTokenStore) SaveToken(token *cloud.OAuthToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SSO token: %w", err)
+	}
+	return s.mgr.SaveCloudSSOToken(string(data))
+}
+
+func (s cloudTokenStore) LockToken() (func(), error) {
+	lock, err := s.mgr.StateStore().Lock(ssoTokenLock)
+	if err != nil {
+		return nil, err
+	}
+	return func() { lock.Unlock() }, nil
+}
+
+// ssoOIDCConfig returns the OIDC settings saved at SSO login, reached with
+// httpClient.
+func ssoOIDCConfig(sso *config.CloudSSOConfig, httpClient *http.Client) *cloud.OIDCConfig {
+	return &cloud.OIDCConfig{
+		ClientID:      sso.ClientID,
+		Scopes:        sso.Scopes,
+		TokenURL:      sso.TokenURL,
+		RevocationURL: sso.RevocationURL,
+		HTTPClient:    httpClient,
+	}
+}
+
+// runCloudLoginSSO logs in to ctx-cloud with the OAuth 2.0 device flow. The
+// issuer and client ID are asked from the server unless given.
+func runCloudLoginSSO(ctx context.Context, mgr *config.Manager, login *config.CloudConfig, httpClient *http.Client, issuer, clientID string) error {
+	yellow := color.New(color.FgYellow)
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+
+	var scopes []string
+	if issuer == "" || clientID == "" {
+		settings, err := cloud.GetSSOSettings(ctx, httpClient, login.ServerURL)
+		if err != nil {
+			return fmt.Errorf("failed to get SSO settings from server (use --issuer and --client-id): %w", err)
+		}
+		if issuer == "" {
+			issuer = settings.Issuer
+		}
+		if clientID == "" {
+			clientID = settings.ClientID
+		}
+		scopes = settings.Scopes
+	}
+	if len(scopes) == 0 {
+		scopes = defaultSSOScopes
+	}
+
+	oidc, err := cloud.DiscoverOIDC(ctx, httpClient, issuer, clientID, scopes)
+	if err != nil {
+		return err
+	}
+	auth, err := oidc.StartDeviceAuthorization(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("To log in, open %s and enter the code:\n\n", auth.VerificationURI)
+	color.New(color.FgCyan, color.Bold).Printf("    %s\n\n", auth.UserCode)
+
+	// Open it where the current context logs in to everything else
+	var browserCfg *config.BrowserConfig
+	if current, err := mgr.GetCurrentContext(); err == nil && current != nil {
+		browserCfg = current.Browser
+	}
+	verificationURL := auth.VerificationURIComplete
+	if verificationURL == "" {
+		verificationURL = auth.VerificationURI
+	}
+	if err := OpenURL(browserCfg, verificationURL); err != nil {
+		yellow.Fprintf(os.Stderr, "⚠ Failed to open browser: %v\n", err)
+	}
+
+	pollCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	yellow.Print("• Waiting for authorization... ")
+	token, err := oidc.PollDeviceToken(pollCtx, auth)
+	if err != nil {
+		red.Println("failed")
+		return fmt.Errorf("login failed: %w", err)
+	}
+	green.Println("done")
+
+	store := cloudTokenStore{mgr: mgr}
+	if err := store.SaveToken(token); err != nil {
+		return err
+	}
+
+	yellow.Print("• Testing connection... ")
+	client := cloud.NewSSOClient(login.ServerURL, oidc, store)
+	client.SetHTTPClient(httpClient)
+	if err := client.TestConnection(ctx); err != nil {
+		red.Println("failed")
+		mgr.DeleteCloudSSOToken()
+		return fmt.Errorf("connection test failed: %w", err)
+	}
+	green.Println("success")
+
+	// The SSO login replaces an API key
+	if err := mgr.DeleteCloudAPIKey(); err != nil {
+		return err
+	}
+
+	login.SSO = &config.CloudSSOConfig{
+		Issuer:        issuer,
+		ClientID:      clientID,
+		Scopes:        scopes,
+		TokenURL:      oidc.TokenURL,
+		RevocationURL: oidc.RevocationURL,
+	}
+	return saveCloudLogin(mgr, login)
+}
+
+// logoutCloudSSO revokes the tokens of an SSO login at the provider and
+// deletes them.
+func logoutCloudSSO(ctx context.Context, mgr *config.Manager, cloudCfg *config.CloudConfig) error {
+	var errs []error
+	token, err := cloudTokenStore{mgr: mgr}.LoadToken()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if token != nil && cloudCfg != nil && cloudCfg.SSO != nil {
+		httpClient, err := cloudHTTPClient(mgr, cloudCfg)
+		if err != nil {
+			return errors.Join(err, mgr.DeleteCloudSSOToken())
+		}
+		oidc := ssoOIDCConfig(cloudCfg.SSO, httpClient)
+		// Revoking the refresh token ends the session at most providers
+		errs = append(errs, oidc.Revoke(ctx, token.RefreshToken, "refresh_token"))
+		errs = append(errs, oidc.Revoke(ctx, token.AccessToken, "access_token"))
+	}
+	if err := mgr.DeleteCloudSSOToken(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
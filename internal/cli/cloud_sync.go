@@ -0,0 +1,609 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/cloud"
+	"github.com/vlebo/ctx/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func newCloudPullCmd() *cobra.Command {
+	var all bool
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:     "pull [context-name...]",
+		Aliases: []string{"sync"},
+		Short:   "Pull shared contexts from ctx-cloud",
+		Long: `Download shared contexts from the ctx-cloud server and save them locally.
+
+The version of each pulled context is recorded, so later pulls only download
+contexts that changed, and 'ctx list' can flag contexts that are out of date
+or have been edited locally.
+
+A context that exists locally but wasn't pulled, or was edited since, is not
+overwritten unless --force is given. Use 'ctx cloud diff' to see what changed.
+With --all, such contexts are skipped.
+
+Examples:
+  ctx cloud pull my-team-context
+  ctx cloud pull my-team-context --force
+  ctx cloud pull --all`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if all && len(args) > 0 {
+				return fmt.Errorf("--all doesn't take context names")
+			}
+			if !all && len(args) == 0 {
+				return fmt.Errorf("requires a context name, or --all")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCloudPull(cmd.Context(), args, all, force)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Pull every shared context")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite local contexts that weren't pulled or were edited")
+
+	return cmd
+}
+
+func runCloudPull(ctx context.Context, names []string, all, force bool) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return fmt.Errorf("ctx-cloud not configured. Run 'ctx cloud login' first")
+	}
+
+	state := cloud.NewSyncState(mgr.StateDir())
+	records, err := state.Records()
+	if err != nil {
+		return err
+	}
+
+	yellow := color.New(color.FgYellow)
+
+	p := &cloudPuller{
+		ctx:            ctx,
+		mgr:            mgr,
+		client:         client,
+		state:          state,
+		records:        records,
+		remoteVersions: make(map[string]int),
+		force:          force,
+		seen:           make(map[string]bool),
+	}
+
+	// With --all, the listing tells which contexts changed without fetching them
+	if all {
+		contexts, err := client.GetSharedContexts(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch contexts: %w", err)
+		}
+		if len(contexts) == 0 {
+			fmt.Println("No shared contexts available.")
+			return nil
+		}
+		if err := state.SetRemoteVersions(contexts); err != nil {
+			yellow.Fprintf(os.Stderr, "⚠ Failed to record remote versions: %v\n", err)
+		}
+		for _, shared := range contexts {
+			names = append(names, shared.Name)
+			p.remoteVersions[shared.Name] = shared.Version
+		}
+	}
+
+	failed := 0
+	for _, name := range names {
+		err := p.pull(name, nil)
+		var localErr *localChangesError
+		switch {
+		case err == nil:
+		case !all:
+			return err
+		case errors.As(err, &localErr):
+			yellow.Fprintf(os.Stderr, "⚠ Skipping '%s': it %s (use --force to overwrite)\n", name, localErr.reason)
+		default:
+			yellow.Fprintf(os.Stderr, "⚠ %v\n", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to pull %d of %d contexts", failed, len(names))
+	}
+	if len(names) == 1 {
+		fmt.Println()
+		fmt.Printf("Use 'ctx use %s' to activate this context.\n", names[0])
+	}
+	return nil
+}
+
+// localChangesError is returned when pulling a context would overwrite local
+// changes.
+type localChangesError struct {
+	name   string
+	reason string
+}
+
+func (e *localChangesError) Error() string {
+	return fmt.Sprintf("context '%s' %s. Use --force to overwrite it, or 'ctx cloud diff %s' to compare", e.name, e.reason, e.name)
+}
+
+// cloudPuller pulls contexts from ctx-cloud along with the contexts they
+// extend, so they can be loaded without the server.
+type cloudPuller struct {
+	ctx            context.Context
+	mgr            *config.Manager
+	client         *cloud.Client
+	state          *cloud.SyncState
+	records        map[string]cloud.SyncRecord
+	remoteVersions map[string]int
+	force          bool
+	seen           map[string]bool
+}
+
+// pull pulls a context, then the context it extends. chain holds the
+// contexts extending it, to detect cycles.
+func (p *cloudPuller) pull(name string, chain []string) error {
+	if err := config.CheckInheritanceCycle(chain, name); err != nil {
+		return err
+	}
+	if p.seen[name] {
+		return nil
+	}
+	p.seen[name] = true
+
+	yellow := color.New(color.FgYellow)
+	green := color.New(color.FgGreen)
+	ancestor := len(chain) > 0
+
+	record, synced := p.records[name]
+	exists := p.mgr.ContextExists(name)
+	modified := false
+	if exists {
+		data, err := p.mgr.ReadContextFile(name)
+		modified = err != nil || !synced || record.Modified(data)
+	}
+
+	if modified && !p.force {
+		reason := "has local changes"
+		if !synced {
+			reason = "exists locally and wasn't pulled from ctx-cloud"
+		}
+		if !ancestor {
+			return &localChangesError{name: name, reason: reason}
+		}
+		// The local parent still satisfies the child
+		yellow.Fprintf(os.Stderr, "⚠ Keeping parent '%s': it %s (use --force to overwrite)\n", name, reason)
+		return p.pullParents(name, localExtends(p.mgr, name), chain)
+	}
+
+	etag := ""
+	if exists && !modified {
+		if version, ok := p.remoteVersions[name]; ok && version == record.Version {
+			fmt.Printf("• '%s' is up to date (v%d)\n", name, record.Version)
+			return p.pullParents(name, localExtends(p.mgr, name), chain)
+		}
+		etag = record.ETag
+	}
+
+	yellow.Printf("• Pulling '%s'... ", name)
+	shared, err := p.fetch(name, etag, ancestor && !exists)
+	switch {
+	case errors.Is(err, cloud.ErrNotModified):
+		fmt.Printf("up to date (v%d)\n", record.Version)
+		return p.pullParents(name, localExtends(p.mgr, name), chain)
+	case err != nil:
+		fmt.Println()
+		return err
+	}
+	green.Printf("done (v%d)\n", shared.Version)
+
+	return p.pullParents(name, shared.Extends, chain)
+}
+
+// pullParents pulls the contexts that name extends.
+func (p *cloudPuller) pullParents(name string, parents config.Extends, chain []string) error {
+	for _, parent := range parents {
+		if err := p.pull(parent, append(chain, name)); err != nil {
+			return fmt.Errorf("failed to pull parent context '%s': %w", parent, err)
+		}
+	}
+	return nil
+}
+
+// fetch fetches a context, saves it locally and records its version. If etag
+// is set and the context hasn't changed, ErrNotModified is returned. Parents
+// pulled only because a context extends them are saved as abstract.
+func (p *cloudPuller) fetch(name, etag string, abstract bool) (*cloud.SharedContext, error) {
+	shared, err := p.client.FetchContext(p.ctx, name, etag)
+	if errors.Is(err, cloud.ErrNotModified) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch context '%s': %w", name, err)
+	}
+	if shared == nil {
+		return nil, fmt.Errorf("context '%s' not found on server", name)
+	}
+
+	ctxConfig, err := convertCloudContext(shared)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert context '%s': %w", name, err)
+	}
+	if abstract {
+		ctxConfig.Abstract = true
+	}
+	if err := p.mgr.SaveContext(ctxConfig); err != nil {
+		return nil, fmt.Errorf("failed to save context '%s': %w", name, err)
+	}
+
+	data, err := p.mgr.ReadContextFile(name)
+	if err != nil {
+		return nil, err
+	}
+	record := cloud.SyncRecord{
+		Version:       shared.Version,
+		ETag:          shared.ETag,
+		Hash:          cloud.HashContextFile(data),
+		RemoteVersion: shared.Version,
+		SyncedAt:      time.Now().UTC(),
+	}
+	err = p.state.Update(func(records map[string]cloud.SyncRecord) {
+		records[name] = record
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.records[name] = record
+	return shared, nil
+}
+
+// localExtends returns the parents of a local context, as written in its
+// file.
+func localExtends(mgr *config.Manager, name string) config.Extends {
+	data, err := mgr.ReadContextFile(name)
+	if err != nil {
+		return nil
+	}
+	var cfg config.ContextConfig
+	if yaml.Unmarshal(data, &cfg) != nil {
+		return nil
+	}
+	return cfg.Extends
+}
+
+// warnModifiedCloudContexts warns about a context and its parents that are
+// managed by ctx-cloud but were edited locally, as pulling them again won't
+// keep the edits.
+func warnModifiedCloudContexts(mgr *config.Manager, name string) {
+	records, err := cloud.NewSyncState(mgr.StateDir()).Records()
+	if err != nil || len(records) == 0 {
+		return
+	}
+
+	yellow := color.New(color.FgYellow)
+	seen := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if record, ok := records[name]; ok {
+			if data, err := mgr.ReadContextFile(name); err == nil && record.Modified(data) {
+				yellow.Fprintf(os.Stderr, "⚠ '%s' is managed by ctx-cloud and has local changes. Push them with 'ctx cloud push %s' or see 'ctx cloud diff %s'\n", name, name, name)
+			}
+		}
+		queue = append(queue, localExtends(mgr, name)...)
+	}
+}
+
+func newCloudPushCmd() *cobra.Command {
+	var force bool
+	var allowPlaintext bool
+
+	cmd := &cobra.Command{
+		Use:   "push <context-name>",
+		Short: "Push a local context to ctx-cloud",
+		Long: `Upload a local context to the ctx-cloud server as a new version.
+
+The push is made against the version that was last pulled or pushed. If
+someone else changed the context on the server since, the push is refused:
+use 'ctx cloud diff' to compare, then pull it with --force and redo your
+changes, or push with --force to overwrite theirs.
+
+A context that was never pulled is created on the server, unless it already
+exists there.
+
+The context is scanned for plaintext secrets first, as 'ctx lint --secrets'
+does, and isn't pushed if any are found unless --allow-plaintext is given.
+
+Examples:
+  ctx cloud push my-team-context
+  ctx cloud push my-team-context --force`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCloudPush(cmd.Context(), args[0], force, allowPlaintext)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the context on the server even if it changed")
+	cmd.Flags().BoolVar(&allowPlaintext, "allow-plaintext", false, "Push even if the context has plaintext secrets")
+
+	return cmd
+}
+
+func runCloudPush(ctx context.Context, name string, force, allowPlaintext bool) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return fmt.Errorf("ctx-cloud not configured. Run 'ctx cloud login' first")
+	}
+
+	data, err := mgr.ReadContextFile(name)
+	if err != nil {
+		return err
+	}
+	var local config.ContextConfig
+	if err := yaml.Unmarshal(data, &local); err != nil {
+		return fmt.Errorf("failed to parse context file: %w", err)
+	}
+
+	state := cloud.NewSyncState(mgr.StateDir())
+	records, err := state.Records()
+	if err != nil {
+		return err
+	}
+	record, synced := records[name]
+	if synced && !record.Modified(data) && !force {
+		fmt.Printf("No local changes to push for '%s' (v%d).\n", name, record.Version)
+		return nil
+	}
+
+	yellow := color.New(color.FgYellow)
+	green := color.New(color.FgGreen)
+
+	// Scan what the context resolves to, secrets from parents included
+	resolved, err := mgr.LoadContext(name)
+	if err != nil {
+		return fmt.Errorf("failed to load context: %w", err)
+	}
+	findings, err := config.ScanSecrets(resolved)
+	if err != nil {
+		return fmt.Errorf("failed to scan context: %w", err)
+	}
+	if len(findings) > 0 {
+		if !allowPlaintext {
+			printSecretFindings(os.Stderr, findings)
+			return fmt.Errorf("context '%s' has %d plaintext secrets. Move them to secrets: or *_env variables, or use --allow-plaintext to push anyway", name, len(findings))
+		}
+		yellow.Fprintf(os.Stderr, "⚠ Pushing '%s' with %d plaintext secrets\n", name, len(findings))
+	}
+
+	shared, err := toSharedContext(&local, data)
+	if err != nil {
+		return fmt.Errorf("failed to convert context: %w", err)
+	}
+	shared.Name = name
+	shared.Version = record.Version
+	shared.ETag = record.ETag
+
+	yellow.Printf("• Pushing context '%s' to cloud... ", name)
+	pushed, err := client.PushContext(ctx, shared, force)
+	if errors.Is(err, cloud.ErrVersionConflict) {
+		fmt.Println()
+		if !synced {
+			return fmt.Errorf("context '%s' already exists on ctx-cloud. Pull it first, or use --force to overwrite it", name)
+		}
+		return fmt.Errorf("context '%s' was changed on ctx-cloud since v%d. Run 'ctx cloud diff %s' to compare, or use --force to overwrite it", name, record.Version, name)
+	}
+	if err != nil {
+		fmt.Println()
+		return fmt.Errorf("failed to push context: %w", err)
+	}
+	green.Println("done")
+
+	err = state.Update(func(records map[string]cloud.SyncRecord) {
+		records[name] = cloud.SyncRecord{
+			Version:       pushed.Version,
+			ETag:          pushed.ETag,
+			Hash:          cloud.HashContextFile(data),
+			RemoteVersion: pushed.Version,
+			SyncedAt:      time.Now().UTC(),
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	green.Printf("✓ Context '%s' pushed (v%d)\n", name, pushed.Version)
+	return nil
+}
+
+// toSharedContext converts a local context and its file to a cloud
+// SharedContext, the reverse of convertCloudContext. The config is taken
+// from the file, so !override and !unset tags are kept.
+func toSharedContext(cfg *config.ContextConfig, data []byte) (*cloud.SharedContext, error) {
+	configMap, err := config.ContextFileMap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Metadata travels next to the config
+	for _, key := range []string{"name", "description", "environment", "abstract", "extends"} {
+		delete(configMap, key)
+	}
+
+	return &cloud.SharedContext{
+		Name:        cfg.Name,
+		Description: cfg.Description,
+		Environment: string(cfg.Environment),
+		Config:      configMap,
+		IsAbstract:  cfg.Abstract,
+		Extends:     cfg.Extends,
+	}, nil
+}
+
+func newCloudDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <context-name>",
+		Short: "Compare a local context with ctx-cloud",
+		Long: `Show the fields that differ between a local context and its version on
+the ctx-cloud server. Lines starting with - are local, lines starting with +
+are on the server.
+
+Example:
+  ctx cloud diff my-team-context`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCloudDiff(cmd.Context(), args[0])
+		},
+	}
+}
+
+func runCloudDiff(ctx context.Context, name string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return fmt.Errorf("ctx-cloud not configured. Run 'ctx cloud login' first")
+	}
+
+	data, err := mgr.ReadContextFile(name)
+	if err != nil {
+		return err
+	}
+	var local config.ContextConfig
+	if err := yaml.Unmarshal(data, &local); err != nil {
+		return fmt.Errorf("failed to parse context file: %w", err)
+	}
+
+	shared, err := client.SyncContext(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch context: %w", err)
+	}
+	if shared == nil {
+		return fmt.Errorf("context '%s' not found on server", name)
+	}
+	if err := cloud.NewSyncState(mgr.StateDir()).SetRemoteVersions([]*cloud.SharedContext{shared}); err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(os.Stderr, "⚠ Failed to record remote version: %v\n", err)
+	}
+
+	remote, err := convertCloudContext(shared)
+	if err != nil {
+		return fmt.Errorf("failed to convert context: %w", err)
+	}
+
+	localFields, err := config.FlattenContextTags(&local)
+	if err != nil {
+		return err
+	}
+	remoteFields, err := config.FlattenContextTags(remote)
+	if err != nil {
+		return err
+	}
+
+	diffs := diffContextFields(localFields, remoteFields)
+	if len(diffs) == 0 {
+		green := color.New(color.FgGreen)
+		green.Printf("✓ '%s' matches ctx-cloud (v%d)\n", name, shared.Version)
+		return nil
+	}
+
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+
+	fmt.Println("--- local")
+	fmt.Printf("+++ ctx-cloud (v%d)\n", shared.Version)
+	for _, d := range diffs {
+		if d.InLocal {
+			red.Printf("- %s: %s\n", d.Path, d.Local)
+		}
+		if d.InRemote {
+			green.Printf("+ %s: %s\n", d.Path, d.Remote)
+		}
+	}
+	return nil
+}
+
+// fieldDiff is a field that differs between a local and a remote context.
+type fieldDiff struct {
+	Path     string
+	Local    string
+	Remote   string
+	InLocal  bool
+	InRemote bool
+}
+
+// diffContextFields returns the fields that differ, sorted by path.
+func diffContextFields(local, remote map[string]string) []fieldDiff {
+	paths := slices.Collect(maps.Keys(local))
+	for path := range remote {
+		if _, ok := local[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var diffs []fieldDiff
+	for _, path := range paths {
+		l, inLocal := local[path]
+		r, inRemote := remote[path]
+		if inLocal && inRemote && l == r {
+			continue
+		}
+		diffs = append(diffs, fieldDiff{Path: path, Local: l, Remote: r, InLocal: inLocal, InRemote: inRemote})
+	}
+	return diffs
+}
+
+// syncStatus describes how a local context stands against the version last
+// synced with ctx-cloud, or returns "" if it's in sync or was never synced.
+// It only uses the recorded state, without contacting the server.
+func syncStatus(mgr *config.Manager, records map[string]cloud.SyncRecord, name string) string {
+	record, ok := records[name]
+	if !ok {
+		return ""
+	}
+
+	var status []string
+	if data, err := mgr.ReadContextFile(name); err == nil && record.Modified(data) {
+		status = append(status, "modified")
+	}
+	if record.OutOfDate() {
+		status = append(status, "out of date")
+	}
+	return strings.Join(status, ", ")
+}
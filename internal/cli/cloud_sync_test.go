@@ -0,0 +1,270 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vlebo/ctx/internal/cloud"
+	"github.com/vlebo/ctx/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDiffContextFields(t *testing.T) {
+	local := map[string]string{
+		"name":       "team",
+		"aws.region": "eu-west-1",
+		"env.DEBUG":  "1",
+	}
+	remote := map[string]string{
+		"name":                 "team",
+		"aws.region":           "us-east-1",
+		"kubernetes.namespace": "web",
+	}
+
+	want := []fieldDiff{
+		{Path: "aws.region", Local: "eu-west-1", Remote: "us-east-1", InLocal: true, InRemote: true},
+		{Path: "env.DEBUG", Local: "1", InLocal: true},
+		{Path: "kubernetes.namespace", Remote: "web", InRemote: true},
+	}
+	if got := diffContextFields(local, remote); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffContextFields() = %+v, want %+v", got, want)
+	}
+	if got := diffContextFields(local, local); len(got) != 0 {
+		t.Errorf("diffContextFields() of equal fields = %+v, want none", got)
+	}
+}
+
+func TestToSharedContextRoundTrip(t *testing.T) {
+	data := []byte(`
+name: team
+description: Team context
+environment: production
+extends: base
+aws:
+  region: eu-west-1
+env:
+  DEBUG: "1"
+`)
+	var cfg config.ContextConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := toSharedContext(&cfg, data)
+	if err != nil {
+		t.Fatalf("toSharedContext() error = %v", err)
+	}
+	if shared.Name != "team" || shared.Environment != "production" || shared.Extends.String() != "base" {
+		t.Errorf("toSharedContext() metadata = %+v", shared)
+	}
+	if _, ok := shared.Config["name"]; ok {
+		t.Error("toSharedContext() kept name in config")
+	}
+
+	back, err := convertCloudContext(shared)
+	if err != nil {
+		t.Fatalf("convertCloudContext() error = %v", err)
+	}
+	want, _ := config.FlattenContext(&cfg)
+	got, _ := config.FlattenContext(back)
+	if diffs := diffContextFields(want, got); len(diffs) != 0 {
+		t.Errorf("round trip changed fields: %+v", diffs)
+	}
+}
+
+func TestCloudPushPullKeepsOverrides(t *testing.T) {
+	data := []byte(`name: team
+extends: base
+aws:
+  use_vault: !override false
+vpn: null
+env:
+  LEVEL: team
+  TOKEN: !unset
+tags: [!unset shared, team]
+tunnels:
+  - !unset cache
+`)
+	var local config.ContextConfig
+	if err := yaml.Unmarshal(data, &local); err != nil {
+		t.Fatal(err)
+	}
+
+	// Push, send it over the wire as JSON, and pull it into another machine
+	shared, err := toSharedContext(&local, data)
+	if err != nil {
+		t.Fatalf("toSharedContext() error = %v", err)
+	}
+	wire, err := json.Marshal(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received cloud.SharedContext
+	if err := json.Unmarshal(wire, &received); err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := convertCloudContext(&received)
+	if err != nil {
+		t.Fatalf("convertCloudContext() error = %v", err)
+	}
+
+	want, _ := config.FlattenContextTags(&local)
+	got, _ := config.FlattenContextTags(pulled)
+	if diffs := diffContextFields(want, got); len(diffs) != 0 {
+		t.Errorf("round trip changed fields: %+v", diffs)
+	}
+
+	mgr := config.NewManagerWithDir(t.TempDir())
+	if err := os.MkdirAll(mgr.ContextsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	base := `name: base
+abstract: true
+aws:
+  use_vault: true
+vpn:
+  type: openvpn
+env:
+  TOKEN: secret
+tags: [shared]
+tunnels:
+  - name: cache
+    remote_host: cache.internal
+    remote_port: 6379
+`
+	if err := os.WriteFile(mgr.ContextPath("base"), []byte(base), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.SaveContext(pulled); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+
+	// The saved file still drops what the team context overrides and unsets
+	team, err := mgr.LoadContext("team")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if team.AWS.UseVault || team.VPN != nil || len(team.Tunnels) != 0 {
+		t.Errorf("LoadContext() inherited overridden fields: %+v", team)
+	}
+	if _, ok := team.Env["TOKEN"]; ok || !reflect.DeepEqual(team.Tags, []string{"team"}) {
+		t.Errorf("LoadContext() env = %v, tags = %v, want TOKEN and shared unset", team.Env, team.Tags)
+	}
+}
+
+// newTestPuller returns a puller for a test server holding the given
+// contexts.
+func newTestPuller(t *testing.T, contexts ...cloud.SharedContext) *cloudPuller {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, ctx := range contexts {
+			if r.URL.Path == "/api/v1/cli/sync/"+ctx.Name {
+				data, _ := json.Marshal(ctx)
+				fmt.Fprintf(w, `{"data":%s}`, data)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(ts.Close)
+
+	mgr := config.NewManagerWithDir(t.TempDir())
+	return &cloudPuller{
+		ctx:            context.Background(),
+		mgr:            mgr,
+		client:         cloud.NewClient(ts.URL, "sk_test"),
+		state:          cloud.NewSyncState(mgr.StateDir()),
+		records:        make(map[string]cloud.SyncRecord),
+		remoteVersions: make(map[string]int),
+		seen:           make(map[string]bool),
+	}
+}
+
+func TestCloudPullerAncestors(t *testing.T) {
+	p := newTestPuller(t,
+		cloud.SharedContext{Name: "team", Extends: config.Extends{"base"}, Version: 2, Config: map[string]any{"aws": map[string]any{"region": "eu-west-1"}}},
+		cloud.SharedContext{Name: "base", Extends: config.Extends{"root"}, Version: 1, Config: map[string]any{"aws": map[string]any{"profile": "team"}}},
+		cloud.SharedContext{Name: "root", Version: 4, IsAbstract: true, Config: map[string]any{}},
+	)
+
+	if err := p.pull("team", nil); err != nil {
+		t.Fatalf("pull() error = %v", err)
+	}
+
+	ctx, err := p.mgr.LoadContext("team")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if ctx.AWS == nil || ctx.AWS.Profile != "team" || ctx.AWS.Region != "eu-west-1" {
+		t.Errorf("LoadContext() aws = %+v, want profile from parent", ctx.AWS)
+	}
+
+	base, err := p.mgr.LoadContext("base")
+	if err != nil {
+		t.Fatalf("LoadContext(base) error = %v", err)
+	}
+	if !base.Abstract {
+		t.Error("parent pulled for a child wasn't saved as abstract")
+	}
+
+	records, err := p.state.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, version := range map[string]int{"team": 2, "base": 1, "root": 4} {
+		if records[name].Version != version {
+			t.Errorf("records[%s].Version = %d, want %d", name, records[name].Version, version)
+		}
+	}
+}
+
+func TestCloudPullerCycle(t *testing.T) {
+	p := newTestPuller(t,
+		cloud.SharedContext{Name: "a", Extends: config.Extends{"b"}, Config: map[string]any{}},
+		cloud.SharedContext{Name: "b", Extends: config.Extends{"a"}, Config: map[string]any{}},
+	)
+
+	err := p.pull("a", nil)
+	if err == nil || !strings.Contains(err.Error(), "circular inheritance detected") {
+		t.Errorf("pull() error = %v, want circular inheritance", err)
+	}
+}
+
+func TestCloudPullerKeepsLocalParent(t *testing.T) {
+	p := newTestPuller(t,
+		cloud.SharedContext{Name: "team", Extends: config.Extends{"base"}, Config: map[string]any{}},
+		cloud.SharedContext{Name: "base", Config: map[string]any{"aws": map[string]any{"profile": "cloud"}}},
+	)
+
+	local := []byte("name: base\nabstract: true\naws:\n  profile: local\n")
+	if err := os.MkdirAll(p.mgr.ContextsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.mgr.ContextPath("base"), local, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.pull("team", nil); err != nil {
+		t.Fatalf("pull() error = %v", err)
+	}
+	data, err := p.mgr.ReadContextFile("base")
+	if err != nil || string(data) != string(local) {
+		t.Errorf("local parent = %q, %v, want it kept", data, err)
+	}
+
+	// The context itself isn't overwritten without --force
+	p.seen = make(map[string]bool)
+	if err := p.pull("base", nil); err == nil {
+		t.Error("pull() overwrote a local context that wasn't pulled")
+	}
+}
@@ -5,9 +5,9 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -16,9 +16,13 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/vlebo/ctx/internal/cloud"
 	"github.com/vlebo/ctx/internal/config"
+	"github.com/vlebo/ctx/internal/shell"
 )
 
-var deactivateExportFlag bool
+var (
+	deactivateExportFlag bool
+	deactivateForceFlag  bool
+)
 
 func newDeactivateCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -28,11 +32,16 @@ func newDeactivateCmd() *cobra.Command {
 and clearing environment variables.
 
 This only affects the current context (based on CTX_CURRENT env var).
-Other contexts' VPNs and tunnels are not affected.`,
+Other contexts' VPNs and tunnels are not affected.
+
+VPN connections, tunnels and the cloud heartbeat are shared between shells.
+They are only torn down when no other shell is still using them, unless
+--force is given.`,
 		RunE: runDeactivate,
 	}
 
-	cmd.Flags().BoolVar(&deactivateExportFlag, "export", false, "Output unset commands for shell eval (used by shell hook)")
+	cmd.Flags().BoolVar(&deactivateExportFlag, "export", false, "Output restore/unset commands for shell eval (used by shell hook)")
+	cmd.Flags().BoolVar(&deactivateForceFlag, "force", false, "Disconnect VPN and stop tunnels even if other shells are still using them")
 
 	return cmd
 }
@@ -60,6 +69,16 @@ func runDeactivate(cmd *cobra.Command, args []string) error {
 
 	// If --export, only output unset commands (no side effects)
 	if deactivateExportFlag {
+		// When the shell hook snapshotted the environment before activation,
+		// restore the original values and only unset what ctx introduced.
+		if snap, err := shell.DecodeSnapshot(os.Getenv(shell.SnapshotEnvVar)); err == nil && len(snap) > 0 {
+			for _, line := range snap.Release(nil) {
+				fmt.Println(line)
+			}
+			fmt.Printf("unset %s\n", shell.SnapshotEnvVar)
+			return nil
+		}
+
 		// Track all vars to unset (avoid duplicates)
 		varsToUnset := make(map[string]bool)
 
@@ -70,19 +89,8 @@ func runDeactivate(cmd *cobra.Command, args []string) error {
 		}
 
 		// Also read the env file to get any dynamically resolved secrets
-		envFile := filepath.Join(mgr.StateDir(), "current.env")
-		if file, err := os.Open(envFile); err == nil {
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				line := scanner.Text()
-				// Parse "export VAR=..." or "VAR=..."
-				line = strings.TrimPrefix(line, "export ")
-				if idx := strings.Index(line, "="); idx > 0 {
-					varName := line[:idx]
-					varsToUnset[varName] = true
-				}
-			}
-			file.Close()
+		for _, key := range readEnvFileKeys(mgr.CurrentEnvPath()) {
+			varsToUnset[key] = true
 		}
 
 		// Also unset all possible ctx-managed vars that might have been set by other contexts
@@ -127,27 +135,71 @@ func runDeactivate(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Clear state files so new shells don't load this context
+	deactivateSession(mgr, ctx, deactivateForceFlag)
+
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	fmt.Fprintln(os.Stderr)
+	green.Fprintf(os.Stderr, "✓ Context '%s' deactivated.\n", currentContext)
+	fmt.Fprintln(os.Stderr)
+	yellow.Fprintln(os.Stderr, "Note: If env vars persist, run: source <(ctx deactivate --export)")
+
+	return nil
+}
+
+// deactivateSession releases everything the manager's session holds for ctx:
+// it clears the session state, then disconnects the VPN, stops tunnels and
+// removes secret files unless another live session still uses them.
+func deactivateSession(mgr *config.Manager, ctx *config.ContextConfig, force bool) {
+	// Stop this session's heartbeat worker, its state lives in the session directory
+	cloud.NewHeartbeatManager(mgr.StateDir(), mgr.SessionID()).StopHeartbeat()
+
+	// Clearing the state and counting the other holders happen in one step,
+	// so two shells leaving at once can't both keep a resource up
+	if lock, err := mgr.LockResources(); err == nil {
+		defer lock.Unlock()
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: failed to lock shared resources: %v\n", err)
+	}
+
+	// Clear this session's state so it no longer holds shared resources
 	if err := mgr.ClearCurrentContext(); err != nil {
 		// Log but don't fail - the env var clearing is more important
 		fmt.Fprintf(os.Stderr, "Warning: failed to clear state files: %v\n", err)
 	}
 
-	// Send cloud deactivation event and stop heartbeat
-	sendCloudDeactivateEvents(mgr, currentContext)
+	// Forget shells that exited without deactivating, so they don't keep
+	// shared resources alive
+	if _, err := mgr.ReapStaleSessions(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to reap stale sessions: %v\n", err)
+	}
+
+	// Send cloud deactivation event and end the cloud session
+	sendCloudDeactivateEvents(mgr, ctx.Name, force || countOtherHolders(mgr, config.ResourceHeartbeat) == 0)
 
 	yellow := color.New(color.FgYellow)
 	green := color.New(color.FgGreen)
 	red := color.New(color.FgRed)
 
-	fmt.Fprintf(os.Stderr, "Deactivating context '%s'...\n\n", currentContext)
+	fmt.Fprintf(os.Stderr, "Deactivating context '%s'...\n\n", ctx.Name)
 
 	// Get effective deactivate config (context overrides global)
 	deactivateCfg := getDeactivateConfig(mgr, ctx)
 
-	// Disconnect VPN if configured, enabled, and actually connected
+	// Disconnect VPN if configured, enabled, not used by another shell, and actually connected
 	if ctx.VPN != nil {
-		if deactivateCfg.DisconnectVPN {
+		vpnHolders := 0
+		if !force {
+			vpnHolders = countOtherHolders(mgr, config.VPNResource(ctx.VPN))
+		}
+
+		if vpnHolders > 0 {
+			if checkVPNStatus(ctx.VPN) {
+				yellow.Fprint(os.Stderr, "• ")
+				fmt.Fprintf(os.Stderr, "VPN: keeping connected (in use by %d other session(s))\n", vpnHolders)
+			}
+		} else if deactivateCfg.DisconnectVPN || force {
 			if checkVPNStatus(ctx.VPN) {
 				yellow.Fprint(os.Stderr, "• ")
 				fmt.Fprintf(os.Stderr, "Disconnecting VPN (%s)... ", ctx.VPN.Type)
@@ -165,12 +217,20 @@ func runDeactivate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Stop tunnels if any are configured and enabled
+	// Stop tunnels if any are configured, enabled and not used by another shell
 	if len(ctx.Tunnels) > 0 {
-		if deactivateCfg.StopTunnels {
+		tunnelHolders := 0
+		if !force {
+			tunnelHolders = countOtherHolders(mgr, config.TunnelsResource(ctx.Name))
+		}
+
+		if tunnelHolders > 0 {
+			yellow.Fprint(os.Stderr, "• ")
+			fmt.Fprintf(os.Stderr, "Tunnels: keeping running (in use by %d other session(s))\n", tunnelHolders)
+		} else if deactivateCfg.StopTunnels || force {
 			yellow.Fprint(os.Stderr, "• ")
 			fmt.Fprint(os.Stderr, "Stopping tunnels... ")
-			stopped, err := stopContextTunnels(mgr.StateDir(), currentContext)
+			stopped, err := stopContextTunnels(mgr.StateDir(), ctx.Name)
 			if err != nil {
 				red.Fprintf(os.Stderr, "failed: %v\n", err)
 			} else if stopped > 0 {
@@ -184,17 +244,44 @@ func runDeactivate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Clean up secret files
-	if err := mgr.CleanupSecretFiles(currentContext); err != nil {
-		yellow.Fprintf(os.Stderr, "⚠ Failed to clean up secret files: %v\n", err)
+	// Clean up secret files, unless another shell on this context still reads them
+	if force || countOtherHolders(mgr, config.SecretFilesResource(ctx.Name)) == 0 {
+		if err := mgr.CleanupSecretFiles(ctx.Name); err != nil {
+			yellow.Fprintf(os.Stderr, "⚠ Failed to clean up secret files: %v\n", err)
+		}
 	}
 
-	fmt.Fprintln(os.Stderr)
-	green.Fprintf(os.Stderr, "✓ Context '%s' deactivated.\n", currentContext)
-	fmt.Fprintln(os.Stderr)
-	yellow.Fprintln(os.Stderr, "Note: If env vars persist, run: source <(ctx deactivate --export)")
+}
 
-	return nil
+// readEnvFileKeys returns the variable names exported by an env file.
+// A missing or unreadable file yields no keys.
+func readEnvFileKeys(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Parse "export VAR=..." or "VAR=..."
+		line := strings.TrimPrefix(scanner.Text(), "export ")
+		if idx := strings.Index(line, "="); idx > 0 {
+			keys = append(keys, line[:idx])
+		}
+	}
+	return keys
+}
+
+// countOtherHolders returns how many other live sessions hold a shared resource.
+// If the sessions can't be read, the resource is treated as unshared.
+func countOtherHolders(mgr *config.Manager, resource string) int {
+	holders, err := mgr.OtherResourceHolders(resource)
+	if err != nil {
+		return 0
+	}
+	return len(holders)
 }
 
 // getDeactivateConfig returns the effective deactivate config.
@@ -222,11 +309,14 @@ func getDeactivateConfig(mgr *config.Manager, ctx *config.ContextConfig) *config
 // stopContextTunnels stops all tunnels for a given context.
 // Returns the number of tunnels stopped.
 func stopContextTunnels(stateDir, contextName string) (int, error) {
-	tunnelStateDir := filepath.Join(stateDir, "tunnels")
-	stateFile := filepath.Join(tunnelStateDir, contextName+".json")
+	lock, err := config.NewStateStore(stateDir).Lock(tunnelStateFile(contextName))
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Unlock()
 
 	// Try to load state
-	state, err := loadTunnelState(stateFile)
+	state, err := loadTunnelState(lock)
 	if err != nil {
 		return 0, nil // No state file = no tunnels running
 	}
@@ -241,7 +331,7 @@ func stopContextTunnels(stateDir, contextName string) (int, error) {
 			time.Sleep(300 * time.Millisecond)
 			stoppedCount = 1
 		}
-		os.Remove(stateFile)
+		lock.Remove()
 		return stoppedCount, nil
 	}
 
@@ -256,14 +346,22 @@ func stopContextTunnels(stateDir, contextName string) (int, error) {
 	}
 
 	// Remove state file
-	os.Remove(stateFile)
+	lock.Remove()
 
 	return stoppedCount, nil
 }
 
-// sendCloudDeactivateEvents sends deactivation event and stops heartbeat.
+// sendCloudDeactivateEvents records the deactivation event and, if no other
+// shell is still present, ends the cloud session.
 // Errors are logged but do not fail the deactivation.
-func sendCloudDeactivateEvents(mgr *config.Manager, contextName string) {
+func sendCloudDeactivateEvents(mgr *config.Manager, contextName string, endSession bool) {
+	event := &cloud.AuditEvent{
+		Action:      "deactivate",
+		ContextName: contextName,
+		Success:     true,
+	}
+	recordAuditEvent(mgr, event)
+
 	client := NewCloudClient(mgr)
 	if client == nil {
 		return // Cloud integration not configured
@@ -274,23 +372,16 @@ func sendCloudDeactivateEvents(mgr *config.Manager, contextName string) {
 		return
 	}
 
-	// Stop heartbeat
-	hbMgr := cloud.NewHeartbeatManager(mgr.StateDir())
-	hbMgr.StopHeartbeat()
-
 	// Send deactivation audit event (synchronous - must complete before exit)
 	if appConfig.Cloud.SendAuditEvents {
-		event := &cloud.AuditEvent{
-			Action:      "deactivate",
-			ContextName: contextName,
-			Success:     true,
-		}
-		if err := client.SendAuditEvent(event); err != nil {
+		if err := deliverAuditEvent(mgr, client, event); err != nil {
 			yellow := color.New(color.FgYellow)
 			yellow.Fprintf(os.Stderr, "⚠ Cloud audit event failed: %v\n", err)
 		}
 	}
 
 	// Notify cloud server to deactivate session
-	_ = client.Deactivate(contextName)
+	if endSession {
+		_ = client.Deactivate(context.Background(), contextName)
+	}
 }
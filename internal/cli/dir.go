@@ -0,0 +1,284 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+func newDirCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dir",
+		Short: "Manage directory-bound contexts",
+		Long: `Bind directory trees to contexts.
+
+A .ctx file in a directory names the context to use there. A .ctx.yaml file
+is an inline overlay: it extends a context and can override any of its
+settings, just like a context file:
+
+  # .ctx.yaml
+  extends: acme-dev
+  env:
+    TF_WORKSPACE: acme
+
+With the shell hook installed, the context is activated when you cd into the
+directory (or any directory below it), and deactivated when you leave.
+
+Marker files must be trusted with 'ctx dir trust' before they are used, and
+trust is revoked whenever the file changes. Production contexts always ask
+for confirmation.`,
+		Args: cobra.NoArgs,
+		RunE: runDirStatus,
+	}
+
+	cmd.AddCommand(newDirStatusCmd())
+	cmd.AddCommand(newDirTrustCmd())
+	cmd.AddCommand(newDirUntrustCmd())
+	cmd.AddCommand(newDirCheckCmd())
+
+	return cmd
+}
+
+func newDirStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the context bound to the current directory",
+		Args:  cobra.NoArgs,
+		RunE:  runDirStatus,
+	}
+}
+
+func newDirTrustCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "trust [path]",
+		Short: "Allow a directory's marker file to activate its context",
+		Long: `Trust the .ctx.yaml or .ctx marker file for a directory (default: the
+current one), so the shell hook activates its context automatically.
+
+Trust covers the file's current content. Run this again after changing it.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runDirTrust,
+	}
+}
+
+func newDirUntrustCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "untrust [path]",
+		Short: "Stop a directory's marker file from activating its context",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runDirUntrust,
+	}
+}
+
+func newDirCheckCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "check",
+		Short:  "Tell the shell hook what to do after a directory change",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE:   runDirCheck,
+	}
+}
+
+func runDirStatus(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	marker, err := findMarker(args)
+	if err != nil {
+		return err
+	}
+	if marker == nil {
+		fmt.Println("No .ctx.yaml or .ctx in this directory or its parents.")
+		return nil
+	}
+
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	fmt.Printf("Marker:  %s\n", marker.Path)
+	if ctx, err := mgr.LoadDirContext(marker); err != nil {
+		fmt.Print("Context: ")
+		yellow.Printf("invalid (%v)\n", err)
+	} else {
+		fmt.Printf("Context: %s (%s)\n", ctx.Name, formatEnvironmentWithColor(ctx))
+	}
+
+	trusted, err := mgr.IsDirTrusted(marker)
+	if err != nil {
+		return err
+	}
+	fmt.Print("Trusted: ")
+	if trusted {
+		green.Println("yes")
+	} else {
+		yellow.Println("no (run 'ctx dir trust' to allow it)")
+	}
+
+	if active := os.Getenv(config.DirEnvVar); active == marker.Dir {
+		fmt.Println("Active:  yes")
+	}
+	return nil
+}
+
+func runDirTrust(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	marker, err := findMarker(args)
+	if err != nil {
+		return err
+	}
+	if marker == nil {
+		return fmt.Errorf("no .ctx.yaml or .ctx found in %s or its parents", markerSearchDir(args))
+	}
+
+	// Only trust markers that resolve to a usable context
+	ctx, err := mgr.LoadDirContext(marker)
+	if err != nil {
+		return err
+	}
+	if err := config.ValidateContext(ctx); err != nil {
+		return fmt.Errorf("invalid context configuration: %w", err)
+	}
+
+	if err := mgr.TrustDir(marker); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen)
+	green.Printf("✓ Trusted %s\n", marker.Path)
+	fmt.Printf("  %s will use context '%s' (%s).\n", marker.Dir, ctx.Name, formatEnvironmentWithColor(ctx))
+	return nil
+}
+
+func runDirUntrust(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	marker, err := findMarker(args)
+	if err != nil {
+		return err
+	}
+	if marker == nil {
+		return fmt.Errorf("no .ctx.yaml or .ctx found in %s or its parents", markerSearchDir(args))
+	}
+
+	if err := mgr.UntrustDir(marker.Path); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen)
+	green.Printf("✓ %s is no longer trusted\n", marker.Path)
+	return nil
+}
+
+// runDirCheck prints the action the shell hook should take for the current
+// directory: "use <dir>" to activate the context bound to dir, "deactivate"
+// when the shell has left the tree that activated its context, or nothing.
+func runDirCheck(cmd *cobra.Command, args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	active := os.Getenv(config.DirEnvVar)
+	yellow := color.New(color.FgYellow)
+
+	marker, err := config.FindDirMarker(cwd)
+	if err != nil {
+		yellow.Fprintf(os.Stderr, "⚠ %v\n", err)
+	}
+
+	if marker != nil {
+		// Still in the tree that activated the current context
+		if marker.Dir == active {
+			return nil
+		}
+
+		mgr, err := GetConfigManager()
+		if err != nil {
+			return err
+		}
+		trusted, err := mgr.IsDirTrusted(marker)
+		if err != nil {
+			return err
+		}
+		if trusted {
+			fmt.Printf("use %s\n", marker.Dir)
+			return nil
+		}
+		yellow.Fprintf(os.Stderr, "⚠ %s is not trusted. Run 'ctx dir trust' to use it.\n", marker.Path)
+	}
+
+	if active != "" {
+		fmt.Println("deactivate")
+	}
+	return nil
+}
+
+// loadTrustedDirContext loads the context bound to dir by its marker file,
+// refusing markers that aren't trusted.
+func loadTrustedDirContext(mgr *config.Manager, dir string) (*config.ContextConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	marker, err := config.ReadDirMarker(dir)
+	if err != nil {
+		return nil, err
+	}
+	if marker == nil {
+		return nil, fmt.Errorf("no .ctx.yaml or .ctx in %s", dir)
+	}
+
+	trusted, err := mgr.IsDirTrusted(marker)
+	if err != nil {
+		return nil, err
+	}
+	if !trusted {
+		return nil, fmt.Errorf("%s is not trusted. Review it and run 'ctx dir trust %s'", marker.Path, dir)
+	}
+
+	ctx, err := mgr.LoadDirContext(marker)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember which tree activated the context, so leaving it deactivates
+	if ctx.Env == nil {
+		ctx.Env = make(map[string]string)
+	}
+	ctx.Env[config.DirEnvVar] = marker.Dir
+	return ctx, nil
+}
+
+// findMarker finds the marker file for the directory given in args, or the
+// current directory.
+func findMarker(args []string) (*config.DirMarker, error) {
+	return config.FindDirMarker(markerSearchDir(args))
+}
+
+func markerSearchDir(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		return cwd
+	}
+	return "."
+}
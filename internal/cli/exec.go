@@ -0,0 +1,422 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/config"
+	"github.com/vlebo/ctx/internal/shell"
+)
+
+var (
+	execConfirmFlag  bool
+	execTagFlags     []string
+	execEnvFlags     []string
+	execAllFlag      bool
+	execParallelFlag int
+	execOutputFlag   string
+	execReasonFlag   string
+)
+
+func newExecCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec [name|pattern...] -- <command> [args...]",
+		Short: "Run a command in one or more contexts without switching",
+		Long: `Run a command with the environment of a context.
+
+The context's env vars, secrets and secret files are resolved for the command
+only. The current shell stays in whatever context it is in, and secret files
+are securely deleted when the command exits.
+
+With a single context name, the command is attached to the terminal, its exit
+code is passed through, and signals sent to ctx are forwarded to it.
+
+Selecting several contexts (by glob pattern, namespace such as acme/payments/,
+--tag, --env or --all) runs the command in each of them, up to --parallel at a
+time, with every output line prefixed by the context name. ctx exits with the
+highest exit code of all runs. Flags must come before the context names.
+
+Examples:
+  ctx exec myproject-prod -- kubectl get pods
+  ctx exec myproject-dev -- terraform plan
+  ctx exec 'myproject-*' -- kubectl get nodes
+  ctx exec acme/payments/ --env production -- kubectl get nodes
+  ctx exec --tag eks --env production --confirm -- kubectl get pods -A
+  ctx exec --all --output json -- aws sts get-caller-identity`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runExec,
+	}
+
+	// Everything after the context names belongs to the command
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().BoolVar(&execConfirmFlag, "confirm", false, "Confirm running in a production environment")
+	cmd.Flags().StringSliceVarP(&execTagFlags, "tag", "t", nil, "Run in contexts with this tag (repeatable, all must match)")
+	cmd.Flags().StringSliceVarP(&execEnvFlags, "env", "e", nil, "Run in contexts with this environment (repeatable)")
+	cmd.Flags().BoolVarP(&execAllFlag, "all", "a", false, "Run in every context")
+	cmd.Flags().IntVarP(&execParallelFlag, "parallel", "p", 4, "Maximum number of contexts to run in at once")
+	cmd.Flags().StringVarP(&execOutputFlag, "output", "o", "text", "Output format for multiple contexts: text or json")
+	cmd.Flags().StringVar(&execReasonFlag, "reason", "", "Reason for using the contexts, recorded in the audit log")
+
+	return cmd
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	selecting := len(execTagFlags) > 0 || len(execEnvFlags) > 0 || execAllFlag
+	patterns, command := splitExecArgs(args, cmd.ArgsLenAtDash(), selecting)
+	if len(command) == 0 {
+		return fmt.Errorf("no command given. Usage: ctx exec <name> -- <command> [args...]")
+	}
+	if execOutputFlag != "text" && execOutputFlag != "json" {
+		return fmt.Errorf("invalid output format %q (use text or json)", execOutputFlag)
+	}
+	if execParallelFlag < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	if !selecting && len(patterns) == 1 && !isGlobPattern(patterns[0]) && execOutputFlag == "text" {
+		return execSingle(mgr, patterns[0], command)
+	}
+
+	configs, err := mgr.ListContextConfigs()
+	if err != nil {
+		return fmt.Errorf("failed to list contexts: %w", err)
+	}
+	contexts, err := selectContexts(configs, patterns, execTagFlags, execEnvFlags, execAllFlag)
+	if err != nil {
+		return err
+	}
+	if len(contexts) == 0 {
+		return fmt.Errorf("no contexts match the selection")
+	}
+
+	// Nothing runs if a policy denies any of the contexts
+	reason := execReasonFlag
+	for _, ctx := range contexts {
+		if reason, err = enforcePolicies(mgr, ctx, reason); err != nil {
+			return err
+		}
+	}
+
+	if !execConfirmFlag {
+		var prod []string
+		for _, ctx := range contexts {
+			if ctx.IsProd() {
+				prod = append(prod, ctx.Name)
+			}
+		}
+		if len(prod) > 0 {
+			if err := confirmProductionFanOut(prod); err != nil {
+				return err
+			}
+		}
+	}
+
+	results := runFanOut(mgr, contexts, command, execParallelFlag, execOutputFlag == "json")
+
+	if execOutputFlag == "json" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal results: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printFanOutSummary(results)
+	}
+
+	if code := aggregateExitCode(results); code != 0 {
+		return &exitCodeError{code: code}
+	}
+	return nil
+}
+
+// splitExecArgs separates context names from the command. The command starts
+// after "--"; without one, the first argument is the context name unless
+// contexts are selected by flags.
+func splitExecArgs(args []string, dashAt int, selecting bool) ([]string, []string) {
+	if dashAt >= 0 {
+		return args[:dashAt], args[dashAt:]
+	}
+	// Flag parsing stops at the first name, so "--" after it is still in args
+	if i := slices.Index(args, "--"); i >= 0 {
+		return args[:i], args[i+1:]
+	}
+	if selecting {
+		return nil, args
+	}
+	return args[:1], args[1:]
+}
+
+// execSingle runs the command in one context, attached to the terminal.
+func execSingle(mgr *config.Manager, contextName string, command []string) error {
+	ctx, err := mgr.LoadContext(contextName)
+	if err != nil {
+		return fmt.Errorf("failed to load context: %w", err)
+	}
+
+	if ctx.Abstract {
+		return fmt.Errorf("context '%s' is abstract (a base template) and cannot be used directly. Create a context that extends it", contextName)
+	}
+
+	if err := config.ValidateContext(ctx); err != nil {
+		return fmt.Errorf("invalid context configuration: %w", err)
+	}
+
+	if _, err := enforcePolicies(mgr, ctx, execReasonFlag); err != nil {
+		return err
+	}
+
+	if ctx.IsProd() && !execConfirmFlag {
+		if !confirmProductionSwitch(ctx) {
+			return fmt.Errorf("aborted: production run not confirmed")
+		}
+	}
+
+	env, cleanup, err := resolveContextEnv(mgr, ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	code, err := runCommandWithEnv(command, env)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &exitCodeError{code: code}
+	}
+	return nil
+}
+
+// resolveContextEnv builds the full environment for running a process in ctx,
+// without touching the current shell's context or session state. The returned
+// cleanup func securely deletes the secret files written for the process.
+func resolveContextEnv(mgr *config.Manager, ctx *config.ContextConfig) (map[string]string, func(), error) {
+	cleanup := func() {}
+
+	// Secret files are private to this process, so they aren't recorded in the
+	// shared state that deactivate cleans up
+	var secretFilePaths map[string]string
+	if ctx.Secrets != nil && len(ctx.Secrets.Files) > 0 {
+		sfResult, err := resolveContextSecretFiles(mgr, ctx)
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("failed to resolve secret files: %w", err)
+		}
+		if sfResult != nil {
+			secretFilePaths = sfResult.EnvVars
+			cleanup = func() {
+				for _, entry := range sfResult.State.Files {
+					secureDeleteFile(entry.Path)
+				}
+			}
+
+			if ctx.Env == nil {
+				ctx.Env = make(map[string]string)
+			}
+			maps.Copy(ctx.Env, secretFilePaths)
+			config.ExpandConfigVars(ctx)
+		}
+	}
+
+	// Fetch cluster credentials into the per-context kubeconfig. An explicit
+	// kubeconfig is left alone: switching its context would leak into every
+	// other shell using it.
+	if k8s := ctx.Kubernetes; k8s != nil && k8s.Kubeconfig == "" && (k8s.AKS != nil || k8s.EKS != nil || k8s.GKE != nil) {
+		if _, err := os.Stat(mgr.KubeconfigPath(ctx.Name)); os.IsNotExist(err) {
+			if err := switchKubernetes(k8s, ctx, mgr); err != nil {
+				yellow := color.New(color.FgYellow)
+				yellow.Fprintf(os.Stderr, "⚠ Kubernetes credential fetch failed: %v\n", err)
+			}
+		}
+	}
+
+	var secrets map[string]string
+	if ctx.Secrets != nil {
+		result, err := resolveContextSecrets(mgr, ctx)
+		if err != nil {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("failed to resolve secrets: %w", err)
+		}
+		if result != nil {
+			secrets = result.Secrets
+		}
+	}
+
+	env := baseEnviron(mgr)
+	maps.Copy(env, mgr.GenerateEnvVars(ctx))
+	maps.Copy(env, secrets)
+	maps.Copy(env, secretFilePaths)
+
+	return env, cleanup, nil
+}
+
+// baseEnviron returns the current process environment with everything the
+// shell's active context set rolled back, so none of it leaks into another
+// context. Session variables are dropped too: the child isn't a ctx session.
+func baseEnviron(mgr *config.Manager) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+
+	if snap, err := shell.DecodeSnapshot(env[shell.SnapshotEnvVar]); err == nil && len(snap) > 0 {
+		// Restore the values from before the shell activated its context
+		for key, value := range snap {
+			if value == nil {
+				delete(env, key)
+			} else {
+				env[key] = *value
+			}
+		}
+	} else if env["CTX_CURRENT"] != "" {
+		for _, key := range readEnvFileKeys(mgr.CurrentEnvPath()) {
+			delete(env, key)
+		}
+	}
+
+	delete(env, shell.SnapshotEnvVar)
+	delete(env, config.SessionIDEnvVar)
+	delete(env, config.SessionPIDEnvVar)
+	return env
+}
+
+// runCommandWithEnv runs a command with the given environment attached to the
+// terminal, forwarding signals to it. It returns the command's exit code; a
+// command killed by a signal exits with 128+signal like in a shell.
+func runCommandWithEnv(command []string, env map[string]string) (int, error) {
+	c, err := newContextCommand(command, env)
+	if err != nil {
+		return 0, err
+	}
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	var procs processSet
+	defer forwardSignals(procs.signal)()
+
+	if err := c.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+	procs.add(c.Process)
+	defer procs.remove(c.Process)
+
+	return waitExitCode(c)
+}
+
+// newContextCommand prepares a command to run with exactly the given environment.
+func newContextCommand(command []string, env map[string]string) (*exec.Cmd, error) {
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return nil, fmt.Errorf("command not found: %s", command[0])
+	}
+
+	environ := make([]string, 0, len(env))
+	for key, value := range env {
+		environ = append(environ, key+"="+value)
+	}
+	sort.Strings(environ)
+
+	c := exec.Command(path, command[1:]...)
+	c.Args[0] = command[0]
+	c.Env = environ
+	return c, nil
+}
+
+// waitExitCode waits for a started command and returns its exit code.
+func waitExitCode(c *exec.Cmd) (int, error) {
+	err := c.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %s: %w", c.Args[0], err)
+	}
+	return 0, nil
+}
+
+// processSet tracks the running child processes signals are forwarded to.
+type processSet struct {
+	mu    sync.Mutex
+	procs []*os.Process
+}
+
+func (s *processSet) add(p *os.Process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.procs = append(s.procs, p)
+}
+
+func (s *processSet) remove(p *os.Process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.procs = slices.DeleteFunc(s.procs, func(q *os.Process) bool { return q == p })
+}
+
+func (s *processSet) signal(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.procs {
+		p.Signal(sig)
+	}
+}
+
+// forwardSignals catches the signals that would normally kill ctx and passes
+// them to send instead, so children get a chance to exit cleanly and secret
+// files are still cleaned up. The returned func stops forwarding.
+func forwardSignals(send func(os.Signal)) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				send(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// exitCodeError makes ctx exit with a command's exit code without printing
+// an error of its own.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
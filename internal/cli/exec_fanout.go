@@ -0,0 +1,299 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// execResult is the outcome of running a command in one context.
+type execResult struct {
+	Context     string    `json:"context"`
+	Environment string    `json:"environment"`
+	ExitCode    int       `json:"exit_code"`
+	Error       string    `json:"error,omitempty"`
+	Stdout      string    `json:"stdout"`
+	Stderr      string    `json:"stderr"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+// isGlobPattern reports whether a context argument is a pattern rather than a
+// name. A namespace such as acme/payments/ is a pattern too.
+func isGlobPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[") || strings.HasSuffix(s, "/")
+}
+
+// validatePatterns returns an error for the first malformed pattern.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesPattern reports whether a context name matches a pattern. A pattern
+// ending in / selects a namespace: acme/ matches every context under acme, at
+// any depth. Otherwise it's a glob, in which * doesn't match /.
+func matchesPattern(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(name, pattern)
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// matchesFilters reports whether a context matches any of the name patterns,
+// all of the tags and any of the environments. Empty filters match every
+// context.
+func matchesFilters(ctx *config.ContextConfig, patterns, tags, envs []string) bool {
+	if len(patterns) > 0 && !slices.ContainsFunc(patterns, func(p string) bool {
+		return matchesPattern(p, ctx.Name)
+	}) {
+		return false
+	}
+
+	missingTag := slices.ContainsFunc(tags, func(tag string) bool {
+		return !slices.Contains(ctx.Tags, tag)
+	})
+	if missingTag {
+		return false
+	}
+
+	if len(envs) > 0 && !slices.ContainsFunc(envs, func(env string) bool {
+		return strings.EqualFold(env, string(ctx.Environment))
+	}) {
+		return false
+	}
+	return true
+}
+
+// selectContexts returns the non-abstract contexts matching every given
+// filter: any of the name patterns, all of the tags and any of the
+// environments. With all set, no filter is required.
+func selectContexts(configs []*config.ContextConfig, patterns, tags, envs []string, all bool) ([]*config.ContextConfig, error) {
+	if len(patterns) == 0 && len(tags) == 0 && len(envs) == 0 && !all {
+		return nil, fmt.Errorf("no contexts selected. Give context names or patterns, --tag, --env or --all")
+	}
+
+	if err := validatePatterns(patterns); err != nil {
+		return nil, err
+	}
+
+	var selected []*config.ContextConfig
+	for _, ctx := range configs {
+		if !ctx.Abstract && matchesFilters(ctx, patterns, tags, envs) {
+			selected = append(selected, ctx)
+		}
+	}
+	return selected, nil
+}
+
+// confirmProductionFanOut asks the user to confirm running in production
+// contexts. The prompt goes to stderr, so it doesn't mix with the output of
+// --output json. Without a terminal to answer it, the run is refused.
+func confirmProductionFanOut(names []string) error {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("running in production contexts (%s) requires --confirm when stdin isn't a terminal", strings.Join(names, ", "))
+	}
+
+	warning := color.New(color.FgRed, color.Bold)
+	warning.Fprintf(os.Stderr, "⚠️  Running in %d PRODUCTION context(s): %s\n", len(names), strings.Join(names, ", "))
+	fmt.Fprint(os.Stderr, "   Type 'yes' to confirm: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	input, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(strings.ToLower(input)) != "yes" {
+		return fmt.Errorf("aborted: production run not confirmed")
+	}
+	return nil
+}
+
+// runFanOut runs the command in each context, at most parallel at a time.
+// Results are returned in the order of contexts. When capture is set, output
+// is collected into the results instead of being printed.
+func runFanOut(mgr *config.Manager, contexts []*config.ContextConfig, command []string, parallel int, capture bool) []*execResult {
+	width := 0
+	for _, ctx := range contexts {
+		width = max(width, len(ctx.Name))
+	}
+
+	var (
+		procs     processSet
+		outMu     sync.Mutex
+		resolveMu sync.Mutex
+		wg        sync.WaitGroup
+	)
+	defer forwardSignals(procs.signal)()
+
+	results := make([]*execResult, len(contexts))
+	sem := make(chan struct{}, parallel)
+	for i, ctx := range contexts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var stdout, stderr io.Writer
+			var outBuf, errBuf bytes.Buffer
+			if capture {
+				stdout, stderr = &outBuf, &errBuf
+			} else {
+				prefix := getEnvColor(ctx).Sprintf("%-*s", width+2, "["+ctx.Name+"]") + " "
+				outW := &prefixWriter{mu: &outMu, out: os.Stdout, prefix: prefix}
+				errW := &prefixWriter{mu: &outMu, out: os.Stderr, prefix: prefix}
+				defer outW.Flush()
+				defer errW.Flush()
+				stdout, stderr = outW, errW
+			}
+
+			result := runInContext(mgr, ctx, command, stdout, stderr, &resolveMu, &procs)
+			result.Stdout = outBuf.String()
+			result.Stderr = errBuf.String()
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runInContext resolves the environment of one context and runs the command in it.
+// Secrets are resolved one context at a time, since providers may prompt.
+func runInContext(mgr *config.Manager, ctx *config.ContextConfig, command []string,
+	stdout, stderr io.Writer, resolveMu *sync.Mutex, procs *processSet) *execResult {
+	result := &execResult{Context: ctx.Name, Environment: string(ctx.Environment), StartedAt: time.Now()}
+	finish := func(code int, err error) *execResult {
+		result.FinishedAt = time.Now()
+		result.DurationMs = result.FinishedAt.Sub(result.StartedAt).Milliseconds()
+		result.ExitCode = code
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	if err := config.ValidateContext(ctx); err != nil {
+		return finish(1, fmt.Errorf("invalid context configuration: %w", err))
+	}
+
+	resolveMu.Lock()
+	env, cleanup, err := resolveContextEnv(mgr, ctx)
+	resolveMu.Unlock()
+	if err != nil {
+		return finish(1, err)
+	}
+	defer cleanup()
+
+	c, err := newContextCommand(command, env)
+	if err != nil {
+		return finish(1, err)
+	}
+	c.Stdout = stdout
+	c.Stderr = stderr
+
+	result.StartedAt = time.Now()
+	if err := c.Start(); err != nil {
+		return finish(1, fmt.Errorf("failed to start %s: %w", command[0], err))
+	}
+	procs.add(c.Process)
+	defer procs.remove(c.Process)
+
+	code, err := waitExitCode(c)
+	if err != nil {
+		return finish(1, err)
+	}
+	return finish(code, nil)
+}
+
+// aggregateExitCode returns the highest exit code of all runs, so any failure
+// makes the whole fan-out fail.
+func aggregateExitCode(results []*execResult) int {
+	code := 0
+	for _, r := range results {
+		code = max(code, r.ExitCode)
+	}
+	return code
+}
+
+// printFanOutSummary prints which contexts succeeded and which failed.
+func printFanOutSummary(results []*execResult) {
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+
+	var failed []*execResult
+	for _, r := range results {
+		if r.ExitCode != 0 {
+			failed = append(failed, r)
+		}
+	}
+
+	fmt.Fprintln(os.Stderr)
+	if len(failed) == 0 {
+		green.Fprintf(os.Stderr, "✓ Succeeded in all %d context(s)\n", len(results))
+		return
+	}
+
+	red.Fprintf(os.Stderr, "✗ Failed in %d of %d context(s):\n", len(failed), len(results))
+	for _, r := range failed {
+		if r.Error != "" {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", r.Context, r.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %s: exit %d\n", r.Context, r.ExitCode)
+		}
+	}
+}
+
+// prefixWriter writes output line by line, prefixing every line. Writers
+// sharing a mutex never interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing partial line, if any.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprint(w.out, w.prefix)
+	w.out.Write(line)
+}
@@ -0,0 +1,321 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vlebo/ctx/internal/config"
+	"github.com/vlebo/ctx/internal/shell"
+)
+
+func TestResolveContextEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	mgr := config.NewManagerWithDir(tmpDir)
+
+	for _, ctx := range []*config.ContextConfig{
+		{Name: "dev", Environment: config.EnvDevelopment, Env: map[string]string{"DEV_ONLY": "dev"}},
+		{Name: "staging", Environment: config.EnvStaging, Env: map[string]string{"APP_ENV": "staging"}},
+	} {
+		if err := mgr.SaveContext(ctx); err != nil {
+			t.Fatalf("SaveContext() error = %v", err)
+		}
+	}
+
+	// The shell is in dev, which set DEV_ONLY and overrode APP_ENV
+	snap := shell.EnvSnapshot{}
+	snap.Capture([]string{"DEV_ONLY", "APP_ENV"}, func(key string) (string, bool) {
+		if key == "APP_ENV" {
+			return "original", true
+		}
+		return "", false
+	})
+	encoded, err := snap.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	t.Setenv(shell.SnapshotEnvVar, encoded)
+	t.Setenv("CTX_CURRENT", "dev")
+	t.Setenv("DEV_ONLY", "dev")
+	t.Setenv("APP_ENV", "dev")
+	t.Setenv(config.SessionIDEnvVar, "1-a")
+
+	if err := mgr.SetCurrentContext("dev"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+
+	ctx, err := mgr.LoadContext("staging")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	env, cleanup, err := resolveContextEnv(mgr, ctx)
+	if err != nil {
+		t.Fatalf("resolveContextEnv() error = %v", err)
+	}
+	defer cleanup()
+
+	if env["CTX_CURRENT"] != "staging" || env["APP_ENV"] != "staging" {
+		t.Errorf("env CTX_CURRENT=%q APP_ENV=%q, want staging", env["CTX_CURRENT"], env["APP_ENV"])
+	}
+	if _, ok := env["DEV_ONLY"]; ok {
+		t.Error("DEV_ONLY leaked from the shell's context")
+	}
+	for _, key := range []string{shell.SnapshotEnvVar, config.SessionIDEnvVar} {
+		if _, ok := env[key]; ok {
+			t.Errorf("%s passed to the child process", key)
+		}
+	}
+
+	// The shell's context is untouched
+	if name, _ := mgr.GetCurrentContextName(); name != "dev" {
+		t.Errorf("current context = %q, want dev", name)
+	}
+}
+
+func TestRunCommandWithEnv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	tests := []struct {
+		name     string
+		script   string
+		wantCode int
+	}{
+		{"success", `printf %s "$GREETING" > "$OUT"`, 0},
+		{"exit code", "exit 3", 3},
+		{"killed", "kill -TERM $$", 128 + 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"GREETING": "hello", "OUT": out, "PATH": os.Getenv("PATH")}
+			code, err := runCommandWithEnv([]string{"sh", "-c", tt.script}, env)
+			if err != nil {
+				t.Fatalf("runCommandWithEnv() error = %v", err)
+			}
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	if content, _ := os.ReadFile(out); string(content) != "hello" {
+		t.Errorf("command saw GREETING = %q, want hello", content)
+	}
+
+	if _, err := runCommandWithEnv([]string{"ctx-no-such-command"}, nil); err == nil {
+		t.Error("runCommandWithEnv() expected error for a missing command")
+	}
+}
+
+func TestSplitExecArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		dashAt      int
+		selecting   bool
+		wantTargets []string
+		wantCommand []string
+	}{
+		{"name then dash", []string{"dev", "--", "ls", "-l"}, -1, false, []string{"dev"}, []string{"ls", "-l"}},
+		{"name without dash", []string{"dev", "ls", "-l"}, -1, false, []string{"dev"}, []string{"ls", "-l"}},
+		{"flags then dash", []string{"ls"}, 0, true, []string{}, []string{"ls"}},
+		{"patterns then dash", []string{"a-*", "b", "--", "ls"}, -1, false, []string{"a-*", "b"}, []string{"ls"}},
+		{"selecting without dash", []string{"ls", "-l"}, -1, true, nil, []string{"ls", "-l"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, command := splitExecArgs(tt.args, tt.dashAt, tt.selecting)
+			if !slices.Equal(targets, tt.wantTargets) || !slices.Equal(command, tt.wantCommand) {
+				t.Errorf("splitExecArgs() = %v, %v, want %v, %v", targets, command, tt.wantTargets, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestSelectContexts(t *testing.T) {
+	configs := []*config.ContextConfig{
+		{Name: "acme-dev", Environment: config.EnvDevelopment, Tags: []string{"acme", "eks"}},
+		{Name: "acme-prod", Environment: config.EnvProduction, Tags: []string{"acme", "eks"}},
+		{Name: "globex-prod", Environment: config.EnvProduction, Tags: []string{"globex", "gke"}},
+		{Name: "base", Abstract: true, Environment: config.EnvProduction, Tags: []string{"acme"}},
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		tags     []string
+		envs     []string
+		all      bool
+		want     []string
+	}{
+		{"all", nil, nil, nil, true, []string{"acme-dev", "acme-prod", "globex-prod"}},
+		{"glob", []string{"acme-*"}, nil, nil, false, []string{"acme-dev", "acme-prod"}},
+		{"tag", nil, []string{"eks"}, nil, false, []string{"acme-dev", "acme-prod"}},
+		{"tags must all match", nil, []string{"acme", "gke"}, nil, false, nil},
+		{"env", nil, nil, []string{"Production"}, false, []string{"acme-prod", "globex-prod"}},
+		{"tag and env", nil, []string{"eks"}, []string{"production"}, false, []string{"acme-prod"}},
+		{"names", []string{"acme-dev", "globex-prod"}, nil, nil, false, []string{"acme-dev", "globex-prod"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := selectContexts(configs, tt.patterns, tt.tags, tt.envs, tt.all)
+			if err != nil {
+				t.Fatalf("selectContexts() error = %v", err)
+			}
+			var names []string
+			for _, ctx := range selected {
+				names = append(names, ctx.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("selectContexts() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	if _, err := selectContexts(configs, nil, nil, nil, false); err == nil {
+		t.Error("selectContexts() expected error without any selector")
+	}
+	if _, err := selectContexts(configs, []string{"["}, nil, nil, false); err == nil {
+		t.Error("selectContexts() expected error for a bad pattern")
+	}
+}
+
+func TestMatchesPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"acme/", "acme/prod", true},
+		{"acme/", "acme/payments/prod", true},
+		{"acme/", "acme-prod", false},
+		{"acme/payments/", "acme/prod", false},
+		{"acme/*", "acme/prod", true},
+		{"acme/*", "acme/payments/prod", false},
+		{"acme/*/prod", "acme/payments/prod", true},
+		{"*-prod", "acme/payments-prod", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchesPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+	if !isGlobPattern("acme/payments/") || isGlobPattern("acme/payments/prod") {
+		t.Error("isGlobPattern() doesn't tell namespaces from names")
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var mu sync.Mutex
+	var out bytes.Buffer
+	w := &prefixWriter{mu: &mu, out: &out, prefix: "[dev] "}
+
+	fmt.Fprint(w, "one\ntw")
+	fmt.Fprint(w, "o\nthree")
+	w.Flush()
+
+	want := "[dev] one\n[dev] two\n[dev] three\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunFanOut(t *testing.T) {
+	mgr := config.NewManagerWithDir(t.TempDir())
+
+	var contexts []*config.ContextConfig
+	for i, code := range []int{0, 2, 0} {
+		contexts = append(contexts, &config.ContextConfig{
+			Name:        fmt.Sprintf("ctx-%d", i),
+			Environment: config.EnvDevelopment,
+			Env:         map[string]string{"EXIT_CODE": fmt.Sprint(code)},
+		})
+	}
+
+	script := `echo "out $CTX_CURRENT"; echo "err $CTX_CURRENT" >&2; exit $EXIT_CODE`
+	results := runFanOut(mgr, contexts, []string{"sh", "-c", script}, 2, true)
+
+	if len(results) != len(contexts) {
+		t.Fatalf("runFanOut() = %d results, want %d", len(results), len(contexts))
+	}
+	for i, r := range results {
+		name := fmt.Sprintf("ctx-%d", i)
+		if r.Context != name {
+			t.Errorf("results[%d].Context = %q, want %q (order not kept)", i, r.Context, name)
+		}
+		if r.Stdout != "out "+name+"\n" || r.Stderr != "err "+name+"\n" {
+			t.Errorf("results[%d] stdout=%q stderr=%q", i, r.Stdout, r.Stderr)
+		}
+		if r.FinishedAt.Before(r.StartedAt) {
+			t.Errorf("results[%d] finished before it started", i)
+		}
+	}
+	if results[1].ExitCode != 2 {
+		t.Errorf("results[1].ExitCode = %d, want 2", results[1].ExitCode)
+	}
+	if code := aggregateExitCode(results); code != 2 {
+		t.Errorf("aggregateExitCode() = %d, want 2", code)
+	}
+}
+
+func TestExecFanOut_ProductionWithoutTerminal(t *testing.T) {
+	mgr := config.NewManagerWithDir(t.TempDir())
+	for _, ctx := range []*config.ContextConfig{
+		{Name: "dev", Environment: config.EnvDevelopment},
+		{Name: "prod", Environment: config.EnvProduction},
+	} {
+		if err := mgr.SaveContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgManager = mgr
+	t.Cleanup(func() { cfgManager = nil })
+
+	// A script answering the prompt can't confirm it
+	stdin, answer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer.WriteString("yes\n")
+	answer.Close()
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, stdout
+	t.Cleanup(func() { os.Stdin, os.Stdout = oldStdin, oldStdout })
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	err = executeCommand(newExecCmd(), "--all", "--output", "json", "--", "touch", marker)
+	if err == nil || !strings.Contains(err.Error(), "--confirm") {
+		t.Errorf("exec without --confirm error = %v, want it to require --confirm", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("command ran without confirmation")
+	}
+
+	if err := executeCommand(newExecCmd(), "--all", "--confirm", "--output", "json", "--", "true"); err != nil {
+		t.Fatalf("exec --confirm error = %v", err)
+	}
+	data, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []execResult
+	if err := json.Unmarshal(data, &results); err != nil || len(results) != 2 {
+		t.Errorf("stdout = %q, want only the JSON results", data)
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/cloud"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// heartbeatLogFile is the per-session file the heartbeat worker logs to.
+const heartbeatLogFile = "heartbeat.log"
+
+func newCloudHeartbeatCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "heartbeat",
+		Short:  "Send heartbeats for the current session until it ends",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE:   runCloudHeartbeat,
+	}
+}
+
+// startHeartbeatWorker starts a detached 'ctx cloud heartbeat' for the
+// manager's session and returns its PID.
+func startHeartbeatWorker(mgr *config.Manager) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	logPath := mgr.StateStore().Path(filepath.Join(config.SessionsSubdir, mgr.SessionID(), heartbeatLogFile))
+	logFd, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create log file: %w", err)
+	}
+	defer logFd.Close()
+
+	cmd := exec.Command(exe, "cloud", "heartbeat")
+	cmd.Env = append(os.Environ(),
+		config.SessionIDEnvVar+"="+mgr.SessionID(),
+		config.SessionPIDEnvVar+"="+strconv.Itoa(mgr.SessionPID()),
+	)
+	cmd.Stdout = logFd
+	cmd.Stderr = logFd
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	return pid, nil
+}
+
+func runCloudHeartbeat(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+	if mgr.SessionID() == "" {
+		return fmt.Errorf("no session: the heartbeat worker is started by 'ctx use' with the shell hook")
+	}
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return nil // Cloud integration not configured
+	}
+	appConfig := mgr.GetAppConfig()
+	if !appConfig.Cloud.SendHeartbeat {
+		return nil
+	}
+
+	hbMgr := cloud.NewHeartbeatManager(mgr.StateDir(), mgr.SessionID())
+	pid := os.Getpid()
+	defer hbMgr.Release(pid)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer stop()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	logger.Printf("heartbeat worker started for session %s", mgr.SessionID())
+
+	status := func() *cloud.HeartbeatInput {
+		// Stop once stopped or replaced by another worker, or when the
+		// session's shell exits or deactivates
+		if !hbMgr.IsWorker(pid) || !isProcessRunning(mgr.SessionPID()) {
+			return nil
+		}
+		return heartbeatStatus(mgr)
+	}
+
+	err = cloud.RunHeartbeat(ctx, client, heartbeatInterval(appConfig.Cloud), status, logger.Printf)
+	logger.Printf("heartbeat worker stopped")
+	return err
+}
+
+// heartbeatInterval returns the configured interval between heartbeats.
+func heartbeatInterval(cfg *config.CloudConfig) time.Duration {
+	if cfg.HeartbeatInterval <= 0 {
+		return cloud.DefaultHeartbeatInterval
+	}
+	return time.Duration(cfg.HeartbeatInterval) * time.Second
+}
+
+// heartbeatStatus returns the live status of the session's context, or nil
+// once the session has no context.
+func heartbeatStatus(mgr *config.Manager) *cloud.HeartbeatInput {
+	ctx, err := mgr.GetCurrentContext()
+	if err != nil || ctx == nil {
+		return nil
+	}
+
+	return &cloud.HeartbeatInput{
+		ContextName:  ctx.Name,
+		Environment:  string(ctx.Environment),
+		VPNConnected: ctx.VPN != nil && checkVPNStatus(ctx.VPN),
+		Tunnels:      runningTunnels(mgr, ctx.Name),
+	}
+}
+
+// runningTunnels returns the names of the context's tunnels that are up.
+func runningTunnels(mgr *config.Manager, contextName string) []string {
+	var state tunnelState
+	if err := mgr.StateStore().LoadJSON(tunnelStateFile(contextName), &state); err != nil {
+		return nil
+	}
+
+	var names []string
+	for name, entry := range state.TunnelPIDs {
+		if isProcessRunning(entry.PID) {
+			names = append(names, name)
+		}
+	}
+	// Tunnels from before per-tunnel PIDs all share one process
+	if len(state.TunnelPIDs) == 0 && isProcessRunning(state.PID) {
+		for _, t := range state.Tunnels {
+			names = append(names, t.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+var (
+	historyLimitFlag   int
+	historySessionFlag bool
+)
+
+func newHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List recent context activations",
+		Long: `List recent context activations, newest first.
+
+Activations by the current shell are marked with *. Use 'ctx use -' to switch
+back to the previous context.`,
+		Args: cobra.NoArgs,
+		RunE: runHistory,
+	}
+
+	cmd.Flags().IntVarP(&historyLimitFlag, "limit", "n", 20, "Number of activations to show (0 for all)")
+	cmd.Flags().BoolVarP(&historySessionFlag, "session", "s", false, "Only show activations by the current shell")
+
+	return cmd
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	history, err := mgr.LoadHistory()
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"", "TIME", "CONTEXT", "ENVIRONMENT", "SESSION"})
+	table.SetBorder(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetTablePadding("  ")
+	table.SetNoWhiteSpace(true)
+
+	shownCount := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if historyLimitFlag > 0 && shownCount >= historyLimitFlag {
+			break
+		}
+
+		entry := history[i]
+		ownSession := entry.Session != "" && entry.Session == mgr.SessionID()
+		if historySessionFlag && !ownSession {
+			continue
+		}
+
+		marker := " "
+		if ownSession {
+			marker = "*"
+		}
+
+		env := entry.Environment
+		if env == "" {
+			env = "-"
+		}
+		session := entry.Session
+		if session == "" {
+			session = "-"
+		}
+
+		table.Append([]string{
+			marker,
+			entry.Time.Local().Format(time.DateTime),
+			entry.Context,
+			env,
+			session,
+		})
+		shownCount++
+	}
+
+	if shownCount == 0 {
+		fmt.Println("No context activations recorded yet.")
+		return nil
+	}
+
+	table.Render()
+	return nil
+}
+
+// previousContextName returns the context to switch back to for 'ctx use -'.
+func previousContextName(mgr *config.Manager) (string, error) {
+	history, err := mgr.LoadHistory()
+	if err != nil {
+		return "", err
+	}
+
+	current, _ := mgr.GetCurrentContextName()
+	name := config.PreviousContext(history, current, mgr.SessionID())
+	if name == "" {
+		return "", fmt.Errorf("no previous context")
+	}
+	return name, nil
+}
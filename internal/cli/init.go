@@ -42,8 +42,6 @@ func runInit(cmd *cobra.Command, args []string) error {
 			Version:          1,
 			ShellIntegration: true,
 			PromptFormat:     "[ctx: {{.Name}}{{if .IsProd}} ⚠️{{end}}]",
-			ContextsDir:      mgr.ContextsDir(),
-			TunnelsDir:       filepath.Join(mgr.ConfigDir(), "tunnels"),
 		}
 		if err := mgr.SaveAppConfig(config); err != nil {
 			return fmt.Errorf("failed to create config: %w", err)
@@ -166,6 +164,7 @@ func runShellHook(shellFlag string) error {
 		ConfigDir:    mgr.ConfigDir(),
 		StateDir:     mgr.StateDir(),
 		PromptFormat: appConfig.PromptFormat,
+		IdleTimeout:  int(mgr.IdleTimeout().Seconds()),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to generate shell hook: %w", err)
@@ -175,6 +174,55 @@ func runShellHook(shellFlag string) error {
 	return nil
 }
 
+func newEnvSnapshotCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "env-snapshot",
+		Short: "Snapshot variables ctx is about to override (used by shell hook)",
+		Long: `Output shell commands that record the current values of every variable
+in the context env file, so 'ctx deactivate' can restore them later.
+
+Variables that a previously active context overrode but the new one does not
+are restored first. Values captured by an earlier activation are kept, so the
+snapshot always holds what the shell had before ctx touched it.`,
+		Hidden: true,
+		RunE:   runEnvSnapshot,
+	}
+}
+
+func runEnvSnapshot(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	keys := readEnvFileKeys(mgr.CurrentEnvPath())
+	if len(keys) == 0 {
+		return nil
+	}
+
+	snap, err := shell.DecodeSnapshot(os.Getenv(shell.SnapshotEnvVar))
+	if err != nil {
+		// A mangled snapshot can't be trusted; start over from the current env
+		snap = shell.EnvSnapshot{}
+	}
+
+	keep := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		keep[key] = true
+	}
+	for _, line := range snap.Release(keep) {
+		fmt.Println(line)
+	}
+
+	snap.Capture(keys, os.LookupEnv)
+	encoded, err := snap.Encode()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("export %s=%q\n", shell.SnapshotEnvVar, encoded)
+	return nil
+}
+
 // GetShellConfigFile returns the path to the shell config file.
 func GetShellConfigFile(shellType shell.ShellType) string {
 	home, _ := os.UserHomeDir()
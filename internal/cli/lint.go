@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+var lintSecretsFlag bool
+
+func newLintCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "lint [name...]",
+		Aliases: []string{"validate"},
+		Short:   "Check contexts for problems",
+		Long: `Check contexts for problems, all of them unless names are given.
+
+Each context is loaded with its parents and checked the same way 'ctx use'
+checks it, including the policies in ~/.config/ctx/policy.yaml and the team
+policies from ctx-cloud. Policies that warn are reported without failing the
+check. With --secrets, values that look like plaintext secrets are
+reported too: known token formats (AWS access keys, GitHub tokens, Vault
+tokens, JWTs, private keys, passwords in URLs), fields such as nomad.token or
+vpn.setup_key, env: variables named like secrets, and random-looking values.
+Keep secrets in secrets: or in *_env variables instead.
+
+The same scan runs before 'ctx cloud push'.
+
+Examples:
+  ctx lint
+  ctx lint --secrets
+  ctx lint prod staging --secrets`,
+		RunE: runLint,
+	}
+
+	cmd.Flags().BoolVar(&lintSecretsFlag, "secrets", false, "Report values that look like plaintext secrets")
+
+	return cmd
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	names := args
+	if len(names) == 0 {
+		if names, err = mgr.ListContexts(); err != nil {
+			return fmt.Errorf("failed to list contexts: %w", err)
+		}
+	}
+
+	policies, err := loadPolicies(mgr, true)
+	if err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+	yellow := color.New(color.FgYellow)
+
+	failed := 0
+	for _, name := range names {
+		var problems, warnings []string
+		ctx, err := mgr.LoadContext(name)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			// Abstract contexts are only complete once extended
+			if !ctx.Abstract {
+				if err := config.ValidateContext(ctx); err != nil {
+					problems = append(problems, err.Error())
+				}
+				violations, err := evaluatePolicies(policies, ctx, false, "")
+				if err != nil {
+					problems = append(problems, err.Error())
+				}
+				for _, v := range violations {
+					if v.Action == config.PolicyDeny {
+						problems = append(problems, "policy "+v.String())
+					} else {
+						warnings = append(warnings, "policy "+v.String())
+					}
+				}
+			}
+			if lintSecretsFlag {
+				findings, err := config.ScanSecrets(ctx)
+				if err != nil {
+					problems = append(problems, err.Error())
+				}
+				for _, f := range findings {
+					problems = append(problems, formatSecretFinding(f))
+				}
+			}
+		}
+
+		if len(problems) == 0 {
+			green.Printf("✓ %s\n", name)
+		} else {
+			failed++
+			red.Printf("✗ %s\n", name)
+		}
+		for _, problem := range problems {
+			fmt.Printf("    %s\n", problem)
+		}
+		for _, warning := range warnings {
+			yellow.Printf("    ⚠ %s\n", warning)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d contexts have problems", failed, len(names))
+	}
+	return nil
+}
+
+func formatSecretFinding(f config.SecretFinding) string {
+	return fmt.Sprintf("%s: plaintext secret (%s) %s", f.Path, f.Rule, f.Redacted)
+}
+
+// printSecretFindings prints the plaintext secrets found in a context.
+func printSecretFindings(w io.Writer, findings []config.SecretFinding) {
+	for _, f := range findings {
+		fmt.Fprintf(w, "    %s\n", formatSecretFinding(f))
+	}
+}
@@ -6,31 +6,68 @@ package cli
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
+	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/cloud"
 	"github.com/vlebo/ctx/internal/config"
 )
 
-var listAllFlag bool
+var (
+	listAllFlag    bool
+	listSourceFlag bool
+	listFlatFlag   bool
+	listTagFlags   []string
+	listEnvFlags   []string
+)
 
 func newListCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "list",
+		Use:     "list [pattern|namespace/...]",
 		Aliases: []string{"ls"},
 		Short:   "List all available contexts",
 		Long: `List all available contexts with their environment, cloud providers, and orchestration tools.
 
-Abstract (base/template) contexts are hidden by default. Use --all to show them.`,
+Abstract (base/template) contexts are hidden by default. Use --all to show them.
+
+Contexts in subdirectories are namespaced, such as acme/payments/prod, and
+listed as a tree of their namespaces. Use --flat to list full names instead.
+Give glob patterns or namespaces (acme/payments/), --tag or --env to list
+only the matching contexts.
+
+Contexts pulled from ctx-cloud are flagged when they were edited locally
+(modified) or a newer version was seen on the server (out of date).
+
+With context repositories (see 'ctx repo'), a SOURCE column shows the
+repository each context comes from, or local. With --source, it shows the
+file each context is loaded from, and how many files of the same name in
+later context paths it shadows.
+
+Examples:
+  ctx list
+  ctx list acme/
+  ctx list 'acme/*/prod' --flat
+  ctx list acme/payments/ --tag eks --env production`,
 		RunE: runList,
 	}
 
 	cmd.Flags().BoolVarP(&listAllFlag, "all", "a", false, "Show all contexts including abstract/base contexts")
+	cmd.Flags().BoolVar(&listSourceFlag, "source", false, "Show the file each context is loaded from")
+	cmd.Flags().BoolVar(&listFlatFlag, "flat", false, "List full context names instead of a tree of namespaces")
+	cmd.Flags().StringSliceVarP(&listTagFlags, "tag", "t", nil, "List contexts with this tag (repeatable, all must match)")
+	cmd.Flags().StringSliceVarP(&listEnvFlags, "env", "e", nil, "List contexts with this environment (repeatable)")
 
 	return cmd
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	if err := validatePatterns(args); err != nil {
+		return err
+	}
+
 	mgr, err := GetConfigManager()
 	if err != nil {
 		return err
@@ -40,11 +77,31 @@ func runList(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to list contexts: %w", err)
 	}
+	filtered := len(args) > 0 || len(listTagFlags) > 0 || len(listEnvFlags) > 0
+	configs = slices.DeleteFunc(configs, func(ctx *config.ContextConfig) bool {
+		// Skip abstract contexts unless --all is set
+		return (ctx.Abstract && !listAllFlag) || !matchesFilters(ctx, args, listTagFlags, listEnvFlags)
+	})
+	if !listFlatFlag {
+		slices.SortStableFunc(configs, func(a, b *config.ContextConfig) int {
+			return compareTreeNames(a.Name, b.Name)
+		})
+	}
 
 	currentName, _ := mgr.GetCurrentContextName()
+	syncRecords, _ := cloud.NewSyncState(mgr.StateDir()).Records()
+	yellow := color.New(color.FgYellow)
+
+	appConfig := mgr.GetAppConfig()
+	showSource := listSourceFlag || (appConfig != nil && len(appConfig.Repos) > 0)
+
+	header := []string{"", "NAME", "ENVIRONMENT", "CLOUD", "ORCHESTRATION", "EXTRAS"}
+	if showSource {
+		header = append(header, "SOURCE")
+	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"", "NAME", "ENVIRONMENT", "CLOUD", "ORCHESTRATION", "EXTRAS"})
+	table.SetHeader(header)
 	table.SetBorder(false)
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -54,14 +111,11 @@ func runList(cmd *cobra.Command, args []string) error {
 	table.SetHeaderLine(false)
 	table.SetTablePadding("  ")
 	table.SetNoWhiteSpace(true)
+	// Paths are long, and must stay on one line to be copied
+	table.SetAutoWrapText(!listSourceFlag)
 
-	shownCount := 0
+	var namespace []string
 	for _, ctx := range configs {
-		// Skip abstract contexts unless --all is set
-		if ctx.Abstract && !listAllFlag {
-			continue
-		}
-
 		summary := config.GetContextSummary(ctx, currentName)
 
 		marker := " "
@@ -87,11 +141,38 @@ func runList(cmd *cobra.Command, args []string) error {
 			extrasStr = "-"
 		}
 
-		table.Append([]string{marker, summary.Name, envStr, cloudStr, orchStr, extrasStr})
-		shownCount++
+		nameStr := summary.Name
+		if !listFlatFlag {
+			// Open the namespaces the context is in that the previous one wasn't
+			segments := strings.Split(ctx.Name, "/")
+			open := 0
+			for open < len(namespace) && open < len(segments)-1 && namespace[open] == segments[open] {
+				open++
+			}
+			namespace = segments[:len(segments)-1]
+			for i := open; i < len(namespace); i++ {
+				row := make([]string, len(header))
+				row[1] = treeIndent(i) + namespace[i] + "/"
+				table.Append(row)
+			}
+			nameStr = treeIndent(len(namespace)) + segments[len(segments)-1]
+		}
+		if status := syncStatus(mgr, syncRecords, ctx.Name); status != "" {
+			nameStr += yellow.Sprintf(" (%s)", status)
+		}
+
+		row := []string{marker, nameStr, envStr, cloudStr, orchStr, extrasStr}
+		if showSource {
+			row = append(row, formatListSource(mgr.ContextSource(ctx.Name)))
+		}
+		table.Append(row)
 	}
 
-	if shownCount == 0 {
+	if len(configs) == 0 && filtered {
+		fmt.Println("No contexts match.")
+		return nil
+	}
+	if len(configs) == 0 {
 		fmt.Println("No contexts found.")
 		fmt.Printf("Create a context file in %s/\n", mgr.ContextsDir())
 		return nil
@@ -102,6 +183,42 @@ func runList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// compareTreeNames orders context names as a tree of their namespaces: one
+// namespace at a time, with the contexts of a namespace before the
+// namespaces in it.
+func compareTreeNames(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if aLeaf, bLeaf := i == len(as)-1, i == len(bs)-1; aLeaf != bLeaf {
+			if aLeaf {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// treeIndent returns the indentation of a name at a depth of the tree.
+func treeIndent(depth int) string {
+	return strings.Repeat("  ", depth)
+}
+
+// formatListSource returns the SOURCE column of a context: its file with
+// --source, or else where it comes from.
+func formatListSource(source config.ContextSource) string {
+	if !listSourceFlag {
+		return source.String()
+	}
+	if len(source.Shadowed) > 0 {
+		return source.Path + color.New(color.FgYellow).Sprintf(" (shadows %d)", len(source.Shadowed))
+	}
+	return source.Path
+}
+
 // formatEnvironmentWithColor returns a colored string for the environment.
 func formatEnvironmentWithColor(ctx *config.ContextConfig) string {
 	c := getEnvColor(ctx)
@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareTreeNames(t *testing.T) {
+	names := []string{
+		"acme/payments/prod",
+		"zeta",
+		"acme/prod",
+		"acme-dev",
+		"acme/payments/dev",
+		"acme/infra/eu/prod",
+		"dev",
+	}
+	want := []string{
+		"acme-dev",
+		"dev",
+		"zeta",
+		"acme/prod",
+		"acme/infra/eu/prod",
+		"acme/payments/dev",
+		"acme/payments/prod",
+	}
+
+	slices.SortFunc(names, compareTreeNames)
+	if !slices.Equal(names, want) {
+		t.Errorf("sorted = %v, want %v", names, want)
+	}
+}
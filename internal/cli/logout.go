@@ -4,6 +4,7 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -162,8 +163,9 @@ func runLogout(cmd *cobra.Command, args []string) error {
 		yellow.Fprintf(os.Stderr, "⚠ Failed to clean up secret files: %v\n", err)
 	}
 
-	// If this was the current context, clear state files
+	// If this was the current context, stop its heartbeat and clear state files
 	if os.Getenv("CTX_CURRENT") == contextName {
+		cloud.NewHeartbeatManager(mgr.StateDir(), mgr.SessionID()).StopHeartbeat()
 		if err := mgr.ClearCurrentContext(); err != nil {
 			yellow.Fprintf(os.Stderr, "⚠ Failed to clear state files: %v\n", err)
 		}
@@ -183,26 +185,24 @@ func runLogout(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// sendCloudLogoutEvents sends logout audit event and deactivates cloud session.
+// sendCloudLogoutEvents records the logout audit event and deactivates cloud session.
 func sendCloudLogoutEvents(mgr *config.Manager, contextName, environment string) {
-	client := NewCloudClient(mgr)
-	if client == nil {
-		return
-	}
-
-	// Stop heartbeat if running
-	hbMgr := cloud.NewHeartbeatManager(mgr.StateDir())
-	_ = hbMgr.StopHeartbeat()
-
-	// Send logout audit event (synchronous - must complete before exit)
 	event := &cloud.AuditEvent{
 		Action:      "logout",
 		ContextName: contextName,
 		Environment: environment,
 		Success:     true,
 	}
-	_ = client.SendAuditEvent(event)
+	recordAuditEvent(mgr, event)
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return
+	}
+
+	// Send logout audit event (synchronous - must complete before exit)
+	_ = deliverAuditEvent(mgr, client, event)
 
 	// Deactivate cloud session
-	_ = client.Deactivate(contextName)
+	_ = client.Deactivate(context.Background(), contextName)
 }
@@ -0,0 +1,306 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// pickerHeight is the number of contexts the picker shows at once.
+const pickerHeight = 10
+
+var (
+	// errNoTTY is returned by pickContext when there is no terminal to use.
+	errNoTTY = errors.New("no terminal")
+	// errPickerCancelled is returned by pickContext when the user cancels.
+	errPickerCancelled = errors.New("no context selected")
+)
+
+// pickerItem is a context offered by the picker.
+type pickerItem struct {
+	ctx      *config.ContextConfig
+	lastUsed time.Time
+}
+
+// pickerItems returns the contexts that can be used, most recently used
+// first, followed by the others by name.
+func pickerItems(configs []*config.ContextConfig, history []config.HistoryEntry) []pickerItem {
+	lastUsed := config.LastUsed(history)
+
+	var items []pickerItem
+	for _, ctx := range configs {
+		if ctx.Abstract {
+			continue
+		}
+		items = append(items, pickerItem{ctx: ctx, lastUsed: lastUsed[ctx.Name]})
+	}
+
+	slices.SortStableFunc(items, func(a, b pickerItem) int {
+		if c := b.lastUsed.Compare(a.lastUsed); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ctx.Name, b.ctx.Name)
+	})
+	return items
+}
+
+// filterPickerItems keeps the items matching every word of query. Words like
+// tag:eks and env:prod match tags and environments by prefix; other words are
+// fuzzy-matched against the context name.
+func filterPickerItems(items []pickerItem, query string) []pickerItem {
+	words := strings.Fields(strings.ToLower(query))
+
+	var matches []pickerItem
+	for _, item := range items {
+		if pickerItemMatches(item.ctx, words) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+func pickerItemMatches(ctx *config.ContextConfig, words []string) bool {
+	for _, word := range words {
+		if tag, ok := strings.CutPrefix(word, "tag:"); ok {
+			if !slices.ContainsFunc(ctx.Tags, func(t string) bool {
+				return strings.HasPrefix(strings.ToLower(t), tag)
+			}) {
+				return false
+			}
+		} else if env, ok := strings.CutPrefix(word, "env:"); ok {
+			if !strings.HasPrefix(strings.ToLower(string(ctx.Environment)), env) {
+				return false
+			}
+		} else if !fuzzyMatch(strings.ToLower(ctx.Name), word) {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in order.
+func fuzzyMatch(s, pattern string) bool {
+	for _, r := range pattern {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// pickContextName asks the user to pick a context for 'ctx use' without a
+// name. Without a terminal it lists the recent contexts instead.
+func pickContextName(mgr *config.Manager) (string, error) {
+	configs, err := mgr.ListContextConfigs()
+	if err != nil {
+		return "", fmt.Errorf("failed to list contexts: %w", err)
+	}
+	history, err := mgr.LoadHistory()
+	if err != nil {
+		return "", err
+	}
+
+	items := pickerItems(configs, history)
+	if len(items) == 0 {
+		return "", fmt.Errorf("no contexts found. Run 'ctx init' to create one")
+	}
+
+	ctx, err := pickContext(items)
+	if errors.Is(err, errNoTTY) {
+		fmt.Fprintln(os.Stderr, "Recent contexts:")
+		for _, item := range items[:min(len(items), pickerHeight)] {
+			fmt.Fprintf(os.Stderr, "  %s (%s)\n", item.ctx.Name, formatEnvironmentWithColor(item.ctx))
+		}
+		return "", fmt.Errorf("no context given. Run 'ctx use <name>'")
+	}
+	if err != nil {
+		return "", err
+	}
+	return ctx.Name, nil
+}
+
+// pickContext lets the user choose a context on the terminal. It returns
+// errNoTTY when ctx isn't run interactively.
+func pickContext(items []pickerItem) (*config.ContextConfig, error) {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil, errNoTTY
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errNoTTY
+	}
+	defer tty.Close()
+
+	restore, err := makeRaw(tty)
+	if err != nil {
+		return nil, errNoTTY
+	}
+	defer restore()
+
+	p := &picker{items: items, matches: items, out: tty}
+	defer p.clear()
+
+	buf := make([]byte, 64)
+	for {
+		p.render()
+
+		n, err := tty.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if ctx, done := p.handleKey(buf[:n]); done {
+			if ctx == nil {
+				return nil, errPickerCancelled
+			}
+			return ctx, nil
+		}
+	}
+}
+
+// picker is the state of the interactive context picker.
+type picker struct {
+	items    []pickerItem
+	matches  []pickerItem
+	query    []rune
+	selected int
+	out      io.Writer
+}
+
+// handleKey applies a key press. It returns done once the user has picked a
+// context (ctx) or cancelled (nil).
+func (p *picker) handleKey(key []byte) (ctx *config.ContextConfig, done bool) {
+	switch {
+	case len(key) == 0:
+	case string(key) == "\x1b[A", len(key) == 1 && (key[0] == 0x10 || key[0] == 0x0b): // Up, Ctrl-P, Ctrl-K
+		if p.selected > 0 {
+			p.selected--
+		}
+	case string(key) == "\x1b[B", len(key) == 1 && (key[0] == 0x0e || key[0] == 0x09): // Down, Ctrl-N, Tab
+		if p.selected < len(p.matches)-1 {
+			p.selected++
+		}
+	case len(key) == 1 && (key[0] == '\r' || key[0] == '\n'):
+		if len(p.matches) > 0 {
+			return p.matches[p.selected].ctx, true
+		}
+	case len(key) == 1 && (key[0] == 0x1b || key[0] == 0x03 || key[0] == 0x04): // Esc, Ctrl-C, Ctrl-D
+		return nil, true
+	case len(key) == 1 && (key[0] == 0x7f || key[0] == 0x08): // Backspace
+		if len(p.query) > 0 {
+			p.setQuery(p.query[:len(p.query)-1])
+		}
+	case len(key) == 1 && key[0] == 0x15: // Ctrl-U
+		p.setQuery(nil)
+	case key[0] != 0x1b:
+		query := p.query
+		for _, r := range string(key) {
+			if unicode.IsPrint(r) {
+				query = append(query, r)
+			}
+		}
+		p.setQuery(query)
+	}
+	return nil, false
+}
+
+func (p *picker) setQuery(query []rune) {
+	p.query = query
+	p.matches = filterPickerItems(p.items, string(query))
+	p.selected = 0
+}
+
+// render draws the prompt followed by the matching contexts, and leaves the
+// cursor at the end of the prompt. The terminal is in raw mode, so lines
+// end in \r\n.
+func (p *picker) render() {
+	var b strings.Builder
+	b.WriteString("\r\x1b[J")
+
+	// Scroll so the selected context is visible
+	start := max(0, p.selected-pickerHeight+1)
+	end := min(len(p.matches), start+pickerHeight)
+
+	width := 0
+	for _, item := range p.matches[start:end] {
+		width = max(width, len(item.ctx.Name))
+	}
+
+	lines := 0
+	for i, item := range p.matches[start:end] {
+		cursor := "  "
+		name := fmt.Sprintf("%-*s", width, item.ctx.Name)
+		if start+i == p.selected {
+			cursor = "> "
+			name = color.New(color.Bold).Sprint(name)
+		}
+		fmt.Fprintf(&b, "\r\n%s%s  %s  %s", cursor, name, formatEnvironmentWithColor(item.ctx), formatAgo(item.lastUsed))
+		lines++
+	}
+	fmt.Fprintf(&b, "\r\n  %d/%d  (tag:<tag> env:<environment> to filter, Esc to cancel)", len(p.matches), len(p.items))
+	lines++
+
+	// Back up to the prompt line
+	prompt := "Context> " + string(p.query)
+	fmt.Fprintf(&b, "\x1b[%dA\r%s", lines, prompt)
+
+	io.WriteString(p.out, b.String())
+}
+
+// clear removes the picker from the terminal.
+func (p *picker) clear() {
+	io.WriteString(p.out, "\r\x1b[J")
+}
+
+// formatAgo describes how long ago t was, or returns "" for the zero time.
+func formatAgo(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// makeRaw puts the terminal in raw mode and returns a function restoring it.
+// stty is used so this works the same on Linux and macOS.
+func makeRaw(tty *os.File) (func(), error) {
+	state, err := stty(tty, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(tty, state) }, nil
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+func pickerItemNames(items []pickerItem) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.ctx.Name)
+	}
+	return names
+}
+
+func TestPickerItems(t *testing.T) {
+	configs := []*config.ContextConfig{
+		{Name: "acme-dev", Environment: config.EnvDevelopment},
+		{Name: "acme-prod", Environment: config.EnvProduction},
+		{Name: "base", Abstract: true},
+		{Name: "globex-dev", Environment: config.EnvDevelopment},
+		{Name: "globex-prod", Environment: config.EnvProduction},
+	}
+	now := time.Now()
+	history := []config.HistoryEntry{
+		{Time: now.Add(-2 * time.Hour), Context: "globex-prod"},
+		{Time: now.Add(-time.Hour), Context: "acme-prod"},
+	}
+
+	got := pickerItemNames(pickerItems(configs, history))
+	want := []string{"acme-prod", "globex-prod", "acme-dev", "globex-dev"}
+	if !slices.Equal(got, want) {
+		t.Errorf("pickerItems() = %v, want %v", got, want)
+	}
+}
+
+func TestFilterPickerItems(t *testing.T) {
+	items := pickerItems([]*config.ContextConfig{
+		{Name: "acme-dev", Environment: config.EnvDevelopment, Tags: []string{"acme", "eks"}},
+		{Name: "acme-prod", Environment: config.EnvProduction, Tags: []string{"acme", "eks"}},
+		{Name: "globex-prod", Environment: config.EnvProduction, Tags: []string{"GKE"}},
+	}, nil)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"acme-dev", "acme-prod", "globex-prod"}},
+		{"prod", []string{"acme-prod", "globex-prod"}},
+		{"apd", []string{"acme-prod"}},
+		{"ADV", []string{"acme-dev"}},
+		{"env:prod", []string{"acme-prod", "globex-prod"}},
+		{"tag:gke", []string{"globex-prod"}},
+		{"tag:eks env:dev", []string{"acme-dev"}},
+		{"tag:eks glo", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := pickerItemNames(filterPickerItems(items, tt.query))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filterPickerItems(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickerHandleKey(t *testing.T) {
+	items := pickerItems([]*config.ContextConfig{
+		{Name: "acme-dev", Environment: config.EnvDevelopment},
+		{Name: "acme-prod", Environment: config.EnvProduction},
+		{Name: "globex-prod", Environment: config.EnvProduction},
+	}, nil)
+
+	tests := []struct {
+		name string
+		keys []string
+		want string
+	}{
+		{"first", []string{"\r"}, "acme-dev"},
+		{"down", []string{"\x1b[B", "\x1b[B", "\x1b[B", "\r"}, "globex-prod"},
+		{"down and up", []string{"\x1b[B", "\x1b[A", "\r"}, "acme-dev"},
+		{"filter", []string{"g", "p", "\r"}, "globex-prod"},
+		{"backspace", []string{"g", "x", "\x7f", "\r"}, "globex-prod"},
+		{"clear", []string{"glob", "\x15", "\r"}, "acme-dev"},
+		{"no match", []string{"zzz", "\r", "\x15", "\r"}, "acme-dev"},
+		{"cancel", []string{"acme", "\x1b"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &picker{items: items, matches: items, out: &bytes.Buffer{}}
+			var got string
+			for _, key := range tt.keys {
+				p.render()
+				ctx, done := p.handleKey([]byte(key))
+				if done {
+					if ctx != nil {
+						got = ctx.Name
+					}
+					break
+				}
+			}
+			if got != tt.want {
+				t.Errorf("picked %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/vlebo/ctx/internal/cloud"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// policyRefreshInterval is how long the team policies cached from ctx-cloud
+// are used before 'ctx use' checks the server for new ones.
+const policyRefreshInterval = 15 * time.Minute
+
+// loadPolicies returns the local policy file and the team policies from
+// ctx-cloud, those that are configured. With refresh, cached team policies
+// older than policyRefreshInterval are fetched again.
+func loadPolicies(mgr *config.Manager, refresh bool) ([]*config.PolicyDocument, error) {
+	var docs []*config.PolicyDocument
+	local, err := mgr.LoadLocalPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if local != nil {
+		docs = append(docs, local)
+	}
+
+	team, err := loadTeamPolicy(mgr, refresh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team policies: %w", err)
+	}
+	if team != nil {
+		docs = append(docs, team)
+	}
+	return docs, nil
+}
+
+// loadTeamPolicy returns the team policies from ctx-cloud, verified with the
+// configured policy key. Without a key, team policies aren't used.
+func loadTeamPolicy(mgr *config.Manager, refresh bool) (*config.PolicyDocument, error) {
+	appConfig := mgr.GetAppConfig()
+	if appConfig == nil || appConfig.Cloud == nil || !appConfig.Cloud.Enabled || appConfig.Cloud.PolicyKey == "" {
+		return nil, nil
+	}
+	key, err := cloud.ParsePolicyKey(appConfig.Cloud.PolicyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := cloud.NewPolicyCache(mgr.StateDir())
+	policy, err := cache.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if refresh && (policy == nil || time.Since(policy.FetchedAt) > policyRefreshInterval) {
+		if client := NewCloudClient(mgr); client != nil {
+			policy, err = fetchTeamPolicy(client, cache, policy, key)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if policy == nil {
+		return nil, nil
+	}
+	return policy.Verify(key)
+}
+
+// fetchTeamPolicy fetches the team policies and caches them once their
+// signature is verified. Only a signed document replaces the cached policy:
+// if the server can't be reached or has no policies, the cached one is kept.
+// A signed document without policies removes them.
+func fetchTeamPolicy(client *cloud.Client, cache *cloud.PolicyCache, cached *cloud.SignedPolicy, key ed25519.PublicKey) (*cloud.SignedPolicy, error) {
+	etag := ""
+	if cached != nil {
+		etag = cached.ETag
+	}
+
+	yellow := color.New(color.FgYellow)
+	policy, err := client.FetchPolicy(context.Background(), etag)
+	switch {
+	case errors.Is(err, cloud.ErrNotModified):
+		cached.FetchedAt = time.Now()
+		return cached, cache.Save(cached)
+	case err != nil:
+		yellow.Fprintf(os.Stderr, "⚠ Failed to fetch team policies, using the cached ones: %v\n", err)
+		return cached, nil
+	case policy == nil:
+		if cached != nil {
+			yellow.Fprintf(os.Stderr, "⚠ ctx-cloud has no team policies, using the cached ones\n")
+		}
+		return cached, nil
+	}
+
+	if _, err := policy.Verify(key); err != nil {
+		return nil, fmt.Errorf("team policies from ctx-cloud: %w", err)
+	}
+	return policy, cache.Save(policy)
+}
+
+// evaluatePolicies checks a context against all policies. reason is only
+// checked when checkReason is set, on activation.
+func evaluatePolicies(docs []*config.PolicyDocument, ctx *config.ContextConfig, checkReason bool, reason string) ([]config.PolicyViolation, error) {
+	var violations []config.PolicyViolation
+	for _, doc := range docs {
+		found, err := doc.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, found...)
+		if checkReason {
+			violations = append(violations, doc.CheckReason(ctx, reason)...)
+		}
+	}
+	return violations, nil
+}
+
+// enforcePolicies checks a context against the policies before it's
+// activated. A reason is asked for when a policy requires one and none was
+// given. Violations are printed and recorded as audit events; an error is
+// returned if any of them denies activation. It returns the reason.
+func enforcePolicies(mgr *config.Manager, ctx *config.ContextConfig, reason string) (string, error) {
+	docs, err := loadPolicies(mgr, true)
+	if err != nil {
+		return reason, err
+	}
+	if len(docs) == 0 {
+		return reason, nil
+	}
+
+	if strings.TrimSpace(reason) == "" && reasonRequired(docs, ctx) {
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			reason = promptReason(ctx)
+		}
+	}
+
+	violations, err := evaluatePolicies(docs, ctx, true, reason)
+	if err != nil {
+		return reason, err
+	}
+	if len(violations) == 0 {
+		return reason, nil
+	}
+
+	denied := config.HasDenials(violations)
+	printPolicyViolations(os.Stderr, violations)
+	reportPolicyViolations(mgr, ctx, violations, denied, reason)
+	if denied {
+		return reason, fmt.Errorf("context '%s' is denied by policy", ctx.Name)
+	}
+	return reason, nil
+}
+
+// reasonRequired reports whether a policy requires a reason to activate ctx.
+func reasonRequired(docs []*config.PolicyDocument, ctx *config.ContextConfig) bool {
+	for _, doc := range docs {
+		if len(doc.CheckReason(ctx, "")) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// promptReason asks for the reason to activate a context.
+func promptReason(ctx *config.ContextConfig) string {
+	fmt.Fprintf(os.Stderr, "A reason is required to use '%s': ", ctx.Name)
+
+	reader := bufio.NewReader(os.Stdin)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return ""
+	}
+	return strings.TrimSpace(input)
+}
+
+// printPolicyViolations prints policy violations, denials in red and
+// warnings in yellow.
+func printPolicyViolations(w io.Writer, violations []config.PolicyViolation) {
+	red := color.New(color.FgRed)
+	yellow := color.New(color.FgYellow)
+	for _, v := range violations {
+		if v.Action == config.PolicyDeny {
+			red.Fprintf(w, "✗ Policy %s\n", v)
+		} else {
+			yellow.Fprintf(w, "⚠ Policy %s\n", v)
+		}
+	}
+}
+
+// reportPolicyViolations records policy violations as an audit event. A
+// denial is sent to ctx-cloud right away, as the command is about to fail;
+// warnings are queued and go out with the switch event.
+func reportPolicyViolations(mgr *config.Manager, ctx *config.ContextConfig, violations []config.PolicyViolation, denied bool, reason string) {
+	details := map[string]any{
+		"violations": violations,
+		"denied":     denied,
+	}
+	if reason != "" {
+		details["reason"] = reason
+	}
+
+	event := &cloud.AuditEvent{
+		Action:      "policy.violation",
+		ContextName: ctx.Name,
+		Environment: string(ctx.Environment),
+		Details:     details,
+		Success:     !denied,
+	}
+	if denied {
+		event.ErrorMessage = "Denied by policy"
+	}
+	recordAuditEvent(mgr, event)
+
+	appConfig := mgr.GetAppConfig()
+	if appConfig == nil || appConfig.Cloud == nil || !appConfig.Cloud.SendAuditEvents {
+		return
+	}
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return
+	}
+
+	var err error
+	if denied {
+		err = deliverAuditEvent(mgr, client, event)
+	} else {
+		err = queueAuditEvent(mgr, event)
+	}
+	if err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(os.Stderr, "⚠ Cloud audit event failed: %v\n", err)
+	}
+}
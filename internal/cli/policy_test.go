@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/cloud"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// newPolicyTestManager returns a manager with a dev context, a debug context
+// the local policy denies, and the manager set as the one commands use.
+func newPolicyTestManager(t *testing.T) *config.Manager {
+	t.Helper()
+	mgr := config.NewManagerWithDir(t.TempDir())
+	for _, ctx := range []*config.ContextConfig{
+		{Name: "dev", Environment: config.EnvDevelopment},
+		{Name: "debug", Environment: config.EnvDevelopment, Env: map[string]string{"DEBUG": "1"}},
+	} {
+		if err := mgr.SaveContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := `policies:
+  - name: no-debug
+    forbid:
+      env.DEBUG: "1"
+`
+	if err := os.WriteFile(mgr.LocalPolicyPath(), []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgManager = mgr
+	t.Cleanup(func() { cfgManager = nil })
+	return mgr
+}
+
+// executeCommand runs a command with args, without printing its usage.
+func executeCommand(cmd *cobra.Command, args ...string) error {
+	cmd.SetArgs(args)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return cmd.Execute()
+}
+
+func TestPoliciesEnforcedOnUse(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	t.Setenv("SHELL", "/bin/sh")
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{
+			name: "exec",
+			run:  func() error { return executeCommand(newExecCmd(), "debug", "--", "touch", marker) },
+		},
+		{
+			name: "exec in several contexts",
+			run:  func() error { return executeCommand(newExecCmd(), "--all", "--", "touch", marker) },
+		},
+		{
+			name: "shell",
+			run:  func() error { return executeCommand(newShellCmd(), "debug") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := newPolicyTestManager(t)
+
+			err := tt.run()
+			if err == nil || !strings.Contains(err.Error(), "denied by policy") {
+				t.Fatalf("error = %v, want a policy denial", err)
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Error("command ran in a denied context")
+			}
+			if name, _ := mgr.GetCurrentContextName(); name != "" {
+				t.Errorf("current context = %q, want none", name)
+			}
+		})
+	}
+
+	// Contexts the policy allows still run
+	newPolicyTestManager(t)
+	if err := executeCommand(newExecCmd(), "dev", "--", "touch", marker); err != nil {
+		t.Fatalf("exec in an allowed context error = %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("command didn't run in an allowed context")
+	}
+}
+
+func TestFetchTeamPolicy(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(doc string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(doc)))
+	}
+	teamPolicy := "policies:\n  - name: prod-reason\n    require_reason: true\n"
+
+	var document, signature string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if document == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := json.Marshal(map[string]any{"document": document, "signature": signature})
+		w.Write([]byte(`{"data":` + string(data) + `}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	client := cloud.NewClient(ts.URL, "sk_test")
+	cache := cloud.NewPolicyCache(t.TempDir())
+	fetch := func() (*config.PolicyDocument, error) {
+		t.Helper()
+		cached, err := cache.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy, err := fetchTeamPolicy(client, cache, cached, publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return policy.Verify(publicKey)
+	}
+	cachedPolicies := func() int {
+		t.Helper()
+		cached, err := cache.Load()
+		if err != nil || cached == nil {
+			t.Fatalf("cache.Load() = %v, %v, want the signed policy", cached, err)
+		}
+		doc, err := cached.Verify(publicKey)
+		if err != nil {
+			t.Fatalf("Verify() of the cached policy error = %v", err)
+		}
+		return len(doc.Policies)
+	}
+
+	document, signature = teamPolicy, sign(teamPolicy)
+	if doc, err := fetch(); err != nil || len(doc.Policies) != 1 {
+		t.Fatalf("fetchTeamPolicy() = %v, %v, want the team policy", doc, err)
+	}
+
+	// A document that doesn't match its signature isn't cached
+	document = "policies: []\n"
+	if _, err := fetch(); !errors.Is(err, cloud.ErrInvalidPolicySignature) {
+		t.Errorf("fetchTeamPolicy() of a forged document error = %v, want ErrInvalidPolicySignature", err)
+	}
+	if n := cachedPolicies(); n != 1 {
+		t.Errorf("cached policies after a forged document = %d, want 1", n)
+	}
+
+	// The server no longer having a document doesn't remove the policies
+	document = ""
+	if doc, err := fetch(); err != nil || len(doc.Policies) != 1 {
+		t.Errorf("fetchTeamPolicy() after a 404 = %v, %v, want the cached policy", doc, err)
+	}
+	if n := cachedPolicies(); n != 1 {
+		t.Errorf("cached policies after a 404 = %d, want 1", n)
+	}
+
+	// A signed empty document does
+	document = "policies: []\n"
+	signature = sign(document)
+	if doc, err := fetch(); err != nil || len(doc.Policies) != 0 {
+		t.Errorf("fetchTeamPolicy() of an empty document = %v, %v, want no policies", doc, err)
+	}
+	if n := cachedPolicies(); n != 0 {
+		t.Errorf("cached policies after an empty document = %d, want 0", n)
+	}
+}
@@ -0,0 +1,481 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// repoStateFile records when each context repository was last updated.
+const repoStateFile = "repos.json"
+
+// repoState is the state of a cloned context repository.
+type repoState struct {
+	Commit    string    `json:"commit"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newRepoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repo",
+		Short: "Manage git repositories of shared contexts",
+		Long: `Subscribe to git repositories of shared contexts.
+
+A repository is cloned into ~/.config/ctx/repos/<name>, and its contexts are
+named <name>/<context>, such as acme/prod. They can be used and extended like
+local contexts. A context in a repository that extends a bare name, such as
+'extends: base', gets the repository's own base context if it has one.
+
+To change a repository's context without pushing to it, put a file with the
+same name in the contexts directory, such as contexts/acme/prod.yaml. The
+local file overrides the repository's until it's deleted.`,
+	}
+
+	cmd.AddCommand(newRepoAddCmd())
+	cmd.AddCommand(newRepoUpdateCmd())
+	cmd.AddCommand(newRepoListCmd())
+	cmd.AddCommand(newRepoRemoveCmd())
+
+	return cmd
+}
+
+func newRepoAddCmd() *cobra.Command {
+	var path string
+	var ref string
+
+	cmd := &cobra.Command{
+		Use:   "add <name> <git-url>",
+		Short: "Add a git repository of shared contexts",
+		Long: `Clone a git repository of shared contexts and add its contexts under the
+<name> namespace.
+
+By default, the repository's default branch is followed by 'ctx repo update'.
+With --ref, the repository is pinned to a branch, tag or commit.
+
+Examples:
+  ctx repo add acme git@github.com:acme/ctx-contexts.git
+  ctx repo add acme https://github.com/acme/infra.git --path contexts/
+  ctx repo add acme git@github.com:acme/ctx-contexts.git --ref v1.4.0`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRepoAdd(args[0], args[1], path, ref)
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "path", "", "Directory of the context files in the repository (default: the root)")
+	cmd.Flags().StringVar(&ref, "ref", "", "Branch, tag or commit to pin the repository to")
+
+	return cmd
+}
+
+func newRepoUpdateCmd() *cobra.Command {
+	var ref string
+
+	cmd := &cobra.Command{
+		Use:   "update [name...]",
+		Short: "Fetch the latest contexts of repositories",
+		Long: `Fetch context repositories and check out their default branch, or the ref
+they're pinned to. Without names, every repository is updated. Repositories
+that were never cloned on this machine are cloned.
+
+With --ref, a single repository is pinned to another branch, tag or commit.
+An empty --ref unpins it.
+
+Examples:
+  ctx repo update
+  ctx repo update acme --ref v1.5.0
+  ctx repo update acme --ref ""`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var newRef *string
+			if cmd.Flags().Changed("ref") {
+				if len(args) != 1 {
+					return fmt.Errorf("--ref requires exactly one repository name")
+				}
+				newRef = &ref
+			}
+			return runRepoUpdate(args, newRef)
+		},
+	}
+
+	cmd.Flags().StringVar(&ref, "ref", "", "Branch, tag or commit to pin the repository to")
+
+	return cmd
+}
+
+func newRepoListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List context repositories",
+		Args:    cobra.NoArgs,
+		RunE:    runRepoList,
+	}
+}
+
+func newRepoRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <name>",
+		Aliases: []string{"rm"},
+		Short:   "Remove a context repository",
+		Long: `Remove a context repository and its clone. Local overrides of its contexts
+are kept in the contexts directory.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRepoRemove(args[0])
+		},
+	}
+}
+
+func runRepoAdd(name, url, path, ref string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	repo := config.RepoConfig{Name: name, URL: url, Path: strings.Trim(filepath.ToSlash(path), "/"), Ref: ref}
+
+	yellow := color.New(color.FgYellow)
+	yellow.Printf("• Cloning %s... ", url)
+	commit, err := addRepo(mgr, repo)
+	if err != nil {
+		color.New(color.FgRed).Println("failed")
+		return err
+	}
+	color.New(color.FgGreen).Println("done")
+
+	contexts, _ := mgr.ListContexts()
+	count := 0
+	for _, ctx := range contexts {
+		if strings.HasPrefix(ctx, name+"/") {
+			count++
+		}
+	}
+
+	green := color.New(color.FgGreen)
+	green.Printf("✓ Added repository '%s' at %s with %d contexts\n", name, shortCommit(commit), count)
+	fmt.Printf("Use its contexts as %s/<name>, e.g. 'ctx use %s/prod'.\n", name, name)
+	return nil
+}
+
+// addRepo clones a context repository and adds it to the app config. It
+// returns the commit checked out.
+func addRepo(mgr *config.Manager, repo config.RepoConfig) (string, error) {
+	if err := config.ValidateRepoName(repo.Name); err != nil {
+		return "", err
+	}
+	if mgr.Repo(repo.Name) != nil {
+		return "", fmt.Errorf("repository '%s' already exists", repo.Name)
+	}
+	if repo.Path != "" && !filepath.IsLocal(repo.Path) {
+		return "", fmt.Errorf("--path must be a directory inside the repository")
+	}
+
+	dir := mgr.RepoDir(repo.Name)
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("%s already exists", dir)
+	}
+	commit, err := cloneRepo(mgr, &repo)
+	if err != nil {
+		return "", err
+	}
+
+	appConfig := mgr.GetAppConfig()
+	if appConfig == nil {
+		appConfig = &config.AppConfig{}
+	}
+	appConfig.Repos = append(appConfig.Repos, repo)
+	if err := mgr.SaveAppConfig(appConfig); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to save config: %w", err)
+	}
+
+	saveRepoState(mgr, repo.Name, commit)
+	return commit, nil
+}
+
+// cloneRepo clones a repository and checks out its ref. Nothing is left
+// behind if it fails.
+func cloneRepo(mgr *config.Manager, repo *config.RepoConfig) (string, error) {
+	dir := mgr.RepoDir(repo.Name)
+	if err := os.MkdirAll(mgr.ReposDir(), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", mgr.ReposDir(), err)
+	}
+	if _, err := git("", "clone", "--quiet", "--", repo.URL, dir); err != nil {
+		return "", err
+	}
+
+	commit, err := checkoutRepo(dir, repo.Ref)
+	if err == nil {
+		if info, statErr := os.Stat(mgr.RepoContextsDir(repo)); statErr != nil || !info.IsDir() {
+			err = fmt.Errorf("directory '%s' not found in the repository", repo.Path)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return commit, nil
+}
+
+// checkoutRepo checks out ref in a clone, or the remote's default branch
+// without one. The commit is checked out detached, so a branch is followed
+// by checking out its remote branch again after fetching.
+func checkoutRepo(dir, ref string) (string, error) {
+	candidates := []string{"origin/HEAD"}
+	if ref != "" {
+		// A branch is taken from the remote, not the local copy made at clone
+		candidates = []string{"origin/" + ref, ref}
+	}
+
+	var commit string
+	for _, candidate := range candidates {
+		if out, err := git(dir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			commit = out
+			break
+		}
+	}
+	if commit == "" && ref == "" {
+		return "", fmt.Errorf("the repository has no default branch")
+	}
+	if commit == "" {
+		return "", fmt.Errorf("ref '%s' not found in the repository", ref)
+	}
+
+	if _, err := git(dir, "checkout", "--quiet", "--detach", commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// updateRepo fetches a context repository and checks out its ref, cloning
+// it if it's missing. It returns the commits checked out before and after;
+// before is empty for a new clone.
+func updateRepo(mgr *config.Manager, repo *config.RepoConfig) (before, after string, err error) {
+	dir := mgr.RepoDir(repo.Name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		after, err = cloneRepo(mgr, repo)
+	} else {
+		before, _ = git(dir, "rev-parse", "HEAD")
+		if _, err = git(dir, "fetch", "--quiet", "--prune", "--tags", "--force", "origin"); err == nil {
+			after, err = checkoutRepo(dir, repo.Ref)
+		}
+	}
+	if err != nil {
+		return before, "", err
+	}
+
+	saveRepoState(mgr, repo.Name, after)
+	return before, after, nil
+}
+
+func runRepoUpdate(names []string, newRef *string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	appConfig := mgr.GetAppConfig()
+	if appConfig == nil || len(appConfig.Repos) == 0 {
+		return fmt.Errorf("no context repositories. Add one with 'ctx repo add <name> <git-url>'")
+	}
+	for _, name := range names {
+		if mgr.Repo(name) == nil {
+			return fmt.Errorf("repository '%s' not found", name)
+		}
+	}
+
+	yellow := color.New(color.FgYellow)
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+
+	failed := 0
+	for i := range appConfig.Repos {
+		repo := &appConfig.Repos[i]
+		if len(names) > 0 && !slices.Contains(names, repo.Name) {
+			continue
+		}
+
+		updated := *repo
+		if newRef != nil {
+			updated.Ref = *newRef
+		}
+
+		yellow.Printf("• Updating %s... ", repo.Name)
+		before, after, err := updateRepo(mgr, &updated)
+		switch {
+		case err != nil:
+			red.Println("failed")
+			red.Fprintf(os.Stderr, "✗ %s: %v\n", repo.Name, err)
+			failed++
+			continue
+		case before == after:
+			green.Printf("up to date at %s\n", shortCommit(after))
+		case before == "":
+			green.Printf("cloned at %s\n", shortCommit(after))
+		default:
+			green.Printf("%s → %s\n", shortCommit(before), shortCommit(after))
+		}
+
+		if newRef != nil {
+			repo.Ref = *newRef
+			if err := mgr.SaveAppConfig(appConfig); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d repositories failed to update", failed)
+	}
+	return nil
+}
+
+func runRepoList(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	appConfig := mgr.GetAppConfig()
+	if appConfig == nil || len(appConfig.Repos) == 0 {
+		fmt.Println("No context repositories.")
+		fmt.Println("Add one with 'ctx repo add <name> <git-url>'.")
+		return nil
+	}
+
+	states := loadRepoStates(mgr)
+	contexts, _ := mgr.ListContexts()
+	red := color.New(color.FgRed)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "URL", "REF", "COMMIT", "CONTEXTS", "UPDATED"})
+	table.SetBorder(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetTablePadding("  ")
+	table.SetNoWhiteSpace(true)
+
+	for _, repo := range appConfig.Repos {
+		ref := repo.Ref
+		if ref == "" {
+			ref = "-"
+		}
+		if repo.Path != "" {
+			repo.URL += " (" + repo.Path + ")"
+		}
+
+		commit := red.Sprint("not cloned")
+		if head, err := git(mgr.RepoDir(repo.Name), "rev-parse", "HEAD"); err == nil {
+			commit = shortCommit(head)
+		}
+
+		count := 0
+		for _, ctx := range contexts {
+			if strings.HasPrefix(ctx, repo.Name+"/") {
+				count++
+			}
+		}
+
+		updated := "-"
+		if state, ok := states[repo.Name]; ok {
+			updated = formatAgo(state.UpdatedAt)
+		}
+
+		table.Append([]string{repo.Name, repo.URL, ref, commit, fmt.Sprint(count), updated})
+	}
+	table.Render()
+
+	return nil
+}
+
+func runRepoRemove(name string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	appConfig := mgr.GetAppConfig()
+	if mgr.Repo(name) == nil {
+		return fmt.Errorf("repository '%s' not found", name)
+	}
+
+	appConfig.Repos = slices.DeleteFunc(appConfig.Repos, func(r config.RepoConfig) bool { return r.Name == name })
+	if err := mgr.SaveAppConfig(appConfig); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := os.RemoveAll(mgr.RepoDir(name)); err != nil {
+		return fmt.Errorf("failed to remove clone: %w", err)
+	}
+
+	states := loadRepoStates(mgr)
+	delete(states, name)
+	if err := mgr.StateStore().SaveJSON(repoStateFile, states, 0o644); err != nil {
+		color.New(color.FgYellow).Fprintf(os.Stderr, "⚠ Failed to update repository state: %v\n", err)
+	}
+
+	color.New(color.FgGreen).Printf("✓ Removed repository '%s'\n", name)
+	return nil
+}
+
+// loadRepoStates returns the state of the cloned repositories, by name.
+func loadRepoStates(mgr *config.Manager) map[string]repoState {
+	states := make(map[string]repoState)
+	if err := mgr.StateStore().LoadJSON(repoStateFile, &states); err != nil {
+		return make(map[string]repoState)
+	}
+	return states
+}
+
+// saveRepoState records that a repository was updated to commit.
+func saveRepoState(mgr *config.Manager, name, commit string) {
+	states := loadRepoStates(mgr)
+	states[name] = repoState{Commit: commit, UpdatedAt: time.Now()}
+	if err := mgr.StateStore().SaveJSON(repoStateFile, states, 0o644); err != nil {
+		color.New(color.FgYellow).Fprintf(os.Stderr, "⚠ Failed to record repository state: %v\n", err)
+	}
+}
+
+// git runs git in dir and returns its output, trimmed. Errors carry git's
+// own message.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if msg := strings.TrimSpace(stderr.String()); errors.As(err, &exitErr) && msg != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// shortCommit abbreviates a commit hash for display.
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// testContextRepo is a git repository of contexts with a bare clone that
+// serves as its remote.
+type testContextRepo struct {
+	t    *testing.T
+	work string
+	URL  string
+}
+
+func newTestContextRepo(t *testing.T) *testContextRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "ctx")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ctx@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ctx")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ctx@example.com")
+
+	dir := t.TempDir()
+	r := &testContextRepo{t: t, work: filepath.Join(dir, "work"), URL: filepath.Join(dir, "contexts.git")}
+	r.git("", "init", "--quiet", "--bare", "--initial-branch=main", r.URL)
+	r.git("", "clone", "--quiet", r.URL, r.work)
+	r.git(r.work, "checkout", "--quiet", "-b", "main")
+	return r
+}
+
+func (r *testContextRepo) git(dir string, args ...string) string {
+	r.t.Helper()
+	out, err := git(dir, args...)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return out
+}
+
+// commit writes files to the repository and pushes them. It returns the
+// commit.
+func (r *testContextRepo) commit(files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git(r.work, "add", "-A")
+	r.git(r.work, "commit", "--quiet", "-m", "update contexts")
+	r.git(r.work, "push", "--quiet", "origin", "main")
+	return r.git(r.work, "rev-parse", "HEAD")
+}
+
+func TestAddAndUpdateRepo(t *testing.T) {
+	remote := newTestContextRepo(t)
+	remote.commit(map[string]string{
+		"contexts/base.yaml": "name: base\nabstract: true\naws:\n  region: eu-west-1\n",
+		"contexts/prod.yaml": "name: prod\nextends: base\nenvironment: production\naws:\n  profile: v1\n",
+		"README.md":          "Acme contexts\n",
+	})
+
+	mgr := config.NewManagerWithDir(t.TempDir())
+	repo := config.RepoConfig{Name: "acme", URL: remote.URL, Path: "contexts"}
+	if _, err := addRepo(mgr, repo); err != nil {
+		t.Fatalf("addRepo() error = %v", err)
+	}
+	if _, err := addRepo(mgr, repo); err == nil {
+		t.Error("addRepo() of an existing repository error = nil")
+	}
+
+	// The config is saved, and its contexts resolve
+	reloaded := config.NewManagerWithDir(mgr.ConfigDir())
+	prod, err := reloaded.LoadContext("acme/prod")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if prod.AWS.Profile != "v1" || prod.AWS.Region != "eu-west-1" {
+		t.Errorf("LoadContext() aws = %+v", prod.AWS)
+	}
+
+	after := remote.commit(map[string]string{"contexts/prod.yaml": "name: prod\nextends: base\naws:\n  profile: v2\n"})
+	before, updated, err := updateRepo(mgr, mgr.Repo("acme"))
+	if err != nil {
+		t.Fatalf("updateRepo() error = %v", err)
+	}
+	if before == updated || updated != after {
+		t.Errorf("updateRepo() = %s, %s, want the new commit %s", before, updated, after)
+	}
+	if prod, _ := mgr.LoadContext("acme/prod"); prod == nil || prod.AWS.Profile != "v2" {
+		t.Errorf("LoadContext() after update = %+v, want profile v2", prod)
+	}
+
+	// A clone missing on this machine is cloned again
+	if err := os.RemoveAll(mgr.RepoDir("acme")); err != nil {
+		t.Fatal(err)
+	}
+	if before, _, err := updateRepo(mgr, mgr.Repo("acme")); err != nil || before != "" {
+		t.Errorf("updateRepo() of a missing clone = %q, %v", before, err)
+	}
+}
+
+func TestRepoPinning(t *testing.T) {
+	remote := newTestContextRepo(t)
+	pinned := remote.commit(map[string]string{"dev.yaml": "name: dev\nenv:\n  RELEASE: one\n"})
+	remote.git(remote.work, "tag", "v1")
+	remote.git(remote.work, "push", "--quiet", "origin", "v1")
+
+	tests := []struct {
+		name string
+		ref  string
+	}{
+		{name: "commit", ref: pinned},
+		{name: "tag", ref: "v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := config.NewManagerWithDir(t.TempDir())
+			if _, err := addRepo(mgr, config.RepoConfig{Name: "acme", URL: remote.URL, Ref: tt.ref}); err != nil {
+				t.Fatalf("addRepo() error = %v", err)
+			}
+
+			// Newer commits aren't checked out while pinned
+			remote.commit(map[string]string{"dev.yaml": "name: dev\nenv:\n  RELEASE: newer" + tt.name + "\n"})
+			if _, commit, err := updateRepo(mgr, mgr.Repo("acme")); err != nil || commit != pinned {
+				t.Fatalf("updateRepo() = %s, %v, want pinned commit %s", commit, err, pinned)
+			}
+			if dev, _ := mgr.LoadContext("acme/dev"); dev == nil || dev.Env["RELEASE"] != "one" {
+				t.Errorf("LoadContext() of pinned repository = %+v", dev)
+			}
+
+			// Unpinned, the default branch is followed
+			unpinned := *mgr.Repo("acme")
+			unpinned.Ref = ""
+			if _, _, err := updateRepo(mgr, &unpinned); err != nil {
+				t.Fatalf("updateRepo() unpinned error = %v", err)
+			}
+			if dev, _ := mgr.LoadContext("acme/dev"); dev == nil || dev.Env["RELEASE"] != "newer"+tt.name {
+				t.Errorf("LoadContext() of unpinned repository = %+v", dev)
+			}
+		})
+	}
+}
+
+func TestAddRepoErrors(t *testing.T) {
+	remote := newTestContextRepo(t)
+	remote.commit(map[string]string{"dev.yaml": "name: dev\n"})
+
+	tests := []struct {
+		name string
+		repo config.RepoConfig
+	}{
+		{name: "invalid name", repo: config.RepoConfig{Name: "acme/eu", URL: remote.URL}},
+		{name: "unknown ref", repo: config.RepoConfig{Name: "acme", URL: remote.URL, Ref: "v9"}},
+		{name: "missing path", repo: config.RepoConfig{Name: "acme", URL: remote.URL, Path: "contexts"}},
+		{name: "path outside the repository", repo: config.RepoConfig{Name: "acme", URL: remote.URL, Path: "../other"}},
+		{name: "unreachable URL", repo: config.RepoConfig{Name: "acme", URL: filepath.Join(t.TempDir(), "missing.git")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := config.NewManagerWithDir(t.TempDir())
+			if _, err := addRepo(mgr, tt.repo); err == nil {
+				t.Fatal("addRepo() error = nil")
+			}
+			if mgr.Repo(tt.repo.Name) != nil {
+				t.Error("addRepo() saved the repository after failing")
+			}
+			if _, err := os.Stat(mgr.RepoDir(tt.repo.Name)); !os.IsNotExist(err) {
+				t.Error("addRepo() left the clone behind after failing")
+			}
+		})
+	}
+}
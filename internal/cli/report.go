@@ -0,0 +1,328 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/config"
+)
+
+var (
+	reportFromFlag    string
+	reportToFlag      string
+	reportGroupByFlag string
+	reportOutputFlag  string
+)
+
+func newReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report time spent in contexts",
+		Long: `Report the time spent in contexts, from the intervals ctx tracks locally.
+
+Time counts from 'ctx use' until 'ctx deactivate', a switch to another context
+or the shell exiting. With the shell hook installed, time a prompt sat idle
+for longer than the idle timeout (default 15 minutes) doesn't count. Time in
+the same context in several shells at once counts once.
+
+Group by context, environment or tag. With tag:<key>, tags like client:acme
+are grouped by their value, so every client gets one line.
+
+Examples:
+  ctx report                                        # This month, by context
+  ctx report --from 2026-09-01 --to 2026-09-30 --group-by tag:client
+  ctx report --group-by environment -o csv > hours.csv`,
+		Args: cobra.NoArgs,
+		RunE: runReport,
+	}
+
+	cmd.Flags().StringVar(&reportFromFlag, "from", "", "Start date, YYYY-MM-DD or RFC 3339 (default: start of this month)")
+	cmd.Flags().StringVar(&reportToFlag, "to", "", "End date, inclusive, YYYY-MM-DD or RFC 3339 (default: now)")
+	cmd.Flags().StringVarP(&reportGroupByFlag, "group-by", "g", "context", "Group by context, environment, tag or tag:<key>")
+	cmd.Flags().StringVarP(&reportOutputFlag, "output", "o", "table", "Output format: table, csv or json")
+
+	return cmd
+}
+
+func newTrackCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "track",
+		Short:  "Record time tracking events from the shell hook",
+		Hidden: true,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "idle <from> <to>",
+		Short: "Don't count the time between two Unix timestamps",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runTrackIdle,
+	})
+
+	return cmd
+}
+
+// reportRow is the time tracked for one group.
+type reportRow struct {
+	Group    string  `json:"group"`
+	Seconds  int64   `json:"seconds"`
+	Hours    float64 `json:"hours"`
+	duration time.Duration
+}
+
+// report is the JSON form of 'ctx report'.
+type report struct {
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	GroupBy      string       `json:"group_by"`
+	Groups       []*reportRow `json:"groups"`
+	TotalSeconds int64        `json:"total_seconds"`
+	TotalHours   float64      `json:"total_hours"`
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	if !slices.Contains([]string{"table", "csv", "json"}, reportOutputFlag) {
+		return fmt.Errorf("invalid output format %q (use table, csv or json)", reportOutputFlag)
+	}
+	if !validReportGroupBy(reportGroupByFlag) {
+		return fmt.Errorf("invalid --group-by %q (use context, environment, tag or tag:<key>)", reportGroupByFlag)
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := now
+	var err error
+	if reportFromFlag != "" {
+		if from, err = parseReportTime(reportFromFlag, false); err != nil {
+			return err
+		}
+	}
+	if reportToFlag != "" {
+		if to, err = parseReportTime(reportToFlag, true); err != nil {
+			return err
+		}
+	}
+	if !to.After(from) {
+		return fmt.Errorf("--to must be after --from")
+	}
+
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	entries, err := mgr.LoadTimeEntries(from, to)
+	if err != nil {
+		return err
+	}
+
+	rows, total := groupTimeEntries(entries, reportGroupByFlag)
+
+	switch reportOutputFlag {
+	case "json":
+		return writeReportJSON(os.Stdout, &report{
+			From:         from,
+			To:           to,
+			GroupBy:      reportGroupByFlag,
+			Groups:       rows,
+			TotalSeconds: int64(total.Seconds()),
+			TotalHours:   roundHours(total),
+		})
+	case "csv":
+		return writeReportCSV(os.Stdout, reportGroupByFlag, rows)
+	}
+
+	if len(rows) == 0 {
+		fmt.Printf("No time tracked from %s to %s.\n", from.Format(time.DateOnly), to.Format(time.DateOnly))
+		return nil
+	}
+
+	fmt.Printf("Time from %s to %s:\n\n", from.Format(time.DateTime), to.Format(time.DateTime))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	column := strings.TrimPrefix(reportGroupByFlag, "tag:")
+	table.SetHeader([]string{strings.ToUpper(column), "TIME", "HOURS"})
+	table.SetBorder(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetTablePadding("  ")
+	table.SetNoWhiteSpace(true)
+
+	for _, row := range rows {
+		table.Append([]string{row.Group, formatTrackedDuration(row.duration), strconv.FormatFloat(row.Hours, 'f', 2, 64)})
+	}
+	table.Render()
+
+	fmt.Printf("\nTotal: %s (%.2f hours)\n", formatTrackedDuration(total), roundHours(total))
+	return nil
+}
+
+func runTrackIdle(cmd *cobra.Command, args []string) error {
+	var times [2]time.Time
+	for i, arg := range args {
+		secs, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", arg)
+		}
+		times[i] = time.Unix(secs, 0)
+	}
+
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+	return mgr.MarkIdle(times[0], times[1])
+}
+
+// parseReportTime parses a report boundary. A date on its own means the
+// start of that day, or for the end of the range, the end of it.
+func parseReportTime(s string, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD or RFC 3339)", s)
+}
+
+func validReportGroupBy(groupBy string) bool {
+	switch groupBy {
+	case "context", "environment", "tag":
+		return true
+	}
+	key, ok := strings.CutPrefix(groupBy, "tag:")
+	return ok && key != ""
+}
+
+// reportGroups returns the groups an entry's time counts towards. With tag
+// grouping, an entry counts towards each of its tags.
+func reportGroups(entry config.TimeEntry, groupBy string) []string {
+	switch groupBy {
+	case "context":
+		return []string{entry.Context}
+	case "environment":
+		if entry.Environment == "" {
+			return []string{"-"}
+		}
+		return []string{entry.Environment}
+	case "tag":
+		if len(entry.Tags) == 0 {
+			return []string{"(untagged)"}
+		}
+		return entry.Tags
+	}
+
+	key := strings.TrimPrefix(groupBy, "tag:")
+	var groups []string
+	for _, tag := range entry.Tags {
+		if value, ok := strings.CutPrefix(tag, key+":"); ok {
+			groups = append(groups, value)
+		}
+	}
+	if len(groups) == 0 {
+		return []string{"(none)"}
+	}
+	return groups
+}
+
+// groupTimeEntries sums the tracked time per group, longest first, and
+// returns the total. Overlapping intervals, from several shells in the same
+// context, count once.
+func groupTimeEntries(entries []config.TimeEntry, groupBy string) ([]*reportRow, time.Duration) {
+	byGroup := make(map[string][]config.TimeEntry)
+	for _, entry := range entries {
+		for _, group := range reportGroups(entry, groupBy) {
+			byGroup[group] = append(byGroup[group], entry)
+		}
+	}
+
+	var rows []*reportRow
+	for group, groupEntries := range byGroup {
+		d := unionDuration(groupEntries)
+		rows = append(rows, &reportRow{
+			Group:    group,
+			Seconds:  int64(d.Seconds()),
+			Hours:    roundHours(d),
+			duration: d,
+		})
+	}
+
+	slices.SortFunc(rows, func(a, b *reportRow) int {
+		if c := cmp.Compare(b.duration, a.duration); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Group, b.Group)
+	})
+	return rows, unionDuration(entries)
+}
+
+// unionDuration returns the time covered by the entries, counting
+// overlapping intervals once.
+func unionDuration(entries []config.TimeEntry) time.Duration {
+	sorted := slices.Clone(entries)
+	slices.SortFunc(sorted, func(a, b config.TimeEntry) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	var total time.Duration
+	var start, end time.Time
+	for _, entry := range sorted {
+		if entry.Start.After(end) {
+			total += end.Sub(start)
+			start, end = entry.Start, entry.End
+		} else if entry.End.After(end) {
+			end = entry.End
+		}
+	}
+	return total + end.Sub(start)
+}
+
+func roundHours(d time.Duration) float64 {
+	return math.Round(d.Hours()*100) / 100
+}
+
+// formatTrackedDuration formats d as hours and minutes, e.g. "12h 05m".
+func formatTrackedDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func writeReportJSON(w io.Writer, r *report) error {
+	if r.Groups == nil {
+		r.Groups = []*reportRow{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func writeReportCSV(w io.Writer, groupBy string, rows []*reportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{groupBy, "hours", "seconds"})
+	for _, row := range rows {
+		cw.Write([]string{row.Group, strconv.FormatFloat(row.Hours, 'f', 2, 64), strconv.FormatInt(row.Seconds, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
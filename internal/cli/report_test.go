@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+func TestGroupTimeEntries(t *testing.T) {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(h float64) time.Time { return base.Add(time.Duration(h * float64(time.Hour))) }
+
+	entries := []config.TimeEntry{
+		// Two shells in acme-dev at the same time count once: 9:00-12:00
+		{Context: "acme-dev", Environment: "development", Tags: []string{"client:acme", "eks"}, Start: at(0), End: at(2)},
+		{Context: "acme-dev", Environment: "development", Tags: []string{"client:acme", "eks"}, Start: at(1), End: at(3)},
+		{Context: "acme-prod", Environment: "production", Tags: []string{"client:acme"}, Start: at(4), End: at(5)},
+		{Context: "globex", Environment: "production", Tags: []string{"client:globex", "eks"}, Start: at(6), End: at(6.5)},
+		{Context: "internal", Environment: "development", Start: at(7), End: at(7.25)},
+	}
+
+	tests := []struct {
+		groupBy string
+		want    map[string]time.Duration
+		order   []string
+	}{
+		{
+			groupBy: "context",
+			want:    map[string]time.Duration{"acme-dev": 3 * time.Hour, "acme-prod": time.Hour, "globex": 30 * time.Minute, "internal": 15 * time.Minute},
+			order:   []string{"acme-dev", "acme-prod", "globex", "internal"},
+		},
+		{
+			groupBy: "environment",
+			want:    map[string]time.Duration{"development": 3*time.Hour + 15*time.Minute, "production": 90 * time.Minute},
+			order:   []string{"development", "production"},
+		},
+		{
+			groupBy: "tag:client",
+			want:    map[string]time.Duration{"acme": 4 * time.Hour, "globex": 30 * time.Minute, "(none)": 15 * time.Minute},
+			order:   []string{"acme", "globex", "(none)"},
+		},
+		{
+			groupBy: "tag",
+			want:    map[string]time.Duration{"client:acme": 4 * time.Hour, "eks": 3*time.Hour + 30*time.Minute, "client:globex": 30 * time.Minute, "(untagged)": 15 * time.Minute},
+			order:   []string{"client:acme", "eks", "client:globex", "(untagged)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			rows, total := groupTimeEntries(entries, tt.groupBy)
+			if total != 4*time.Hour+45*time.Minute {
+				t.Errorf("total = %v, want 4h45m", total)
+			}
+			if len(rows) != len(tt.order) {
+				t.Fatalf("got %d groups, want %d", len(rows), len(tt.order))
+			}
+			for i, row := range rows {
+				if row.Group != tt.order[i] {
+					t.Errorf("rows[%d] = %s, want %s", i, row.Group, tt.order[i])
+				}
+				if row.duration != tt.want[row.Group] {
+					t.Errorf("%s = %v, want %v", row.Group, row.duration, tt.want[row.Group])
+				}
+			}
+		})
+	}
+}
+
+func TestParseReportTime(t *testing.T) {
+	from, err := parseReportTime("2026-03-01", false)
+	if err != nil || !from.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("parseReportTime(from) = %v, %v", from, err)
+	}
+
+	// The end date is inclusive
+	to, err := parseReportTime("2026-03-31", true)
+	if err != nil || !to.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("parseReportTime(to) = %v, %v", to, err)
+	}
+
+	exact, err := parseReportTime("2026-03-01T12:30:00Z", true)
+	if err != nil || !exact.Equal(time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("parseReportTime(RFC 3339) = %v, %v", exact, err)
+	}
+
+	if _, err := parseReportTime("March", false); err == nil {
+		t.Error("parseReportTime() expected error")
+	}
+}
+
+func TestValidReportGroupBy(t *testing.T) {
+	for groupBy, want := range map[string]bool{
+		"context": true, "environment": true, "tag": true, "tag:client": true,
+		"tag:": false, "client": false, "": false,
+	} {
+		if got := validReportGroupBy(groupBy); got != want {
+			t.Errorf("validReportGroupBy(%q) = %v, want %v", groupBy, got, want)
+		}
+	}
+}
+
+func TestWriteReportCSV(t *testing.T) {
+	rows := []*reportRow{
+		{Group: "acme, inc", Seconds: 5400, Hours: 1.5},
+		{Group: "globex", Seconds: 900, Hours: 0.25},
+	}
+
+	var buf bytes.Buffer
+	if err := writeReportCSV(&buf, "tag:client", rows); err != nil {
+		t.Fatalf("writeReportCSV() error = %v", err)
+	}
+
+	want := "tag:client,hours,seconds\n\"acme, inc\",1.50,5400\nglobex,0.25,900\n"
+	if buf.String() != want {
+		t.Errorf("writeReportCSV() = %q, want %q", buf.String(), want)
+	}
+}
@@ -5,6 +5,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -43,6 +44,16 @@ forwarding for tunnel access to remote services.`,
 	rootCmd.AddCommand(newShowCmd())
 	rootCmd.AddCommand(newUseCmd())
 	rootCmd.AddCommand(newDeactivateCmd())
+	rootCmd.AddCommand(newSessionsCmd())
+	rootCmd.AddCommand(newDefaultCmd())
+	rootCmd.AddCommand(newHistoryCmd())
+	rootCmd.AddCommand(newReportCmd())
+	rootCmd.AddCommand(newTrackCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newLintCmd())
+	rootCmd.AddCommand(newExecCmd())
+	rootCmd.AddCommand(newShellCmd())
+	rootCmd.AddCommand(newDirCmd())
 	rootCmd.AddCommand(newLogoutCmd())
 	rootCmd.AddCommand(newTunnelCmd())
 	rootCmd.AddCommand(newVPNCmd())
@@ -51,7 +62,9 @@ forwarding for tunnel access to remote services.`,
 	rootCmd.AddCommand(newEditCmd())
 	rootCmd.AddCommand(newInitCmd())
 	rootCmd.AddCommand(newShellHookCmd())
+	rootCmd.AddCommand(newEnvSnapshotCmd())
 	rootCmd.AddCommand(newCloudCmd())
+	rootCmd.AddCommand(newRepoCmd())
 
 	return rootCmd
 }
@@ -62,6 +75,10 @@ func Execute() {
 	// Update version after it's been set by main.go
 	rootCmd.Version = Version
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -121,11 +121,6 @@ func resolveSecretFiles(cfg *config.SecretsConfig, mgr *config.Manager, contextN
 		result.FileCount++
 	}
 
-	// Save state for cleanup on deactivate
-	if err := mgr.SaveSecretFilesState(result.State); err != nil {
-		yellow.Fprintf(os.Stderr, "⚠ Failed to save secret files state: %v\n", err)
-	}
-
 	green.Fprintf(os.Stderr, "✓ Wrote %d secret file(s) to %s\n", result.FileCount, tmpDir)
 
 	return result, nil
@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func newSessionsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sessions",
+		Short: "List shells with an active context",
+		Long: `List the live shell sessions and the context each one is using.
+
+Every shell with the ctx shell hook loaded gets its own session, so switching
+context in one terminal does not affect the others. The current shell is
+marked with *.`,
+		Args: cobra.NoArgs,
+		RunE: runSessions,
+	}
+}
+
+func runSessions(cmd *cobra.Command, args []string) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	// Drop shells that exited without deactivating
+	if _, err := mgr.ReapStaleSessions(); err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(os.Stderr, "⚠ Failed to reap stale sessions: %v\n", err)
+	}
+
+	sessions, err := mgr.ListSessions()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"", "SESSION", "PID", "CONTEXT", "ENVIRONMENT", "ACTIVE SINCE"})
+	table.SetBorder(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetTablePadding("  ")
+	table.SetNoWhiteSpace(true)
+
+	shownCount := 0
+	for _, session := range sessions {
+		if !session.IsAlive() {
+			continue
+		}
+
+		marker := " "
+		if session.ID == mgr.SessionID() {
+			marker = "*"
+		}
+
+		env := session.Environment
+		if env == "" {
+			env = "-"
+		}
+
+		table.Append([]string{
+			marker,
+			session.ID,
+			strconv.Itoa(session.PID),
+			session.ContextName,
+			env,
+			session.UpdatedAt.Format(time.DateTime),
+		})
+		shownCount++
+	}
+
+	if shownCount == 0 {
+		fmt.Println("No active sessions.")
+		return nil
+	}
+
+	table.Render()
+	return nil
+}
+
+func newDefaultCmd() *cobra.Command {
+	var unsetFlag bool
+
+	cmd := &cobra.Command{
+		Use:   "default [name]",
+		Short: "Show or set the context new shells start in",
+		Long: `Show or set the default context for new shells.
+
+When a default is set, the shell hook activates it in every new shell that
+doesn't already have a context. Without a default, new shells start fresh.
+
+Examples:
+  ctx default                # Show the default context
+  ctx default myproject-dev  # Start new shells in myproject-dev
+  ctx default --unset        # Start new shells without a context`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDefault(args, unsetFlag)
+		},
+	}
+
+	cmd.Flags().BoolVar(&unsetFlag, "unset", false, "Clear the default context")
+
+	return cmd
+}
+
+func runDefault(args []string, unset bool) error {
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	appConfig, err := mgr.LoadAppConfig()
+	if err != nil {
+		return err
+	}
+
+	// Show the current default. The bare name goes to stdout so the shell
+	// hook can read it.
+	if len(args) == 0 && !unset {
+		if appConfig.DefaultContext == "" {
+			fmt.Fprintln(os.Stderr, "No default context set.")
+			return nil
+		}
+		fmt.Println(appConfig.DefaultContext)
+		return nil
+	}
+
+	green := color.New(color.FgGreen)
+
+	if unset {
+		appConfig.DefaultContext = ""
+		if err := mgr.SaveAppConfig(appConfig); err != nil {
+			return err
+		}
+		green.Println("✓ Default context cleared. New shells will start without a context.")
+		return nil
+	}
+
+	name := args[0]
+	ctx, err := mgr.LoadContext(name)
+	if err != nil {
+		return fmt.Errorf("failed to load context: %w", err)
+	}
+	if ctx.Abstract {
+		return fmt.Errorf("context '%s' is abstract (a base template) and cannot be used as the default", name)
+	}
+
+	appConfig.DefaultContext = name
+	if err := mgr.SaveAppConfig(appConfig); err != nil {
+		return err
+	}
+	green.Printf("✓ New shells will start in '%s'.\n", name)
+	return nil
+}
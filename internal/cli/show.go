@@ -4,38 +4,194 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
 
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/vlebo/ctx/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	showFormatFlag   string
+	showSourceFlag   bool
+	showExplainFlag  bool
+	showResolvedFlag bool
 )
 
 func newShowCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "show <name>",
 		Short: "Show context configuration details",
-		Long:  "Display detailed configuration for a specific context.",
-		Args:  cobra.ExactArgs(1),
-		RunE:  runShow,
+		Long: `Display detailed configuration for a specific context.
+
+With --source, the file the context is loaded from is shown first, with the
+files of the same name it shadows in later context paths.
+
+With --explain, every field of the merged configuration is listed with the
+context its value comes from, the file and line that set it, and the env
+variables expanded in it, after the order the context and the contexts it
+extends are merged in.
+
+With --resolved, the merged configuration is printed as YAML or JSON, with
+variables expanded, as ctx uses it.
+
+Examples:
+  ctx show acme/prod --explain
+  ctx show acme/prod --resolved
+  ctx show acme/prod --resolved --format json | jq .aws`,
+		Args: cobra.ExactArgs(1),
+		RunE: runShow,
 	}
 
+	cmd.Flags().BoolVar(&showSourceFlag, "source", false, "Show the file the context is loaded from")
+	cmd.Flags().BoolVar(&showExplainFlag, "explain", false, "Show where each value comes from")
+	cmd.Flags().BoolVar(&showResolvedFlag, "resolved", false, "Print the merged configuration")
+	cmd.Flags().StringVar(&showFormatFlag, "format", "yaml", "Format of --resolved: yaml or json")
+
 	return cmd
 }
 
 func runShow(cmd *cobra.Command, args []string) error {
 	contextName := args[0]
 
+	if !slices.Contains([]string{"yaml", "json"}, showFormatFlag) {
+		return fmt.Errorf("invalid format %q (use yaml or json)", showFormatFlag)
+	}
+	if cmd.Flags().Changed("format") && !showResolvedFlag {
+		return fmt.Errorf("--format requires --resolved")
+	}
+	if showExplainFlag && showResolvedFlag {
+		return fmt.Errorf("--explain and --resolved can't be used together")
+	}
+
 	mgr, err := GetConfigManager()
 	if err != nil {
 		return err
 	}
 
+	if showSourceFlag {
+		fmt.Print(formatContextSource(mgr.ContextSource(contextName)))
+	}
+
+	if showExplainFlag {
+		return showExplanation(mgr, contextName)
+	}
+
 	ctx, err := mgr.LoadContext(contextName)
 	if err != nil {
 		return fmt.Errorf("failed to load context: %w", err)
 	}
-
+	if showResolvedFlag {
+		return printResolvedContext(ctx, showFormatFlag)
+	}
 	fmt.Print(config.FormatContextDetails(ctx))
 
 	return nil
 }
+
+// printResolvedContext prints a merged context as YAML or JSON.
+func printResolvedContext(ctx *config.ContextConfig, format string) error {
+	data, err := yaml.Marshal(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal context: %w", err)
+	}
+	if format == "yaml" {
+		fmt.Print(string(data))
+		return nil
+	}
+
+	// The config types only have YAML keys, so JSON goes through a map
+	var fields map[string]any
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to unmarshal context: %w", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fields)
+}
+
+// showExplanation prints the merged fields of a context with where each
+// value comes from.
+func showExplanation(mgr *config.Manager, name string) error {
+	ctx, explanation, err := mgr.ExplainContext(name)
+	if err != nil {
+		return fmt.Errorf("failed to load context: %w", err)
+	}
+	fields, err := config.FlattenContextByName(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Merge order: %s\n\n", strings.Join(explanation.Order, " -> "))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"FIELD", "VALUE", "FROM", "FILE", "EXPANDS"})
+	table.SetBorder(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetTablePadding("  ")
+	table.SetNoWhiteSpace(true)
+	table.SetAutoWrapText(false)
+
+	for _, path := range slices.Sorted(maps.Keys(fields)) {
+		source, ok := explanation.Sources[path]
+		// Fields no context sets are left at their defaults
+		if !ok {
+			continue
+		}
+		table.Append([]string{path, fields[path], source.Context, formatFieldLocation(source), formatExpandedVars(source, explanation)})
+	}
+	table.Render()
+
+	return nil
+}
+
+// formatFieldLocation returns the file and line a field is set at.
+func formatFieldLocation(source config.FieldSource) string {
+	switch {
+	case source.File == "":
+		return "-"
+	case source.Line == 0:
+		return source.File
+	}
+	return fmt.Sprintf("%s:%d", source.File, source.Line)
+}
+
+// formatExpandedVars lists the variables expanded in a field, with the
+// context each comes from.
+func formatExpandedVars(source config.FieldSource, explanation *config.Explanation) string {
+	if len(source.Vars) == 0 {
+		return "-"
+	}
+	vars := make([]string, 0, len(source.Vars))
+	for _, key := range source.Vars {
+		if from := explanation.Sources["env."+key].Context; from != "" {
+			vars = append(vars, fmt.Sprintf("${%s} from %s", key, from))
+		} else {
+			vars = append(vars, "${"+key+"}")
+		}
+	}
+	return strings.Join(vars, ", ")
+}
+
+// formatContextSource describes the file a context is loaded from and the
+// files it shadows.
+func formatContextSource(source config.ContextSource) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Source: %s (%s)\n", source.Path, source)
+	for _, path := range source.Shadowed {
+		fmt.Fprintf(&b, "  Shadows: %s\n", path)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
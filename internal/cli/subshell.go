@@ -0,0 +1,233 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vlebo/ctx/internal/config"
+	"github.com/vlebo/ctx/internal/shell"
+)
+
+var (
+	shellConfirmFlag bool
+	shellHistoryFlag bool
+	shellReasonFlag  string
+)
+
+func newShellCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shell <name>",
+		Short: "Start a subshell pinned to a context",
+		Long: `Start $SHELL with a context activated, leaving the current shell untouched.
+
+The subshell gets the context's env vars and secrets and shows the context in
+its prompt. It is a session of its own, so VPN connections and tunnels it
+starts are shared with other shells like with 'ctx use'. When the subshell
+exits, ctx deactivates it: secret files are removed and the VPN and tunnels
+are released unless another shell still uses them.
+
+This works without the shell hook installed.
+
+Examples:
+  ctx shell myproject-dev
+  ctx shell myproject-prod --history   # Keep a separate history for this context`,
+		Args: cobra.ExactArgs(1),
+		RunE: runShell,
+	}
+
+	cmd.Flags().BoolVar(&shellConfirmFlag, "confirm", false, "Confirm switching to production environment")
+	cmd.Flags().BoolVar(&shellHistoryFlag, "history", false, "Use a per-context shell history file")
+	cmd.Flags().StringVar(&shellReasonFlag, "reason", "", "Reason for using the context, recorded in the audit log")
+
+	return cmd
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	contextName := args[0]
+
+	mgr, err := GetConfigManager()
+	if err != nil {
+		return err
+	}
+
+	ctx, err := mgr.LoadContext(contextName)
+	if err != nil {
+		return fmt.Errorf("failed to load context: %w", err)
+	}
+
+	if ctx.Abstract {
+		return fmt.Errorf("context '%s' is abstract (a base template) and cannot be used directly. Create a context that extends it", contextName)
+	}
+
+	if err := config.ValidateContext(ctx); err != nil {
+		return fmt.Errorf("invalid context configuration: %w", err)
+	}
+
+	if _, err := enforcePolicies(mgr, ctx, shellReasonFlag); err != nil {
+		return err
+	}
+
+	if ctx.IsProd() && !shellConfirmFlag {
+		if !confirmProductionSwitch(ctx) {
+			return fmt.Errorf("aborted: production switch not confirmed")
+		}
+	}
+
+	// Roll back the current shell's context before switching sessions, while
+	// its env file can still be found
+	env := baseEnviron(mgr)
+
+	// The subshell is a session of its own, kept alive by this process
+	sessionID := fmt.Sprintf("%d-shell", os.Getpid())
+	if err := mgr.SetSessionID(sessionID, os.Getpid()); err != nil {
+		return err
+	}
+
+	failures, err := switchContext(mgr, ctx)
+	if err != nil {
+		return err
+	}
+	defer endShellSession(mgr)
+
+	ctxEnv, err := readEnvFile(mgr.CurrentEnvPath())
+	if err != nil {
+		return fmt.Errorf("failed to read environment file: %w", err)
+	}
+
+	// Remember what the context overrides, so 'ctx deactivate' in the
+	// subshell restores it like in a hooked shell
+	snap := shell.EnvSnapshot{}
+	snap.Capture(slices.Collect(maps.Keys(ctxEnv)), func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+	encoded, err := snap.Encode()
+	if err != nil {
+		return err
+	}
+
+	maps.Copy(env, ctxEnv)
+	env[shell.SnapshotEnvVar] = encoded
+	env[config.SessionIDEnvVar] = sessionID
+	env[config.SessionPIDEnvVar] = strconv.Itoa(os.Getpid())
+	env[shell.SubshellEnvVar] = sessionID
+
+	shellPath := os.Getenv("SHELL")
+	if shellPath == "" {
+		shellPath = "/bin/sh"
+	}
+
+	dir, err := os.MkdirTemp("", "ctx-shell-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	subshellCfg, err := newSubshellConfig(mgr, ctx, shellPath)
+	if err != nil {
+		return err
+	}
+	shellArgs, shellEnv, err := shell.PrepareSubshell(subshellCfg, dir)
+	if err != nil {
+		return err
+	}
+	maps.Copy(env, shellEnv)
+
+	printSwitchSuccess(ctx, failures)
+	fmt.Fprintf(os.Stderr, "Starting %s in '%s'. Exit the shell to deactivate.\n", filepath.Base(shellPath), ctx.Name)
+
+	code, err := runCommandWithEnv(append([]string{shellPath}, shellArgs...), env)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &exitCodeError{code: code}
+	}
+	return nil
+}
+
+// newSubshellConfig returns the prompt marker and history settings for a subshell.
+func newSubshellConfig(mgr *config.Manager, ctx *config.ContextConfig, shellPath string) (shell.SubshellConfig, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return shell.SubshellConfig{}, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	format := "[ctx: {{.Name}}{{if .IsProd}} ⚠️{{end}}]"
+	if appConfig := mgr.GetAppConfig(); appConfig != nil && appConfig.PromptFormat != "" {
+		format = appConfig.PromptFormat
+	}
+	marker, err := shell.FormatPrompt(format, ctx)
+	if err != nil {
+		return shell.SubshellConfig{}, fmt.Errorf("failed to format prompt: %w", err)
+	}
+
+	cfg := shell.SubshellConfig{Path: shellPath, Marker: marker, Home: home}
+	if shellHistoryFlag {
+		historyDir := filepath.Join(mgr.StateDir(), "history")
+		if err := os.MkdirAll(historyDir, 0o700); err != nil {
+			return shell.SubshellConfig{}, fmt.Errorf("failed to create history directory: %w", err)
+		}
+		cfg.HistFile = filepath.Join(historyDir, config.ContextFileName(ctx.Name)+"."+filepath.Base(shellPath)+"_history")
+		cfg.HistName = shell.FishHistoryName(ctx.Name)
+	}
+	return cfg, nil
+}
+
+// endShellSession deactivates whatever context the subshell's session ended
+// in. It may have switched context, or already deactivated.
+func endShellSession(mgr *config.Manager) {
+	name, _ := mgr.GetCurrentContextName()
+	if name == "" {
+		return
+	}
+
+	ctx, err := mgr.LoadContext(name)
+	if err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(os.Stderr, "⚠ Failed to load context '%s' for cleanup: %v\n", name, err)
+		mgr.ClearCurrentContext()
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	deactivateSession(mgr, ctx, false)
+
+	green := color.New(color.FgGreen)
+	fmt.Fprintln(os.Stderr)
+	green.Fprintf(os.Stderr, "✓ Context '%s' deactivated.\n", name)
+}
+
+// readEnvFile parses the export lines of an env file written by ctx.
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimPrefix(scanner.Text(), "export "), "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
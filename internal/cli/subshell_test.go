@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "current.env")
+	content := "export PLAIN=\"value\"\nexport QUOTED=\"a \\\"b\\\" c=d\"\n\nnot a var\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := readEnvFile(path)
+	if err != nil {
+		t.Fatalf("readEnvFile() error = %v", err)
+	}
+	if vars["PLAIN"] != "value" || vars["QUOTED"] != `a "b" c=d` || len(vars) != 2 {
+		t.Errorf("readEnvFile() = %v", vars)
+	}
+}
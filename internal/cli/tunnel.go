@@ -4,7 +4,6 @@
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
 	"net"
 	"os"
@@ -170,15 +169,21 @@ func runTunnelUp(cmd *cobra.Command, args []string) error {
 		tunnelsToStart = ctx.Tunnels
 	}
 
-	// Get state directory
-	stateDir := filepath.Join(mgr.StateDir(), "tunnels")
-	if err := os.MkdirAll(stateDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create state directory: %w", err)
+	logDir := mgr.TunnelsDir()
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create tunnels directory: %w", err)
 	}
 
+	// Hold the state lock until we're done so concurrent activations don't
+	// clobber each other's tunnel PIDs
+	lock, err := mgr.StateStore().Lock(tunnelStateFile(ctx.Name))
+	if err != nil {
+		return fmt.Errorf("failed to lock tunnel state: %w", err)
+	}
+	defer lock.Unlock()
+
 	// Load existing state
-	stateFile := filepath.Join(stateDir, ctx.Name+".json")
-	state, _ := loadTunnelState(stateFile)
+	state, _ := loadTunnelState(lock)
 	if state == nil {
 		state = &tunnelState{
 			ContextName: ctx.Name,
@@ -242,7 +247,7 @@ func runTunnelUp(cmd *cobra.Command, args []string) error {
 		}
 
 		// Redirect output to log file
-		logFile := filepath.Join(stateDir, fmt.Sprintf("%s-%s.log", ctx.Name, t.Name))
+		logFile := filepath.Join(logDir, fmt.Sprintf("%s-%s.log", config.ContextFileName(ctx.Name), t.Name))
 		logFd, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 		if err != nil {
 			yellow.Printf("⚠ %s: failed to create log file: %v\n", t.Name, err)
@@ -299,7 +304,7 @@ func runTunnelUp(cmd *cobra.Command, args []string) error {
 	}
 
 	// Save state
-	if err := saveTunnelState(stateFile, state); err != nil {
+	if err := saveTunnelState(lock, state); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to save state: %v\n", err)
 	}
 
@@ -377,24 +382,21 @@ type tunnelEntry struct {
 	PID       int                 `json:"pid"`
 }
 
-func loadTunnelState(path string) (*tunnelState, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+// tunnelStateFile returns the state store name of a context's tunnel state.
+func tunnelStateFile(contextName string) string {
+	return filepath.Join("tunnels", config.ContextFileName(contextName)+".json")
+}
+
+func loadTunnelState(lock *config.StateLock) (*tunnelState, error) {
 	var state tunnelState
-	if err := json.Unmarshal(data, &state); err != nil {
+	if err := lock.LoadJSON(&state); err != nil {
 		return nil, err
 	}
 	return &state, nil
 }
 
-func saveTunnelState(path string, state *tunnelState) error {
-	data, err := json.MarshalIndent(state, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(path, data, 0o644)
+func saveTunnelState(lock *config.StateLock, state *tunnelState) error {
+	return lock.SaveJSON(state, 0o644)
 }
 
 func isProcessRunning(pid int) bool {
@@ -451,11 +453,14 @@ func runTunnelDown(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load context '%s': %w", currentContext, err)
 	}
 
-	stateDir := filepath.Join(mgr.StateDir(), "tunnels")
-	stateFile := filepath.Join(stateDir, ctx.Name+".json")
+	lock, err := mgr.StateStore().Lock(tunnelStateFile(ctx.Name))
+	if err != nil {
+		return fmt.Errorf("failed to lock tunnel state: %w", err)
+	}
+	defer lock.Unlock()
 
 	// Try to load state
-	state, err := loadTunnelState(stateFile)
+	state, err := loadTunnelState(lock)
 	if err != nil {
 		fmt.Println("No active tunnels found for this context.")
 		return nil
@@ -471,7 +476,7 @@ func runTunnelDown(cmd *cobra.Command, args []string) error {
 			process.Signal(syscall.SIGTERM)
 			time.Sleep(300 * time.Millisecond)
 		}
-		os.Remove(stateFile)
+		lock.Remove()
 		green.Println("✓ All tunnels stopped.")
 		return nil
 	}
@@ -479,7 +484,7 @@ func runTunnelDown(cmd *cobra.Command, args []string) error {
 	// New format: per-tunnel PIDs
 	if len(state.TunnelPIDs) == 0 {
 		fmt.Println("No active tunnels found for this context.")
-		os.Remove(stateFile)
+		lock.Remove()
 		return nil
 	}
 
@@ -514,9 +519,9 @@ func runTunnelDown(cmd *cobra.Command, args []string) error {
 
 	// Save or remove state file
 	if len(state.TunnelPIDs) == 0 {
-		os.Remove(stateFile)
+		lock.Remove()
 	} else {
-		saveTunnelState(stateFile, state)
+		saveTunnelState(lock, state)
 	}
 
 	if stoppedCount == 0 {
@@ -556,15 +561,21 @@ func startAutoConnectTunnels(mgr *config.Manager, ctx *config.ContextConfig) ([]
 		return nil, nil, nil
 	}
 
-	// Get state directory
-	stateDir := filepath.Join(mgr.StateDir(), "tunnels")
-	if err := os.MkdirAll(stateDir, 0o755); err != nil {
-		return nil, nil, fmt.Errorf("failed to create state directory: %w", err)
+	logDir := mgr.TunnelsDir()
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create tunnels directory: %w", err)
 	}
 
+	// Hold the state lock until we're done so concurrent activations don't
+	// clobber each other's tunnel PIDs
+	lock, err := mgr.StateStore().Lock(tunnelStateFile(ctx.Name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock tunnel state: %w", err)
+	}
+	defer lock.Unlock()
+
 	// Load existing state
-	stateFile := filepath.Join(stateDir, ctx.Name+".json")
-	state, _ := loadTunnelState(stateFile)
+	state, _ := loadTunnelState(lock)
 	if state == nil {
 		state = &tunnelState{
 			ContextName: ctx.Name,
@@ -605,7 +616,7 @@ func startAutoConnectTunnels(mgr *config.Manager, ctx *config.ContextConfig) ([]
 		}
 
 		// Redirect output to log file
-		logFile := filepath.Join(stateDir, fmt.Sprintf("%s-%s.log", ctx.Name, t.Name))
+		logFile := filepath.Join(logDir, fmt.Sprintf("%s-%s.log", config.ContextFileName(ctx.Name), t.Name))
 		logFd, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 		if err != nil {
 			yellow.Fprintf(os.Stderr, "⚠ Tunnel %s: failed to create log file: %v\n", t.Name, err)
@@ -686,7 +697,7 @@ func startAutoConnectTunnels(mgr *config.Manager, ctx *config.ContextConfig) ([]
 	}
 
 	// Save state
-	if err := saveTunnelState(stateFile, state); err != nil {
+	if err := saveTunnelState(lock, state); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to save state: %v\n", err)
 	}
 
@@ -710,11 +721,16 @@ func runTunnelStatus(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load context '%s': %w", currentContext, err)
 	}
 
-	stateDir := filepath.Join(mgr.StateDir(), "tunnels")
-	stateFile := filepath.Join(stateDir, ctx.Name+".json")
+	logDir := mgr.TunnelsDir()
+
+	lock, err := mgr.StateStore().Lock(tunnelStateFile(ctx.Name))
+	if err != nil {
+		return fmt.Errorf("failed to lock tunnel state: %w", err)
+	}
+	defer lock.Unlock()
 
 	// Try to load state
-	state, err := loadTunnelState(stateFile)
+	state, err := loadTunnelState(lock)
 	if err != nil {
 		fmt.Println("No active tunnels for this context.")
 		return nil
@@ -723,7 +739,7 @@ func runTunnelStatus(cmd *cobra.Command, args []string) error {
 	// Handle old format (single PID)
 	if state.PID > 0 && len(state.TunnelPIDs) == 0 {
 		if !isProcessRunning(state.PID) {
-			os.Remove(stateFile)
+			lock.Remove()
 			fmt.Println("No active tunnels for this context.")
 			return nil
 		}
@@ -793,28 +809,23 @@ func runTunnelStatus(cmd *cobra.Command, args []string) error {
 	}
 	if len(staleNames) > 0 {
 		if len(state.TunnelPIDs) == 0 {
-			os.Remove(stateFile)
+			lock.Remove()
 		} else {
-			saveTunnelState(stateFile, state)
+			saveTunnelState(lock, state)
 		}
 	}
 
 	table.Render()
 
 	// Show log file location
-	logFile := filepath.Join(stateDir, ctx.Name+".log")
+	logFile := filepath.Join(logDir, config.ContextFileName(ctx.Name)+".log")
 	fmt.Printf("\nLog file: %s\n", logFile)
 
 	return nil
 }
 
-// sendTunnelEvent sends a tunnel audit event to the cloud server.
+// sendTunnelEvent records a tunnel audit event and sends it to the cloud server.
 func sendTunnelEvent(mgr *config.Manager, contextName, environment, action string, tunnelNames []string, success bool) {
-	client := NewCloudClient(mgr)
-	if client == nil {
-		return
-	}
-
 	details := map[string]any{
 		"tunnels": tunnelNames,
 	}
@@ -826,5 +837,11 @@ func sendTunnelEvent(mgr *config.Manager, contextName, environment, action strin
 		Details:     details,
 		Success:     success,
 	}
-	_ = client.SendAuditEvent(event)
+	recordAuditEvent(mgr, event)
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return
+	}
+	_ = deliverAuditEvent(mgr, client, event)
 }
@@ -5,6 +5,7 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"maps"
@@ -13,7 +14,6 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
-	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -25,36 +25,81 @@ var (
 	confirmFlag bool
 	exportFlag  bool
 	replaceFlag bool
+	useDirFlag  string
+	reasonFlag  string
 )
 
 func newUseCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "use <name>",
+		Use:   "use [name|-]",
 		Short: "Switch to a context",
 		Long: `Switch to a specified context. This will update environment variables,
 switch cloud provider profiles, and configure orchestration tools.
 
 For production environments, the --confirm flag is required, or you will be
-prompted for confirmation.`,
-		Args: cobra.ExactArgs(1),
+prompted for confirmation.
+
+Use '-' to switch back to the previous context. Without a name, an interactive
+picker lists the contexts, most recently used first. Type to filter by name,
+or with tag:<tag> and env:<environment>.
+
+With --dir, the context bound to a directory by its .ctx.yaml or .ctx marker
+is used (see 'ctx dir'). The marker must be trusted, and production contexts
+always ask for confirmation.
+
+Contexts are checked against the policies in ~/.config/ctx/policy.yaml and
+the team policies from ctx-cloud. A policy can deny the switch, warn, or
+require a reason, given with --reason or asked for.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if useDirFlag != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			if exportFlag {
+				return cobra.ExactArgs(1)(cmd, args)
+			}
+			return cobra.MaximumNArgs(1)(cmd, args)
+		},
 		RunE: runUse,
 	}
 
 	cmd.Flags().BoolVar(&confirmFlag, "confirm", false, "Confirm switching to production environment")
 	cmd.Flags().BoolVar(&exportFlag, "export", false, "Output environment variables for shell eval (used by shell hook)")
 	cmd.Flags().BoolVar(&replaceFlag, "replace", false, "Deactivate previous context (disconnect VPN, stop tunnels) before switching")
+	cmd.Flags().StringVar(&useDirFlag, "dir", "", "Use the context bound to this directory by its marker file (used by shell hook)")
+	cmd.Flags().StringVar(&reasonFlag, "reason", "", "Reason for using the context, recorded in the audit log")
 
 	return cmd
 }
 
 func runUse(cmd *cobra.Command, args []string) error {
-	contextName := args[0]
-
 	mgr, err := GetConfigManager()
 	if err != nil {
 		return err
 	}
 
+	var ctx *config.ContextConfig
+	var contextName string
+	if useDirFlag != "" {
+		ctx, err = loadTrustedDirContext(mgr, useDirFlag)
+		if err != nil {
+			return err
+		}
+		contextName = ctx.Name
+	} else if len(args) == 0 {
+		contextName, err = pickContextName(mgr)
+		if err != nil {
+			return err
+		}
+	} else if args[0] == "-" {
+		contextName, err = previousContextName(mgr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Switching back to '%s'\n", contextName)
+	} else {
+		contextName = args[0]
+	}
+
 	// Deactivate previous context if --replace flag is set or auto_deactivate is enabled
 	// This must run even with --export flag, because shell hook updates CTX_CURRENT after --export
 	appConfig := mgr.GetAppConfig()
@@ -69,9 +114,11 @@ func runUse(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	ctx, err := mgr.LoadContext(contextName)
-	if err != nil {
-		return fmt.Errorf("failed to load context: %w", err)
+	if ctx == nil {
+		ctx, err = mgr.LoadContext(contextName)
+		if err != nil {
+			return fmt.Errorf("failed to load context: %w", err)
+		}
 	}
 
 	// Prevent using abstract/base contexts directly
@@ -94,8 +141,15 @@ func runUse(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Check if production and require confirmation
-	if ctx.IsProd() && !confirmFlag {
+	warnModifiedCloudContexts(mgr, contextName)
+
+	if reasonFlag, err = enforcePolicies(mgr, ctx, reasonFlag); err != nil {
+		return err
+	}
+
+	// Check if production and require confirmation. A directory marker can't
+	// confirm on the user's behalf.
+	if ctx.IsProd() && (!confirmFlag || useDirFlag != "") {
 		if !confirmProductionSwitch(ctx) {
 			return fmt.Errorf("aborted: production switch not confirmed")
 		}
@@ -170,30 +224,16 @@ func switchContext(mgr *config.Manager, ctx *config.ContextConfig) ([]string, er
 	// Resolve secret files (before orchestration, so ${KUBECONFIG} etc. can be used)
 	var secretFilePaths map[string]string
 	if ctx.Secrets != nil && len(ctx.Secrets.Files) > 0 {
-		// Get GCP config dir for per-context credentials
-		sfGCPConfigDir := ""
-		if ctx.GCP != nil {
-			sfGCPConfigDir = mgr.GCPConfigDir(ctx.Name)
-		}
-
-		// Load cached AWS credentials if using aws-vault
-		var sfAWSCreds *config.AWSCredentials
-		if ctx.AWS != nil && ctx.AWS.UseVault {
-			sfAWSCreds = mgr.LoadAWSCredentials(ctx.Name)
-		}
-
-		// Load vault token from keychain
-		sfVaultToken := ""
-		if ctx.Vault != nil {
-			sfVaultToken = mgr.LoadVaultToken(ctx.Name)
-		}
-
-		sfResult, err := resolveSecretFiles(ctx.Secrets, mgr, ctx.Name, ctx.Bitwarden, ctx.OnePassword,
-			ctx.Vault, sfVaultToken, ctx.AWS, sfAWSCreds, ctx.GCP, sfGCPConfigDir, ctx.Browser)
+		sfResult, err := resolveContextSecretFiles(mgr, ctx)
 		if err != nil {
 			yellow.Fprintf(os.Stderr, "⚠ Secret files resolution failed: %v\n", err)
 			failures = append(failures, "SecretFiles")
 		} else if sfResult != nil {
+			// Save state for cleanup on deactivate
+			if err := mgr.SaveSecretFilesState(sfResult.State); err != nil {
+				yellow.Fprintf(os.Stderr, "⚠ Failed to save secret files state: %v\n", err)
+			}
+
 			secretFilePaths = sfResult.EnvVars
 			// Add file paths to ctx.Env so they can be referenced via ${VAR}
 			if ctx.Env == nil {
@@ -259,26 +299,8 @@ func switchContext(mgr *config.Manager, ctx *config.ContextConfig) ([]string, er
 	var secrets map[string]string
 	var secretsResult *SecretsResult
 	if ctx.Secrets != nil {
-		// Get GCP config dir for per-context credentials
-		gcpConfigDir := ""
-		if ctx.GCP != nil {
-			gcpConfigDir = mgr.GCPConfigDir(ctx.Name)
-		}
-
-		// Load cached AWS credentials if using aws-vault
-		var awsCreds *config.AWSCredentials
-		if ctx.AWS != nil && ctx.AWS.UseVault {
-			awsCreds = mgr.LoadAWSCredentials(ctx.Name)
-		}
-
-		// Load vault token from keychain
-		vaultToken := ""
-		if ctx.Vault != nil {
-			vaultToken = mgr.LoadVaultToken(ctx.Name)
-		}
-
 		var err error
-		secretsResult, err = resolveAllSecrets(ctx.Secrets, mgr, ctx.Name, ctx.Bitwarden, ctx.OnePassword, ctx.Vault, vaultToken, ctx.AWS, awsCreds, ctx.GCP, gcpConfigDir, ctx.Browser)
+		secretsResult, err = resolveContextSecrets(mgr, ctx)
 		if err != nil {
 			yellow.Fprintf(os.Stderr, "⚠ Secrets resolution failed: %v\n", err)
 			failures = append(failures, "Secrets")
@@ -297,10 +319,18 @@ func switchContext(mgr *config.Manager, ctx *config.ContextConfig) ([]string, er
 	}
 
 	// Set current context
-	if err := mgr.SetCurrentContext(ctx.Name); err != nil {
+	if err := mgr.SetCurrentContextConfig(ctx); err != nil {
 		return failures, fmt.Errorf("failed to set current context: %w", err)
 	}
 
+	// Remember the activation for 'ctx use -' and 'ctx history'
+	if err := mgr.RecordActivation(ctx); err != nil {
+		yellow.Fprintf(os.Stderr, "⚠ Failed to record context history: %v\n", err)
+	}
+	if err := mgr.StartTracking(ctx); err != nil {
+		yellow.Fprintf(os.Stderr, "⚠ Failed to start time tracking: %v\n", err)
+	}
+
 	// Merge secret file paths into secrets map so they're included in the env file
 	if len(secretFilePaths) > 0 {
 		if secrets == nil {
@@ -320,19 +350,55 @@ func switchContext(mgr *config.Manager, ctx *config.ContextConfig) ([]string, er
 	return failures, nil
 }
 
-// sendCloudEvents sends audit event and starts heartbeat to ctx-cloud.
-// This is non-blocking and errors are logged but do not fail the context switch.
-func sendCloudEvents(mgr *config.Manager, ctx *config.ContextConfig, failures []string) {
-	client := NewCloudClient(mgr)
-	if client == nil {
-		return // Cloud integration not configured
+// secretProviderCreds holds the cached credentials secret providers of a
+// context authenticate with.
+type secretProviderCreds struct {
+	gcpConfigDir string
+	awsCreds     *config.AWSCredentials
+	vaultToken   string
+}
+
+// loadSecretProviderCreds loads the per-context credentials used to fetch secrets.
+func loadSecretProviderCreds(mgr *config.Manager, ctx *config.ContextConfig) secretProviderCreds {
+	var creds secretProviderCreds
+
+	// Get GCP config dir for per-context credentials
+	if ctx.GCP != nil {
+		creds.gcpConfigDir = mgr.GCPConfigDir(ctx.Name)
 	}
 
-	appConfig := mgr.GetAppConfig()
-	if appConfig == nil || appConfig.Cloud == nil {
-		return
+	// Load cached AWS credentials if using aws-vault
+	if ctx.AWS != nil && ctx.AWS.UseVault {
+		creds.awsCreds = mgr.LoadAWSCredentials(ctx.Name)
 	}
 
+	// Load vault token from keychain
+	if ctx.Vault != nil {
+		creds.vaultToken = mgr.LoadVaultToken(ctx.Name)
+	}
+
+	return creds
+}
+
+// resolveContextSecretFiles writes the secret files of a context to secure temp files.
+// The caller decides whether to record them for cleanup on deactivate.
+func resolveContextSecretFiles(mgr *config.Manager, ctx *config.ContextConfig) (*SecretFilesResult, error) {
+	creds := loadSecretProviderCreds(mgr, ctx)
+	return resolveSecretFiles(ctx.Secrets, mgr, ctx.Name, ctx.Bitwarden, ctx.OnePassword,
+		ctx.Vault, creds.vaultToken, ctx.AWS, creds.awsCreds, ctx.GCP, creds.gcpConfigDir, ctx.Browser)
+}
+
+// resolveContextSecrets resolves the secrets of a context from all configured providers.
+func resolveContextSecrets(mgr *config.Manager, ctx *config.ContextConfig) (*SecretsResult, error) {
+	creds := loadSecretProviderCreds(mgr, ctx)
+	return resolveAllSecrets(ctx.Secrets, mgr, ctx.Name, ctx.Bitwarden, ctx.OnePassword, ctx.Vault,
+		creds.vaultToken, ctx.AWS, creds.awsCreds, ctx.GCP, creds.gcpConfigDir, ctx.Browser)
+}
+
+// sendCloudEvents writes the switch to the audit log, and sends it and starts
+// the heartbeat to ctx-cloud. This is non-blocking and errors are logged but
+// do not fail the context switch.
+func sendCloudEvents(mgr *config.Manager, ctx *config.ContextConfig, failures []string) {
 	// Determine VPN status and tunnels
 	vpnConnected := false
 	var activeTunnels []string
@@ -371,24 +437,47 @@ func sendCloudEvents(mgr *config.Manager, ctx *config.ContextConfig, failures []
 	if len(failures) > 0 {
 		details["partial_failures"] = failures
 	}
+	if reasonFlag != "" {
+		details["reason"] = reasonFlag
+	}
 
-	// Send switch audit event (async to not block)
 	// VPN and tunnel auto-connects are included in the switch event details,
 	// not as separate events. Manual `ctx vpn connect` and `ctx tunnel up`
 	// commands send their own events.
 	//
 	// Success is true if context was loaded (even with partial failures).
 	// Success is false only if the switch was aborted.
+	event := &cloud.AuditEvent{
+		Action:      "switch",
+		ContextName: ctx.Name,
+		Environment: string(ctx.Environment),
+		Details:     details,
+		Success:     true, // Context was loaded (partial failures are in details)
+	}
+	recordAuditEvent(mgr, event)
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return // Cloud integration not configured
+	}
+
+	appConfig := mgr.GetAppConfig()
+	if appConfig == nil || appConfig.Cloud == nil {
+		return
+	}
+
+	// Send switch audit event (async to not block). It's queued first, so
+	// it's sent by a later command if ctx exits before it goes out.
 	if appConfig.Cloud.SendAuditEvents {
+		queueErr := queueAuditEvent(mgr, event)
 		go func() {
-			event := &cloud.AuditEvent{
-				Action:      "switch",
-				ContextName: ctx.Name,
-				Environment: string(ctx.Environment),
-				Details:     details,
-				Success:     true, // Context was loaded (partial failures are in details)
+			var err error
+			if queueErr != nil {
+				err = client.SendAuditEvent(context.Background(), event)
+			} else {
+				err = flushAuditEvents(mgr, client)
 			}
-			if err := client.SendAuditEvent(event); err != nil {
+			if err != nil {
 				yellow := color.New(color.FgYellow)
 				yellow.Fprintf(os.Stderr, "⚠ Cloud audit event failed: %v\n", err)
 			}
@@ -397,31 +486,29 @@ func sendCloudEvents(mgr *config.Manager, ctx *config.ContextConfig, failures []
 
 	// Start heartbeat
 	if appConfig.Cloud.SendHeartbeat {
-		interval := time.Duration(appConfig.Cloud.HeartbeatInterval) * time.Second
-		if interval == 0 {
-			interval = 30 * time.Second
+		input := &cloud.HeartbeatInput{
+			ContextName:  ctx.Name,
+			Environment:  string(ctx.Environment),
+			VPNConnected: vpnConnected,
+			Tunnels:      activeTunnels,
 		}
 
-		hbMgr := cloud.NewHeartbeatManager(mgr.StateDir())
-		if err := hbMgr.StartHeartbeat(client, ctx.Name, string(ctx.Environment), vpnConnected, activeTunnels, interval); err != nil {
+		// Without the shell hook there is no shell for a worker to outlive
+		var startWorker func() (int, error)
+		if mgr.SessionID() != "" {
+			startWorker = func() (int, error) { return startHeartbeatWorker(mgr) }
+		}
+
+		hbMgr := cloud.NewHeartbeatManager(mgr.StateDir(), mgr.SessionID())
+		if err := hbMgr.StartHeartbeat(context.Background(), client, input, startWorker); err != nil {
 			yellow := color.New(color.FgYellow)
 			yellow.Fprintf(os.Stderr, "⚠ Cloud heartbeat failed: %v\n", err)
 		}
 	}
 }
 
-// sendAbortEvent sends an audit event when the user aborts a context switch due to failures.
+// sendAbortEvent records an audit event when the user aborts a context switch due to failures.
 func sendAbortEvent(mgr *config.Manager, ctx *config.ContextConfig, failures []string) {
-	client := NewCloudClient(mgr)
-	if client == nil {
-		return
-	}
-
-	appConfig := mgr.GetAppConfig()
-	if appConfig == nil || appConfig.Cloud == nil || !appConfig.Cloud.SendAuditEvents {
-		return
-	}
-
 	details := map[string]any{
 		"partial_failures": failures,
 		"aborted":          true,
@@ -435,9 +522,20 @@ func sendAbortEvent(mgr *config.Manager, ctx *config.ContextConfig, failures []s
 		Success:      false, // Aborted - context was NOT loaded
 		ErrorMessage: fmt.Sprintf("Aborted: %s failed", strings.Join(failures, ", ")),
 	}
+	recordAuditEvent(mgr, event)
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return
+	}
+
+	appConfig := mgr.GetAppConfig()
+	if appConfig == nil || appConfig.Cloud == nil || !appConfig.Cloud.SendAuditEvents {
+		return
+	}
 
 	// Send synchronously since we're about to exit anyway
-	if err := client.SendAuditEvent(event); err != nil {
+	if err := deliverAuditEvent(mgr, client, event); err != nil {
 		yellow := color.New(color.FgYellow)
 		yellow.Fprintf(os.Stderr, "⚠ Cloud audit event failed: %v\n", err)
 	}
@@ -1245,11 +1343,28 @@ func deactivatePreviousContext(mgr *config.Manager, contextName string) error {
 
 	yellow := color.New(color.FgYellow)
 
-	// Disconnect VPN
+	// Count the other holders and let go of the VPN in one step, so a shell
+	// leaving at the same time sees this one gone
+	lock, err := mgr.LockResources()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	// Disconnect VPN, unless another shell is still using it
 	if ctx.VPN != nil {
-		yellow.Fprintf(os.Stderr, "• Disconnecting VPN for '%s'...\n", contextName)
-		if err := disconnectVPN(ctx.VPN); err != nil {
-			return fmt.Errorf("VPN disconnect failed: %w", err)
+		resource := config.VPNResource(ctx.VPN)
+		holders := countOtherHolders(mgr, resource)
+		if err := mgr.ReleaseResource(resource); err != nil {
+			return err
+		}
+		if holders > 0 {
+			yellow.Fprintf(os.Stderr, "• Keeping VPN for '%s' connected (in use by %d other session(s))\n", contextName, holders)
+		} else {
+			yellow.Fprintf(os.Stderr, "• Disconnecting VPN for '%s'...\n", contextName)
+			if err := disconnectVPN(ctx.VPN); err != nil {
+				return fmt.Errorf("VPN disconnect failed: %w", err)
+			}
 		}
 	}
 
@@ -4,8 +4,12 @@
 package cli
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/vlebo/ctx/internal/config"
@@ -56,6 +60,65 @@ func TestSwitchContext(t *testing.T) {
 	}
 }
 
+func TestSwitchContext_DirOverlay(t *testing.T) {
+	mgr := config.NewManagerWithDir(t.TempDir())
+	if err := mgr.SetSessionID("overlay-test", os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	base := &config.ContextConfig{
+		Name:        "base",
+		Abstract:    true,
+		Environment: config.EnvDevelopment,
+		Env:         map[string]string{"LEVEL": "base"},
+	}
+	if err := mgr.SaveContext(base); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	overlay := "name: proj\nextends: base\nenv:\n  LEVEL: proj\n"
+	if err := os.WriteFile(filepath.Join(dir, config.DirMarkerFile), []byte(overlay), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	marker, err := config.ReadDirMarker(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.TrustDir(marker); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err := loadTrustedDirContext(mgr, dir)
+	if err != nil {
+		t.Fatalf("loadTrustedDirContext() error = %v", err)
+	}
+	if _, err := switchContext(mgr, ctx); err != nil {
+		t.Fatalf("switchContext() error = %v", err)
+	}
+
+	if name, _ := mgr.GetCurrentContextName(); name != "proj" {
+		t.Errorf("GetCurrentContextName() = %q, want proj", name)
+	}
+	current, err := mgr.GetCurrentContext()
+	if err != nil {
+		t.Fatalf("GetCurrentContext() error = %v", err)
+	}
+	if current.Name != "proj" || current.Env["LEVEL"] != "proj" || current.Env[config.DirEnvVar] != marker.Dir {
+		t.Errorf("GetCurrentContext() = %s with env %v, want the overlay", current.Name, current.Env)
+	}
+
+	// Switching to a named context forgets the overlay
+	if err := mgr.SaveContext(&config.ContextConfig{Name: "other", Environment: config.EnvDevelopment}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.SetCurrentContext("other"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+	if current, err := mgr.GetCurrentContext(); err != nil || current.Name != "other" {
+		t.Errorf("GetCurrentContext() after switching = %v, %v", current, err)
+	}
+}
+
 func TestSwitchContext_WithAWS(t *testing.T) {
 	tmpDir := t.TempDir()
 	mgr := config.NewManagerWithDir(tmpDir)
@@ -162,6 +225,109 @@ func TestSwitchContext_WithNomadConsul(t *testing.T) {
 	}
 }
 
+func TestSwitchContext_Concurrent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := &config.ContextConfig{
+		Name:        "shared",
+		Environment: config.EnvDevelopment,
+		Env: map[string]string{
+			"TEST_VAR": "test_value",
+		},
+	}
+	if err := config.NewManagerWithDir(tmpDir).SaveContext(ctx); err != nil {
+		t.Fatalf("Failed to save context: %v", err)
+	}
+
+	// Several shells activating the same context at once
+	const shells = 10
+	var wg sync.WaitGroup
+	for i := range shells {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mgr := config.NewManagerWithDir(tmpDir)
+			mgr.SetSessionID(fmt.Sprintf("%d-test", i), os.Getpid())
+			if _, err := switchContext(mgr, ctx); err != nil {
+				t.Errorf("switchContext() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mgr := config.NewManagerWithDir(tmpDir)
+	sessions, err := mgr.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != shells {
+		t.Errorf("ListSessions() = %d sessions, want %d", len(sessions), shells)
+	}
+
+	for i := range shells {
+		mgr.SetSessionID(fmt.Sprintf("%d-test", i), os.Getpid())
+		content, err := os.ReadFile(mgr.CurrentEnvPath())
+		if err != nil {
+			t.Fatalf("session %d env file: %v", i, err)
+		}
+		if !contains(string(content), `export TEST_VAR="test_value"`) {
+			t.Errorf("session %d env file is incomplete:\n%s", i, content)
+		}
+	}
+}
+
+func TestDeactivatePreviousContext_SharedVPN(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := &config.ContextConfig{
+		Name:        "vpn-ctx",
+		Environment: config.EnvDevelopment,
+		VPN:         &config.VPNConfig{Type: config.VPNTypeTailscale},
+		Tunnels:     []config.TunnelConfig{{Name: "db", RemoteHost: "db.internal", RemotePort: 5432, LocalPort: 5432}},
+	}
+	if err := config.NewManagerWithDir(tmpDir).SaveContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var mgrs []*config.Manager
+	for _, id := range []string{"1-leaving", "2-staying"} {
+		mgr := config.NewManagerWithDir(tmpDir)
+		if err := mgr.SetSessionID(id, os.Getpid()); err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.SetCurrentContext(ctx.Name); err != nil {
+			t.Fatal(err)
+		}
+		mgrs = append(mgrs, mgr)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	err = deactivatePreviousContext(mgrs[0], ctx.Name)
+	os.Stderr = stderr
+	w.Close()
+	out, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("deactivatePreviousContext() error = %v", err)
+	}
+	// The shared VPN is kept, and the tunnels are still handled
+	for _, want := range []string{"Keeping VPN", "Tunnels for 'vpn-ctx'"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("deactivatePreviousContext() output = %q, want %q", out, want)
+		}
+	}
+
+	// The staying shell is now the last holder, and will disconnect the VPN
+	holders, err := mgrs[1].OtherResourceHolders(config.VPNResource(ctx.VPN))
+	if err != nil || len(holders) != 0 {
+		t.Errorf("OtherResourceHolders() = %v, %v, want none after the other shell left", holders, err)
+	}
+}
+
 func TestSwitchAWS_WithVault_MissingBinary(t *testing.T) {
 	tmpDir := t.TempDir()
 	mgr := config.NewManagerWithDir(tmpDir)
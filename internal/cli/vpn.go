@@ -170,13 +170,8 @@ func runVPNStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// sendVPNEvent sends a VPN audit event to the cloud server.
+// sendVPNEvent records a VPN audit event and sends it to the cloud server.
 func sendVPNEvent(mgr *config.Manager, contextName, environment, action string, success bool, errMsg string) {
-	client := NewCloudClient(mgr)
-	if client == nil {
-		return
-	}
-
 	event := &cloud.AuditEvent{
 		Action:       action,
 		ContextName:  contextName,
@@ -184,5 +179,11 @@ func sendVPNEvent(mgr *config.Manager, contextName, environment, action string,
 		Success:      success,
 		ErrorMessage: errMsg,
 	}
-	_ = client.SendAuditEvent(event)
+	recordAuditEvent(mgr, event)
+
+	client := NewCloudClient(mgr)
+	if client == nil {
+		return
+	}
+	_ = deliverAuditEvent(mgr, client, event)
 }
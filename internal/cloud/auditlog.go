@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+const (
+	// AuditLogFile is the state file holding the local audit log, one JSON
+	// record per line.
+	AuditLogFile = "audit.jsonl"
+	// auditLogRotated is the name pattern of rotated audit logs. They are
+	// numbered by their last record, so they sort oldest first.
+	auditLogRotated = "audit-%010d.jsonl"
+	// maxAuditLogSize is the size at which the audit log is rotated.
+	maxAuditLogSize = 1 << 20
+	// maxAuditLogFiles is the number of rotated audit logs kept.
+	maxAuditLogFiles = 5
+)
+
+// ErrAuditChainBroken is returned by AuditLog.Verify when a record was
+// changed, removed or inserted.
+var ErrAuditChainBroken = errors.New("audit log hash chain is broken")
+
+// AuditRecord is an audit event as written to the local audit log. Each
+// record holds the hash of the record before it, so editing or removing a
+// record breaks the chain.
+type AuditRecord struct {
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	Session string    `json:"session,omitempty"`
+	AuditEvent
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// computeHash returns the hash of the record, covering every field but Hash.
+func (r AuditRecord) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditLog is the local append-only audit log.
+type AuditLog struct {
+	store *config.StateStore
+}
+
+// NewAuditLog returns the audit log in stateDir.
+func NewAuditLog(stateDir string) *AuditLog {
+	return &AuditLog{store: config.NewStateStore(stateDir)}
+}
+
+// Append adds an event to the audit log, rotating it once it grows too big.
+func (l *AuditLog) Append(session string, event *AuditEvent) error {
+	lock, err := l.store.Lock(AuditLogFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	last, err := l.lastRecord(lock)
+	if err != nil {
+		return err
+	}
+
+	record := AuditRecord{
+		Time:       time.Now().UTC(),
+		Session:    session,
+		AuditEvent: *event,
+	}
+	if last != nil {
+		record.Seq = last.Seq + 1
+		record.PrevHash = last.Hash
+	}
+	if record.Hash, err = record.computeHash(); err != nil {
+		return fmt.Errorf("failed to hash audit record: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	if info, err := os.Stat(l.store.Path(AuditLogFile)); err == nil && info.Size()+int64(len(data)) > maxAuditLogSize {
+		if err := l.rotate(last.Seq); err != nil {
+			return err
+		}
+	}
+
+	if err := lock.AppendFile(data, 0o600); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// lastRecord returns the newest record, from the current log or, right after
+// a rotation, the newest rotated one.
+func (l *AuditLog) lastRecord(lock *config.StateLock) (*AuditRecord, error) {
+	data, err := lock.ReadFile()
+	if os.IsNotExist(err) || (err == nil && len(bytes.TrimSpace(data)) == 0) {
+		files, err := l.rotatedFiles()
+		if err != nil || len(files) == 0 {
+			return nil, err
+		}
+		if data, err = os.ReadFile(files[len(files)-1]); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	var record AuditRecord
+	if err := json.Unmarshal(lines[len(lines)-1], &record); err != nil {
+		return nil, fmt.Errorf("failed to parse last audit record: %w", err)
+	}
+	return &record, nil
+}
+
+// rotate moves the current log aside and prunes the oldest rotated logs.
+func (l *AuditLog) rotate(lastSeq int64) error {
+	rotated := l.store.Path(fmt.Sprintf(auditLogRotated, lastSeq))
+	if err := os.Rename(l.store.Path(AuditLogFile), rotated); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	files, err := l.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for len(files) > maxAuditLogFiles {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// rotatedFiles returns the paths of the rotated logs, oldest first.
+func (l *AuditLog) rotatedFiles() ([]string, error) {
+	files, err := filepath.Glob(l.store.Path("audit-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// files returns the paths of all logs, oldest first.
+func (l *AuditLog) files() ([]string, error) {
+	files, err := l.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(l.store.Path(AuditLogFile)); err == nil {
+		files = append(files, l.store.Path(AuditLogFile))
+	}
+	return files, nil
+}
+
+// Records returns the records of the audit log and its rotated logs, oldest
+// first. Lines that can't be parsed are skipped.
+func (l *AuditLog) Records() ([]AuditRecord, error) {
+	files, err := l.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []AuditRecord
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, maxAuditLogSize)
+		for scanner.Scan() {
+			var record AuditRecord
+			if json.Unmarshal(scanner.Bytes(), &record) == nil {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// Verify checks the hash chain of the audit log and returns the number of
+// records checked. The oldest rotated logs are pruned, so the chain may start
+// part way through. Removing records from the end of the log can't be told
+// apart from them never having been written.
+func (l *AuditLog) Verify() (int, error) {
+	files, err := l.files()
+	if err != nil {
+		return 0, err
+	}
+
+	var prev *AuditRecord
+	count := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return count, fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, maxAuditLogSize)
+		for line := 1; scanner.Scan(); line++ {
+			var record AuditRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return count, fmt.Errorf("%w: %s line %d is not a valid record", ErrAuditChainBroken, filepath.Base(file), line)
+			}
+
+			hash, err := record.computeHash()
+			if err != nil {
+				return count, fmt.Errorf("failed to hash audit record: %w", err)
+			}
+			if hash != record.Hash {
+				return count, fmt.Errorf("%w: record %d was modified", ErrAuditChainBroken, record.Seq)
+			}
+			if prev != nil && (record.PrevHash != prev.Hash || record.Seq != prev.Seq+1) {
+				return count, fmt.Errorf("%w: records missing between %d and %d", ErrAuditChainBroken, prev.Seq, record.Seq)
+			}
+
+			prev = &record
+			count++
+		}
+		if err := scanner.Err(); err != nil {
+			return count, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendEvents(t *testing.T, log *AuditLog, n int) {
+	t.Helper()
+	for i := range n {
+		event := &AuditEvent{
+			Action:      "switch",
+			ContextName: "dev",
+			Details:     map[string]any{"tunnels": []string{"db"}, "attempt": i},
+			Success:     true,
+		}
+		if err := log.Append("1234", event); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+func TestAuditLog_AppendAndVerify(t *testing.T) {
+	log := NewAuditLog(t.TempDir())
+	appendEvents(t, log, 3)
+
+	records, err := log.Records()
+	if err != nil {
+		t.Fatalf("Records() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	for i, record := range records {
+		if record.Seq != int64(i) {
+			t.Errorf("record %d: Seq = %d", i, record.Seq)
+		}
+		if record.Session != "1234" || record.ContextName != "dev" {
+			t.Errorf("record %d = %+v", i, record)
+		}
+	}
+	if records[0].PrevHash != "" || records[1].PrevHash != records[0].Hash {
+		t.Errorf("records aren't chained: %+v", records)
+	}
+
+	count, err := log.Verify()
+	if err != nil || count != 3 {
+		t.Errorf("Verify() = %d, %v, want 3, nil", count, err)
+	}
+}
+
+func TestAuditLog_VerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+	}{
+		{
+			name: "edited record",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"context_name":"dev"`), []byte(`"context_name":"prod"`), 1)
+				return lines
+			},
+		},
+		{
+			name: "removed record",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+		},
+		{
+			name: "reordered records",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+		},
+		{
+			name: "garbage line",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines, []byte("not json"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			log := NewAuditLog(dir)
+			appendEvents(t, log, 4)
+
+			path := filepath.Join(dir, AuditLogFile)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(bytes.Split(bytes.TrimSpace(data), []byte("\n")))
+			if err := os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := log.Verify(); !errors.Is(err, ErrAuditChainBroken) {
+				t.Errorf("Verify() error = %v, want ErrAuditChainBroken", err)
+			}
+		})
+	}
+}
+
+func TestAuditLog_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	log := NewAuditLog(dir)
+
+	// Fill the log to just under the limit so the next record rotates it
+	appendEvents(t, log, 1)
+	path := filepath.Join(dir, AuditLogFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perRecord := int(info.Size())
+	appendEvents(t, log, maxAuditLogSize/perRecord)
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("got rotated logs %v, want 1", rotated)
+	}
+
+	// The chain carries on across the rotation
+	count, err := log.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if want := 1 + maxAuditLogSize/perRecord; count != want {
+		t.Errorf("Verify() = %d records, want %d", count, want)
+	}
+}
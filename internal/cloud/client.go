@@ -6,34 +6,154 @@ package cloud
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	mathrand "math/rand/v2"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+const (
+	// defaultMaxRetries is how many times a request that failed on the
+	// network or with a server error is retried.
+	defaultMaxRetries = 2
+	// maxRetryDelay caps the wait between retries, including waits the
+	// server asks for with Retry-After.
+	maxRetryDelay = 5 * time.Second
 )
 
+// retryBaseDelay is the wait before the first retry, doubled for each one
+// after. Tests shorten it.
+var retryBaseDelay = 250 * time.Millisecond
+
 // Client handles communication with the ctx-cloud server.
 type Client struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
+	maxRetries int
+
+	// SSO logins authenticate with an access token instead of an API key
+	oidc   *OIDCConfig
+	tokens TokenStore
+	mu     sync.Mutex
+	token  *OAuthToken
 }
 
 // NewClient creates a new cloud client.
 func NewClient(baseURL, apiKey string) *Client {
 	return &Client{
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: defaultHTTPClient(),
+		maxRetries: defaultMaxRetries,
+	}
+}
+
+// NewSSOClient creates a cloud client that authenticates with the tokens of
+// an SSO login. Access tokens are refreshed when they expire or the server
+// rejects them, and the new tokens are saved to tokens.
+func NewSSOClient(baseURL string, oidc *OIDCConfig, tokens TokenStore) *Client {
+	return &Client{
+		baseURL:    baseURL,
+		httpClient: defaultHTTPClient(),
+		maxRetries: defaultMaxRetries,
+		oidc:       oidc,
+		tokens:     tokens,
 	}
 }
 
+// SetHTTPClient sets the HTTP client used to reach the server, such as one
+// from NewHTTPClient with TLS and proxy settings.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
 // IsConfigured returns true if the client has valid configuration.
 func (c *Client) IsConfigured() bool {
-	return c.baseURL != "" && c.apiKey != ""
+	return c.baseURL != "" && (c.apiKey != "" || c.tokens != nil)
+}
+
+// accessToken returns the access token to authenticate with, refreshing it
+// if it has expired or is stale, the token the server just rejected.
+func (c *Client) accessToken(ctx context.Context, stale string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != nil && c.token.AccessToken != stale && !c.token.expired(time.Now()) {
+		return c.token.AccessToken, nil
+	}
+
+	// Another ctx process may be refreshing the token, or have refreshed it
+	// already
+	unlock, err := c.tokens.LockToken()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if err := c.reloadToken(); err != nil {
+		return "", err
+	}
+	if c.token.AccessToken != stale && !c.token.expired(time.Now()) {
+		return c.token.AccessToken, nil
+	}
+
+	if c.token.RefreshToken == "" {
+		return "", ErrSessionExpired
+	}
+	used := c.token.RefreshToken
+	token, err := c.oidc.Refresh(ctx, used)
+	if errors.Is(err, ErrSessionExpired) {
+		// The tokens may have been replaced while this one was in use, by a
+		// new login or a ctx that doesn't take the lock
+		if err := c.reloadToken(); err != nil {
+			return "", err
+		}
+		if c.token.RefreshToken != used && !c.token.expired(time.Now()) {
+			return c.token.AccessToken, nil
+		}
+		return "", ErrSessionExpired
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh ctx-cloud session: %w", err)
+	}
+	if err := c.tokens.SaveToken(token); err != nil {
+		return "", fmt.Errorf("failed to save refreshed token: %w", err)
+	}
+	c.token = token
+	return token.AccessToken, nil
+}
+
+// reloadToken loads the saved token. The caller must hold c.mu.
+func (c *Client) reloadToken() error {
+	token, err := c.tokens.LoadToken()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return ErrNotLoggedIn
+	}
+	c.token = token
+	return nil
+}
+
+// Token returns the tokens of an SSO client, or nil for an API key client.
+func (c *Client) Token() *OAuthToken {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
 }
 
 // AuditEvent represents an audit event to send to the cloud server.
@@ -63,10 +183,26 @@ type SharedContext struct {
 	Config      map[string]any `json:"config"`
 	Version     int            `json:"version"`
 	IsAbstract  bool           `json:"is_abstract"`
-	Extends     string         `json:"extends,omitempty"`
+	Extends     config.Extends `json:"extends,omitempty"`
 	UpdatedAt   string         `json:"updated_at"`
+	// ETag is the entity tag the server sent with the context, if any.
+	ETag string `json:"-"`
 }
 
+var (
+	// ErrNotConfigured is returned when the client has no server or
+	// credentials.
+	ErrNotConfigured = errors.New("cloud integration not configured")
+	// ErrInvalidResponse is returned when a response can't be parsed.
+	ErrInvalidResponse = errors.New("invalid response from server")
+	// ErrNotModified is returned by FetchContext when the context still
+	// matches the given ETag.
+	ErrNotModified = errors.New("context not modified")
+	// ErrVersionConflict is returned by PushContext when the context changed
+	// on the server since the base version.
+	ErrVersionConflict = errors.New("context was changed on the server")
+)
+
 // apiResponse wraps API responses.
 type apiResponse struct {
 	Data  json.RawMessage `json:"data,omitempty"`
@@ -79,138 +215,405 @@ type apiError struct {
 	Details map[string]any `json:"details,omitempty"`
 }
 
+// APIError is an error response from the cloud server.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    map[string]any
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" && e.Message == "" {
+		return fmt.Sprintf("request failed with status %d", e.StatusCode)
+	}
+	// Include details in error message if present (e.g., partial git sync results)
+	if len(e.Details) > 0 {
+		detailsJSON, _ := json.Marshal(e.Details)
+		return fmt.Sprintf("API error [%s]: %s (details: %s)", e.Code, e.Message, string(detailsJSON))
+	}
+	return fmt.Sprintf("API error [%s]: %s", e.Code, e.Message)
+}
+
+// RequestError is returned when a request can't reach the server, such as on
+// network errors and timeouts.
+type RequestError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s failed: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func newRequestError(req *http.Request, err error) *RequestError {
+	// The URL error repeats the method and URL
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return &RequestError{Method: req.Method, URL: req.URL.Redacted(), Err: err}
+}
+
+// Permanent reports whether retrying the request can't succeed, because the
+// server rejected the request itself.
+func (e *APIError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
 // request makes an HTTP request to the cloud server.
-func (c *Client) request(method, path string, body any) ([]byte, error) {
-	var bodyReader io.Reader
+func (c *Client) request(ctx context.Context, method, path string, body any) ([]byte, error) {
+	respBody, _, err := c.requestWithHeader(ctx, method, path, body, nil)
+	return respBody, err
+}
+
+// requestWithHeader makes an HTTP request to the cloud server with extra
+// headers. The response is returned with its body already read.
+func (c *Client) requestWithHeader(ctx context.Context, method, path string, body any, header http.Header) ([]byte, *http.Response, error) {
+	return c.requestWithRetries(ctx, method, path, body, header, c.maxRetries)
+}
+
+// requestWithRetries makes an HTTP request to the cloud server, retrying
+// failures up to maxRetries times. A request the server may already have
+// applied is only retried if it's idempotent or carries an Idempotency-Key.
+func (c *Client) requestWithRetries(ctx context.Context, method, path string, body any, header http.Header, maxRetries int) ([]byte, *http.Response, error) {
+	if !idempotent(method) && header.Get("Idempotency-Key") == "" {
+		maxRetries = 0
+	}
+
+	var data []byte
 	if body != nil {
-		data, err := json.Marshal(body)
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+	}
+
+	// An SSO access token may be revoked or expire early, so a rejected
+	// request is retried once with a refreshed token
+	stale := ""
+	for {
+		token := ""
+		if c.tokens != nil {
+			var err error
+			if token, err = c.accessToken(ctx, stale); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		respBody, resp, err := c.send(ctx, maxRetries, func() (*http.Request, error) {
+			var bodyReader io.Reader
+			if data != nil {
+				bodyReader = bytes.NewReader(data)
+			}
+			req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create request: %w", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+			if c.tokens != nil {
+				req.Header.Set("Authorization", "Bearer "+token)
+			} else {
+				req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+			}
+			for key, values := range header {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+			return req, nil
+		})
+		var apiErr *APIError
+		if c.tokens != nil && stale == "" && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			stale = token
+			continue
+		}
+		return respBody, resp, err
+	}
+}
+
+// send sends a request, retrying network errors, server errors and rate
+// limits with exponential backoff and jitter. newRequest builds the request
+// for each attempt.
+func (c *Client) send(ctx context.Context, maxRetries int, newRequest func() (*http.Request, error)) ([]byte, *http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+			return nil, nil, err
+		}
+
+		respBody, resp, err := c.do(req)
+		if err == nil || attempt >= maxRetries || !retryable(ctx, err) {
+			return respBody, resp, err
+		}
+
+		timer := time.NewTimer(retryDelay(attempt, resp))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
 		}
-		bodyReader = bytes.NewReader(data)
 	}
+}
 
-	req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// retryable reports whether a failed request may succeed if sent again.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
 	}
+	// A certificate the client doesn't trust won't be trusted on retry either
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return false
+	}
+	var reqErr *RequestError
+	return errors.As(err, &reqErr)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+// idempotent reports whether sending a request with the given method twice
+// has the same effect as sending it once. PUT is left out: a push checks the
+// version it was made against, so a retried one after a lost response fails
+// against its own update.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	}
+	return false
+}
 
+// retryDelay returns how long to wait before retrying a request for the
+// given attempt, starting at 0. A Retry-After the server sent in seconds is
+// honored; otherwise the delay doubles with each attempt, half of it random
+// so clients don't retry in lockstep.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxRetryDelay)
+		}
+	}
+	delay := min(retryBaseDelay<<attempt, maxRetryDelay)
+	return delay/2 + mathrand.N(delay/2+1)
+}
+
+// do sends a request and reads the response. Error responses are returned as
+// *APIError, and failures to reach the server as *RequestError.
+func (c *Client) do(req *http.Request) ([]byte, *http.Response, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, nil, newRequestError(req, err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, newRequestError(req, err)
 	}
 
 	if resp.StatusCode >= 400 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
 		var apiResp apiResponse
 		if json.Unmarshal(respBody, &apiResp) == nil && apiResp.Error != nil {
-			// Include details in error message if present (e.g., partial git sync results)
-			if len(apiResp.Error.Details) > 0 {
-				detailsJSON, _ := json.Marshal(apiResp.Error.Details)
-				return nil, fmt.Errorf("API error [%s]: %s (details: %s)", apiResp.Error.Code, apiResp.Error.Message, string(detailsJSON))
-			}
-			return nil, fmt.Errorf("API error [%s]: %s", apiResp.Error.Code, apiResp.Error.Message)
+			apiErr.Code = apiResp.Error.Code
+			apiErr.Message = apiResp.Error.Message
+			apiErr.Details = apiResp.Error.Details
 		}
-		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+		return nil, resp, apiErr
 	}
 
-	return respBody, nil
+	return respBody, resp, nil
 }
 
 // SendAuditEvent sends an audit event to the cloud server.
-func (c *Client) SendAuditEvent(event *AuditEvent) error {
+func (c *Client) SendAuditEvent(ctx context.Context, event *AuditEvent) error {
+	return c.SendAuditEventWithKey(ctx, NewIdempotencyKey(), event)
+}
+
+// SendAuditEventWithKey sends an audit event with an idempotency key. Sending
+// the same event again with the same key lets the server drop the duplicate.
+func (c *Client) SendAuditEventWithKey(ctx context.Context, key string, event *AuditEvent) error {
 	if !c.IsConfigured() {
 		return nil // Silently skip if not configured
 	}
 
-	_, err := c.request("POST", "/api/v1/cli/audit", event)
+	header := http.Header{}
+	header.Set("Idempotency-Key", key)
+	_, _, err := c.requestWithHeader(ctx, "POST", "/api/v1/cli/audit", event, header)
 	return err
 }
 
+// NewIdempotencyKey returns a random key identifying one event.
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // SendHeartbeat sends a heartbeat to the cloud server.
-func (c *Client) SendHeartbeat(input *HeartbeatInput) error {
+func (c *Client) SendHeartbeat(ctx context.Context, input *HeartbeatInput) error {
 	if !c.IsConfigured() {
 		return nil
 	}
 
-	_, err := c.request("POST", "/api/v1/cli/heartbeat", input)
+	// A failed heartbeat isn't retried, the next one reports the live state
+	_, _, err := c.requestWithRetries(ctx, "POST", "/api/v1/cli/heartbeat", input, nil, 0)
 	return err
 }
 
 // Deactivate notifies the cloud server that the user's session is ending.
-func (c *Client) Deactivate(contextName string) error {
+func (c *Client) Deactivate(ctx context.Context, contextName string) error {
 	if !c.IsConfigured() {
 		return nil
 	}
 
 	body := map[string]string{"context_name": contextName}
-	_, err := c.request("DELETE", "/api/v1/sessions/me", body)
+	_, err := c.request(ctx, "DELETE", "/api/v1/sessions/me", body)
 	return err
 }
 
 // GetSharedContexts fetches shared contexts from the cloud server.
-func (c *Client) GetSharedContexts() ([]*SharedContext, error) {
+func (c *Client) GetSharedContexts(ctx context.Context) ([]*SharedContext, error) {
 	if !c.IsConfigured() {
 		return nil, nil
 	}
 
-	respBody, err := c.request("GET", "/api/v1/contexts", nil)
+	respBody, err := c.request(ctx, "GET", "/api/v1/contexts", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	var apiResp apiResponse
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
 	}
 
 	var contexts []*SharedContext
 	if err := json.Unmarshal(apiResp.Data, &contexts); err != nil {
-		return nil, fmt.Errorf("failed to parse contexts: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
 	}
 
 	return contexts, nil
 }
 
 // SyncContext fetches a specific context by name with resolved inheritance.
-func (c *Client) SyncContext(name string) (*SharedContext, error) {
+func (c *Client) SyncContext(ctx context.Context, name string) (*SharedContext, error) {
+	return c.FetchContext(ctx, name, "")
+}
+
+// FetchContext fetches a specific context by name with resolved inheritance.
+// If etag is set and the context still matches it, ErrNotModified is returned.
+func (c *Client) FetchContext(ctx context.Context, name, etag string) (*SharedContext, error) {
 	if !c.IsConfigured() {
 		return nil, nil
 	}
 
-	respBody, err := c.request("GET", "/api/v1/cli/sync/"+name, nil)
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	respBody, resp, err := c.requestWithHeader(ctx, "GET", "/api/v1/cli/sync/"+url.PathEscape(name), nil, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+
+	return parseSharedContext(respBody, resp)
+}
+
+// pushContextRequest is the body of a context push.
+type pushContextRequest struct {
+	Description string         `json:"description,omitempty"`
+	Environment string         `json:"environment,omitempty"`
+	Config      map[string]any `json:"config"`
+	IsAbstract  bool           `json:"is_abstract"`
+	Extends     config.Extends `json:"extends,omitempty"`
+	BaseVersion int            `json:"base_version,omitempty"`
+}
+
+// PushContext uploads a context and returns it as stored on the server, with
+// its new version. shared.Version is the version the changes were made against;
+// if the context changed on the server since, ErrVersionConflict is
+// returned. A version of 0 creates the context, which fails if it already
+// exists. force skips the check and overwrites the context.
+func (c *Client) PushContext(ctx context.Context, shared *SharedContext, force bool) (*SharedContext, error) {
+	if !c.IsConfigured() {
+		return nil, ErrNotConfigured
+	}
+
+	body := pushContextRequest{
+		Description: shared.Description,
+		Environment: shared.Environment,
+		Config:      shared.Config,
+		IsAbstract:  shared.IsAbstract,
+		Extends:     shared.Extends,
+	}
+	// The key lets the server answer a retried push with the result of the
+	// first attempt, rather than refusing it as a conflict with itself
+	header := http.Header{}
+	header.Set("Idempotency-Key", NewIdempotencyKey())
+	switch {
+	case force:
+	case shared.Version == 0:
+		header.Set("If-None-Match", "*")
+	default:
+		body.BaseVersion = shared.Version
+		if shared.ETag != "" {
+			header.Set("If-Match", shared.ETag)
+		}
+	}
+
+	respBody, resp, err := c.requestWithHeader(ctx, "PUT", "/api/v1/cli/sync/"+url.PathEscape(shared.Name), body, header)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusConflict || apiErr.StatusCode == http.StatusPreconditionFailed) {
+		return nil, fmt.Errorf("%w: %w", ErrVersionConflict, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	return parseSharedContext(respBody, resp)
+}
+
+// parseSharedContext parses a context from an API response.
+func parseSharedContext(respBody []byte, resp *http.Response) (*SharedContext, error) {
 	var apiResp apiResponse
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
 	}
 
 	var ctx SharedContext
 	if err := json.Unmarshal(apiResp.Data, &ctx); err != nil {
-		return nil, fmt.Errorf("failed to parse context: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
 	}
+	ctx.ETag = resp.Header.Get("ETag")
 
 	return &ctx, nil
 }
 
 // TestConnection tests the connection to the cloud server.
-func (c *Client) TestConnection() error {
+func (c *Client) TestConnection(ctx context.Context) error {
 	if !c.IsConfigured() {
-		return fmt.Errorf("cloud integration not configured")
+		return ErrNotConfigured
 	}
 
 	// Use sessions/stats endpoint which works with API key auth and always returns data
-	_, err := c.request("GET", "/api/v1/sessions/stats", nil)
+	_, err := c.request(ctx, "GET", "/api/v1/sessions/stats", nil)
 	return err
 }
@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries shortens the delay between retries for a test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	old := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = old })
+}
+
+// failingServer answers with each of statuses in turn, then with success.
+func failingServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n <= len(statuses) {
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(statuses[n-1])
+			w.Write([]byte(`{"error":{"code":"unavailable","message":"try again"}}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"name":"dev","version":1}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestClientRetries(t *testing.T) {
+	fastRetries(t)
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantStatus   int
+		wantRequests int32
+	}{
+		{name: "server error then success", statuses: []int{503}, wantRequests: 2},
+		{name: "rate limited then success", statuses: []int{429, 502}, wantRequests: 3},
+		{name: "server errors exhaust retries", statuses: []int{500, 502, 503}, wantStatus: 503, wantRequests: 3},
+		{name: "client error isn't retried", statuses: []int{400}, wantStatus: 400, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := failingServer(t, tt.statuses...)
+			client := NewClient(server.URL, "test-key")
+			client.SetHTTPClient(server.Client())
+
+			contexts, err := client.GetSharedContexts(context.Background())
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", got, tt.wantRequests)
+			}
+			if tt.wantStatus == 0 {
+				if err != nil || len(contexts) != 1 {
+					t.Errorf("GetSharedContexts() = %v, %v, want one context", contexts, err)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus || apiErr.Code != "unavailable" {
+				t.Errorf("GetSharedContexts() error = %v, want *APIError with status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClientRetriesOnlyIdempotentRequests(t *testing.T) {
+	fastRetries(t)
+
+	tests := []struct {
+		name         string
+		method       string
+		key          string
+		wantRequests int32
+	}{
+		{name: "GET", method: "GET", wantRequests: 2},
+		{name: "DELETE", method: "DELETE", wantRequests: 2},
+		{name: "POST", method: "POST", wantRequests: 1},
+		{name: "PUT", method: "PUT", wantRequests: 1},
+		{name: "POST with idempotency key", method: "POST", key: "abc", wantRequests: 2},
+		{name: "PUT with idempotency key", method: "PUT", key: "abc", wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := failingServer(t, http.StatusBadGateway)
+			client := NewClient(server.URL, "test-key")
+			client.SetHTTPClient(server.Client())
+
+			header := http.Header{}
+			if tt.key != "" {
+				header.Set("Idempotency-Key", tt.key)
+			}
+			client.requestWithHeader(context.Background(), tt.method, "/api/v1/test", nil, header)
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestClientRetriesNetworkErrors(t *testing.T) {
+	fastRetries(t)
+
+	server := httptest.NewServer(http.HandlerFunc(statsHandler))
+	url := server.URL
+	server.Close()
+
+	client := NewClient(url, "test-key")
+	err := client.TestConnection(context.Background())
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("TestConnection() error = %v, want *RequestError", err)
+	}
+	if reqErr.Method != http.MethodGet || reqErr.URL != url+"/api/v1/sessions/stats" {
+		t.Errorf("RequestError = %+v", reqErr)
+	}
+}
+
+func TestClientCancellation(t *testing.T) {
+	requests := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// Canceled during the request or while waiting 60s to retry it
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-requests
+		cancel()
+	}()
+
+	client := NewClient(server.URL, "test-key")
+	start := time.Now()
+	err := client.TestConnection(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("TestConnection() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > maxRetryDelay {
+		t.Errorf("TestConnection() returned after %v, want right after cancellation", elapsed)
+	}
+
+	// Canceled before it's sent
+	if err := client.TestConnection(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("TestConnection() with canceled context error = %v, want context.Canceled", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("server got %d requests after cancellation", len(requests))
+	}
+}
+
+func TestClientInvalidResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"not":"a list"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	if _, err := client.GetSharedContexts(context.Background()); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("GetSharedContexts() error = %v, want ErrInvalidResponse", err)
+	}
+}
+
+func TestClientNotConfigured(t *testing.T) {
+	if err := NewClient("", "").TestConnection(context.Background()); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("TestConnection() error = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt := range 4 {
+		base := retryBaseDelay << attempt
+		for range 20 {
+			if got := retryDelay(attempt, nil); got < base/2 || got > base {
+				t.Fatalf("retryDelay(%d) = %v, want between %v and %v", attempt, got, base/2, base)
+			}
+		}
+	}
+	if got := retryDelay(30, nil); got > maxRetryDelay {
+		t.Errorf("retryDelay(30) = %v, want at most %v", got, maxRetryDelay)
+	}
+
+	for _, tt := range []struct {
+		retryAfter int
+		want       time.Duration
+	}{
+		{retryAfter: 2, want: 2 * time.Second},
+		{retryAfter: 120, want: maxRetryDelay},
+	} {
+		resp := &http.Response{Header: http.Header{"Retry-After": {strconv.Itoa(tt.retryAfter)}}}
+		if got := retryDelay(0, resp); got != tt.want {
+			t.Errorf("retryDelay() with Retry-After %d = %v, want %v", tt.retryAfter, got, tt.want)
+		}
+	}
+}
@@ -4,71 +4,99 @@
 package cloud
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"syscall"
 	"time"
+
+	"github.com/vlebo/ctx/internal/config"
 )
 
-// HeartbeatManager manages the heartbeat background process.
+// heartbeatStateFile is the state store name of the heartbeat state.
+const heartbeatStateFile = "heartbeat.json"
+
+// DefaultHeartbeatInterval is the interval between heartbeats when none is
+// configured.
+const DefaultHeartbeatInterval = 30 * time.Second
+
+// HeartbeatManager manages the heartbeat worker of a session. The worker is a
+// detached process that keeps sending heartbeats until the session's shell
+// exits or deactivates.
 type HeartbeatManager struct {
-	stateDir string
+	store *config.StateStore
+	file  string
 }
 
-// NewHeartbeatManager creates a new heartbeat manager.
-func NewHeartbeatManager(stateDir string) *HeartbeatManager {
-	return &HeartbeatManager{stateDir: stateDir}
+// NewHeartbeatManager creates a heartbeat manager for the given session. An
+// empty session ID means the shell hook isn't in use.
+func NewHeartbeatManager(stateDir, sessionID string) *HeartbeatManager {
+	file := heartbeatStateFile
+	if sessionID != "" {
+		file = filepath.Join(config.SessionsSubdir, sessionID, heartbeatStateFile)
+	}
+	return &HeartbeatManager{store: config.NewStateStore(stateDir), file: file}
 }
 
-// heartbeatState stores the state of the running heartbeat process.
+// heartbeatState stores the state of the running heartbeat worker.
 type heartbeatState struct {
 	PID         int    `json:"pid"`
 	ContextName string `json:"context_name"`
 	StartedAt   string `json:"started_at"`
 }
 
-func (m *HeartbeatManager) stateFile() string {
-	return filepath.Join(m.stateDir, "heartbeat.json")
-}
-
-// StartHeartbeat starts a background heartbeat process.
-// This is called from the main CLI process and forks a background goroutine.
-func (m *HeartbeatManager) StartHeartbeat(client *Client, contextName, environment string, vpnConnected bool, tunnels []string, interval time.Duration) error {
+// StartHeartbeat sends a heartbeat right away and makes sure the session's
+// worker is running to keep sending them. startWorker starts the worker
+// process and returns its PID; it is nil without a session, as there is no
+// shell to outlive. The worker is started even if the first heartbeat fails,
+// so a network blip doesn't stop the presence.
+func (m *HeartbeatManager) StartHeartbeat(ctx context.Context, client *Client, input *HeartbeatInput, startWorker func() (int, error)) error {
 	if !client.IsConfigured() {
 		return nil
 	}
 
-	// Send initial heartbeat synchronously
-	input := &HeartbeatInput{
-		ContextName:  contextName,
-		Environment:  environment,
-		VPNConnected: vpnConnected,
-		Tunnels:      tunnels,
+	sendErr := client.SendHeartbeat(ctx, input)
+	if sendErr != nil {
+		sendErr = fmt.Errorf("initial heartbeat failed: %w", sendErr)
+	}
+	if startWorker == nil {
+		return sendErr
 	}
 
-	if err := client.SendHeartbeat(input); err != nil {
-		return fmt.Errorf("initial heartbeat failed: %w", err)
+	lock, err := m.store.Lock(m.file)
+	if err != nil {
+		return err
 	}
+	defer lock.Unlock()
 
-	// Save state with current PID (the main process)
-	// The shell will source the env file which keeps the context active
-	// We rely on shell hooks and deactivate to properly end sessions
-	state := &heartbeatState{
-		PID:         os.Getpid(),
-		ContextName: contextName,
-		StartedAt:   time.Now().Format(time.RFC3339),
+	// The worker follows context switches in its session by itself
+	var state heartbeatState
+	if lock.LoadJSON(&state) == nil && isProcessRunning(state.PID) {
+		state.ContextName = input.ContextName
+		if err := lock.SaveJSON(&state, 0o644); err != nil {
+			return fmt.Errorf("failed to save heartbeat state: %w", err)
+		}
+		return sendErr
 	}
 
-	if err := m.saveState(state); err != nil {
+	pid, err := startWorker()
+	if err != nil {
+		return fmt.Errorf("failed to start heartbeat worker: %w", err)
+	}
+	state = heartbeatState{
+		PID:         pid,
+		ContextName: input.ContextName,
+		StartedAt:   time.Now().Format(time.RFC3339),
+	}
+	if err := lock.SaveJSON(&state, 0o644); err != nil {
 		return fmt.Errorf("failed to save heartbeat state: %w", err)
 	}
-
-	return nil
+	return sendErr
 }
 
-// StopHeartbeat stops the heartbeat process if running.
+// StopHeartbeat stops the heartbeat worker if running.
 func (m *HeartbeatManager) StopHeartbeat() error {
 	state, err := m.loadState()
 	if err != nil {
@@ -76,9 +104,9 @@ func (m *HeartbeatManager) StopHeartbeat() error {
 	}
 
 	// Clean up state file
-	os.Remove(m.stateFile())
+	m.store.Remove(m.file)
 
-	// Signal the process if it's still running and it's not us
+	// Signal the worker if it's still running and it's not us
 	if state.PID > 0 && state.PID != os.Getpid() {
 		if isProcessRunning(state.PID) {
 			process, err := os.FindProcess(state.PID)
@@ -100,7 +128,7 @@ func (m *HeartbeatManager) GetCurrentContext() string {
 	return state.ContextName
 }
 
-// IsRunning returns true if a heartbeat process is running.
+// IsRunning returns true if a heartbeat worker is running.
 func (m *HeartbeatManager) IsRunning() bool {
 	state, err := m.loadState()
 	if err != nil {
@@ -109,35 +137,81 @@ func (m *HeartbeatManager) IsRunning() bool {
 	return state.PID > 0 && isProcessRunning(state.PID)
 }
 
-func (m *HeartbeatManager) saveState(state *heartbeatState) error {
-	if err := os.MkdirAll(m.stateDir, 0o755); err != nil {
-		return err
+// IsWorker reports whether pid is the session's heartbeat worker. A worker
+// that has been stopped or replaced is no longer.
+func (m *HeartbeatManager) IsWorker(pid int) bool {
+	state, err := m.loadState()
+	return err == nil && state.PID == pid
+}
+
+// Release removes the heartbeat state if pid is still the session's worker.
+// The worker calls it on its way out.
+func (m *HeartbeatManager) Release(pid int) error {
+	// Locking would bring back a session directory removed on deactivate
+	if _, err := os.Stat(m.store.Path(m.file)); err != nil {
+		return nil
 	}
 
-	data, err := json.Marshal(state)
+	lock, err := m.store.Lock(m.file)
 	if err != nil {
 		return err
 	}
+	defer lock.Unlock()
 
-	return os.WriteFile(m.stateFile(), data, 0o644)
+	var state heartbeatState
+	if lock.LoadJSON(&state) != nil || state.PID != pid {
+		return nil
+	}
+	return lock.Remove()
 }
 
 func (m *HeartbeatManager) loadState() (*heartbeatState, error) {
-	data, err := os.ReadFile(m.stateFile())
-	if err != nil {
-		return nil, err
-	}
-
 	var state heartbeatState
-	if err := json.Unmarshal(data, &state); err != nil {
+	if err := m.store.LoadJSON(m.file, &state); err != nil {
 		return nil, err
 	}
 
 	return &state, nil
 }
 
+// RunHeartbeat sends a heartbeat every interval until ctx is done or status
+// returns nil, which it does once the session has ended. status is called
+// before every heartbeat, so the heartbeat reports the live state. Heartbeats
+// that fail are logged and the next one is sent at the next interval, unless
+// the server rejected it.
+func RunHeartbeat(ctx context.Context, client *Client, interval time.Duration, status func() *HeartbeatInput, logf func(format string, args ...any)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		input := status()
+		if input == nil {
+			return nil
+		}
+
+		err := client.SendHeartbeat(ctx, input)
+		var apiErr *APIError
+		switch {
+		case err == nil:
+		case errors.As(err, &apiErr) && apiErr.Permanent():
+			return fmt.Errorf("heartbeat rejected: %w", err)
+		default:
+			logf("heartbeat failed: %v", err)
+		}
+	}
+}
+
 // isProcessRunning checks if a process with the given PID is running.
 func isProcessRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
 	process, err := os.FindProcess(pid)
 	if err != nil {
 		return false
@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// heartbeatServer is a test server recording heartbeats. The statuses are
+// returned for the first requests, in order; later requests succeed.
+type heartbeatServer struct {
+	mu         sync.Mutex
+	statuses   []int
+	heartbeats []HeartbeatInput
+}
+
+func (s *heartbeatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path != "/api/v1/cli/heartbeat" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	var input HeartbeatInput
+	json.NewDecoder(r.Body).Decode(&input)
+	s.heartbeats = append(s.heartbeats, input)
+}
+
+func (s *heartbeatServer) received() []HeartbeatInput {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]HeartbeatInput(nil), s.heartbeats...)
+}
+
+func newHeartbeatServer(t *testing.T, statuses ...int) (*heartbeatServer, *Client) {
+	t.Helper()
+	server := &heartbeatServer{statuses: statuses}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return server, NewClient(ts.URL, "sk_test")
+}
+
+func TestRunHeartbeat_SendsLiveStatusUntilSessionEnds(t *testing.T) {
+	// The second heartbeat hits a network blip
+	server, client := newHeartbeatServer(t, http.StatusOK, http.StatusServiceUnavailable)
+
+	var mu sync.Mutex
+	calls := 0
+	status := func() *HeartbeatInput {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls > 4 {
+			return nil // Session ended
+		}
+		return &HeartbeatInput{ContextName: "dev", VPNConnected: calls%2 == 0}
+	}
+
+	var logged []string
+	logf := func(format string, args ...any) { logged = append(logged, format) }
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RunHeartbeat(context.Background(), client, 10*time.Millisecond, status, logf)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("RunHeartbeat() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunHeartbeat() didn't stop when the session ended")
+	}
+
+	got := server.received()
+	if len(got) != 3 {
+		t.Fatalf("server got %d heartbeats, want 3 (one failed): %+v", len(got), got)
+	}
+	// The status is read before every heartbeat
+	if got[1].VPNConnected || !got[2].VPNConnected {
+		t.Errorf("heartbeats don't report the live status: %+v", got)
+	}
+	if len(logged) != 1 {
+		t.Errorf("logged %d failures, want 1", len(logged))
+	}
+}
+
+func TestRunHeartbeat_StopsOnCancel(t *testing.T) {
+	_, client := newHeartbeatServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunHeartbeat(ctx, client, time.Hour, func() *HeartbeatInput { return &HeartbeatInput{} }, t.Logf)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunHeartbeat() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunHeartbeat() didn't stop when cancelled")
+	}
+}
+
+func TestRunHeartbeat_StopsWhenRejected(t *testing.T) {
+	_, client := newHeartbeatServer(t, http.StatusUnauthorized)
+
+	err := RunHeartbeat(context.Background(), client, 10*time.Millisecond,
+		func() *HeartbeatInput { return &HeartbeatInput{ContextName: "dev"} }, t.Logf)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("RunHeartbeat() error = %v, want the 401", err)
+	}
+}
+
+func TestHeartbeatManager_StartHeartbeat(t *testing.T) {
+	server, client := newHeartbeatServer(t, http.StatusServiceUnavailable)
+	hbMgr := NewHeartbeatManager(t.TempDir(), "1234-abcd")
+
+	// The test process stands in for a running worker
+	started := 0
+	startWorker := func() (int, error) {
+		started++
+		return os.Getpid(), nil
+	}
+
+	// The first heartbeat fails, the worker is started anyway
+	err := hbMgr.StartHeartbeat(context.Background(), client, &HeartbeatInput{ContextName: "dev"}, startWorker)
+	if err == nil {
+		t.Error("StartHeartbeat() error = nil, want the failed initial heartbeat")
+	}
+	if started != 1 || !hbMgr.IsRunning() || !hbMgr.IsWorker(os.Getpid()) {
+		t.Fatalf("worker not started (started %d times)", started)
+	}
+
+	// Switching context in the session keeps the running worker
+	if err := hbMgr.StartHeartbeat(context.Background(), client, &HeartbeatInput{ContextName: "prod"}, startWorker); err != nil {
+		t.Fatalf("StartHeartbeat() error = %v", err)
+	}
+	if started != 1 {
+		t.Errorf("worker started %d times, want once", started)
+	}
+	if got := hbMgr.GetCurrentContext(); got != "prod" {
+		t.Errorf("GetCurrentContext() = %q, want prod", got)
+	}
+	if got := server.received(); len(got) != 1 || got[0].ContextName != "prod" {
+		t.Errorf("server got %+v, want the prod heartbeat", got)
+	}
+
+	// Another worker's exit leaves the state alone
+	if err := hbMgr.Release(os.Getpid() + 1); err != nil || !hbMgr.IsRunning() {
+		t.Errorf("Release() by another PID removed the state (err %v)", err)
+	}
+	if err := hbMgr.Release(os.Getpid()); err != nil || hbMgr.IsRunning() {
+		t.Errorf("Release() by the worker kept the state (err %v)", err)
+	}
+}
+
+func TestHeartbeatManager_PerSession(t *testing.T) {
+	_, client := newHeartbeatServer(t)
+	dir := t.TempDir()
+	shellA := NewHeartbeatManager(dir, "1-a")
+	shellB := NewHeartbeatManager(dir, "2-b")
+
+	startWorker := func() (int, error) { return os.Getpid(), nil }
+	for _, hbMgr := range []*HeartbeatManager{shellA, shellB} {
+		if err := hbMgr.StartHeartbeat(context.Background(), client, &HeartbeatInput{ContextName: "dev"}, startWorker); err != nil {
+			t.Fatalf("StartHeartbeat() error = %v", err)
+		}
+	}
+
+	// Without a session no worker is started
+	if err := NewHeartbeatManager(dir, "").StartHeartbeat(context.Background(), client, &HeartbeatInput{ContextName: "dev"}, nil); err != nil {
+		t.Fatalf("StartHeartbeat() error = %v", err)
+	}
+	if NewHeartbeatManager(dir, "").IsRunning() {
+		t.Error("sessionless heartbeat recorded a worker")
+	}
+
+	// Stopping one session's worker leaves the other's. The worker is this
+	// process, which StopHeartbeat doesn't signal.
+	if err := shellA.StopHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if shellA.IsRunning() || !shellB.IsRunning() {
+		t.Errorf("IsRunning() = %v, %v, want false, true", shellA.IsRunning(), shellB.IsRunning())
+	}
+}
@@ -0,0 +1,352 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// deviceCodeGrantType is the grant type of the OAuth 2.0 device flow.
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// devicePollUnit is the unit of the device flow's polling interval and
+// expiry, which the provider gives in seconds. Tests shorten it.
+var devicePollUnit = time.Second
+
+// tokenExpiryMargin is how long before its expiry an access token is
+// refreshed, so it doesn't expire in flight.
+const tokenExpiryMargin = 30 * time.Second
+
+var (
+	// ErrNotLoggedIn is returned when an SSO client has no tokens.
+	ErrNotLoggedIn = errors.New("not logged in to ctx-cloud. Run 'ctx cloud login --sso'")
+	// ErrSessionExpired is returned when the SSO session can no longer be
+	// refreshed.
+	ErrSessionExpired = errors.New("ctx-cloud session expired. Run 'ctx cloud login --sso' again")
+	// ErrAuthorizationDenied is returned when the user denies the device
+	// authorization.
+	ErrAuthorizationDenied = errors.New("authorization denied")
+)
+
+// SSOSettings are the settings a ctx-cloud server advertises for SSO logins.
+type SSOSettings struct {
+	Issuer   string   `json:"issuer"`
+	ClientID string   `json:"client_id"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// OIDCConfig holds the endpoints and client of an OpenID Connect provider.
+type OIDCConfig struct {
+	ClientID      string
+	Scopes        []string
+	DeviceAuthURL string
+	TokenURL      string
+	RevocationURL string
+	// HTTPClient is used to reach the provider, if set.
+	HTTPClient *http.Client
+}
+
+// OAuthToken is an access token with the refresh token to renew it.
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+}
+
+// expired reports whether the token has expired, or is about to.
+func (t *OAuthToken) expired(now time.Time) bool {
+	return !t.Expiry.IsZero() && now.Add(tokenExpiryMargin).After(t.Expiry)
+}
+
+// TokenStore persists the tokens of an SSO login, so a refreshed token is
+// shared by every ctx process.
+type TokenStore interface {
+	// LoadToken returns the saved token, or nil if there is none.
+	LoadToken() (*OAuthToken, error)
+	SaveToken(token *OAuthToken) error
+	// LockToken takes a lock shared by every ctx process, held while a
+	// token is reloaded, refreshed and saved, so that only one process uses
+	// a refresh token. It returns the func that releases the lock.
+	LockToken() (unlock func(), err error)
+}
+
+// DeviceAuthorization is a pending device authorization. The user approves
+// it by entering UserCode at VerificationURI.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// oauthError is an error response from an OAuth endpoint.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// GetSSOSettings fetches the SSO settings a ctx-cloud server advertises.
+// httpClient may be nil.
+func GetSSOSettings(ctx context.Context, httpClient *http.Client, serverURL string) (*SSOSettings, error) {
+	var resp apiResponse
+	if err := getJSON(ctx, httpClient, serverURL+"/api/v1/cli/auth/sso", &resp); err != nil {
+		return nil, err
+	}
+
+	var settings SSOSettings
+	if err := json.Unmarshal(resp.Data, &settings); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if settings.Issuer == "" || settings.ClientID == "" {
+		return nil, fmt.Errorf("server has no SSO configured")
+	}
+	return &settings, nil
+}
+
+// DiscoverOIDC looks up the endpoints of an OpenID Connect issuer.
+// httpClient may be nil, and is kept to reach the provider later.
+func DiscoverOIDC(ctx context.Context, httpClient *http.Client, issuer, clientID string, scopes []string) (*OIDCConfig, error) {
+	var doc struct {
+		DeviceAuthURL string `json:"device_authorization_endpoint"`
+		TokenURL      string `json:"token_endpoint"`
+		RevocationURL string `json:"revocation_endpoint"`
+	}
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, httpClient, wellKnown, &doc); err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+	if doc.DeviceAuthURL == "" || doc.TokenURL == "" {
+		return nil, fmt.Errorf("OIDC provider %s doesn't support the device flow", issuer)
+	}
+
+	return &OIDCConfig{
+		ClientID:      clientID,
+		Scopes:        scopes,
+		DeviceAuthURL: doc.DeviceAuthURL,
+		TokenURL:      doc.TokenURL,
+		RevocationURL: doc.RevocationURL,
+		HTTPClient:    httpClient,
+	}, nil
+}
+
+// StartDeviceAuthorization starts the device flow.
+func (o *OIDCConfig) StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error) {
+	form := url.Values{"client_id": {o.ClientID}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+
+	var auth DeviceAuthorization
+	if err := o.postForm(ctx, o.DeviceAuthURL, form, &auth); err != nil {
+		return nil, fmt.Errorf("failed to start device authorization: %w", err)
+	}
+	return &auth, nil
+}
+
+// PollDeviceToken waits for the user to approve a device authorization and
+// returns the tokens. It returns ErrAuthorizationDenied if the user denies
+// it, and gives up when the authorization expires or ctx is done.
+func (o *OIDCConfig) PollDeviceToken(ctx context.Context, auth *DeviceAuthorization) (*OAuthToken, error) {
+	interval := time.Duration(auth.Interval) * devicePollUnit
+	if auth.Interval <= 0 {
+		interval = 5 * devicePollUnit
+	}
+	expiresIn := time.Duration(auth.ExpiresIn) * devicePollUnit
+	if auth.ExpiresIn <= 0 {
+		expiresIn = 10 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, expiresIn)
+	defer cancel()
+
+	form := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {auth.DeviceCode},
+		"client_id":   {o.ClientID},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("device authorization expired")
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		token, err := o.requestToken(ctx, form)
+		var oauthErr *oauthError
+		if !errors.As(err, &oauthErr) {
+			return token, err
+		}
+		switch oauthErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * devicePollUnit
+		case "access_denied":
+			return nil, ErrAuthorizationDenied
+		case "expired_token":
+			return nil, fmt.Errorf("device authorization expired")
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token. It returns
+// ErrSessionExpired if the refresh token is no longer valid.
+func (o *OIDCConfig) Refresh(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	token, err := o.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {o.ClientID},
+	})
+	var oauthErr *oauthError
+	if errors.As(err, &oauthErr) && oauthErr.Code == "invalid_grant" {
+		return nil, ErrSessionExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// Revoke revokes a token at the provider. tokenTypeHint is "access_token" or
+// "refresh_token". Providers without a revocation endpoint are skipped.
+func (o *OIDCConfig) Revoke(ctx context.Context, token, tokenTypeHint string) error {
+	if o.RevocationURL == "" || token == "" {
+		return nil
+	}
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {tokenTypeHint},
+		"client_id":       {o.ClientID},
+	}
+	if err := o.postForm(ctx, o.RevocationURL, form, nil); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// requestToken requests a token from the token endpoint.
+func (o *OIDCConfig) requestToken(ctx context.Context, form url.Values) (*OAuthToken, error) {
+	var resp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := o.postForm(ctx, o.TokenURL, form, &resp); err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, fmt.Errorf("%w: token response has no access token", ErrInvalidResponse)
+	}
+
+	token := &OAuthToken{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		TokenType:    resp.TokenType,
+	}
+	if resp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// postForm posts a form to an OAuth endpoint and decodes the JSON response
+// into out, if set. OAuth error responses are returned as *oauthError.
+func (o *OIDCConfig) postForm(ctx context.Context, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	body, err := doJSONRequest(o.HTTPClient, req)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		var oauthErr oauthError
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return &oauthErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	return nil
+}
+
+// getJSON fetches a URL and decodes the JSON response into out.
+func getJSON(ctx context.Context, httpClient *http.Client, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	body, err := doJSONRequest(httpClient, req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	return nil
+}
+
+// doJSONRequest sends a request outside the ctx-cloud API and reads the
+// response. Error responses are returned as *APIError, with the body.
+func doJSONRequest(httpClient *http.Client, req *http.Request) ([]byte, error) {
+	if httpClient == nil {
+		httpClient = defaultHTTPClient()
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, newRequestError(req, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newRequestError(req, err)
+	}
+	if resp.StatusCode >= 400 {
+		return body, &APIError{StatusCode: resp.StatusCode}
+	}
+	return body, nil
+}
+
+// defaultHTTPClient returns the HTTP client used to talk to ctx-cloud and
+// its identity provider without TLS or proxy settings.
+func defaultHTTPClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
@@ -0,0 +1,379 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAuthServer is a local OAuth 2.0 authorization server with the device
+// flow, and a ctx-cloud server that accepts its access tokens.
+type fakeAuthServer struct {
+	mu sync.Mutex
+	// pending is the number of polls answered with authorization_pending
+	pending int
+	// deny answers polls with access_denied
+	deny bool
+	// issued counts the access tokens issued
+	issued int
+	// valid holds the access and refresh tokens that are still valid
+	valid   map[string]bool
+	revoked []string
+	// rejectAll makes the ctx-cloud server reject every token
+	rejectAll bool
+	// rotate makes refresh tokens single use
+	rotate bool
+
+	issuer *httptest.Server
+	cloud  *httptest.Server
+}
+
+func newFakeAuthServer(t *testing.T) *fakeAuthServer {
+	t.Helper()
+	old := devicePollUnit
+	devicePollUnit = time.Millisecond
+	t.Cleanup(func() { devicePollUnit = old })
+
+	s := &fakeAuthServer{valid: make(map[string]bool)}
+	s.issuer = httptest.NewServer(http.HandlerFunc(s.serveIssuer))
+	s.cloud = httptest.NewServer(http.HandlerFunc(s.serveCloud))
+	t.Cleanup(s.issuer.Close)
+	t.Cleanup(s.cloud.Close)
+	return s
+}
+
+func (s *fakeAuthServer) serveIssuer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	r.ParseForm()
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		fmt.Fprintf(w, `{"device_authorization_endpoint":"%[1]s/device","token_endpoint":"%[1]s/token","revocation_endpoint":"%[1]s/revoke"}`, s.issuer.URL)
+	case "/device":
+		fmt.Fprint(w, `{"device_code":"dev-1","user_code":"ABCD-EFGH","verification_uri":"https://idp.example/device","expires_in":5000,"interval":1}`)
+	case "/token":
+		switch r.Form.Get("grant_type") {
+		case deviceCodeGrantType:
+			if s.deny {
+				s.oauthError(w, "access_denied")
+				return
+			}
+			if s.pending > 0 {
+				s.pending--
+				s.oauthError(w, "authorization_pending")
+				return
+			}
+		case "refresh_token":
+			if !s.valid[r.Form.Get("refresh_token")] {
+				s.oauthError(w, "invalid_grant")
+				return
+			}
+			if s.rotate {
+				delete(s.valid, r.Form.Get("refresh_token"))
+			}
+		}
+		s.issued++
+		access, refresh := fmt.Sprintf("access-%d", s.issued), fmt.Sprintf("refresh-%d", s.issued)
+		s.valid[access], s.valid[refresh] = true, true
+		fmt.Fprintf(w, `{"access_token":%q,"refresh_token":%q,"token_type":"Bearer","expires_in":3600}`, access, refresh)
+	case "/revoke":
+		delete(s.valid, r.Form.Get("token"))
+		s.revoked = append(s.revoked, r.Form.Get("token"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *fakeAuthServer) oauthError(w http.ResponseWriter, code string) {
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, `{"error":%q}`, code)
+}
+
+func (s *fakeAuthServer) serveCloud(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/api/v1/cli/auth/sso" {
+		fmt.Fprintf(w, `{"data":{"issuer":%q,"client_id":"ctx-cli","scopes":["openid","offline_access"]}}`, s.issuer.URL)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !s.valid[token] || s.rejectAll {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	fmt.Fprint(w, `{"data":{}}`)
+}
+
+// memoryTokenStore is a TokenStore counting the saved tokens.
+type memoryTokenStore struct {
+	lock  sync.Mutex
+	token *OAuthToken
+	saves int
+	// next replaces token once it's loaded, as another process would
+	next *OAuthToken
+}
+
+func (m *memoryTokenStore) LoadToken() (*OAuthToken, error) {
+	if m.token == nil {
+		return nil, nil
+	}
+	token := *m.token
+	if m.next != nil {
+		m.token, m.next = m.next, nil
+	}
+	return &token, nil
+}
+
+func (m *memoryTokenStore) SaveToken(token *OAuthToken) error {
+	m.token = token
+	m.saves++
+	return nil
+}
+
+func (m *memoryTokenStore) LockToken() (func(), error) {
+	m.lock.Lock()
+	return m.lock.Unlock, nil
+}
+
+func TestDeviceFlowLogin(t *testing.T) {
+	server := newFakeAuthServer(t)
+	server.pending = 2
+
+	settings, err := GetSSOSettings(context.Background(), nil, server.cloud.URL)
+	if err != nil {
+		t.Fatalf("GetSSOSettings() error = %v", err)
+	}
+	oidc, err := DiscoverOIDC(context.Background(), nil, settings.Issuer, settings.ClientID, settings.Scopes)
+	if err != nil {
+		t.Fatalf("DiscoverOIDC(context.Background(), nil, ) error = %v", err)
+	}
+	if oidc.RevocationURL != server.issuer.URL+"/revoke" {
+		t.Errorf("RevocationURL = %q", oidc.RevocationURL)
+	}
+
+	auth, err := oidc.StartDeviceAuthorization(context.Background())
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization() error = %v", err)
+	}
+	if auth.UserCode != "ABCD-EFGH" {
+		t.Errorf("UserCode = %q", auth.UserCode)
+	}
+
+	token, err := oidc.PollDeviceToken(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("PollDeviceToken() error = %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" || token.Expiry.IsZero() {
+		t.Errorf("PollDeviceToken() = %+v", token)
+	}
+	if server.pending != 0 {
+		t.Errorf("stopped polling with %d pending answers left", server.pending)
+	}
+}
+
+func TestPollDeviceTokenDenied(t *testing.T) {
+	server := newFakeAuthServer(t)
+	server.deny = true
+
+	oidc, err := DiscoverOIDC(context.Background(), nil, server.issuer.URL, "ctx-cli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := oidc.StartDeviceAuthorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oidc.PollDeviceToken(context.Background(), auth); !errors.Is(err, ErrAuthorizationDenied) {
+		t.Errorf("PollDeviceToken() error = %v, want ErrAuthorizationDenied", err)
+	}
+}
+
+func TestSSOClientRefresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     func(s *fakeAuthServer) *OAuthToken
+		wantErr   error
+		wantSaves int
+	}{
+		{
+			name: "valid token",
+			token: func(s *fakeAuthServer) *OAuthToken {
+				s.valid["access-0"] = true
+				return &OAuthToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: time.Now().Add(time.Hour)}
+			},
+		},
+		{
+			name: "expired token",
+			token: func(s *fakeAuthServer) *OAuthToken {
+				s.valid["refresh-0"] = true
+				return &OAuthToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: time.Now().Add(-time.Minute)}
+			},
+			wantSaves: 1,
+		},
+		{
+			name: "revoked before expiry",
+			token: func(s *fakeAuthServer) *OAuthToken {
+				s.valid["refresh-0"] = true
+				return &OAuthToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: time.Now().Add(time.Hour)}
+			},
+			wantSaves: 1,
+		},
+		{
+			name: "refresh token revoked",
+			token: func(s *fakeAuthServer) *OAuthToken {
+				return &OAuthToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: time.Now().Add(-time.Minute)}
+			},
+			wantErr: ErrSessionExpired,
+		},
+		{
+			name:    "not logged in",
+			token:   func(s *fakeAuthServer) *OAuthToken { return nil },
+			wantErr: ErrNotLoggedIn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeAuthServer(t)
+			oidc, err := DiscoverOIDC(context.Background(), nil, server.issuer.URL, "ctx-cli", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := &memoryTokenStore{token: tt.token(server)}
+			client := NewSSOClient(server.cloud.URL, oidc, store)
+
+			err = client.TestConnection(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("TestConnection() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TestConnection() error = %v", err)
+			}
+			if store.saves != tt.wantSaves {
+				t.Errorf("saved %d tokens, want %d", store.saves, tt.wantSaves)
+			}
+
+			// The refreshed token is kept for the next request
+			if err := client.TestConnection(context.Background()); err != nil {
+				t.Fatalf("second TestConnection() error = %v", err)
+			}
+			if store.saves != tt.wantSaves {
+				t.Errorf("second request saved %d tokens, want %d", store.saves, tt.wantSaves)
+			}
+		})
+	}
+}
+
+func TestSSOClientRetriesOnce(t *testing.T) {
+	server := newFakeAuthServer(t)
+	oidc, err := DiscoverOIDC(context.Background(), nil, server.issuer.URL, "ctx-cli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.valid["refresh-0"] = true
+	store := &memoryTokenStore{token: &OAuthToken{AccessToken: "access-0", RefreshToken: "refresh-0"}}
+	client := NewSSOClient(server.cloud.URL, oidc, store)
+
+	// A server rejecting every token gets one retry, not a refresh loop
+	server.rejectAll = true
+
+	var apiErr *APIError
+	if err := client.TestConnection(context.Background()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("TestConnection() error = %v, want 401", err)
+	}
+	if store.saves != 1 {
+		t.Errorf("saved %d tokens, want 1 refresh", store.saves)
+	}
+}
+
+func TestSSOClientSharedRefresh(t *testing.T) {
+	server := newFakeAuthServer(t)
+	oidc, err := DiscoverOIDC(context.Background(), nil, server.issuer.URL, "ctx-cli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.rotate = true
+	server.valid["refresh-0"] = true
+	store := &memoryTokenStore{token: &OAuthToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: time.Now().Add(-time.Minute)}}
+
+	// Processes sharing the tokens refresh them once, and the others use
+	// the refreshed token instead of the spent refresh token
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		client := NewSSOClient(server.cloud.URL, oidc, store)
+		wg.Go(func() { errs[i] = client.TestConnection(context.Background()) })
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("TestConnection() of client %d error = %v", i, err)
+		}
+	}
+	if store.saves != 1 || server.issued != 1 {
+		t.Errorf("saved %d tokens and issued %d, want 1 refresh", store.saves, server.issued)
+	}
+}
+
+func TestSSOClientReloadsReplacedToken(t *testing.T) {
+	server := newFakeAuthServer(t)
+	oidc, err := DiscoverOIDC(context.Background(), nil, server.issuer.URL, "ctx-cli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.valid["access-9"] = true
+
+	// The refresh token is refused because a new login replaced it
+	store := &memoryTokenStore{
+		token: &OAuthToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: time.Now().Add(-time.Minute)},
+		next:  &OAuthToken{AccessToken: "access-9", RefreshToken: "refresh-9", Expiry: time.Now().Add(time.Hour)},
+	}
+	client := NewSSOClient(server.cloud.URL, oidc, store)
+	if err := client.TestConnection(context.Background()); err != nil {
+		t.Fatalf("TestConnection() error = %v, want the replaced token used", err)
+	}
+	if token := client.Token(); token.AccessToken != "access-9" {
+		t.Errorf("Token() = %+v, want the replaced token", token)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	server := newFakeAuthServer(t)
+	oidc, err := DiscoverOIDC(context.Background(), nil, server.issuer.URL, "ctx-cli", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.valid["refresh-0"] = true
+
+	if err := oidc.Revoke(context.Background(), "refresh-0", "refresh_token"); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := oidc.Refresh(context.Background(), "refresh-0"); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("Refresh() after Revoke() error = %v, want ErrSessionExpired", err)
+	}
+
+	oidc.RevocationURL = ""
+	if err := oidc.Revoke(context.Background(), "access-0", "access_token"); err != nil {
+		t.Errorf("Revoke() without endpoint error = %v", err)
+	}
+	if data, _ := json.Marshal(server.revoked); string(data) != `["refresh-0"]` {
+		t.Errorf("revoked = %s", data)
+	}
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// PolicyCacheFile is the state file caching the team policies last fetched
+// from ctx-cloud, so they're enforced offline too.
+const PolicyCacheFile = "cloud-policy.json"
+
+// ErrInvalidPolicySignature is returned when a policy document isn't signed
+// by the team's policy key.
+var ErrInvalidPolicySignature = errors.New("policy document signature is invalid")
+
+// SignedPolicy is a team policy document as served by ctx-cloud, with the
+// Ed25519 signature of the document.
+type SignedPolicy struct {
+	Document  string `json:"document"`
+	Signature string `json:"signature"`
+	Version   int    `json:"version,omitempty"`
+	// ETag is the entity tag the server sent with the document, if any.
+	ETag string `json:"etag,omitempty"`
+	// FetchedAt is when the document was last fetched or found unchanged.
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// ParsePolicyKey parses a base64-encoded Ed25519 public key.
+func ParsePolicyKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid policy key: want %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Verify checks the signature of the policy document with the team's public
+// key and parses the document.
+func (p *SignedPolicy) Verify(publicKey ed25519.PublicKey) (*config.PolicyDocument, error) {
+	sig, err := base64.StdEncoding.DecodeString(p.Signature)
+	if err != nil || !ed25519.Verify(publicKey, []byte(p.Document), sig) {
+		return nil, ErrInvalidPolicySignature
+	}
+	return config.ParsePolicyDocument([]byte(p.Document))
+}
+
+// FetchPolicy fetches the team policy document. If etag is set and the
+// document still matches it, ErrNotModified is returned. It returns nil if
+// the team has no policies.
+func (c *Client) FetchPolicy(ctx context.Context, etag string) (*SignedPolicy, error) {
+	if !c.IsConfigured() {
+		return nil, ErrNotConfigured
+	}
+
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	respBody, resp, err := c.requestWithHeader(ctx, "GET", "/api/v1/cli/policy", nil, header)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+
+	var apiResp apiResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	var policy SignedPolicy
+	if err := json.Unmarshal(apiResp.Data, &policy); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	policy.ETag = resp.Header.Get("ETag")
+	policy.FetchedAt = time.Now()
+
+	return &policy, nil
+}
+
+// PolicyCache holds the last policy document fetched from ctx-cloud. The
+// document is cached with its signature and verified each time it's used.
+type PolicyCache struct {
+	store *config.StateStore
+}
+
+// NewPolicyCache returns the policy cache in stateDir.
+func NewPolicyCache(stateDir string) *PolicyCache {
+	return &PolicyCache{store: config.NewStateStore(stateDir)}
+}
+
+// Load returns the cached policy, or nil if there is none.
+func (c *PolicyCache) Load() (*SignedPolicy, error) {
+	var policy SignedPolicy
+	if err := c.store.LoadJSON(PolicyCacheFile, &policy); err != nil {
+		if os.IsNotExist(err) || errors.Is(err, config.ErrCorruptState) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cached policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Save caches a policy.
+func (c *PolicyCache) Save(policy *SignedPolicy) error {
+	if err := c.store.SaveJSON(PolicyCacheFile, policy, 0o644); err != nil {
+		return fmt.Errorf("failed to cache policy: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the cached policy.
+func (c *PolicyCache) Clear() error {
+	if err := c.store.Remove(PolicyCacheFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cached policy: %w", err)
+	}
+	return nil
+}
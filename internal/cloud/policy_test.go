@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testPolicyDocument = `policies:
+  - name: prod-reason
+    match: {environments: [production]}
+    require_reason: true
+`
+
+func TestFetchPolicy(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(testPolicyDocument)))
+
+	empty := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/cli/policy" || empty {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"p3"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		data, _ := json.Marshal(map[string]any{"document": testPolicyDocument, "signature": signature, "version": 3})
+		w.Header().Set("ETag", `"p3"`)
+		w.Write([]byte(`{"data":` + string(data) + `}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	policy, err := client.FetchPolicy(context.Background(), "")
+	if err != nil {
+		t.Fatalf("FetchPolicy() error = %v", err)
+	}
+	if policy.Version != 3 || policy.ETag != `"p3"` || policy.FetchedAt.IsZero() {
+		t.Errorf("FetchPolicy() = %+v", policy)
+	}
+	if _, err := client.FetchPolicy(context.Background(), policy.ETag); !errors.Is(err, ErrNotModified) {
+		t.Errorf("FetchPolicy(etag) error = %v, want ErrNotModified", err)
+	}
+
+	doc, err := policy.Verify(publicKey)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(doc.Policies) != 1 || doc.Policies[0].Name != "prod-reason" {
+		t.Errorf("Verify() = %+v", doc)
+	}
+
+	// A cached document edited locally no longer verifies
+	cache := NewPolicyCache(t.TempDir())
+	policy.Document += "  - name: extra\n    require_reason: true\n"
+	if err := cache.Save(policy); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := cache.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, err := cached.Verify(publicKey); !errors.Is(err, ErrInvalidPolicySignature) {
+		t.Errorf("Verify() of edited document error = %v, want ErrInvalidPolicySignature", err)
+	}
+
+	empty = true
+	if policy, err := client.FetchPolicy(context.Background(), ""); err != nil || policy != nil {
+		t.Errorf("FetchPolicy() without policies = %v, %v, want nil", policy, err)
+	}
+}
+
+func TestParsePolicyKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParsePolicyKey(base64.StdEncoding.EncodeToString(publicKey))
+	if err != nil || !key.Equal(publicKey) {
+		t.Errorf("ParsePolicyKey() = %v, %v", key, err)
+	}
+	for _, invalid := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParsePolicyKey(invalid); err == nil {
+			t.Errorf("ParsePolicyKey(%q) error = nil", invalid)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+const (
+	// SpoolFile is the state file holding audit events waiting to be sent.
+	SpoolFile = "cloud-spool.json"
+	// spoolBaseBackoff is how long to wait before retrying an event that
+	// failed once. The wait doubles with every failure.
+	spoolBaseBackoff = 30 * time.Second
+	// spoolMaxBackoff is the longest wait between retries.
+	spoolMaxBackoff = time.Hour
+	// maxSpoolEvents is the number of events kept. Beyond it, the oldest
+	// events are dropped.
+	maxSpoolEvents = 1000
+)
+
+// SpooledEvent is an audit event waiting to be sent to the cloud server.
+type SpooledEvent struct {
+	Key         string      `json:"key"`
+	Event       *AuditEvent `json:"event"`
+	QueuedAt    time.Time   `json:"queued_at"`
+	Attempts    int         `json:"attempts"`
+	NextAttempt time.Time   `json:"next_attempt,omitzero"`
+	LastError   string      `json:"last_error,omitempty"`
+}
+
+// EventSpool is a durable queue of audit events for the cloud server, so
+// events aren't lost while the server can't be reached.
+type EventSpool struct {
+	store *config.StateStore
+	now   func() time.Time
+}
+
+// NewEventSpool returns the event spool in stateDir.
+func NewEventSpool(stateDir string) *EventSpool {
+	return &EventSpool{store: config.NewStateStore(stateDir), now: time.Now}
+}
+
+// Enqueue adds an event to the spool, due to be sent right away.
+func (s *EventSpool) Enqueue(event *AuditEvent) error {
+	return s.update(func(events []SpooledEvent) []SpooledEvent {
+		events = append(events, SpooledEvent{
+			Key:      NewIdempotencyKey(),
+			Event:    event,
+			QueuedAt: s.now().UTC(),
+		})
+		if len(events) > maxSpoolEvents {
+			events = events[len(events)-maxSpoolEvents:]
+		}
+		return events
+	})
+}
+
+// Pending returns the events waiting to be sent, oldest first.
+func (s *EventSpool) Pending() ([]SpooledEvent, error) {
+	var events []SpooledEvent
+	if err := s.store.LoadJSON(SpoolFile, &events); err != nil && !os.IsNotExist(err) && !errors.Is(err, config.ErrCorruptState) {
+		return nil, fmt.Errorf("failed to read event spool: %w", err)
+	}
+	return events, nil
+}
+
+// Flush sends the events that are due, oldest first, and returns how many
+// were sent. Events the server rejects are dropped. When an event can't be
+// sent, the server is assumed to be unreachable: the event is retried after
+// a backoff and the events after it wait as long.
+func (s *EventSpool) Flush(ctx context.Context, client *Client) (int, error) {
+	events, err := s.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	// Send without holding the lock, so other shells aren't held up while
+	// the server is slow. If two shells send the same event, the server
+	// drops the duplicate by its key.
+	now := s.now()
+	done := make(map[string]bool)
+	retry := make(map[string]SpooledEvent)
+	var sendErr, rejectErr error
+	var retryAt time.Time
+	for _, event := range events {
+		if event.NextAttempt.After(now) {
+			continue
+		}
+		if sendErr != nil {
+			// Don't try the rest until the server is back
+			event.NextAttempt = retryAt
+			retry[event.Key] = event
+			continue
+		}
+
+		err := client.SendAuditEventWithKey(ctx, event.Key, event.Event)
+		var apiErr *APIError
+		switch {
+		case err == nil:
+			done[event.Key] = true
+		case errors.As(err, &apiErr) && apiErr.Permanent():
+			// The server is up, it just won't take this event
+			done[event.Key] = true
+			if rejectErr == nil {
+				rejectErr = fmt.Errorf("server rejected %s event: %w", event.Event.Action, err)
+			}
+		default:
+			event.Attempts++
+			event.LastError = err.Error()
+			retryAt = now.Add(spoolBackoff(event.Attempts))
+			event.NextAttempt = retryAt
+			retry[event.Key] = event
+			sendErr = err
+		}
+	}
+
+	if len(done) > 0 || len(retry) > 0 {
+		err := s.update(func(events []SpooledEvent) []SpooledEvent {
+			var kept []SpooledEvent
+			for _, event := range events {
+				if done[event.Key] {
+					continue
+				}
+				if updated, ok := retry[event.Key]; ok {
+					event = updated
+				}
+				kept = append(kept, event)
+			}
+			return kept
+		})
+		if err != nil {
+			return len(done), err
+		}
+	}
+	if sendErr != nil {
+		return len(done), sendErr
+	}
+	return len(done), rejectErr
+}
+
+// update applies fn to the spooled events under the spool's lock.
+func (s *EventSpool) update(fn func([]SpooledEvent) []SpooledEvent) error {
+	lock, err := s.store.Lock(SpoolFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	var events []SpooledEvent
+	if err := lock.LoadJSON(&events); err != nil && !os.IsNotExist(err) && !errors.Is(err, config.ErrCorruptState) {
+		return fmt.Errorf("failed to read event spool: %w", err)
+	}
+
+	events = fn(events)
+	if len(events) == 0 {
+		if err := lock.Remove(); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := lock.SaveJSON(events, 0o600); err != nil {
+		return fmt.Errorf("failed to write event spool: %w", err)
+	}
+	return nil
+}
+
+// spoolBackoff returns how long to wait after an event failed attempts times.
+func spoolBackoff(attempts int) time.Duration {
+	backoff := spoolBaseBackoff
+	for range attempts - 1 {
+		backoff *= 2
+		if backoff >= spoolMaxBackoff {
+			return spoolMaxBackoff
+		}
+	}
+	return backoff
+}
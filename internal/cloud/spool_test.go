@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// auditServer is a test server recording the audit events it receives.
+type auditServer struct {
+	mu     sync.Mutex
+	status int
+	keys   []string
+	events []AuditEvent
+}
+
+func (s *auditServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "ERR", "message": "nope"}})
+		return
+	}
+	var event AuditEvent
+	json.NewDecoder(r.Body).Decode(&event)
+	s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+	s.events = append(s.events, event)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func newTestSpool(t *testing.T) (*EventSpool, *auditServer, *Client, *time.Time) {
+	t.Helper()
+	fastRetries(t)
+	server := &auditServer{}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	spool := NewEventSpool(t.TempDir())
+	spool.now = func() time.Time { return now }
+	return spool, server, NewClient(ts.URL, "sk_test"), &now
+}
+
+func TestEventSpool_FlushSendsAndRemoves(t *testing.T) {
+	spool, server, client, _ := newTestSpool(t)
+
+	for _, action := range []string{"switch", "vpn.connect"} {
+		if err := spool.Enqueue(&AuditEvent{Action: action, ContextName: "dev", Success: true}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+
+	sent, err := spool.Flush(context.Background(), client)
+	if err != nil || sent != 2 {
+		t.Fatalf("Flush() = %d, %v, want 2, nil", sent, err)
+	}
+	if len(server.events) != 2 || server.events[0].Action != "switch" || server.events[1].Action != "vpn.connect" {
+		t.Errorf("server got %+v", server.events)
+	}
+	if server.keys[0] == "" || server.keys[0] == server.keys[1] {
+		t.Errorf("idempotency keys = %q", server.keys)
+	}
+
+	pending, err := spool.Pending()
+	if err != nil || len(pending) != 0 {
+		t.Errorf("Pending() = %v, %v, want empty", pending, err)
+	}
+}
+
+func TestEventSpool_RetriesWithBackoff(t *testing.T) {
+	spool, server, client, now := newTestSpool(t)
+
+	spool.Enqueue(&AuditEvent{Action: "switch", ContextName: "dev"})
+	spool.Enqueue(&AuditEvent{Action: "deactivate", ContextName: "dev"})
+
+	server.status = http.StatusServiceUnavailable
+	if _, err := spool.Flush(context.Background(), client); err == nil {
+		t.Fatal("Flush() error = nil, want error")
+	}
+
+	pending, _ := spool.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("got %d pending events, want 2", len(pending))
+	}
+	if pending[0].Attempts != 1 || pending[0].LastError == "" {
+		t.Errorf("first event = %+v, want one failed attempt", pending[0])
+	}
+	// Only the first event was tried, the second waits as long
+	if pending[1].Attempts != 0 || !pending[1].NextAttempt.Equal(pending[0].NextAttempt) {
+		t.Errorf("second event = %+v", pending[1])
+	}
+	key := pending[0].Key
+
+	// Before the backoff is up, nothing is sent
+	server.status = 0
+	*now = now.Add(spoolBaseBackoff - time.Second)
+	if sent, err := spool.Flush(context.Background(), client); sent != 0 || err != nil {
+		t.Errorf("Flush() during backoff = %d, %v, want 0, nil", sent, err)
+	}
+
+	*now = now.Add(time.Second)
+	if sent, err := spool.Flush(context.Background(), client); sent != 2 || err != nil {
+		t.Errorf("Flush() after backoff = %d, %v, want 2, nil", sent, err)
+	}
+	// The retry reuses the key so the server can dedupe
+	if len(server.keys) != 2 || server.keys[0] != key {
+		t.Errorf("server got keys %q, want first %q", server.keys, key)
+	}
+}
+
+func TestEventSpool_DropsRejectedEvents(t *testing.T) {
+	spool, server, client, _ := newTestSpool(t)
+
+	spool.Enqueue(&AuditEvent{Action: "switch", ContextName: "dev"})
+	server.status = http.StatusBadRequest
+
+	_, err := spool.Flush(context.Background(), client)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Flush() error = %v, want the API error", err)
+	}
+	if pending, _ := spool.Pending(); len(pending) != 0 {
+		t.Errorf("rejected event still queued: %+v", pending)
+	}
+}
+
+func TestEventSpool_EnqueueDropsOldest(t *testing.T) {
+	spool, _, _, _ := newTestSpool(t)
+
+	spool.update(func([]SpooledEvent) []SpooledEvent {
+		full := make([]SpooledEvent, maxSpoolEvents)
+		for i := range full {
+			full[i] = SpooledEvent{Key: NewIdempotencyKey(), Event: &AuditEvent{Action: "switch"}}
+		}
+		return full
+	})
+	spool.Enqueue(&AuditEvent{Action: "deactivate"})
+
+	pending, err := spool.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != maxSpoolEvents || pending[len(pending)-1].Event.Action != "deactivate" {
+		t.Errorf("got %d events, last %+v", len(pending), pending[len(pending)-1].Event)
+	}
+}
+
+func TestSpoolBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := spoolBackoff(tt.attempts); got != tt.want {
+			t.Errorf("spoolBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
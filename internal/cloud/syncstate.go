@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// SyncStateFile is the state file recording which version of each context
+// was last pulled from or pushed to ctx-cloud.
+const SyncStateFile = "cloud-sync.json"
+
+// SyncRecord records the last sync of a context with ctx-cloud.
+type SyncRecord struct {
+	// Version is the version of the context that was synced.
+	Version int `json:"version"`
+	// ETag is the entity tag the server sent with that version.
+	ETag string `json:"etag,omitempty"`
+	// Hash is the hash of the local context file as it was synced, to tell
+	// whether it has been edited since.
+	Hash string `json:"hash"`
+	// RemoteVersion is the newest version seen on the server.
+	RemoteVersion int       `json:"remote_version"`
+	SyncedAt      time.Time `json:"synced_at"`
+}
+
+// Modified reports whether the local context file differs from the synced one.
+func (r SyncRecord) Modified(data []byte) bool {
+	return HashContextFile(data) != r.Hash
+}
+
+// OutOfDate reports whether a newer version was seen on the server.
+func (r SyncRecord) OutOfDate() bool {
+	return r.RemoteVersion > r.Version
+}
+
+// HashContextFile returns the hash recorded for a context file's contents.
+func HashContextFile(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SyncState holds the sync records of the contexts synced with ctx-cloud.
+type SyncState struct {
+	store *config.StateStore
+}
+
+// NewSyncState returns the sync state in stateDir.
+func NewSyncState(stateDir string) *SyncState {
+	return &SyncState{store: config.NewStateStore(stateDir)}
+}
+
+// Records returns the sync records by context name.
+func (s *SyncState) Records() (map[string]SyncRecord, error) {
+	records := make(map[string]SyncRecord)
+	if err := s.store.LoadJSON(SyncStateFile, &records); err != nil && !os.IsNotExist(err) && !errors.Is(err, config.ErrCorruptState) {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	return records, nil
+}
+
+// Update applies fn to the sync records under the sync state's lock.
+func (s *SyncState) Update(fn func(records map[string]SyncRecord)) error {
+	lock, err := s.store.Lock(SyncStateFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	records := make(map[string]SyncRecord)
+	if err := lock.LoadJSON(&records); err != nil && !os.IsNotExist(err) && !errors.Is(err, config.ErrCorruptState) {
+		return fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	fn(records)
+	if err := lock.SaveJSON(records, 0o644); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+// SetRemoteVersions records the newest versions seen on the server for the
+// contexts that have been synced.
+func (s *SyncState) SetRemoteVersions(contexts []*SharedContext) error {
+	return s.Update(func(records map[string]SyncRecord) {
+		for _, ctx := range contexts {
+			if record, ok := records[ctx.Name]; ok && ctx.Version > record.RemoteVersion {
+				record.RemoteVersion = ctx.Version
+				records[ctx.Name] = record
+			}
+		}
+	})
+}
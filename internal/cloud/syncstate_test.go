@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// contextServer is a test server holding one versioned context, with the
+// version as its ETag.
+type contextServer struct {
+	mu      sync.Mutex
+	ctx     SharedContext
+	headers http.Header
+	path    string
+}
+
+func (s *contextServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path != "/api/v1/cli/sync/"+s.ctx.Name {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.headers = r.Header.Clone()
+	s.path = r.URL.EscapedPath()
+	etag := strconv.Quote(strconv.Itoa(s.ctx.Version))
+
+	switch r.Method {
+	case "GET":
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	case "PUT":
+		var req pushContextRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != etag || req.BaseVersion != 0 && req.BaseVersion != s.ctx.Version {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"error":{"code":"version_conflict","message":"context was updated"}}`)
+			return
+		}
+		s.ctx.Config = req.Config
+		s.ctx.Version++
+		etag = strconv.Quote(strconv.Itoa(s.ctx.Version))
+	}
+
+	data, _ := json.Marshal(s.ctx)
+	w.Header().Set("ETag", etag)
+	fmt.Fprintf(w, `{"data":%s}`, data)
+}
+
+func newContextServer(t *testing.T, version int) (*contextServer, *Client) {
+	t.Helper()
+	server := &contextServer{ctx: SharedContext{
+		Name:    "team",
+		Config:  map[string]any{"aws": map[string]any{"region": "eu-west-1"}},
+		Version: version,
+	}}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return server, NewClient(ts.URL, "sk_test")
+}
+
+func TestFetchContextETag(t *testing.T) {
+	_, client := newContextServer(t, 3)
+
+	ctx, err := client.FetchContext(context.Background(), "team", "")
+	if err != nil {
+		t.Fatalf("FetchContext() error = %v", err)
+	}
+	if ctx.Version != 3 || ctx.ETag != `"3"` {
+		t.Errorf("FetchContext() version = %d, etag = %s, want 3, \"3\"", ctx.Version, ctx.ETag)
+	}
+
+	if _, err := client.FetchContext(context.Background(), "team", ctx.ETag); !errors.Is(err, ErrNotModified) {
+		t.Errorf("FetchContext() with current etag error = %v, want ErrNotModified", err)
+	}
+	if _, err := client.FetchContext(context.Background(), "team", `"2"`); err != nil {
+		t.Errorf("FetchContext() with old etag error = %v", err)
+	}
+}
+
+func TestPushContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int
+		etag        string
+		force       bool
+		wantErr     error
+		wantVersion int
+	}{
+		{name: "current version", version: 3, etag: `"3"`, wantVersion: 4},
+		{name: "stale version", version: 2, etag: `"2"`, wantErr: ErrVersionConflict},
+		{name: "stale version without etag", version: 2, wantErr: ErrVersionConflict},
+		{name: "stale version forced", version: 2, etag: `"2"`, force: true, wantVersion: 4},
+		{name: "create existing", version: 0, wantErr: ErrVersionConflict},
+		{name: "create existing forced", version: 0, force: true, wantVersion: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newContextServer(t, 3)
+
+			pushed, err := client.PushContext(context.Background(), &SharedContext{
+				Name:    "team",
+				Config:  map[string]any{"aws": map[string]any{"region": "us-east-1"}},
+				Version: tt.version,
+				ETag:    tt.etag,
+			}, tt.force)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("PushContext() error = %v, want %v", err, tt.wantErr)
+				}
+				if server.ctx.Version != 3 {
+					t.Errorf("server version = %d after a refused push, want 3", server.ctx.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PushContext() error = %v", err)
+			}
+			if pushed.Version != tt.wantVersion || pushed.ETag != strconv.Quote(strconv.Itoa(tt.wantVersion)) {
+				t.Errorf("PushContext() version = %d, etag = %s, want %d", pushed.Version, pushed.ETag, tt.wantVersion)
+			}
+			if tt.force && (server.headers.Get("If-Match") != "" || server.headers.Get("If-None-Match") != "") {
+				t.Errorf("forced push sent preconditions: %v", server.headers)
+			}
+		})
+	}
+}
+
+func TestSyncNamespacedContext(t *testing.T) {
+	server, client := newContextServer(t, 3)
+	server.ctx.Name = "acme/payments/prod"
+	const wantPath = "/api/v1/cli/sync/acme%2Fpayments%2Fprod"
+
+	ctx, err := client.FetchContext(context.Background(), "acme/payments/prod", "")
+	if err != nil {
+		t.Fatalf("FetchContext() error = %v", err)
+	}
+	if server.path != wantPath {
+		t.Errorf("FetchContext() requested %s, want %s", server.path, wantPath)
+	}
+
+	if _, err := client.PushContext(context.Background(), ctx, false); err != nil {
+		t.Fatalf("PushContext() error = %v", err)
+	}
+	if server.path != wantPath {
+		t.Errorf("PushContext() requested %s, want %s", server.path, wantPath)
+	}
+}
+
+func TestPushContextRetry(t *testing.T) {
+	fastRetries(t)
+
+	// The first response is lost, the server answers the retry from the key
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("ETag", `"4"`)
+		fmt.Fprint(w, `{"data":{"name":"team","version":4}}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "sk_test")
+	pushed, err := client.PushContext(context.Background(), &SharedContext{Name: "team", Version: 3, ETag: `"3"`}, false)
+	if err != nil {
+		t.Fatalf("PushContext() error = %v", err)
+	}
+	if pushed.Version != 4 {
+		t.Errorf("PushContext() version = %d, want 4", pushed.Version)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Idempotency-Key of each attempt = %q, want the same key twice", keys)
+	}
+}
+
+func TestSyncState(t *testing.T) {
+	state := NewSyncState(t.TempDir())
+
+	records, err := state.Records()
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Records() = %v, %v, want empty", records, err)
+	}
+
+	data := []byte("name: team\n")
+	err = state.Update(func(records map[string]SyncRecord) {
+		records["team"] = SyncRecord{Version: 3, Hash: HashContextFile(data), RemoteVersion: 3}
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	err = state.SetRemoteVersions([]*SharedContext{
+		{Name: "team", Version: 5},
+		{Name: "other", Version: 1},
+	})
+	if err != nil {
+		t.Fatalf("SetRemoteVersions() error = %v", err)
+	}
+
+	records, err = state.Records()
+	if err != nil {
+		t.Fatalf("Records() error = %v", err)
+	}
+	if _, ok := records["other"]; ok {
+		t.Error("SetRemoteVersions() recorded a context that was never synced")
+	}
+	record := records["team"]
+	if !record.OutOfDate() || record.RemoteVersion != 5 {
+		t.Errorf("record = %+v, want out of date with remote version 5", record)
+	}
+	if record.Modified(data) {
+		t.Error("Modified() = true for the synced file")
+	}
+	if !record.Modified([]byte("name: team\nenvironment: prod\n")) {
+		t.Error("Modified() = false for an edited file")
+	}
+}
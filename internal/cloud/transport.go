@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// requestTimeout limits each attempt of a request to ctx-cloud or its
+// identity provider.
+const requestTimeout = 10 * time.Second
+
+// TransportConfig holds the TLS and proxy settings used to reach ctx-cloud.
+type TransportConfig struct {
+	// CAFile is a PEM file of CA certificates trusted besides the system
+	// ones, for servers behind a corporate CA.
+	CAFile string
+	// ClientCert and ClientKey are the PEM certificate and key presented to
+	// servers that require mutual TLS.
+	ClientCert string
+	ClientKey  string
+	// Proxy is the proxy to connect through. Without it, the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables are used.
+	Proxy *config.ProxyConfig
+}
+
+// CertificateError is returned when a CA file or client certificate can't be
+// loaded.
+type CertificateError struct {
+	Path string
+	Err  error
+}
+
+func (e *CertificateError) Error() string {
+	return fmt.Sprintf("failed to load certificate %s: %v", e.Path, e.Err)
+}
+
+func (e *CertificateError) Unwrap() error {
+	return e.Err
+}
+
+// NewHTTPClient returns an HTTP client with the given TLS and proxy settings.
+func NewHTTPClient(cfg TransportConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(cfg.Proxy)
+
+	if cfg.CAFile != "" || cfg.ClientCert != "" || cfg.ClientKey != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, &CertificateError{Path: cfg.CAFile, Err: err}
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, &CertificateError{Path: cfg.CAFile, Err: fmt.Errorf("no PEM certificates found")}
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		if cfg.ClientCert != "" || cfg.ClientKey != "" {
+			if cfg.ClientCert == "" || cfg.ClientKey == "" {
+				return nil, fmt.Errorf("client_cert and client_key must be set together")
+			}
+			cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+			if err != nil {
+				return nil, &CertificateError{Path: cfg.ClientCert, Err: err}
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport, Timeout: requestTimeout}, nil
+}
+
+// proxyFunc returns the proxy selection for a context's proxy settings. Like
+// the environment variables, https requests use the https proxy and http
+// requests the http one.
+func proxyFunc(proxy *config.ProxyConfig) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return http.ProxyFromEnvironment
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL := proxy.HTTP
+		if req.URL.Scheme == "https" {
+			proxyURL = proxy.HTTPS
+		}
+		if proxyURL == "" || bypassProxy(req.URL.Hostname(), proxy.NoProxy) {
+			return nil, nil
+		}
+		if !strings.Contains(proxyURL, "://") {
+			proxyURL = "http://" + proxyURL
+		}
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address %q: %w", proxyURL, err)
+		}
+		return u, nil
+	}
+}
+
+// bypassProxy reports whether host is excluded from the proxy by noProxy, a
+// comma-separated list of host names, domains, IP addresses and CIDR ranges.
+// A domain also matches its subdomains, and * matches every host.
+func bypassProxy(host, noProxy string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for entry := range strings.SplitSeq(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package cloud
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vlebo/ctx/internal/config"
+)
+
+// writeServerCA writes the certificate of a TLS test server to a PEM file.
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeClientCert writes a self-signed client certificate and its key to PEM
+// files, and returns them with the certificate.
+func writeClientCert(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ctx test client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"data":{}}`))
+}
+
+func TestNewHTTPClientCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(statsHandler))
+	defer server.Close()
+
+	// The test server's certificate isn't trusted by default
+	client := NewClient(server.URL, "test-key")
+	err := client.TestConnection(context.Background())
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("TestConnection() without CA error = %v, want *RequestError", err)
+	}
+
+	httpClient, err := NewHTTPClient(TransportConfig{CAFile: writeServerCA(t, server)})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	client.SetHTTPClient(httpClient)
+	if err := client.TestConnection(context.Background()); err != nil {
+		t.Errorf("TestConnection() with CA error = %v", err)
+	}
+}
+
+func TestNewHTTPClientMutualTLS(t *testing.T) {
+	certFile, keyFile, cert := writeClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(statsHandler))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writeServerCA(t, server)
+
+	tests := []struct {
+		name    string
+		cfg     TransportConfig
+		wantErr bool
+	}{
+		{name: "without client certificate", cfg: TransportConfig{CAFile: caFile}, wantErr: true},
+		{name: "with client certificate", cfg: TransportConfig{CAFile: caFile, ClientCert: certFile, ClientKey: keyFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient, err := NewHTTPClient(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewHTTPClient() error = %v", err)
+			}
+			client := NewClient(server.URL, "test-key")
+			client.SetHTTPClient(httpClient)
+			client.maxRetries = 0
+
+			err = client.TestConnection(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TestConnection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientErrors(t *testing.T) {
+	certFile, keyFile, _ := writeClientCert(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name     string
+		cfg      TransportConfig
+		wantPath string
+	}{
+		{name: "missing CA file", cfg: TransportConfig{CAFile: missing}, wantPath: missing},
+		{name: "CA file without certificates", cfg: TransportConfig{CAFile: keyFile}, wantPath: keyFile},
+		{name: "key without certificate", cfg: TransportConfig{ClientKey: keyFile}},
+		{name: "mismatched key", cfg: TransportConfig{ClientCert: certFile, ClientKey: certFile}, wantPath: certFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPClient(tt.cfg)
+			if err == nil {
+				t.Fatal("NewHTTPClient() error = nil")
+			}
+			var certErr *CertificateError
+			if tt.wantPath != "" && (!errors.As(err, &certErr) || certErr.Path != tt.wantPath) {
+				t.Errorf("NewHTTPClient() error = %v, want *CertificateError for %s", err, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	proxy := &config.ProxyConfig{
+		HTTP:    "proxy.corp:3128",
+		HTTPS:   "https://secure-proxy.corp:3129",
+		NoProxy: "localhost, .internal, 10.0.0.0/8, cloud.example.com:443",
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "http://cloud.example.org/api", want: "http://proxy.corp:3128"},
+		{url: "https://cloud.example.org/api", want: "https://secure-proxy.corp:3129"},
+		{url: "https://localhost:8080/api"},
+		{url: "https://ctx.corp.internal/api"},
+		{url: "https://internal/api"},
+		{url: "https://10.1.2.3/api"},
+		{url: "https://cloud.example.com/api"},
+		{url: "https://11.1.2.3/api", want: "https://secure-proxy.corp:3129"},
+	}
+
+	proxyFor := proxyFunc(proxy)
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			got, err := proxyFor(req)
+			if err != nil {
+				t.Fatalf("proxy error = %v", err)
+			}
+			gotURL := ""
+			if got != nil {
+				gotURL = got.String()
+			}
+			if gotURL != tt.want {
+				t.Errorf("proxy = %q, want %q", gotURL, tt.want)
+			}
+		})
+	}
+
+	if got, _ := proxyFunc(&config.ProxyConfig{HTTP: "proxy.corp:3128"})(httptest.NewRequest(http.MethodGet, "https://cloud.example.org", nil)); got != nil {
+		t.Errorf("proxy for https without an https proxy = %v, want none", got)
+	}
+	if got, _ := proxyFunc(&config.ProxyConfig{HTTPS: "proxy.corp:3128", NoProxy: "*"})(httptest.NewRequest(http.MethodGet, "https://cloud.example.org", nil)); got != nil {
+		t.Errorf("proxy with NO_PROXY=* = %v, want none", got)
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	// A plain HTTP proxy sees the full URL of the requests sent through it
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		statsHandler(w, r)
+	}))
+	defer proxy.Close()
+
+	httpClient, err := NewHTTPClient(TransportConfig{Proxy: &config.ProxyConfig{HTTP: proxy.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("http://cloud.example.org", "test-key")
+	client.SetHTTPClient(httpClient)
+	if err := client.TestConnection(context.Background()); err != nil {
+		t.Fatalf("TestConnection() error = %v", err)
+	}
+	if proxied != "http://cloud.example.org/api/v1/sessions/stats" {
+		t.Errorf("proxy received %q, want the stats request", proxied)
+	}
+}
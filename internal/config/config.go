@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,6 +31,8 @@ const (
 	DefaultConfigDir = ".config/ctx"
 	// ConfigFileName is the name of the main configuration file.
 	ConfigFileName = "config.yaml"
+	// PolicyFileName is the name of the local policy file.
+	PolicyFileName = "policy.yaml"
 	// ContextsSubdir is the subdirectory for context files.
 	ContextsSubdir = "contexts"
 	// StateSubdir is the subdirectory for state files.
@@ -38,6 +41,9 @@ const (
 	CurrentNameFile = "current.name"
 	// CurrentEnvFile is the file that stores the current environment variables.
 	CurrentEnvFile = "current.env"
+	// CurrentDirFile is the file that stores the directory whose marker
+	// activated the current context, if one did.
+	CurrentDirFile = "current.dir"
 )
 
 // Manager handles configuration operations.
@@ -46,20 +52,25 @@ type Manager struct {
 	configDir   string
 	contextsDir string
 	stateDir    string
+	sessionID   string
+	sessionPID  int
 }
 
-// NewManager creates a new configuration manager.
+// NewManager creates a new configuration manager for the configuration
+// directory of the environment (see defaultDirs).
 func NewManager() (*Manager, error) {
-	homeDir, err := os.UserHomeDir()
+	configDir, stateDir, err := defaultDirs()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
 	}
 
-	configDir := filepath.Join(homeDir, DefaultConfigDir)
+	sessionID, sessionPID := sessionFromEnv()
 	m := &Manager{
 		configDir:   configDir,
 		contextsDir: filepath.Join(configDir, ContextsSubdir),
-		stateDir:    filepath.Join(configDir, StateSubdir),
+		stateDir:    stateDir,
+		sessionID:   sessionID,
+		sessionPID:  sessionPID,
 	}
 
 	return m, nil
@@ -79,8 +90,12 @@ func (m *Manager) ConfigDir() string {
 	return m.configDir
 }
 
-// ContextsDir returns the contexts directory path.
+// ContextsDir returns the directory contexts are saved in: contexts_dir from
+// the app config, or the contexts subdirectory.
 func (m *Manager) ContextsDir() string {
+	if appConfig := m.GetAppConfig(); appConfig != nil && appConfig.ContextsDir != "" {
+		return m.resolvePath(appConfig.ContextsDir)
+	}
 	return m.contextsDir
 }
 
@@ -92,7 +107,7 @@ func (m *Manager) StateDir() string {
 // KubeconfigPath returns the per-context kubeconfig file path used for
 // auto-isolated cloud kubernetes configurations.
 func (m *Manager) KubeconfigPath(contextName string) string {
-	return filepath.Join(m.stateDir, "kubeconfig-"+contextName)
+	return filepath.Join(m.stateDir, "kubeconfig-"+ContextFileName(contextName))
 }
 
 // hasCloudKubernetesConfig reports whether a kubernetes config has any cloud
@@ -106,7 +121,7 @@ func hasCloudKubernetesConfig(k *KubernetesConfig) bool {
 
 // EnsureDirs creates the configuration directories if they don't exist.
 func (m *Manager) EnsureDirs() error {
-	dirs := []string{m.configDir, m.contextsDir, m.stateDir}
+	dirs := []string{m.configDir, m.ContextsDir(), m.stateDir}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
@@ -139,13 +154,8 @@ func (m *Manager) LoadAppConfig() (*AppConfig, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-	// Apply defaults for empty fields
-	if config.ContextsDir == "" {
-		config.ContextsDir = m.contextsDir
-	}
-	if config.TunnelsDir == "" {
-		config.TunnelsDir = filepath.Join(m.configDir, "tunnels")
-	}
+	// Apply defaults for empty fields. The directories are left empty, so
+	// their defaults follow the configuration directory when it moves.
 	if config.PromptFormat == "" {
 		config.PromptFormat = "[ctx: {{.Name}}{{if .IsProd}} ⚠️{{end}}]"
 	}
@@ -181,8 +191,6 @@ func (m *Manager) defaultAppConfig() *AppConfig {
 		DefaultContext:   "",
 		ShellIntegration: true,
 		PromptFormat:     "[ctx: {{.Name}}{{if .IsProd}} ⚠️{{end}}]",
-		TunnelsDir:       filepath.Join(m.configDir, "tunnels"),
-		ContextsDir:      m.contextsDir,
 	}
 }
 
@@ -198,7 +206,7 @@ func (m *Manager) GetAppConfig() *AppConfig {
 // If the context extends another context, it will be merged with the parent.
 // After merging, ${VAR} references in string fields are expanded using the env: map.
 func (m *Manager) LoadContext(name string) (*ContextConfig, error) {
-	cfg, err := m.loadContextWithChain(name, nil)
+	cfg, err := m.loadContextWithChain(name, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -206,47 +214,64 @@ func (m *Manager) LoadContext(name string) (*ContextConfig, error) {
 	return cfg, nil
 }
 
-// loadContextWithChain loads a context and tracks the inheritance chain to detect cycles.
-func (m *Manager) loadContextWithChain(name string, chain []string) (*ContextConfig, error) {
-	// Check for circular dependency
-	if slices.Contains(chain, name) {
-		return nil, fmt.Errorf("circular inheritance detected: %s -> %s", strings.Join(append(chain, name), " -> "), name)
+// CheckInheritanceCycle returns an error if name is already in the
+// inheritance chain leading to it.
+func CheckInheritanceCycle(chain []string, name string) error {
+	if i := slices.Index(chain, name); i >= 0 {
+		cycle := append(slices.Clone(chain[i:]), name)
+		return fmt.Errorf("circular inheritance detected: %s", strings.Join(cycle, " -> "))
 	}
+	return nil
+}
 
-	contextPath := filepath.Join(m.contextsDir, name+".yaml")
+// loadContextWithChain loads a context merged with the contexts it extends.
+// chain holds the contexts leading to it, to detect cycles. If explanation
+// isn't nil, the merge order and where each field comes from are recorded in
+// it.
+func (m *Manager) loadContextWithChain(name string, chain []string, explanation *Explanation) (*ContextConfig, error) {
+	h := m.newInheritance()
+	h.explanation = explanation
+	order, err := h.linearize(name, chain)
+	if err != nil {
+		return nil, err
+	}
+	return h.merge(order)
+}
+
+// ContextPath returns the path of a context's file: the first one found in
+// the context paths, or the file in the context's repository.
+func (m *Manager) ContextPath(name string) string {
+	return m.ContextSource(name).Path
+}
 
-	data, err := os.ReadFile(contextPath)
+// ReadContextFile returns the contents of a context's file as written, without
+// inheritance or variable expansion.
+func (m *Manager) ReadContextFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(m.ContextPath(name))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("context '%s' not found", name)
 		}
 		return nil, fmt.Errorf("failed to read context file: %w", err)
 	}
-
-	config := &ContextConfig{}
-	if err := yaml.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("failed to parse context file: %w", err)
-	}
-
-	// Handle inheritance
-	if config.Extends != "" {
-		parent, err := m.loadContextWithChain(config.Extends, append(chain, name))
-		if err != nil {
-			return nil, fmt.Errorf("failed to load parent context '%s': %w", config.Extends, err)
-		}
-		config.MergeFrom(parent)
-	}
-
-	return config, nil
+	return data, nil
 }
 
-// SaveContext saves a context configuration.
+// SaveContext saves a context configuration in the contexts directory. A
+// context from another context path or a repository is saved as a local
+// override of it.
 func (m *Manager) SaveContext(config *ContextConfig) error {
 	if err := m.EnsureDirs(); err != nil {
 		return err
 	}
 
-	contextPath := filepath.Join(m.contextsDir, config.Name+".yaml")
+	contextPath := findContextFile(m.ContextsDir(), config.Name)
+	if contextPath == "" {
+		contextPath = filepath.Join(m.ContextsDir(), filepath.FromSlash(config.Name)+".yaml")
+	}
+	if err := os.MkdirAll(filepath.Dir(contextPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(contextPath), err)
+	}
 	data, err := yaml.Marshal(config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal context: %w", err)
@@ -259,12 +284,20 @@ func (m *Manager) SaveContext(config *ContextConfig) error {
 	return nil
 }
 
-// DeleteContext deletes a context configuration.
+// DeleteContext deletes a context configuration from the contexts directory.
+// Contexts in other context paths and repositories can't be deleted, only
+// their local overrides.
 func (m *Manager) DeleteContext(name string) error {
-	contextPath := filepath.Join(m.contextsDir, name+".yaml")
+	contextPath := findContextFile(m.ContextsDir(), name)
 
-	if _, err := os.Stat(contextPath); os.IsNotExist(err) {
-		return fmt.Errorf("context '%s' not found", name)
+	if contextPath == "" {
+		if !m.ContextExists(name) {
+			return fmt.Errorf("context '%s' not found", name)
+		}
+		if source := m.ContextSource(name); source.Repo != "" && !source.Override {
+			return fmt.Errorf("context '%s' belongs to repository '%s' and can't be deleted", name, source.Repo)
+		}
+		return fmt.Errorf("context '%s' is in %s and can't be deleted", name, filepath.Dir(m.ContextPath(name)))
 	}
 
 	if err := os.Remove(contextPath); err != nil {
@@ -274,25 +307,33 @@ func (m *Manager) DeleteContext(name string) error {
 	return nil
 }
 
-// ListContexts returns a list of all available context names.
+// ListContexts returns a list of all available context names: the contexts
+// in the context paths, then those of each context repository. A name is
+// listed once, however many context paths have it.
 func (m *Manager) ListContexts() ([]string, error) {
-	if _, err := os.Stat(m.contextsDir); os.IsNotExist(err) {
-		return []string{}, nil
-	}
-
-	entries, err := os.ReadDir(m.contextsDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read contexts directory: %w", err)
-	}
-
-	var contexts []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	contexts := []string{}
+	for _, dir := range m.ContextPaths() {
+		names, err := listContextFiles(dir)
+		if err != nil {
+			return nil, err
 		}
-		name := entry.Name()
-		if filepath.Ext(name) == ".yaml" || filepath.Ext(name) == ".yml" {
-			contexts = append(contexts, name[:len(name)-len(filepath.Ext(name))])
+		// Local overrides are listed with their repository's contexts
+		names = slices.DeleteFunc(names, func(name string) bool {
+			repo, _ := m.contextRepo(name)
+			return repo != nil
+		})
+		contexts = append(contexts, names...)
+	}
+	slices.Sort(contexts)
+	contexts = slices.Compact(contexts)
+
+	if appConfig := m.GetAppConfig(); appConfig != nil {
+		for i := range appConfig.Repos {
+			names, err := m.listRepoContexts(&appConfig.Repos[i])
+			if err != nil {
+				return nil, err
+			}
+			contexts = append(contexts, names...)
 		}
 	}
 
@@ -320,11 +361,9 @@ func (m *Manager) ListContextConfigs() ([]*ContextConfig, error) {
 	return configs, nil
 }
 
-// GetCurrentContextName returns the name of the currently active context.
+// GetCurrentContextName returns the name of the context active in this session.
 func (m *Manager) GetCurrentContextName() (string, error) {
-	namePath := filepath.Join(m.stateDir, CurrentNameFile)
-
-	data, err := os.ReadFile(namePath)
+	data, err := m.StateStore().ReadFile(m.sessionStateFile(CurrentNameFile))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return "", nil
@@ -335,23 +374,38 @@ func (m *Manager) GetCurrentContextName() (string, error) {
 	return string(data), nil
 }
 
-// SetCurrentContext sets the current active context.
+// SetCurrentContext sets the active context for this session.
+// Other shells keep whatever context they are using.
 func (m *Manager) SetCurrentContext(name string) error {
-	if err := m.EnsureDirs(); err != nil {
+	// Verify the context exists
+	ctx, err := m.LoadContext(name)
+	if err != nil {
 		return err
 	}
+	return m.SetCurrentContextConfig(ctx)
+}
 
-	// Verify the context exists
-	if _, err := m.LoadContext(name); err != nil {
+// SetCurrentContextConfig sets an already loaded context as the active
+// context for this session. A context loaded from a directory marker (with
+// CTX_DIR in its env) is recorded with its directory, so GetCurrentContext
+// loads the overlay again rather than the context it's named after.
+func (m *Manager) SetCurrentContextConfig(ctx *ContextConfig) error {
+	if err := m.EnsureDirs(); err != nil {
 		return err
 	}
 
-	namePath := filepath.Join(m.stateDir, CurrentNameFile)
-	if err := os.WriteFile(namePath, []byte(name), 0o644); err != nil {
+	if err := m.StateStore().WriteFile(m.sessionStateFile(CurrentNameFile), []byte(ctx.Name), 0o644); err != nil {
+		return fmt.Errorf("failed to write current context: %w", err)
+	}
+	if dir := ctx.Env[DirEnvVar]; dir != "" {
+		if err := m.StateStore().WriteFile(m.sessionStateFile(CurrentDirFile), []byte(dir), 0o644); err != nil {
+			return fmt.Errorf("failed to write current context: %w", err)
+		}
+	} else if err := m.StateStore().Remove(m.sessionStateFile(CurrentDirFile)); err != nil {
 		return fmt.Errorf("failed to write current context: %w", err)
 	}
 
-	return nil
+	return m.saveSession(ctx)
 }
 
 // GetCurrentContext returns the currently active context configuration.
@@ -365,7 +419,43 @@ func (m *Manager) GetCurrentContext() (*ContextConfig, error) {
 		return nil, nil
 	}
 
-	return m.LoadContext(name)
+	dir, err := m.StateStore().ReadFile(m.sessionStateFile(CurrentDirFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m.LoadContext(name)
+		}
+		return nil, fmt.Errorf("failed to read current context: %w", err)
+	}
+	return m.loadCurrentDirContext(string(dir))
+}
+
+// loadCurrentDirContext loads the context the marker in dir activated. The
+// marker must still be trusted, as it was when it was activated.
+func (m *Manager) loadCurrentDirContext(dir string) (*ContextConfig, error) {
+	marker, err := ReadDirMarker(dir)
+	if err != nil {
+		return nil, err
+	}
+	if marker == nil {
+		return nil, fmt.Errorf("the marker that activated the current context is gone from %s", dir)
+	}
+	trusted, err := m.IsDirTrusted(marker)
+	if err != nil {
+		return nil, err
+	}
+	if !trusted {
+		return nil, fmt.Errorf("%s changed since it was activated and is no longer trusted", marker.Path)
+	}
+
+	ctx, err := m.LoadDirContext(marker)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Env == nil {
+		ctx.Env = make(map[string]string)
+	}
+	ctx.Env[DirEnvVar] = marker.Dir
+	return ctx, nil
 }
 
 // WriteEnvFile writes the environment variables for the current context to a file.
@@ -379,7 +469,6 @@ func (m *Manager) WriteEnvFileWithSecrets(ctx *ContextConfig, secrets map[string
 		return err
 	}
 
-	envPath := filepath.Join(m.stateDir, CurrentEnvFile)
 	envVars := m.GenerateEnvVars(ctx)
 
 	// Merge secrets (secrets take precedence)
@@ -390,7 +479,7 @@ func (m *Manager) WriteEnvFileWithSecrets(ctx *ContextConfig, secrets map[string
 		content.WriteString(fmt.Sprintf("export %s=%q\n", key, value))
 	}
 
-	if err := os.WriteFile(envPath, []byte(content.String()), 0o644); err != nil {
+	if err := m.StateStore().WriteFile(m.sessionStateFile(CurrentEnvFile), []byte(content.String()), 0o644); err != nil {
 		return fmt.Errorf("failed to write env file: %w", err)
 	}
 
@@ -612,10 +701,20 @@ func expandPath(path string) string {
 // using the env: map as the variable source. This enables template-based configs
 // where child contexts set variables that get expanded in inherited parent values.
 func ExpandConfigVars(cfg *ContextConfig) {
+	expandConfigVars(cfg, nil)
+}
+
+// expandConfigVars is ExpandConfigVars, recording the variables expanded in
+// each field in explanation if it isn't nil.
+func expandConfigVars(cfg *ContextConfig, explanation *Explanation) {
 	if len(cfg.Env) == 0 {
 		return
 	}
-	expandStructVars(reflect.ValueOf(cfg), cfg.Env, "")
+	var record func(path string, keys []string)
+	if explanation != nil {
+		record = explanation.recordVars
+	}
+	expandStructVars(reflect.ValueOf(cfg), cfg.Env, "", record)
 }
 
 // skipFields are struct field names that should not be expanded.
@@ -625,63 +724,97 @@ var skipFields = map[string]bool{
 	"Env":     true,
 }
 
-// expandStructVars recursively walks a struct and expands ${VAR} in string fields.
-func expandStructVars(v reflect.Value, vars map[string]string, fieldName string) {
+// expandStructVars recursively walks a struct and expands ${VAR} in string
+// fields. path is the dotted path of v (see FlattenContextByName), and record,
+// if set, is called with the variables expanded in each field.
+func expandStructVars(v reflect.Value, vars map[string]string, path string, record func(path string, keys []string)) {
 	switch v.Kind() {
 	case reflect.Ptr:
 		if v.IsNil() {
 			return
 		}
-		expandStructVars(v.Elem(), vars, fieldName)
+		expandStructVars(v.Elem(), vars, path, record)
 
 	case reflect.Struct:
 		t := v.Type()
 		for i := 0; i < v.NumField(); i++ {
-			name := t.Field(i).Name
-			if skipFields[name] {
+			field := t.Field(i)
+			if skipFields[field.Name] || !field.IsExported() {
 				continue
 			}
-			expandStructVars(v.Field(i), vars, name)
+			key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if path != "" {
+				key = path + "." + key
+			}
+			expandStructVars(v.Field(i), vars, key, record)
 		}
 
 	case reflect.String:
 		if v.CanSet() {
-			s := v.String()
-			if strings.Contains(s, "$") {
-				expanded := os.Expand(s, func(key string) string {
-					if val, ok := vars[key]; ok {
-						return val
-					}
-					// Leave undefined vars as-is
-					return "${" + key + "}"
-				})
-				v.SetString(expanded)
+			expanded, keys := expandVars(v.String(), vars)
+			v.SetString(expanded)
+			if record != nil && len(keys) > 0 {
+				record(path, keys)
 			}
 		}
 
 	case reflect.Slice:
 		for i := 0; i < v.Len(); i++ {
-			expandStructVars(v.Index(i), vars, fieldName)
+			item := v.Index(i)
+			if item.Kind() != reflect.String {
+				expandStructVars(item, vars, listItemPath(path, item, i), record)
+				continue
+			}
+			// Strings are keyed by their expanded value
+			expanded, keys := expandVars(item.String(), vars)
+			item.SetString(expanded)
+			if record != nil && len(keys) > 0 {
+				record(listItemPath(path, item, i), keys)
+			}
 		}
 
 	case reflect.Map:
 		if v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.String {
 			for _, key := range v.MapKeys() {
-				val := v.MapIndex(key).String()
-				if strings.Contains(val, "$") {
-					expanded := os.Expand(val, func(k string) string {
-						if v, ok := vars[k]; ok {
-							return v
-						}
-						return "${" + k + "}"
-					})
-					v.SetMapIndex(key, reflect.ValueOf(expanded))
+				expanded, keys := expandVars(v.MapIndex(key).String(), vars)
+				v.SetMapIndex(key, reflect.ValueOf(expanded))
+				if record != nil && len(keys) > 0 {
+					record(path+"."+key.String(), keys)
 				}
 			}
 		}
 	}
 }
 
+// expandVars expands ${VAR} references in s, and returns the variables it
+// expanded. Undefined variables are left as is.
+func expandVars(s string, vars map[string]string) (string, []string) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var keys []string
+	expanded := os.Expand(s, func(key string) string {
+		if val, ok := vars[key]; ok {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+			return val
+		}
+		return "${" + key + "}"
+	})
+	return expanded, keys
+}
+
+// listItemPath returns the path of a list item, keyed like
+// FlattenContextByName.
+func listItemPath(path string, item reflect.Value, i int) string {
+	key := namedItem(item)
+	if key == "" {
+		key = strconv.Itoa(i)
+	}
+	return path + "[" + key + "]"
+}
+
 // SecretFileEntry represents a single secret file written to disk.
 type SecretFileEntry struct {
 	Path      string    `json:"path"`
@@ -702,6 +835,11 @@ func (m *Manager) SecretFilesStateDir() string {
 	return filepath.Join(m.stateDir, "secret-files")
 }
 
+// secretFilesStateFile returns the state store name of a context's secret files state.
+func secretFilesStateFile(contextName string) string {
+	return filepath.Join("secret-files", ContextFileName(contextName)+".json")
+}
+
 // SaveSecretFilesState persists the secret files state for a context.
 func (m *Manager) SaveSecretFilesState(state *SecretFilesState) error {
 	dir := m.SecretFilesStateDir()
@@ -709,13 +847,7 @@ func (m *Manager) SaveSecretFilesState(state *SecretFilesState) error {
 		return fmt.Errorf("failed to create secret-files state dir: %w", err)
 	}
 
-	data, err := json.MarshalIndent(state, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal secret files state: %w", err)
-	}
-
-	statePath := filepath.Join(dir, state.ContextName+".json")
-	if err := os.WriteFile(statePath, data, 0o600); err != nil {
+	if err := m.StateStore().SaveJSON(secretFilesStateFile(state.ContextName), state, 0o600); err != nil {
 		return fmt.Errorf("failed to write secret files state: %w", err)
 	}
 	return nil
@@ -724,31 +856,36 @@ func (m *Manager) SaveSecretFilesState(state *SecretFilesState) error {
 // LoadSecretFilesState loads the secret files state for a context.
 // Returns nil, nil if no state file exists.
 func (m *Manager) LoadSecretFilesState(contextName string) (*SecretFilesState, error) {
-	statePath := filepath.Join(m.SecretFilesStateDir(), contextName+".json")
-
-	data, err := os.ReadFile(statePath)
-	if err != nil {
+	var state SecretFilesState
+	if err := m.StateStore().LoadJSON(secretFilesStateFile(contextName), &state); err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to read secret files state: %w", err)
 	}
-
-	var state SecretFilesState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("failed to parse secret files state: %w", err)
-	}
 	return &state, nil
 }
 
 // CleanupSecretFiles securely deletes all secret files for a context and removes the state file.
 func (m *Manager) CleanupSecretFiles(contextName string) error {
-	state, err := m.LoadSecretFilesState(contextName)
+	if _, err := os.Stat(m.StateStore().Path(secretFilesStateFile(contextName))); os.IsNotExist(err) {
+		return nil // No secret files to clean up
+	}
+
+	// Hold the lock until the state file is gone so a concurrent switch
+	// can't record new files that we'd then forget about
+	lock, err := m.StateStore().Lock(secretFilesStateFile(contextName))
 	if err != nil {
 		return err
 	}
-	if state == nil {
-		return nil // No secret files to clean up
+	defer lock.Unlock()
+
+	var state SecretFilesState
+	if err := lock.LoadJSON(&state); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read secret files state: %w", err)
 	}
 
 	for _, entry := range state.Files {
@@ -764,34 +901,43 @@ func (m *Manager) CleanupSecretFiles(contextName string) error {
 	}
 
 	// Remove the state file
-	statePath := filepath.Join(m.SecretFilesStateDir(), contextName+".json")
-	os.Remove(statePath)
+	lock.Remove()
 
 	return nil
 }
 
-// ClearCurrentContext clears the current context.
+// ClearCurrentContext clears the current context of this session.
 func (m *Manager) ClearCurrentContext() error {
-	namePath := filepath.Join(m.stateDir, CurrentNameFile)
-	envPath := filepath.Join(m.stateDir, CurrentEnvFile)
+	// Close the tracked interval first, its state lives in the session directory
+	trackErr := m.StopTracking()
+
+	if m.sessionID != "" {
+		if err := os.RemoveAll(m.sessionStateDir()); err != nil {
+			return fmt.Errorf("failed to remove session state: %w", err)
+		}
+		return trackErr
+	}
 
 	// Remove name file
-	if err := os.Remove(namePath); err != nil && !os.IsNotExist(err) {
+	if err := m.StateStore().Remove(CurrentNameFile); err != nil {
 		return fmt.Errorf("failed to remove current context file: %w", err)
 	}
 
 	// Remove env file
-	if err := os.Remove(envPath); err != nil && !os.IsNotExist(err) {
+	if err := m.StateStore().Remove(CurrentEnvFile); err != nil {
 		return fmt.Errorf("failed to remove env file: %w", err)
 	}
 
-	return nil
+	if err := m.StateStore().Remove(CurrentDirFile); err != nil {
+		return fmt.Errorf("failed to remove current context file: %w", err)
+	}
+
+	return trackErr
 }
 
 // ContextExists checks if a context with the given name exists.
 func (m *Manager) ContextExists(name string) bool {
-	contextPath := filepath.Join(m.contextsDir, name+".yaml")
-	_, err := os.Stat(contextPath)
+	_, err := os.Stat(m.ContextPath(name))
 	return err == nil
 }
 
@@ -819,7 +965,7 @@ func (m *Manager) SaveVaultToken(contextName, token string) error {
 	err := keyring.Set(keyringService, key, token)
 	if err == nil {
 		// Successfully stored in keychain, remove any old file-based token
-		tokenPath := filepath.Join(m.TokensDir(), contextName+".vault")
+		tokenPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".vault")
 		os.Remove(tokenPath) // Ignore error - file might not exist
 		return nil
 	}
@@ -829,7 +975,7 @@ func (m *Manager) SaveVaultToken(contextName, token string) error {
 		return fmt.Errorf("failed to create tokens directory: %w", err)
 	}
 
-	tokenPath := filepath.Join(m.TokensDir(), contextName+".vault")
+	tokenPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".vault")
 	// Use restrictive permissions - tokens are sensitive
 	if err := os.WriteFile(tokenPath, []byte(token), 0o600); err != nil {
 		return fmt.Errorf("failed to save vault token: %w", err)
@@ -851,7 +997,7 @@ func (m *Manager) LoadVaultToken(contextName string) string {
 	}
 
 	// Fall back to file
-	tokenPath := filepath.Join(m.TokensDir(), contextName+".vault")
+	tokenPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".vault")
 	data, err := os.ReadFile(tokenPath)
 	if err != nil {
 		return ""
@@ -868,7 +1014,7 @@ func (m *Manager) DeleteVaultToken(contextName string) error {
 	keyring.Delete(keyringService, key)
 
 	// Delete from file
-	tokenPath := filepath.Join(m.TokensDir(), contextName+".vault")
+	tokenPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".vault")
 	if err := os.Remove(tokenPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete vault token: %w", err)
 	}
@@ -889,7 +1035,7 @@ func (m *Manager) SaveBitwardenSession(contextName, session string) error {
 	err := keyring.Set(keyringService, key, session)
 	if err == nil {
 		// Successfully stored in keychain, remove any old file-based session
-		sessionPath := filepath.Join(m.TokensDir(), contextName+".bitwarden")
+		sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".bitwarden")
 		os.Remove(sessionPath) // Ignore error - file might not exist
 		return nil
 	}
@@ -899,7 +1045,7 @@ func (m *Manager) SaveBitwardenSession(contextName, session string) error {
 		return fmt.Errorf("failed to create tokens directory: %w", err)
 	}
 
-	sessionPath := filepath.Join(m.TokensDir(), contextName+".bitwarden")
+	sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".bitwarden")
 	// Use restrictive permissions - sessions are sensitive
 	if err := os.WriteFile(sessionPath, []byte(session), 0o600); err != nil {
 		return fmt.Errorf("failed to save bitwarden session: %w", err)
@@ -921,7 +1067,7 @@ func (m *Manager) LoadBitwardenSession(contextName string) string {
 	}
 
 	// Fall back to file
-	sessionPath := filepath.Join(m.TokensDir(), contextName+".bitwarden")
+	sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".bitwarden")
 	data, err := os.ReadFile(sessionPath)
 	if err != nil {
 		return ""
@@ -938,7 +1084,7 @@ func (m *Manager) DeleteBitwardenSession(contextName string) error {
 	keyring.Delete(keyringService, key)
 
 	// Delete from file
-	sessionPath := filepath.Join(m.TokensDir(), contextName+".bitwarden")
+	sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".bitwarden")
 	if err := os.Remove(sessionPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete bitwarden session: %w", err)
 	}
@@ -957,7 +1103,7 @@ func (m *Manager) SaveOnePasswordSession(contextName, session string) error {
 	// Try keychain first
 	err := keyring.Set(keyringService, key, session)
 	if err == nil {
-		sessionPath := filepath.Join(m.TokensDir(), contextName+".onepassword")
+		sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".onepassword")
 		os.Remove(sessionPath)
 		return nil
 	}
@@ -967,7 +1113,7 @@ func (m *Manager) SaveOnePasswordSession(contextName, session string) error {
 		return fmt.Errorf("failed to create tokens directory: %w", err)
 	}
 
-	sessionPath := filepath.Join(m.TokensDir(), contextName+".onepassword")
+	sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".onepassword")
 	if err := os.WriteFile(sessionPath, []byte(session), 0o600); err != nil {
 		return fmt.Errorf("failed to save 1password session: %w", err)
 	}
@@ -986,7 +1132,7 @@ func (m *Manager) LoadOnePasswordSession(contextName string) string {
 	}
 
 	// Fall back to file
-	sessionPath := filepath.Join(m.TokensDir(), contextName+".onepassword")
+	sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".onepassword")
 	data, err := os.ReadFile(sessionPath)
 	if err != nil {
 		return ""
@@ -1002,7 +1148,7 @@ func (m *Manager) DeleteOnePasswordSession(contextName string) error {
 	keyring.Delete(keyringService, key)
 
 	// Delete from file
-	sessionPath := filepath.Join(m.TokensDir(), contextName+".onepassword")
+	sessionPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".onepassword")
 	if err := os.Remove(sessionPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete 1password session: %w", err)
 	}
@@ -1016,7 +1162,7 @@ func (m *Manager) CloudConfigDir() string {
 
 // AzureConfigDir returns the Azure config directory for a specific context.
 func (m *Manager) AzureConfigDir(contextName string) string {
-	return filepath.Join(m.CloudConfigDir(), contextName, "azure")
+	return filepath.Join(m.CloudConfigDir(), ContextFileName(contextName), "azure")
 }
 
 // EnsureAzureConfigDir creates the Azure config directory for a context if it doesn't exist.
@@ -1026,7 +1172,7 @@ func (m *Manager) EnsureAzureConfigDir(contextName string) error {
 
 // GCPConfigDir returns the GCP config directory for a specific context.
 func (m *Manager) GCPConfigDir(contextName string) string {
-	return filepath.Join(m.CloudConfigDir(), contextName, "gcloud")
+	return filepath.Join(m.CloudConfigDir(), ContextFileName(contextName), "gcloud")
 }
 
 // EnsureGCPConfigDir creates the GCP config directory for a context if it doesn't exist.
@@ -1055,7 +1201,7 @@ func (m *Manager) SaveAWSCredentials(contextName string, creds *AWSCredentials)
 		return fmt.Errorf("failed to marshal credentials: %w", err)
 	}
 
-	credPath := filepath.Join(m.TokensDir(), contextName+".aws")
+	credPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".aws")
 	if err := os.WriteFile(credPath, data, 0o600); err != nil {
 		return fmt.Errorf("failed to save AWS credentials: %w", err)
 	}
@@ -1065,7 +1211,7 @@ func (m *Manager) SaveAWSCredentials(contextName string, creds *AWSCredentials)
 // LoadAWSCredentials loads saved AWS credentials for a context.
 // Returns nil if no credentials are saved or if they're expired.
 func (m *Manager) LoadAWSCredentials(contextName string) *AWSCredentials {
-	credPath := filepath.Join(m.TokensDir(), contextName+".aws")
+	credPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".aws")
 	data, err := os.ReadFile(credPath)
 	if err != nil {
 		return nil
@@ -1091,7 +1237,7 @@ func (m *Manager) LoadAWSCredentials(contextName string) *AWSCredentials {
 
 // DeleteAWSCredentials removes saved AWS credentials for a context.
 func (m *Manager) DeleteAWSCredentials(contextName string) error {
-	credPath := filepath.Join(m.TokensDir(), contextName+".aws")
+	credPath := filepath.Join(m.TokensDir(), ContextFileName(contextName)+".aws")
 	if err := os.Remove(credPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete AWS credentials: %w", err)
 	}
@@ -1167,3 +1313,64 @@ func (m *Manager) DeleteCloudAPIKey() error {
 	}
 	return nil
 }
+
+// cloudSSOTokenKey returns the keyring key for the ctx-cloud SSO tokens.
+func cloudSSOTokenKey() string {
+	return "cloud-sso-token"
+}
+
+// SaveCloudSSOToken saves the tokens of a ctx-cloud SSO login, as JSON.
+// It tries to use the system keychain first, falling back to file storage.
+func (m *Manager) SaveCloudSSOToken(token string) error {
+	key := cloudSSOTokenKey()
+
+	// Try keychain first
+	err := keyring.Set(keyringService, key, token)
+	if err == nil {
+		tokenPath := filepath.Join(m.TokensDir(), "cloud-sso.json")
+		os.Remove(tokenPath) // Ignore error - file might not exist
+		return nil
+	}
+
+	// Keychain not available, fall back to file
+	if err := m.EnsureTokensDir(); err != nil {
+		return fmt.Errorf("failed to create tokens directory: %w", err)
+	}
+
+	tokenPath := filepath.Join(m.TokensDir(), "cloud-sso.json")
+	if err := os.WriteFile(tokenPath, []byte(token), 0o600); err != nil {
+		return fmt.Errorf("failed to save cloud SSO token: %w", err)
+	}
+
+	return nil
+}
+
+// LoadCloudSSOToken loads the saved ctx-cloud SSO tokens.
+// Returns empty string if not logged in with SSO.
+func (m *Manager) LoadCloudSSOToken() string {
+	key := cloudSSOTokenKey()
+
+	// Try keychain first
+	token, err := keyring.Get(keyringService, key)
+	if err == nil && token != "" {
+		return token
+	}
+
+	// Fall back to file
+	data, err := os.ReadFile(filepath.Join(m.TokensDir(), "cloud-sso.json"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// DeleteCloudSSOToken removes the saved ctx-cloud SSO tokens.
+func (m *Manager) DeleteCloudSSOToken() error {
+	keyring.Delete(keyringService, cloudSSOTokenKey())
+
+	tokenPath := filepath.Join(m.TokensDir(), "cloud-sso.json")
+	if err := os.Remove(tokenPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete cloud SSO token: %w", err)
+	}
+	return nil
+}
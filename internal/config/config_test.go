@@ -413,7 +413,7 @@ func TestExpandConfigVars(t *testing.T) {
 	t.Run("name and extends not expanded", func(t *testing.T) {
 		cfg := &ContextConfig{
 			Name:    "${CLUSTER_NAME}",
-			Extends: "${PARENT}",
+			Extends: Extends{"${PARENT}"},
 			Env: map[string]string{
 				"CLUSTER_NAME": "alpha",
 				"PARENT":       "acme-cloud",
@@ -425,7 +425,7 @@ func TestExpandConfigVars(t *testing.T) {
 		if cfg.Name != "${CLUSTER_NAME}" {
 			t.Errorf("Name was expanded to %v, should not be expanded", cfg.Name)
 		}
-		if cfg.Extends != "${PARENT}" {
+		if cfg.Extends.String() != "${PARENT}" {
 			t.Errorf("Extends was expanded to %v, should not be expanded", cfg.Extends)
 		}
 	})
@@ -665,7 +665,7 @@ func TestManager_LoadContext_Inheritance(t *testing.T) {
 	// Create child context that extends base
 	child := &ContextConfig{
 		Name:        "child-context",
-		Extends:     "base-context",
+		Extends:     Extends{"base-context"},
 		Description: "Child context",
 		Environment: EnvProduction,
 		AWS: &AWSConfig{
@@ -728,11 +728,11 @@ func TestManager_LoadContext_CircularInheritance(t *testing.T) {
 	// Create circular dependency: A -> B -> A
 	contextA := &ContextConfig{
 		Name:    "context-a",
-		Extends: "context-b",
+		Extends: Extends{"context-b"},
 	}
 	contextB := &ContextConfig{
 		Name:    "context-b",
-		Extends: "context-a",
+		Extends: Extends{"context-a"},
 	}
 
 	m.SaveContext(contextA)
@@ -765,14 +765,14 @@ func TestManager_LoadContext_MultiLevelInheritance(t *testing.T) {
 	}
 	parent := &ContextConfig{
 		Name:    "parent",
-		Extends: "grandparent",
+		Extends: Extends{"grandparent"},
 		Kubernetes: &KubernetesConfig{
 			Context: "parent-k8s",
 		},
 	}
 	child := &ContextConfig{
 		Name:        "child",
-		Extends:     "parent",
+		Extends:     Extends{"parent"},
 		Environment: EnvProduction,
 	}
 
@@ -810,7 +810,7 @@ func TestManager_LoadContext_ParentNotFound(t *testing.T) {
 
 	child := &ContextConfig{
 		Name:    "orphan",
-		Extends: "nonexistent-parent",
+		Extends: Extends{"nonexistent-parent"},
 	}
 	m.SaveContext(child)
 
@@ -5,7 +5,10 @@ package config
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // ContextSummary provides a summary of a context for display purposes.
@@ -38,7 +41,7 @@ func FormatContextDetails(ctx *ContextConfig) string {
 	if ctx.Abstract {
 		sb.WriteString("Type: abstract (base template)\n")
 	}
-	if ctx.Extends != "" {
+	if len(ctx.Extends) > 0 {
 		sb.WriteString(fmt.Sprintf("Extends: %s\n", ctx.Extends))
 	}
 	if ctx.Description != "" {
@@ -433,3 +436,109 @@ func ValidateContext(ctx *ContextConfig) error {
 
 	return nil
 }
+
+// FlattenContext returns the fields of a context by their dotted path, such
+// as aws.region or tunnels[0].name, as written in YAML.
+func FlattenContext(cfg *ContextConfig) (map[string]string, error) {
+	return flattenContext(cfg, false)
+}
+
+// FlattenContextByName is like FlattenContext, but list items are keyed by
+// their name, or by their value for lists of strings, as in tunnels[db].name
+// or tags[prod]. Paths then don't change when inheritance reorders lists.
+func FlattenContextByName(cfg *ContextConfig) (map[string]string, error) {
+	return flattenContext(cfg, true)
+}
+
+// FlattenContextTags is like FlattenContext, but keeps the tags of the
+// fields the context overrides or unsets, as in aws.use_vault: "!override
+// false" or env.DEBUG: "!unset".
+func FlattenContextTags(cfg *ContextConfig) (map[string]string, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to marshal context: %w", err)
+	}
+
+	fields := make(map[string]string)
+	flattenNode("", &node, fields)
+	return fields, nil
+}
+
+func flattenNode(path string, node *yaml.Node, fields map[string]string) {
+	tagged := node.Tag == overrideTag || node.Tag == unsetTag
+	if tagged {
+		fields[path] = strings.TrimSpace(node.Tag + " " + node.Value)
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			flattenNode(key, node.Content[i+1], fields)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			flattenNode(path+"["+strconv.Itoa(i)+"]", item, fields)
+		}
+	case yaml.ScalarNode:
+		if !tagged && node.ShortTag() != "!!null" {
+			fields[path] = node.Value
+		}
+	}
+}
+
+func flattenContext(cfg *ContextConfig, byName bool) (map[string]string, error) {
+	// The values only, without the tags MarshalYAML adds
+	yamlBytes, err := yaml.Marshal((*plainContext)(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal context: %w", err)
+	}
+
+	var value any
+	if err := yaml.Unmarshal(yamlBytes, &value); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal context: %w", err)
+	}
+
+	fields := make(map[string]string)
+	flattenValue("", value, byName, fields)
+	return fields, nil
+}
+
+func flattenValue(path string, value any, byName bool, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if path != "" {
+				key = path + "." + key
+			}
+			flattenValue(key, item, byName, fields)
+		}
+	case []any:
+		for i, item := range v {
+			key := strconv.Itoa(i)
+			if name := listItemName(item); byName && name != "" {
+				key = name
+			}
+			flattenValue(path+"["+key+"]", item, byName, fields)
+		}
+	case nil:
+	default:
+		fields[path] = fmt.Sprint(v)
+	}
+}
+
+// listItemName returns the name of a list item that's a map with a name, or
+// the item itself if it's a string.
+func listItemName(item any) string {
+	switch v := item.(type) {
+	case string:
+		return v
+	case map[string]any:
+		name, _ := v["name"].(string)
+		return name
+	}
+	return ""
+}
@@ -6,6 +6,8 @@ package config
 import (
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestGetContextSummary(t *testing.T) {
@@ -388,3 +390,39 @@ func TestValidateContext(t *testing.T) {
 		})
 	}
 }
+
+func TestFlattenContext(t *testing.T) {
+	var cfg ContextConfig
+	err := yaml.Unmarshal([]byte(`
+name: team
+environment: staging
+aws:
+  profile: team
+  region: eu-west-1
+tunnels:
+  - name: db
+    local_port: 5432
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := FlattenContext(&cfg)
+	if err != nil {
+		t.Fatalf("FlattenContext() error = %v", err)
+	}
+
+	want := map[string]string{
+		"name":                  "team",
+		"environment":           "staging",
+		"aws.profile":           "team",
+		"aws.region":            "eu-west-1",
+		"tunnels[0].name":       "db",
+		"tunnels[0].local_port": "5432",
+	}
+	for path, value := range want {
+		if fields[path] != value {
+			t.Errorf("fields[%q] = %q, want %q", path, fields[path], value)
+		}
+	}
+}
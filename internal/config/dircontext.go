@@ -0,0 +1,225 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DirMarkerFile binds a directory tree to a context with an inline overlay.
+	DirMarkerFile = ".ctx.yaml"
+	// DirMarkerNameFile binds a directory tree to a context by name.
+	DirMarkerNameFile = ".ctx"
+	// DirEnvVar holds the directory whose marker activated the current context.
+	DirEnvVar = "CTX_DIR"
+	// TrustedDirsFile is the state file listing trusted marker files.
+	TrustedDirsFile = "trusted-dirs.json"
+)
+
+// DirMarker is a marker file binding a directory tree to a context.
+//
+// A .ctx file holds just a context name. A .ctx.yaml file is a context
+// overlay: it extends a context and may override any of its settings, like
+// a context file in the contexts directory.
+type DirMarker struct {
+	// Path is the marker file.
+	Path string
+	// Dir is the directory the marker applies to (and everything below it).
+	Dir string
+	// Hash identifies the marker's content, so trust is revoked when it changes.
+	Hash string
+
+	data []byte
+}
+
+// FindDirMarker looks for a marker file in dir and its parents. It returns nil
+// if there is none. A .ctx.yaml wins over a .ctx in the same directory.
+func FindDirMarker(dir string) (*DirMarker, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		marker, err := ReadDirMarker(dir)
+		if err != nil || marker != nil {
+			return marker, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// ReadDirMarker reads the marker file in dir itself. It returns nil if there is none.
+func ReadDirMarker(dir string) (*DirMarker, error) {
+	for _, name := range []string{DirMarkerFile, DirMarkerNameFile} {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		// A .ctx directory is not a marker
+		if info.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		sum := sha256.Sum256(data)
+		return &DirMarker{Path: path, Dir: dir, Hash: hex.EncodeToString(sum[:]), data: data}, nil
+	}
+	return nil, nil
+}
+
+// LoadDirContext resolves the context a marker binds its directory to.
+//
+// An overlay can't turn a production context into a non-production one, so
+// the production confirmation can't be skipped by a marker.
+func (m *Manager) LoadDirContext(marker *DirMarker) (*ContextConfig, error) {
+	if filepath.Base(marker.Path) == DirMarkerNameFile {
+		name := markerContextName(marker.data)
+		if name == "" {
+			return nil, fmt.Errorf("%s does not name a context", marker.Path)
+		}
+		return m.LoadContext(name)
+	}
+
+	overlay := &ContextConfig{}
+	if err := yaml.Unmarshal(marker.data, overlay); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", marker.Path, err)
+	}
+	if len(overlay.Extends) == 0 {
+		return nil, fmt.Errorf("%s must extend a context (extends: <name>)", marker.Path)
+	}
+
+	h := m.newInheritance()
+	order, err := h.linearizeParents(marker.Path, overlay.Extends, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last parent takes precedence, and gives the overlay its name
+	parent := h.own[order[1]]
+	if overlay.Name == "" {
+		if parent.Abstract {
+			return nil, fmt.Errorf("%s extends abstract context '%s' and must set a name", marker.Path, parent.Name)
+		}
+		overlay.Name = parent.Name
+	}
+	overlay.Abstract = false
+
+	var parentEnv Environment
+	for _, ancestor := range order[1:] {
+		if env := h.own[ancestor].Environment; env != "" {
+			parentEnv = env
+			break
+		}
+	}
+	for _, ancestor := range order[1:] {
+		overlay.MergeFrom(h.own[ancestor])
+	}
+	overlay.overrides = nil
+
+	if parentEnv.IsProd() {
+		overlay.Environment = parentEnv
+	}
+
+	ExpandConfigVars(overlay)
+	return overlay, nil
+}
+
+// markerContextName returns the first non-comment line of a .ctx file.
+func markerContextName(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// IsDirTrusted reports whether the marker, with its current content, has
+// been trusted with TrustDir.
+func (m *Manager) IsDirTrusted(marker *DirMarker) (bool, error) {
+	trusted, err := m.loadTrustedDirs()
+	if err != nil {
+		return false, err
+	}
+	return trusted[marker.Path] == marker.Hash, nil
+}
+
+// TrustDir allows the marker to activate its context automatically. Trust
+// covers the marker's current content only.
+func (m *Manager) TrustDir(marker *DirMarker) error {
+	return m.updateTrustedDirs(func(trusted map[string]string) {
+		trusted[marker.Path] = marker.Hash
+	})
+}
+
+// UntrustDir revokes trust for the marker file at path.
+func (m *Manager) UntrustDir(path string) error {
+	return m.updateTrustedDirs(func(trusted map[string]string) {
+		delete(trusted, path)
+	})
+}
+
+// loadTrustedDirs returns the trusted marker files, keyed by path, with the
+// hash of the content that was trusted.
+func (m *Manager) loadTrustedDirs() (map[string]string, error) {
+	// A corrupt file is moved aside; nothing is trusted until trusted again
+	trusted := make(map[string]string)
+	if err := m.StateStore().LoadJSON(TrustedDirsFile, &trusted); err != nil {
+		if !os.IsNotExist(err) && !errors.Is(err, ErrCorruptState) {
+			return nil, fmt.Errorf("failed to read trusted directories: %w", err)
+		}
+		trusted = make(map[string]string)
+	}
+	return trusted, nil
+}
+
+func (m *Manager) updateTrustedDirs(update func(map[string]string)) error {
+	lock, err := m.StateStore().Lock(TrustedDirsFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	trusted := make(map[string]string)
+	if err := lock.LoadJSON(&trusted); err != nil {
+		if !os.IsNotExist(err) && !errors.Is(err, ErrCorruptState) {
+			return fmt.Errorf("failed to read trusted directories: %w", err)
+		}
+		trusted = make(map[string]string)
+	}
+
+	update(trusted)
+
+	if err := lock.SaveJSON(trusted, 0o600); err != nil {
+		return fmt.Errorf("failed to write trusted directories: %w", err)
+	}
+	return nil
+}
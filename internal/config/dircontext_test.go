@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeMarker(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindDirMarker(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "acme", "repo")
+	deep := filepath.Join(repo, "src", "pkg")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	marker, err := FindDirMarker(deep)
+	if err != nil || marker != nil {
+		t.Fatalf("FindDirMarker() = %v, %v, want nil", marker, err)
+	}
+
+	writeMarker(t, filepath.Join(root, "acme"), DirMarkerNameFile, "acme-dev\n")
+	writeMarker(t, repo, DirMarkerNameFile, "acme-repo\n")
+
+	// The nearest marker wins
+	marker, err = FindDirMarker(deep)
+	if err != nil {
+		t.Fatalf("FindDirMarker() error = %v", err)
+	}
+	if marker == nil || marker.Dir != repo {
+		t.Fatalf("FindDirMarker() = %+v, want marker in %s", marker, repo)
+	}
+
+	// .ctx.yaml wins over .ctx in the same directory
+	writeMarker(t, repo, DirMarkerFile, "extends: acme-dev\n")
+	marker, _ = FindDirMarker(deep)
+	if marker == nil || filepath.Base(marker.Path) != DirMarkerFile {
+		t.Errorf("FindDirMarker() = %+v, want %s", marker, DirMarkerFile)
+	}
+
+	// A .ctx directory is not a marker
+	other := filepath.Join(root, "other")
+	if err := os.MkdirAll(filepath.Join(other, DirMarkerNameFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if marker, err := FindDirMarker(other); err != nil || marker != nil {
+		t.Errorf("FindDirMarker() = %v, %v, want nil for a .ctx directory", marker, err)
+	}
+}
+
+func TestManager_LoadDirContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManagerWithDir(tmpDir)
+
+	for _, ctx := range []*ContextConfig{
+		{Name: "acme-dev", Environment: EnvDevelopment, Env: map[string]string{"A": "base", "B": "base"}},
+		{Name: "acme-prod", Environment: EnvProduction},
+		{Name: "base", Abstract: true, Environment: EnvStaging},
+	} {
+		if err := m.SaveContext(ctx); err != nil {
+			t.Fatalf("SaveContext() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		wantName string
+		wantEnv  Environment
+		wantVars map[string]string
+		wantErr  bool
+	}{
+		{
+			name: "name file", file: DirMarkerNameFile, content: "# client repo\nacme-dev\n",
+			wantName: "acme-dev", wantEnv: EnvDevelopment, wantVars: map[string]string{"A": "base"},
+		},
+		{
+			name: "overlay", file: DirMarkerFile, content: "extends: acme-dev\nenv:\n  B: overlay\n",
+			wantName: "acme-dev", wantEnv: EnvDevelopment, wantVars: map[string]string{"A": "base", "B": "overlay"},
+		},
+		{
+			name: "overlay cannot downgrade production", file: DirMarkerFile, content: "extends: acme-prod\nenvironment: development\n",
+			wantName: "acme-prod", wantEnv: EnvProduction,
+		},
+		{
+			name: "named overlay of abstract base", file: DirMarkerFile, content: "extends: base\nname: repo\n",
+			wantName: "repo", wantEnv: EnvStaging,
+		},
+		{name: "abstract base needs a name", file: DirMarkerFile, content: "extends: base\n", wantErr: true},
+		{name: "overlay must extend", file: DirMarkerFile, content: "env:\n  A: x\n", wantErr: true},
+		{name: "empty name file", file: DirMarkerNameFile, content: "# nothing\n", wantErr: true},
+		{name: "unknown context", file: DirMarkerNameFile, content: "nope\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMarker(t, dir, tt.file, tt.content)
+			marker, err := ReadDirMarker(dir)
+			if err != nil || marker == nil {
+				t.Fatalf("ReadDirMarker() = %v, %v", marker, err)
+			}
+
+			ctx, err := m.LoadDirContext(marker)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("LoadDirContext() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadDirContext() error = %v", err)
+			}
+			if ctx.Name != tt.wantName || ctx.Environment != tt.wantEnv {
+				t.Errorf("LoadDirContext() = %s (%s), want %s (%s)", ctx.Name, ctx.Environment, tt.wantName, tt.wantEnv)
+			}
+			for key, want := range tt.wantVars {
+				if ctx.Env[key] != want {
+					t.Errorf("Env[%s] = %q, want %q", key, ctx.Env[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestManager_TrustDir(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	dir := t.TempDir()
+	writeMarker(t, dir, DirMarkerNameFile, "acme-dev\n")
+
+	marker, _ := ReadDirMarker(dir)
+	if trusted, err := m.IsDirTrusted(marker); err != nil || trusted {
+		t.Fatalf("IsDirTrusted() = %v, %v, want false for a new marker", trusted, err)
+	}
+
+	if err := m.TrustDir(marker); err != nil {
+		t.Fatalf("TrustDir() error = %v", err)
+	}
+	if trusted, _ := m.IsDirTrusted(marker); !trusted {
+		t.Error("IsDirTrusted() = false after TrustDir()")
+	}
+
+	// Changing the marker revokes trust
+	writeMarker(t, dir, DirMarkerNameFile, "acme-prod\n")
+	changed, _ := ReadDirMarker(dir)
+	if trusted, _ := m.IsDirTrusted(changed); trusted {
+		t.Error("IsDirTrusted() = true after the marker changed")
+	}
+
+	if err := m.UntrustDir(marker.Path); err != nil {
+		t.Fatalf("UntrustDir() error = %v", err)
+	}
+	if trusted, _ := m.IsDirTrusted(marker); trusted {
+		t.Error("IsDirTrusted() = true after UntrustDir()")
+	}
+}
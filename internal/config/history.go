@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// HistoryFile is the state file recording context activations.
+	HistoryFile = "history.json"
+	// MaxHistoryEntries is how many activations are kept, oldest dropped first.
+	MaxHistoryEntries = 500
+)
+
+// HistoryEntry records one activation of a context.
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	Context     string    `json:"context"`
+	Environment string    `json:"environment,omitempty"`
+	Session     string    `json:"session,omitempty"`
+}
+
+// RecordActivation appends an activation of ctx by the manager's session to
+// the history.
+func (m *Manager) RecordActivation(ctx *ContextConfig) error {
+	lock, err := m.StateStore().Lock(HistoryFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	var history []HistoryEntry
+	if err := lock.LoadJSON(&history); err != nil && !os.IsNotExist(err) && !errors.Is(err, ErrCorruptState) {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	history = append(history, HistoryEntry{
+		Time:        time.Now(),
+		Context:     ctx.Name,
+		Environment: string(ctx.Environment),
+		Session:     m.sessionID,
+	})
+	if len(history) > MaxHistoryEntries {
+		history = history[len(history)-MaxHistoryEntries:]
+	}
+
+	if err := lock.SaveJSON(history, 0o600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// LoadHistory returns the recorded activations, oldest first.
+func (m *Manager) LoadHistory() ([]HistoryEntry, error) {
+	var history []HistoryEntry
+	if err := m.StateStore().LoadJSON(HistoryFile, &history); err != nil {
+		if os.IsNotExist(err) || errors.Is(err, ErrCorruptState) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return history, nil
+}
+
+// PreviousContext returns the context activated before current, like 'cd -'.
+// Activations in the given session are preferred, so each shell toggles
+// between its own contexts. It returns "" if there is none.
+func PreviousContext(history []HistoryEntry, current, session string) string {
+	if session != "" {
+		if name := previousContext(history, current, session); name != "" {
+			return name
+		}
+	}
+	return previousContext(history, current, "")
+}
+
+func previousContext(history []HistoryEntry, current, session string) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if session != "" && entry.Session != session {
+			continue
+		}
+		if entry.Context != current {
+			return entry.Context
+		}
+	}
+	return ""
+}
+
+// LastUsed returns when each context in the history was last activated.
+func LastUsed(history []HistoryEntry) map[string]time.Time {
+	lastUsed := make(map[string]time.Time)
+	for _, entry := range history {
+		if entry.Time.After(lastUsed[entry.Context]) {
+			lastUsed[entry.Context] = entry.Time
+		}
+	}
+	return lastUsed
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManager_RecordActivation(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	if err := m.SetSessionID("1-a", 1); err != nil {
+		t.Fatalf("SetSessionID() error = %v", err)
+	}
+
+	history, err := m.LoadHistory()
+	if err != nil || len(history) != 0 {
+		t.Fatalf("LoadHistory() = %v, %v, want empty", history, err)
+	}
+
+	for _, name := range []string{"dev", "staging"} {
+		if err := m.RecordActivation(&ContextConfig{Name: name, Environment: EnvDevelopment}); err != nil {
+			t.Fatalf("RecordActivation() error = %v", err)
+		}
+	}
+
+	history, err = m.LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if len(history) != 2 || history[0].Context != "dev" || history[1].Context != "staging" {
+		t.Fatalf("LoadHistory() = %+v, want dev then staging", history)
+	}
+	if history[1].Session != "1-a" || history[1].Environment != string(EnvDevelopment) {
+		t.Errorf("history[1] = %+v, want session 1-a and environment development", history[1])
+	}
+}
+
+func TestManager_RecordActivationTrims(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	ctx := &ContextConfig{Name: "dev"}
+	for range MaxHistoryEntries + 5 {
+		if err := m.RecordActivation(ctx); err != nil {
+			t.Fatalf("RecordActivation() error = %v", err)
+		}
+	}
+
+	history, _ := m.LoadHistory()
+	if len(history) != MaxHistoryEntries {
+		t.Errorf("len(history) = %d, want %d", len(history), MaxHistoryEntries)
+	}
+}
+
+func TestPreviousContext(t *testing.T) {
+	history := []HistoryEntry{
+		{Context: "dev", Session: "a"},
+		{Context: "staging", Session: "a"},
+		{Context: "prod", Session: "b"},
+		{Context: "staging", Session: "a"},
+	}
+
+	tests := []struct {
+		name    string
+		current string
+		session string
+		want    string
+	}{
+		{"own session first", "staging", "a", "dev"},
+		{"any session", "staging", "", "prod"},
+		{"falls back to other sessions", "prod", "c", "staging"},
+		{"no current context", "", "a", "staging"},
+		{"nothing else", "staging", "x", "prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PreviousContext(history, tt.current, tt.session); got != tt.want {
+				t.Errorf("PreviousContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := PreviousContext([]HistoryEntry{{Context: "dev"}}, "dev", ""); got != "" {
+		t.Errorf("PreviousContext() = %q, want none", got)
+	}
+}
+
+func TestLastUsed(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	lastUsed := LastUsed([]HistoryEntry{
+		{Time: t1, Context: "dev"},
+		{Time: t2, Context: "staging"},
+		{Time: t2, Context: "dev"},
+	})
+
+	if !lastUsed["dev"].Equal(t2) || !lastUsed["staging"].Equal(t2) {
+		t.Errorf("LastUsed() = %v", lastUsed)
+	}
+}
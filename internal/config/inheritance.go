@@ -0,0 +1,364 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extends lists the contexts a context inherits from. In YAML and JSON it's
+// a single name or a list of names. A later parent takes precedence over the
+// ones before it, and the context's own values over all of them.
+type Extends []string
+
+// UnmarshalYAML accepts a name or a list of names.
+func (e *Extends) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var name string
+		if err := node.Decode(&name); err != nil {
+			return err
+		}
+		*e = nil
+		if name != "" {
+			*e = Extends{name}
+		}
+		return nil
+	case yaml.SequenceNode:
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		*e = names
+		return nil
+	}
+	return fmt.Errorf("line %d: extends must be a context name or a list of names", node.Line)
+}
+
+// MarshalYAML writes a single parent as a name.
+func (e Extends) MarshalYAML() (any, error) {
+	if len(e) == 1 {
+		return e[0], nil
+	}
+	return []string(e), nil
+}
+
+// UnmarshalJSON accepts a name or a list of names.
+func (e *Extends) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*e = nil
+		if name != "" {
+			*e = Extends{name}
+		}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("extends must be a context name or a list of names")
+	}
+	*e = names
+	return nil
+}
+
+// MarshalJSON writes a single parent as a name, as servers that predate
+// lists of parents expect.
+func (e Extends) MarshalJSON() ([]byte, error) {
+	if len(e) == 1 {
+		return json.Marshal(e[0])
+	}
+	return json.Marshal([]string(e))
+}
+
+// String returns the parents separated by commas.
+func (e Extends) String() string {
+	return strings.Join(e, ", ")
+}
+
+// Explanation tells where the values of a context come from.
+type Explanation struct {
+	// Sources maps the dotted path of each field (see FlattenContextByName)
+	// to where its value comes from. Fields no context sets are left out.
+	Sources map[string]FieldSource `json:"sources"`
+	// Order is the order the context and its ancestors are merged in, from
+	// the context itself to its most distant ancestor. Each one fills in what
+	// the ones before it left unset.
+	Order []string `json:"order"`
+}
+
+// FieldSource is where the value of a field comes from.
+type FieldSource struct {
+	// Context is the context that sets the field
+	Context string `json:"context"`
+	// File and Line are where the context sets it. Line is 0 if the value
+	// isn't written in the file.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Vars are the env variables expanded in the value, if any
+	Vars []string `json:"vars,omitempty"`
+}
+
+// recordVars records that the variables keys were expanded in a field.
+func (e *Explanation) recordVars(path string, keys []string) {
+	source := e.Sources[path]
+	source.Vars = keys
+	e.Sources[path] = source
+}
+
+// inheritance resolves the parents of contexts while loading one, reading
+// each context file once.
+type inheritance struct {
+	m *Manager
+	// own holds contexts as written in their file, with their extends
+	// resolved
+	own map[string]*ContextConfig
+	// linear holds the linearizations already computed
+	linear map[string][]string
+	// explanation, if set, records where the merged values come from, with
+	// the line of each field in the files in lines
+	explanation *Explanation
+	lines       map[string]map[string]int
+}
+
+func (m *Manager) newInheritance() *inheritance {
+	return &inheritance{
+		m:      m,
+		own:    make(map[string]*ContextConfig),
+		linear: make(map[string][]string),
+		lines:  make(map[string]map[string]int),
+	}
+}
+
+// load returns a context as written in its file.
+func (h *inheritance) load(name string) (*ContextConfig, error) {
+	if cfg, ok := h.own[name]; ok {
+		return cfg, nil
+	}
+
+	data, err := h.m.ReadContextFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ContextConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse context file: %w", err)
+	}
+
+	// Namespaced contexts are named by their path, whatever their file says
+	if strings.Contains(name, "/") {
+		config.Name = name
+	}
+
+	if err := h.resolve(name, config); err != nil {
+		return nil, err
+	}
+	h.own[name] = config
+
+	if h.explanation != nil {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse context file: %w", err)
+		}
+		h.lines[name] = make(map[string]int)
+		if len(doc.Content) > 0 {
+			fieldLines(doc.Content[0], "", h.lines[name])
+		}
+	}
+	return config, nil
+}
+
+// fieldLines records the line of each value under node by its path, keyed
+// like FlattenContextByName.
+func fieldLines(node *yaml.Node, path string, lines map[string]int) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			fieldLines(node.Content[i+1], key, lines)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			key := itemName(item)
+			if item.Kind == yaml.ScalarNode {
+				key = item.Value
+			}
+			if key == "" {
+				key = strconv.Itoa(i)
+			}
+			fieldLines(item, path+"["+key+"]", lines)
+		}
+	case yaml.ScalarNode:
+		lines[path] = node.Line
+	}
+}
+
+// resolve replaces the names a context extends with the names of the
+// contexts they refer to from name (see resolveExtends).
+func (h *inheritance) resolve(name string, config *ContextConfig) error {
+	for i, parent := range config.Extends {
+		resolved, err := h.m.resolveExtends(name, parent)
+		if err != nil {
+			return fmt.Errorf("context '%s': %w", name, err)
+		}
+		if slices.Contains(config.Extends[:i], resolved) {
+			return fmt.Errorf("context '%s' extends '%s' more than once", name, resolved)
+		}
+		config.Extends[i] = resolved
+	}
+	return nil
+}
+
+// linearize returns the order a context and its ancestors are merged in.
+// chain holds the contexts being linearized that lead to name, to detect
+// cycles.
+func (h *inheritance) linearize(name string, chain []string) ([]string, error) {
+	if err := CheckInheritanceCycle(chain, name); err != nil {
+		return nil, err
+	}
+	if order, ok := h.linear[name]; ok {
+		return order, nil
+	}
+
+	config, err := h.load(name)
+	if err != nil {
+		return nil, err
+	}
+	order, err := h.linearizeParents(name, config.Extends, append(chain, name))
+	if err != nil {
+		return nil, err
+	}
+	h.linear[name] = order
+	return order, nil
+}
+
+// linearizeParents returns the order a context named name with the given
+// parents is merged in. It's the C3 linearization of the parents in reverse:
+// a later parent and its ancestors come before an earlier one, each context
+// comes before the contexts it extends, and a context several parents
+// extend is merged once.
+func (h *inheritance) linearizeParents(name string, parents []string, chain []string) ([]string, error) {
+	var orders [][]string
+	for _, parent := range slices.Backward(parents) {
+		order, err := h.linearize(parent, chain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load parent context '%s': %w", parent, err)
+		}
+		orders = append(orders, order)
+	}
+	reversed := slices.Clone(parents)
+	slices.Reverse(reversed)
+
+	merged, err := c3Merge(append(orders, reversed))
+	if err != nil {
+		return nil, fmt.Errorf("can't order the parents of '%s': %w", name, err)
+	}
+	return append([]string{name}, merged...), nil
+}
+
+// c3Merge merges orders into one that keeps the order of each, by taking
+// the first head that isn't in the tail of any of them, one at a time.
+func c3Merge(orders [][]string) ([]string, error) {
+	var merged []string
+	for {
+		orders = slices.DeleteFunc(orders, func(order []string) bool { return len(order) == 0 })
+		if len(orders) == 0 {
+			return merged, nil
+		}
+
+		next := ""
+		for _, order := range orders {
+			inTail := slices.ContainsFunc(orders, func(other []string) bool {
+				return slices.Contains(other[1:], order[0])
+			})
+			if !inTail {
+				next = order[0]
+				break
+			}
+		}
+		if next == "" {
+			var heads []string
+			for _, order := range orders {
+				if head := "'" + order[0] + "'"; !slices.Contains(heads, head) {
+					heads = append(heads, head)
+				}
+			}
+			return nil, fmt.Errorf("the order of %s conflicts with the contexts they extend", strings.Join(heads, " and "))
+		}
+
+		merged = append(merged, next)
+		for i, order := range orders {
+			if order[0] == next {
+				orders[i] = order[1:]
+			}
+		}
+	}
+}
+
+// merge merges the contexts of a linearization into the first one.
+func (h *inheritance) merge(order []string) (*ContextConfig, error) {
+	config := h.own[order[0]]
+	if h.explanation == nil {
+		for _, name := range order[1:] {
+			config.MergeFrom(h.own[name])
+		}
+		// Nothing is left to override once the parents are merged
+		config.overrides = nil
+		return config, nil
+	}
+
+	h.explanation.Order = order
+	h.explanation.Sources = make(map[string]FieldSource)
+	var before map[string]string
+	for i, name := range order {
+		if i > 0 {
+			config.MergeFrom(h.own[name])
+		}
+		after, err := FlattenContextByName(config)
+		if err != nil {
+			return nil, err
+		}
+		h.recordSources(name, before, after)
+		before = after
+	}
+	config.overrides = nil
+	return config, nil
+}
+
+// recordSources records that the fields that changed from before to after
+// come from the context name. Zero values only count if they're written in
+// its file, as every context has them.
+func (h *inheritance) recordSources(name string, before, after map[string]string) {
+	for path, value := range after {
+		if existing, ok := before[path]; ok && existing == value {
+			continue
+		}
+		line := h.lines[name][path]
+		if line == 0 && (value == "" || value == "false" || value == "0") {
+			continue
+		}
+		h.explanation.Sources[path] = FieldSource{Context: name, File: h.m.ContextPath(name), Line: line}
+	}
+}
+
+// ExplainContext loads a context like LoadContext, and tells where each of
+// its values comes from.
+func (m *Manager) ExplainContext(name string) (*ContextConfig, *Explanation, error) {
+	explanation := &Explanation{}
+	cfg, err := m.loadContextWithChain(name, nil, explanation)
+	if err != nil {
+		return nil, nil, err
+	}
+	expandConfigVars(cfg, explanation)
+	return cfg, explanation, nil
+}
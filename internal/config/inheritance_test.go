@@ -0,0 +1,399 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func writeContextFiles(t *testing.T, m *Manager, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(m.ContextsDir(), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExtends_Encoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		json     string
+		wantList Extends
+	}{
+		{name: "single name", yaml: "extends: base\n", json: `{"extends":"base"}`, wantList: Extends{"base"}},
+		{name: "list", yaml: "extends:\n    - base\n    - vpn\n", json: `{"extends":["base","vpn"]}`, wantList: Extends{"base", "vpn"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromYAML, fromJSON struct {
+				Extends Extends `yaml:"extends" json:"extends"`
+			}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &fromYAML); err != nil {
+				t.Fatalf("yaml.Unmarshal() error = %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.json), &fromJSON); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(fromYAML.Extends, tt.wantList) || !reflect.DeepEqual(fromJSON.Extends, tt.wantList) {
+				t.Errorf("Unmarshal() = %v, %v, want %v", fromYAML.Extends, fromJSON.Extends, tt.wantList)
+			}
+
+			// A single parent is written back as a name
+			data, err := yaml.Marshal(fromYAML)
+			if err != nil || string(data) != tt.yaml {
+				t.Errorf("yaml.Marshal() = %q, %v, want %q", data, err, tt.yaml)
+			}
+			data, err = json.Marshal(fromJSON)
+			if err != nil || string(data) != tt.json {
+				t.Errorf("json.Marshal() = %s, %v, want %s", data, err, tt.json)
+			}
+		})
+	}
+
+	var invalid struct {
+		Extends Extends `yaml:"extends"`
+	}
+	if err := yaml.Unmarshal([]byte("extends:\n  name: base\n"), &invalid); err == nil {
+		t.Error("yaml.Unmarshal() of a map error = nil")
+	}
+}
+
+func TestManager_MultipleInheritance(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	writeContextFiles(t, m, map[string]string{
+		"base.yaml":     "name: base\nabstract: true\naws:\n  region: us-east-1\n  profile: base\nenv:\n  LEVEL: base\n",
+		"vpn.yaml":      "name: vpn\nabstract: true\nextends: base\naws:\n  region: eu-west-1\nenv:\n  LEVEL: vpn\n",
+		"proxy.yaml":    "name: proxy\nabstract: true\nextends: base\nproxy:\n  http: http://proxy:3128\nenv:\n  LEVEL: proxy\n",
+		"prod.yaml":     "name: prod\nextends: [proxy, vpn]\nenvironment: production\n",
+		"reversed.yaml": "name: reversed\nextends: [vpn, proxy]\n",
+		"twice.yaml":    "name: twice\nextends: [vpn, vpn]\n",
+		"conflict.yaml": "name: conflict\nextends: [vpn, base]\n",
+		"cycle-a.yaml":  "name: cycle-a\nextends: [base, cycle-b]\n",
+		"cycle-b.yaml":  "name: cycle-b\nextends: cycle-a\n",
+	})
+
+	tests := []struct {
+		name      string
+		wantOrder []string
+		wantLevel string
+		wantErr   string
+	}{
+		// The later parent wins, and the shared base is merged last
+		{name: "prod", wantOrder: []string{"prod", "vpn", "proxy", "base"}, wantLevel: "vpn"},
+		{name: "reversed", wantOrder: []string{"reversed", "proxy", "vpn", "base"}, wantLevel: "proxy"},
+		{name: "twice", wantErr: "more than once"},
+		{name: "conflict", wantErr: "conflicts"},
+		{name: "cycle-a", wantErr: "circular inheritance detected: cycle-a -> cycle-b -> cycle-a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, explanation, err := m.ExplainContext(tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExplainContext() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := m.LoadContext(tt.name); err == nil {
+					t.Error("LoadContext() error = nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExplainContext() error = %v", err)
+			}
+			if !reflect.DeepEqual(explanation.Order, tt.wantOrder) {
+				t.Errorf("ExplainContext() order = %v, want %v", explanation.Order, tt.wantOrder)
+			}
+			if ctx.Env["LEVEL"] != tt.wantLevel {
+				t.Errorf("ExplainContext() LEVEL = %q, want %q", ctx.Env["LEVEL"], tt.wantLevel)
+			}
+
+			loaded, err := m.LoadContext(tt.name)
+			if err != nil {
+				t.Fatalf("LoadContext() error = %v", err)
+			}
+			if !reflect.DeepEqual(loaded, ctx) {
+				t.Errorf("LoadContext() = %+v, want the explained context %+v", loaded, ctx)
+			}
+		})
+	}
+
+	_, explanation, err := m.ExplainContext("prod")
+	if err != nil {
+		t.Fatalf("ExplainContext() error = %v", err)
+	}
+	wantOrigins := map[string]string{
+		"environment": "prod",
+		"aws.region":  "vpn",
+		"aws.profile": "base",
+		"proxy.http":  "proxy",
+		"env.LEVEL":   "vpn",
+	}
+	for path, want := range wantOrigins {
+		if got := explanation.Sources[path].Context; got != want {
+			t.Errorf("ExplainContext() origin of %s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestManager_InheritanceOverrides(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	writeContextFiles(t, m, map[string]string{
+		"base.yaml": `name: base
+abstract: true
+tags: [team, shared]
+aws:
+  profile: base
+  region: us-east-1
+  use_vault: true
+vpn:
+  type: openvpn
+env:
+  LEVEL: base
+  DEBUG: "1"
+  TOKEN: secret
+urls:
+  docs: https://docs.example.com
+tunnels:
+  - name: db
+    remote_host: db.internal
+    remote_port: 5432
+    local_port: 5432
+    auto_connect: true
+  - name: cache
+    remote_host: cache.internal
+    remote_port: 6379
+    local_port: 6379
+databases:
+  - name: main
+    type: postgres
+    host: db.internal
+    port: 5432
+    username: admin
+`,
+		"middle.yaml": `name: middle
+abstract: true
+extends: base
+aws:
+  use_vault: !override false
+env:
+  DEBUG: null
+`,
+		"child.yaml": `name: child
+extends: middle
+tags: [!unset shared, child, team]
+vpn: !unset
+env:
+  LEVEL: child
+  TOKEN: !unset
+urls: !override {}
+tunnels:
+  - name: db
+    local_port: 15432
+    auto_connect: !override false
+  - !unset cache
+  - name: web
+    remote_host: web.internal
+    remote_port: 80
+    local_port: 8080
+databases:
+  - !override
+    name: main
+    type: mysql
+    host: mysql.internal
+`,
+		"restored.yaml": `name: restored
+extends: middle
+aws:
+  use_vault: true
+env:
+  DEBUG: "2"
+`,
+	})
+
+	child, err := m.LoadContext("child")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	restored, err := m.LoadContext("restored")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+
+	wantTunnels := []TunnelConfig{
+		{Name: "db", RemoteHost: "db.internal", RemotePort: 5432, LocalPort: 15432},
+		{Name: "web", RemoteHost: "web.internal", RemotePort: 80, LocalPort: 8080},
+	}
+	wantDatabases := []DatabaseConfig{{Name: "main", Type: DBTypeMySQL, Host: "mysql.internal"}}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "overridden false boolean", got: child.AWS.UseVault, want: false},
+		{name: "inherited values next to the override", got: child.AWS.Region, want: "us-east-1"},
+		{name: "child true over a parent's override", got: restored.AWS.UseVault, want: true},
+		{name: "unset section", got: child.VPN, want: (*VPNConfig)(nil)},
+		{name: "env with unset keys", got: child.Env, want: map[string]string{"LEVEL": "child"}},
+		{name: "child value over a parent's null", got: restored.Env["DEBUG"], want: "2"},
+		{name: "overridden map", got: child.URLs, want: map[string]string{}},
+		{name: "unset tag", got: child.Tags, want: []string{"team", "child"}},
+		{name: "tunnels merged by name", got: child.Tunnels, want: wantTunnels},
+		{name: "overridden database", got: child.Databases, want: wantDatabases},
+		{name: "inherited lists", got: len(restored.Tunnels) + len(restored.Databases), want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %#v, want %#v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextConfig_MarshalYAMLKeepsOverrides(t *testing.T) {
+	src := `name: child
+extends: base
+vpn: !unset
+aws:
+  use_vault: !override false
+env:
+  LEVEL: child
+  DEBUG: null
+urls: !override {}
+tags: [!unset shared, child]
+tunnels:
+  - name: db
+    auto_connect: !override false
+  - !unset cache
+databases:
+  - !override
+    name: main
+    type: mysql
+`
+	var cfg ContextConfig
+	if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, err := yaml.Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+	var back ContextConfig
+	if err := yaml.Unmarshal(data, &back); err != nil {
+		t.Fatalf("yaml.Unmarshal() of %s error = %v", data, err)
+	}
+
+	want := map[string]string{
+		"vpn":                     "!unset",
+		"aws.use_vault":           "!override false",
+		"env.DEBUG":               "!unset",
+		"urls":                    "!override",
+		"tags[1]":                 "!unset shared",
+		"tunnels[0].auto_connect": "!override false",
+		"tunnels[1]":              "!unset cache",
+		"databases[0]":            "!override",
+		"databases[0].type":       "mysql",
+		"env.LEVEL":               "child",
+	}
+	got, err := FlattenContextTags(&back)
+	if err != nil {
+		t.Fatalf("FlattenContextTags() error = %v", err)
+	}
+	for path, value := range want {
+		if got[path] != value {
+			t.Errorf("FlattenContextTags() %s = %q, want %q", path, got[path], value)
+		}
+	}
+
+	// Merged contexts have nothing left to override
+	resolved := NewManagerWithDir(t.TempDir())
+	writeContextFiles(t, resolved, map[string]string{
+		"base.yaml":  "name: base\nabstract: true\naws:\n  use_vault: true\n",
+		"child.yaml": src,
+	})
+	child, err := resolved.LoadContext("child")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if data, _ := yaml.Marshal(child); strings.Contains(string(data), "!") {
+		t.Errorf("yaml.Marshal() of a loaded context kept tags:\n%s", data)
+	}
+}
+
+func TestManager_ExplainContext(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	writeContextFiles(t, m, map[string]string{
+		"base.yaml": `name: base
+abstract: true
+aws:
+  profile: ${TEAM}-admin
+  use_vault: true
+tags: [shared]
+tunnels:
+  - name: db
+    remote_host: db.internal
+    remote_port: 5432
+`,
+		"acme/prod.yaml": `name: prod
+extends: ../base
+env:
+  TEAM: acme
+tags: [prod]
+tunnels:
+  - name: web
+    remote_host: web.internal
+  - name: db
+    local_port: 15432
+`,
+	})
+	base := filepath.Join(m.ContextsDir(), "base.yaml")
+	prod := filepath.Join(m.ContextsDir(), "acme", "prod.yaml")
+
+	ctx, explanation, err := m.ExplainContext("acme/prod")
+	if err != nil {
+		t.Fatalf("ExplainContext() error = %v", err)
+	}
+	if ctx.AWS.Profile != "acme-admin" {
+		t.Errorf("ExplainContext() aws.profile = %q, want it expanded", ctx.AWS.Profile)
+	}
+
+	want := map[string]FieldSource{
+		"name":                     {Context: "acme/prod", File: prod, Line: 1},
+		"extends":                  {Context: "acme/prod", File: prod, Line: 2},
+		"env.TEAM":                 {Context: "acme/prod", File: prod, Line: 4},
+		"aws.profile":              {Context: "base", File: base, Line: 4, Vars: []string{"TEAM"}},
+		"aws.use_vault":            {Context: "base", File: base, Line: 5},
+		"tags[prod]":               {Context: "acme/prod", File: prod, Line: 5},
+		"tags[shared]":             {Context: "base", File: base, Line: 6},
+		"tunnels[db].local_port":   {Context: "acme/prod", File: prod, Line: 10},
+		"tunnels[db].remote_host":  {Context: "base", File: base, Line: 9},
+		"tunnels[web].remote_host": {Context: "acme/prod", File: prod, Line: 8},
+	}
+	for path, source := range want {
+		if got := explanation.Sources[path]; !reflect.DeepEqual(got, source) {
+			t.Errorf("ExplainContext() source of %s = %+v, want %+v", path, got, source)
+		}
+	}
+	// Defaults no context sets have no source
+	if source, ok := explanation.Sources["tunnels[web].auto_connect"]; ok {
+		t.Errorf("ExplainContext() source of a default = %+v", source)
+	}
+}
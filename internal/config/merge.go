@@ -0,0 +1,442 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"dario.cat/mergo"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML tags that control inheritance. A value tagged !override replaces the
+// inherited one as is, even if it's false, empty or a map. A key or list
+// item tagged !unset, or a key set to an explicit null, drops the inherited
+// value.
+const (
+	overrideTag = "!override"
+	unsetTag    = "!unset"
+)
+
+// fieldPath is the path of a field by its YAML keys. Items of lists of named
+// entries, such as tunnels, are found by name.
+type fieldPath []string
+
+// override is a field a context overrides, or unsets.
+type override struct {
+	path  fieldPath
+	unset bool
+}
+
+// plainContext is a ContextConfig without its YAML methods.
+type plainContext ContextConfig
+
+// UnmarshalYAML decodes a context and records the fields it overrides or
+// unsets, which its parents can't fill in.
+func (c *ContextConfig) UnmarshalYAML(node *yaml.Node) error {
+	overrides := takeOverrides(node, nil)
+
+	if err := node.Decode((*plainContext)(c)); err != nil {
+		return err
+	}
+	c.overrides = overrides
+	return nil
+}
+
+// MarshalYAML encodes a context with the tags of the fields it overrides or
+// unsets, so saving it keeps them.
+func (c ContextConfig) MarshalYAML() (any, error) {
+	var node yaml.Node
+	if err := node.Encode((*plainContext)(&c)); err != nil {
+		return nil, err
+	}
+	for _, o := range c.overrides {
+		if err := tagField(&node, reflect.ValueOf(c), o.path, o.unset); err != nil {
+			return nil, err
+		}
+	}
+	return &node, nil
+}
+
+// takeOverrides returns the fields under node that are tagged !override or
+// !unset or set to null. Unset keys and list items are removed from node,
+// and the tags are cleared so the values decode as usual.
+func takeOverrides(node *yaml.Node, path fieldPath) []override {
+	var overrides []override
+	switch node.Kind {
+	case yaml.MappingNode:
+		var content []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := append(slices.Clone(path), key.Value)
+			unwrapTag(value)
+			switch {
+			case value.Tag == unsetTag || isExplicitNull(value):
+				overrides = append(overrides, override{path: keyPath, unset: true})
+				continue
+			case value.Tag == overrideTag:
+				value.Tag = ""
+				overrides = append(overrides, override{path: keyPath})
+			default:
+				overrides = append(overrides, takeOverrides(value, keyPath)...)
+			}
+			content = append(content, key, value)
+		}
+		node.Content = content
+	case yaml.SequenceNode:
+		var content []*yaml.Node
+		for _, item := range node.Content {
+			// Named items can be unset by name, and overridden as a whole
+			unwrapTag(item)
+			if item.Tag == unsetTag && item.Kind == yaml.ScalarNode {
+				overrides = append(overrides, override{path: append(slices.Clone(path), item.Value), unset: true})
+				continue
+			}
+			overridden := item.Tag == overrideTag
+			if overridden {
+				item.Tag = ""
+			}
+			if name := itemName(item); name != "" {
+				itemPath := append(slices.Clone(path), name)
+				if overridden {
+					overrides = append(overrides, override{path: itemPath})
+				} else {
+					overrides = append(overrides, takeOverrides(item, itemPath)...)
+				}
+			}
+			content = append(content, item)
+		}
+		node.Content = content
+	}
+	return overrides
+}
+
+// unwrapTag turns a mapping whose only key is !override or !unset back into
+// the tagged value. JSON has no tags, so ContextFileMap writes them this way.
+func unwrapTag(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return
+	}
+	tag := node.Content[0].Value
+	if tag != overrideTag && tag != unsetTag {
+		return
+	}
+	*node = *node.Content[1]
+	node.Tag = tag
+}
+
+// isExplicitNull reports whether a value is written as null or ~. An empty
+// value is left alone, as files often have keys with nothing set.
+func isExplicitNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" && node.Value != ""
+}
+
+// itemName returns the name of a list item that's a mapping with a name.
+func itemName(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "name" {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// tagField tags the field at path under node, the encoding of v, with
+// !override or !unset. Fields left out of node, such as false values and
+// unset keys, are added to it.
+func tagField(node *yaml.Node, v reflect.Value, path fieldPath, unset bool) error {
+	child, ok := fieldValue(v, path[0])
+	if !ok {
+		return nil
+	}
+	last := len(path) == 1
+
+	var value *yaml.Node
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == path[0] {
+				value = node.Content[i+1]
+			}
+		}
+		if value == nil {
+			value = &yaml.Node{Kind: yaml.ScalarNode}
+			if !last || !unset {
+				if err := value.Encode(child.Interface()); err != nil {
+					return err
+				}
+			}
+			appendContent(node, &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}, value)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if itemName(item) == path[0] || (item.Kind == yaml.ScalarNode && item.Value == path[0]) {
+				value = item
+			}
+		}
+		if value == nil {
+			// Only unset items are left out of a list
+			if last && unset {
+				appendContent(node, &yaml.Node{Kind: yaml.ScalarNode, Tag: unsetTag, Value: path[0]})
+			}
+			return nil
+		}
+		if last && unset {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if !last {
+		if value.Kind != yaml.MappingNode && value.Kind != yaml.SequenceNode {
+			*value = yaml.Node{Kind: yaml.MappingNode}
+			if t := child.Type(); t.Kind() == reflect.Slice {
+				value.Kind = yaml.SequenceNode
+			}
+		}
+		return tagField(value, child, path[1:], unset)
+	}
+	value.Tag = overrideTag
+	if unset {
+		value.Tag = unsetTag
+	}
+	return nil
+}
+
+// fieldValue returns the field with the given YAML key, map key or item
+// name in v, or its zero value if v doesn't have it.
+func fieldValue(v reflect.Value, key string) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			v = v.Elem()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		i := yamlFieldIndex(v.Type(), key)
+		if i < 0 {
+			return reflect.Value{}, false
+		}
+		return v.Field(i), true
+	case reflect.Map:
+		if item := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())); item.IsValid() {
+			return item, true
+		}
+		return reflect.Zero(v.Type().Elem()), true
+	case reflect.Slice:
+		for i := range v.Len() {
+			if namedItem(v.Index(i)) == key {
+				return v.Index(i), true
+			}
+		}
+		return reflect.Zero(v.Type().Elem()), true
+	}
+	return reflect.Value{}, false
+}
+
+// appendContent adds items to a mapping or sequence node, written in block
+// style if it was empty.
+func appendContent(node *yaml.Node, items ...*yaml.Node) {
+	if len(node.Content) == 0 {
+		node.Style = 0
+	}
+	node.Content = append(node.Content, items...)
+}
+
+// ContextFileMap returns the fields of a context file as a map that can be
+// sent as JSON. JSON has no tags, so values tagged !override or !unset are
+// maps with the tag as their only key, as in {"!unset": "cache"}. Contexts
+// decoded from the map take them as the tags.
+func ContextFileMap(data []byte) (map[string]any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse context: %w", err)
+	}
+	fields := make(map[string]any)
+	if len(doc.Content) == 0 {
+		return fields, nil
+	}
+
+	value, err := taggedValue(doc.Content[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse context: %w", err)
+	}
+	if value != nil {
+		var ok bool
+		if fields, ok = value.(map[string]any); !ok {
+			return nil, fmt.Errorf("context isn't a mapping")
+		}
+	}
+	return fields, nil
+}
+
+// taggedValue decodes a node, with its values tagged !override or !unset
+// wrapped in maps by their tag.
+func taggedValue(node *yaml.Node) (any, error) {
+	tag := node.Tag
+	if tag == overrideTag || tag == unsetTag {
+		untagged := *node
+		untagged.Tag = ""
+		node = &untagged
+	}
+
+	var value any
+	switch node.Kind {
+	case yaml.MappingNode:
+		fields := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			item, err := taggedValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			fields[node.Content[i].Value] = item
+		}
+		value = fields
+	case yaml.SequenceNode:
+		items := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			decoded, err := taggedValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, decoded)
+		}
+		value = items
+	default:
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+
+	if tag == overrideTag || tag == unsetTag {
+		return map[string]any{tag: value}, nil
+	}
+	return value, nil
+}
+
+// withoutField returns a copy of v with the field at path cleared. Only the
+// values along the path are copied.
+func withoutField(v reflect.Value, path fieldPath) reflect.Value {
+	if len(path) == 0 {
+		return reflect.Zero(v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(withoutField(v.Elem(), path))
+		return copied
+	case reflect.Struct:
+		i := yamlFieldIndex(v.Type(), path[0])
+		if i < 0 {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		copied.Field(i).Set(withoutField(v.Field(i), path[1:]))
+		return copied
+	case reflect.Map:
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		if v.IsNil() || !v.MapIndex(key).IsValid() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), iter.Value())
+		}
+		if len(path) == 1 {
+			copied.SetMapIndex(key, reflect.Value{})
+		} else {
+			copied.SetMapIndex(key, withoutField(v.MapIndex(key), path[1:]))
+		}
+		return copied
+	case reflect.Slice:
+		copied := reflect.MakeSlice(v.Type(), 0, v.Len())
+		for i := range v.Len() {
+			item := v.Index(i)
+			switch {
+			case namedItem(item) != path[0]:
+			case len(path) == 1:
+				continue
+			default:
+				item = withoutField(item, path[1:])
+			}
+			copied = reflect.Append(copied, item)
+		}
+		return copied
+	}
+	return v
+}
+
+// yamlFieldIndex returns the index of the field of a struct type with the
+// given YAML key, or -1.
+func yamlFieldIndex(t reflect.Type, key string) int {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if field.IsExported() && name == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// namedItem returns the Name of a list item that's a struct with one, or
+// the item itself if it's a string.
+func namedItem(item reflect.Value) string {
+	if item.Kind() == reflect.String {
+		return item.String()
+	}
+	if item.Kind() != reflect.Struct {
+		return ""
+	}
+	name := item.FieldByName("Name")
+	if !name.IsValid() || name.Kind() != reflect.String {
+		return ""
+	}
+	return name.String()
+}
+
+// mergeNamed merges a parent's list of named entries into a child's. The
+// parent's entries keep their place, each filled in by the child's entry of
+// the same name, and the child's other entries come after them.
+func mergeNamed[T any](items, parents []T, name func(T) string) []T {
+	if len(parents) == 0 {
+		return items
+	}
+
+	merged := make([]T, 0, len(items)+len(parents))
+	used := make([]bool, len(items))
+	for _, parent := range parents {
+		i := slices.IndexFunc(items, func(item T) bool { return name(item) == name(parent) })
+		if i < 0 {
+			merged = append(merged, parent)
+			continue
+		}
+		item := items[i]
+		mergo.Merge(&item, parent)
+		merged = append(merged, item)
+		used[i] = true
+	}
+	for i, item := range items {
+		if !used[i] {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ConfigDirEnv is the environment variable that overrides the configuration
+// directory.
+const ConfigDirEnv = "CTX_CONFIG_DIR"
+
+// defaultDirs returns the configuration and state directories.
+//
+// $CTX_CONFIG_DIR holds everything, state included. Otherwise the
+// configuration is in $XDG_CONFIG_HOME/ctx (default ~/.config/ctx), and the
+// state in $XDG_STATE_HOME/ctx when XDG_STATE_HOME is set. A state directory
+// that already exists in the configuration directory is kept, so setting
+// XDG_STATE_HOME doesn't lose the current context, sessions and tokens.
+func defaultDirs() (configDir, stateDir string, err error) {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		configDir, err := filepath.Abs(expandPath(dir))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to resolve %s: %w", ConfigDirEnv, err)
+		}
+		return configDir, filepath.Join(configDir, StateSubdir), nil
+	}
+
+	// Relative XDG paths are invalid and ignored, as the spec says
+	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdgConfig) {
+		configDir = filepath.Join(xdgConfig, "ctx")
+	} else {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		configDir = filepath.Join(homeDir, DefaultConfigDir)
+	}
+
+	stateDir = filepath.Join(configDir, StateSubdir)
+	if xdgState := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(xdgState) {
+		if _, err := os.Stat(stateDir); os.IsNotExist(err) {
+			stateDir = filepath.Join(xdgState, "ctx")
+		}
+	}
+	return configDir, stateDir, nil
+}
+
+// resolvePath expands ~ in a path from the app config and makes a relative
+// path relative to the configuration directory.
+func (m *Manager) resolvePath(path string) string {
+	path = expandPath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.configDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// ContextPaths returns the directories contexts are looked up in, in order:
+// the contexts directory, then the context_paths of the app config. A context
+// in an earlier directory shadows the contexts of the same name in later
+// ones.
+func (m *Manager) ContextPaths() []string {
+	dirs := []string{m.ContextsDir()}
+	if appConfig := m.GetAppConfig(); appConfig != nil {
+		for _, dir := range appConfig.ContextPaths {
+			if dir = m.resolvePath(dir); !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// TunnelsDir returns the directory of tunnel logs: tunnels_dir from the app
+// config, or the tunnels subdirectory of the state directory.
+func (m *Manager) TunnelsDir() string {
+	if appConfig := m.GetAppConfig(); appConfig != nil && appConfig.TunnelsDir != "" {
+		return m.resolvePath(appConfig.TunnelsDir)
+	}
+	return filepath.Join(m.stateDir, "tunnels")
+}
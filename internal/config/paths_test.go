@@ -0,0 +1,258 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultDirs(t *testing.T) {
+	home := t.TempDir()
+	legacyHome := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(legacyHome, DefaultConfigDir, StateSubdir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		home       string
+		env        map[string]string
+		wantConfig string
+		wantState  string
+	}{
+		{
+			name:       "default",
+			home:       home,
+			wantConfig: filepath.Join(home, ".config", "ctx"),
+			wantState:  filepath.Join(home, ".config", "ctx", "state"),
+		},
+		{
+			name:       "CTX_CONFIG_DIR",
+			home:       home,
+			env:        map[string]string{ConfigDirEnv: "/srv/ctx", "XDG_STATE_HOME": "/xdg/state"},
+			wantConfig: "/srv/ctx",
+			wantState:  "/srv/ctx/state",
+		},
+		{
+			name:       "XDG directories",
+			home:       home,
+			env:        map[string]string{"XDG_CONFIG_HOME": "/xdg/config", "XDG_STATE_HOME": "/xdg/state"},
+			wantConfig: "/xdg/config/ctx",
+			wantState:  "/xdg/state/ctx",
+		},
+		{
+			name:       "relative XDG directories are ignored",
+			home:       home,
+			env:        map[string]string{"XDG_CONFIG_HOME": "config", "XDG_STATE_HOME": "state"},
+			wantConfig: filepath.Join(home, ".config", "ctx"),
+			wantState:  filepath.Join(home, ".config", "ctx", "state"),
+		},
+		{
+			name:       "existing state directory is kept",
+			home:       legacyHome,
+			env:        map[string]string{"XDG_STATE_HOME": "/xdg/state"},
+			wantConfig: filepath.Join(legacyHome, ".config", "ctx"),
+			wantState:  filepath.Join(legacyHome, ".config", "ctx", "state"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", tt.home)
+			for _, key := range []string{ConfigDirEnv, "XDG_CONFIG_HOME", "XDG_STATE_HOME"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			configDir, stateDir, err := defaultDirs()
+			if err != nil {
+				t.Fatalf("defaultDirs() error = %v", err)
+			}
+			if configDir != tt.wantConfig || stateDir != tt.wantState {
+				t.Errorf("defaultDirs() = %s, %s, want %s, %s", configDir, stateDir, tt.wantConfig, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestManager_ContextPaths(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	team := t.TempDir()
+	generated := filepath.Join(m.ConfigDir(), "generated")
+	if err := m.SaveAppConfig(&AppConfig{ContextPaths: []string{team, "generated", team}}); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		filepath.Join(m.ContextsDir(), "dev.yaml"):   "name: dev\nenvironment: development\n",
+		filepath.Join(team, "dev.yaml"):              "name: dev\nenvironment: staging\n",
+		filepath.Join(team, "prod.yaml"):             "name: prod\nextends: base\n",
+		filepath.Join(generated, "prod.yaml"):        "name: prod\nenvironment: development\n",
+		filepath.Join(generated, "base.yaml"):        "name: base\nenvironment: production\n",
+		filepath.Join(generated, "notes.txt"):        "not a context\n",
+		filepath.Join(m.ContextsDir(), "local.yaml"): "name: local\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := []string{m.ContextsDir(), team, generated}; !reflect.DeepEqual(m.ContextPaths(), want) {
+		t.Errorf("ContextPaths() = %v, want %v", m.ContextPaths(), want)
+	}
+
+	names, err := m.ListContexts()
+	if err != nil {
+		t.Fatalf("ListContexts() error = %v", err)
+	}
+	if want := []string{"base", "dev", "local", "prod"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ListContexts() = %v, want %v", names, want)
+	}
+
+	// Earlier paths shadow later ones
+	source := m.ContextSource("dev")
+	if source.Path != filepath.Join(m.ContextsDir(), "dev.yaml") || !reflect.DeepEqual(source.Shadowed, []string{filepath.Join(team, "dev.yaml")}) {
+		t.Errorf("ContextSource(dev) = %+v", source)
+	}
+	prod, err := m.LoadContext("prod")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if prod.Environment != EnvProduction {
+		t.Errorf("LoadContext(prod) environment = %q, want the team's file with the generated base", prod.Environment)
+	}
+
+	// Contexts outside the contexts directory are saved as local copies and
+	// can't be deleted
+	if err := m.DeleteContext("prod"); err == nil {
+		t.Error("DeleteContext() of a context in another path error = nil")
+	}
+	if err := m.SaveContext(&ContextConfig{Name: "prod", Environment: EnvStaging}); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+	if source := m.ContextSource("prod"); source.Path != filepath.Join(m.ContextsDir(), "prod.yaml") || len(source.Shadowed) != 2 {
+		t.Errorf("ContextSource(prod) after saving = %+v", source)
+	}
+	if err := m.DeleteContext("prod"); err != nil {
+		t.Errorf("DeleteContext() of the local copy error = %v", err)
+	}
+
+	if m.ContextSource("missing").Path != filepath.Join(m.ContextsDir(), "missing.yaml") {
+		t.Error("ContextSource() of a missing context isn't in the contexts directory")
+	}
+}
+
+func TestManager_ConfiguredDirs(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	if m.TunnelsDir() != filepath.Join(m.StateDir(), "tunnels") {
+		t.Errorf("TunnelsDir() = %q, want the default in the state directory", m.TunnelsDir())
+	}
+
+	contexts := t.TempDir()
+	if err := m.SaveAppConfig(&AppConfig{ContextsDir: contexts, TunnelsDir: "logs/tunnels"}); err != nil {
+		t.Fatal(err)
+	}
+	if m.ContextsDir() != contexts {
+		t.Errorf("ContextsDir() = %q, want %q", m.ContextsDir(), contexts)
+	}
+	if want := filepath.Join(m.ConfigDir(), "logs", "tunnels"); m.TunnelsDir() != want {
+		t.Errorf("TunnelsDir() = %q, want %q", m.TunnelsDir(), want)
+	}
+
+	if err := m.SaveContext(&ContextConfig{Name: "dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(contexts, "dev.yaml")); err != nil {
+		t.Errorf("SaveContext() didn't save in the configured contexts directory: %v", err)
+	}
+
+	// The default directories aren't written to the config
+	if err := m.SaveAppConfig(m.defaultAppConfig()); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := m.LoadAppConfig()
+	if err != nil {
+		t.Fatalf("LoadAppConfig() error = %v", err)
+	}
+	if loaded.ContextsDir != "" || loaded.TunnelsDir != "" {
+		t.Errorf("LoadAppConfig() dirs = %q, %q, want them empty", loaded.ContextsDir, loaded.TunnelsDir)
+	}
+}
+
+func TestManager_NestedContexts(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	files := map[string]string{
+		"dev.yaml":                "name: dev\nenvironment: development\n",
+		"base.yaml":               "name: base\nenv:\n  LEVEL: top\n",
+		"acme/base.yaml":          "name: base\nabstract: true\nenv:\n  LEVEL: acme\n",
+		"acme/payments/prod.yml":  "name: prod\nextends: ../base\nenvironment: production\n",
+		"acme/payments/dev.yaml":  "name: dev\nextends: ./prod\nenvironment: development\n",
+		"acme/infra/prod.yaml":    "name: prod\nextends: base\n",
+		"acme/infra/escape.yaml":  "name: escape\nextends: ../../../base\n",
+		"acme/.git/config.yaml":   "not: a context\n",
+		"acme/payments/notes.txt": "not a context\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(m.ContextsDir(), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := m.ListContexts()
+	if err != nil {
+		t.Fatalf("ListContexts() error = %v", err)
+	}
+	want := []string{"acme/base", "acme/infra/escape", "acme/infra/prod", "acme/payments/dev", "acme/payments/prod", "base", "dev"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("ListContexts() = %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		name        string
+		wantExtends string
+		wantLevel   string
+	}{
+		{name: "acme/payments/prod", wantExtends: "acme/base", wantLevel: "acme"},
+		{name: "acme/payments/dev", wantExtends: "acme/payments/prod", wantLevel: "acme"},
+		// A bare name is the namespace's own context, or else the top-level one
+		{name: "acme/infra/prod", wantExtends: "base", wantLevel: "top"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := m.LoadContext(tt.name)
+			if err != nil {
+				t.Fatalf("LoadContext() error = %v", err)
+			}
+			if ctx.Name != tt.name || ctx.Extends.String() != tt.wantExtends || ctx.Env["LEVEL"] != tt.wantLevel {
+				t.Errorf("LoadContext() name = %q, extends = %q, LEVEL = %q, want %q, %q, %q",
+					ctx.Name, ctx.Extends, ctx.Env["LEVEL"], tt.name, tt.wantExtends, tt.wantLevel)
+			}
+		})
+	}
+
+	if _, err := m.LoadContext("acme/infra/escape"); err == nil {
+		t.Error("LoadContext() extending outside the contexts directory error = nil")
+	}
+
+	// A .yml context is saved in place
+	if err := m.SaveContext(&ContextConfig{Name: "acme/payments/prod", Environment: EnvStaging}); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.ContextsDir(), "acme", "payments", "prod.yaml")); !os.IsNotExist(err) {
+		t.Error("SaveContext() created a .yaml next to the .yml file")
+	}
+	if err := m.DeleteContext("acme/payments/prod"); err != nil {
+		t.Errorf("DeleteContext() of a .yml context error = %v", err)
+	}
+}
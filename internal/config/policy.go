@@ -0,0 +1,252 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyAction is what happens when a context breaks a policy.
+type PolicyAction string
+
+const (
+	// PolicyDeny refuses to activate the context.
+	PolicyDeny PolicyAction = "deny"
+	// PolicyWarn activates the context with a warning.
+	PolicyWarn PolicyAction = "warn"
+)
+
+// PolicyDocument is a set of team policies, from a local policy file or
+// from ctx-cloud.
+type PolicyDocument struct {
+	Policies []Policy `yaml:"policies" json:"policies"`
+}
+
+// Policy is a rule the contexts it matches must follow.
+type Policy struct {
+	Name        string       `yaml:"name" json:"name"`
+	Description string       `yaml:"description,omitempty" json:"description,omitempty"`
+	Action      PolicyAction `yaml:"action,omitempty" json:"action,omitempty"` // deny (default) or warn
+	Match       PolicyMatch  `yaml:"match,omitempty" json:"match,omitempty"`
+	// Require maps field paths, such as vpn.auto_connect, to the value they
+	// must have. An empty value only requires the field to be set.
+	Require map[string]any `yaml:"require,omitempty" json:"require,omitempty"`
+	// Forbid maps field paths to a value they must not have. Paths may
+	// contain * wildcards, such as *.skip_verify. An empty value forbids
+	// setting the field at all.
+	Forbid map[string]any `yaml:"forbid,omitempty" json:"forbid,omitempty"`
+	// RequireReason requires a reason to activate the context.
+	RequireReason bool `yaml:"require_reason,omitempty" json:"require_reason,omitempty"`
+}
+
+// PolicyMatch selects the contexts a policy applies to. Each list that is
+// set must match; an empty match applies to every context.
+type PolicyMatch struct {
+	Environments []string `yaml:"environments,omitempty" json:"environments,omitempty"`
+	Contexts     []string `yaml:"contexts,omitempty" json:"contexts,omitempty"` // Name patterns with * wildcards
+	Tags         []string `yaml:"tags,omitempty" json:"tags,omitempty"`         // Any of the tags
+}
+
+// PolicyViolation is a policy broken by a context.
+type PolicyViolation struct {
+	Policy      string       `json:"policy"`
+	Action      PolicyAction `json:"action"`
+	Description string       `json:"description,omitempty"`
+	// Detail says which field breaks the policy.
+	Detail string `json:"detail"`
+}
+
+func (v PolicyViolation) String() string {
+	if v.Description == "" {
+		return fmt.Sprintf("%s: %s", v.Policy, v.Detail)
+	}
+	return fmt.Sprintf("%s: %s (%s)", v.Policy, v.Description, v.Detail)
+}
+
+// ParsePolicyDocument parses a policy document in YAML or JSON and checks
+// its policies.
+func ParsePolicyDocument(data []byte) (*PolicyDocument, error) {
+	var doc PolicyDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse policy document: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, p := range doc.Policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("policy %d has no name", i+1)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("policy '%s' is defined twice", p.Name)
+		}
+		seen[p.Name] = true
+		switch p.Action {
+		case "":
+			doc.Policies[i].Action = PolicyDeny
+		case PolicyDeny, PolicyWarn:
+		default:
+			return nil, fmt.Errorf("policy '%s' has unknown action '%s' (use deny or warn)", p.Name, p.Action)
+		}
+		if len(p.Require) == 0 && len(p.Forbid) == 0 && !p.RequireReason {
+			return nil, fmt.Errorf("policy '%s' has no rules", p.Name)
+		}
+	}
+	return &doc, nil
+}
+
+// LoadLocalPolicy loads the policy file in the config directory. It returns
+// nil if there is none.
+func (m *Manager) LoadLocalPolicy() (*PolicyDocument, error) {
+	data, err := os.ReadFile(m.LocalPolicyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	doc, err := ParsePolicyDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", PolicyFileName, err)
+	}
+	return doc, nil
+}
+
+// LocalPolicyPath returns the path of the local policy file.
+func (m *Manager) LocalPolicyPath() string {
+	return filepath.Join(m.configDir, PolicyFileName)
+}
+
+// Evaluate checks the fields of a context against the policies that apply to
+// it. Violations are returned in policy order. Reasons are checked by
+// CheckReason, as they're only given on activation.
+func (d *PolicyDocument) Evaluate(ctx *ContextConfig) ([]PolicyViolation, error) {
+	fields, err := FlattenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []PolicyViolation
+	for _, p := range d.Policies {
+		if !p.Match.matches(ctx) {
+			continue
+		}
+		for _, detail := range p.check(fields) {
+			violations = append(violations, PolicyViolation{
+				Policy:      p.Name,
+				Action:      p.Action,
+				Description: p.Description,
+				Detail:      detail,
+			})
+		}
+	}
+	return violations, nil
+}
+
+// CheckReason returns a violation for each policy that applies to a context
+// and requires a reason for activating it, unless one is given.
+func (d *PolicyDocument) CheckReason(ctx *ContextConfig, reason string) []PolicyViolation {
+	if strings.TrimSpace(reason) != "" {
+		return nil
+	}
+	var violations []PolicyViolation
+	for _, p := range d.Policies {
+		if p.RequireReason && p.Match.matches(ctx) {
+			violations = append(violations, PolicyViolation{
+				Policy:      p.Name,
+				Action:      p.Action,
+				Description: p.Description,
+				Detail:      "a reason is required (use --reason)",
+			})
+		}
+	}
+	return violations
+}
+
+// check returns what breaks the policy in the flattened fields of a context.
+func (p Policy) check(fields map[string]string) []string {
+	var details []string
+	for _, path := range slices.Sorted(maps.Keys(p.Require)) {
+		want := policyValue(p.Require[path])
+		got, ok := fields[path]
+		switch {
+		case !ok || got == "":
+			if want == "" {
+				details = append(details, fmt.Sprintf("%s must be set", path))
+			} else {
+				details = append(details, fmt.Sprintf("%s must be %s", path, want))
+			}
+		case want != "" && got != want:
+			details = append(details, fmt.Sprintf("%s is %s, must be %s", path, got, want))
+		}
+	}
+
+	for _, pattern := range slices.Sorted(maps.Keys(p.Forbid)) {
+		forbidden := policyValue(p.Forbid[pattern])
+		re := wildcardRegexp(pattern)
+		for _, path := range slices.Sorted(maps.Keys(fields)) {
+			got := fields[path]
+			if !re.MatchString(path) || got == "" {
+				continue
+			}
+			if forbidden == "" {
+				details = append(details, fmt.Sprintf("%s must not be set", path))
+			} else if got == forbidden {
+				details = append(details, fmt.Sprintf("%s must not be %s", path, forbidden))
+			}
+		}
+	}
+	return details
+}
+
+func (m PolicyMatch) matches(ctx *ContextConfig) bool {
+	if len(m.Environments) > 0 && !slices.ContainsFunc(m.Environments, func(env string) bool {
+		// prod and production are the same environment
+		if Environment(env).IsProd() {
+			return ctx.IsProd()
+		}
+		return env == string(ctx.Environment)
+	}) {
+		return false
+	}
+	if len(m.Contexts) > 0 && !slices.ContainsFunc(m.Contexts, func(pattern string) bool {
+		return wildcardRegexp(pattern).MatchString(ctx.Name)
+	}) {
+		return false
+	}
+	if len(m.Tags) > 0 && !slices.ContainsFunc(m.Tags, func(tag string) bool {
+		return slices.Contains(ctx.Tags, tag)
+	}) {
+		return false
+	}
+	return true
+}
+
+// HasDenials reports whether any of the violations denies activation.
+func HasDenials(violations []PolicyViolation) bool {
+	return slices.ContainsFunc(violations, func(v PolicyViolation) bool {
+		return v.Action == PolicyDeny
+	})
+}
+
+// policyValue returns a policy value as it's compared with a flattened field.
+func policyValue(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// wildcardRegexp compiles a pattern where * matches any run of characters.
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	return regexp.MustCompile("^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$")
+}
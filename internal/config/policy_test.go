@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testPolicy = `
+policies:
+  - name: prod-vpn
+    description: Production contexts must connect the VPN
+    match:
+      environments: [production]
+    require:
+      vpn.auto_connect: true
+  - name: no-skip-verify
+    match:
+      environments: [prod]
+    forbid:
+      "*.skip_verify": true
+  - name: acme-git
+    action: warn
+    match:
+      contexts: ["acme-*"]
+    require:
+      git.user_email:
+  - name: prod-reason
+    match:
+      tags: [prod]
+    require_reason: true
+`
+
+func TestPolicyEvaluate(t *testing.T) {
+	doc, err := ParsePolicyDocument([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicyDocument() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ctx  *ContextConfig
+		want []string
+	}{
+		{
+			name: "compliant production context",
+			ctx: &ContextConfig{
+				Name:        "acme-prod",
+				Environment: EnvProduction,
+				VPN:         &VPNConfig{Type: VPNTypeWireGuard, AutoConnect: true},
+				Git:         &GitConfig{UserEmail: "ops@acme.example"},
+			},
+		},
+		{
+			name: "production context breaking policies",
+			ctx: &ContextConfig{
+				Name:        "acme-prod",
+				Environment: "prod",
+				VPN:         &VPNConfig{Type: VPNTypeWireGuard},
+				Nomad:       &NomadConfig{Address: "https://nomad:4646", SkipVerify: true},
+				Vault:       &VaultConfig{Address: "https://vault:8200", SkipVerify: true},
+			},
+			want: []string{
+				"prod-vpn deny vpn.auto_connect must be true",
+				"no-skip-verify deny nomad.skip_verify must not be true",
+				"no-skip-verify deny vault.skip_verify must not be true",
+				"acme-git warn git.user_email must be set",
+			},
+		},
+		{
+			name: "development context",
+			ctx: &ContextConfig{
+				Name:        "other-dev",
+				Environment: EnvDevelopment,
+				Nomad:       &NomadConfig{Address: "https://nomad:4646", SkipVerify: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := doc.Evaluate(tt.ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			var got []string
+			for _, v := range violations {
+				got = append(got, v.Policy+" "+string(v.Action)+" "+v.Detail)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCheckReason(t *testing.T) {
+	doc, err := ParsePolicyDocument([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &ContextConfig{Name: "acme-prod", Tags: []string{"acme", "prod"}}
+
+	if got := doc.CheckReason(ctx, " "); len(got) != 1 || got[0].Policy != "prod-reason" {
+		t.Errorf("CheckReason() without reason = %+v, want prod-reason", got)
+	}
+	if got := doc.CheckReason(ctx, "incident 42"); len(got) != 0 {
+		t.Errorf("CheckReason() with reason = %+v, want none", got)
+	}
+	if got := doc.CheckReason(&ContextConfig{Name: "acme-dev"}, ""); len(got) != 0 {
+		t.Errorf("CheckReason() for unmatched context = %+v, want none", got)
+	}
+	if !HasDenials(doc.CheckReason(ctx, "")) {
+		t.Error("HasDenials() = false, want the default deny action")
+	}
+}
+
+func TestParsePolicyDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{name: "no name", doc: `{"policies":[{"require_reason":true}]}`},
+		{name: "duplicate", doc: "policies:\n  - {name: a, require_reason: true}\n  - {name: a, require_reason: true}"},
+		{name: "unknown action", doc: "policies:\n  - {name: a, action: block, require_reason: true}"},
+		{name: "no rules", doc: "policies:\n  - {name: a}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePolicyDocument([]byte(tt.doc)); err == nil {
+				t.Error("ParsePolicyDocument() error = nil")
+			}
+		})
+	}
+}
+
+func TestLoadLocalPolicy(t *testing.T) {
+	mgr := NewManagerWithDir(t.TempDir())
+
+	doc, err := mgr.LoadLocalPolicy()
+	if err != nil || doc != nil {
+		t.Fatalf("LoadLocalPolicy() without file = %v, %v, want nil", doc, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(mgr.ConfigDir(), PolicyFileName), []byte(testPolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err = mgr.LoadLocalPolicy()
+	if err != nil {
+		t.Fatalf("LoadLocalPolicy() error = %v", err)
+	}
+	if len(doc.Policies) != 4 {
+		t.Errorf("LoadLocalPolicy() loaded %d policies, want 4", len(doc.Policies))
+	}
+}
@@ -0,0 +1,243 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ReposSubdir is the subdirectory context repositories are cloned into.
+const ReposSubdir = "repos"
+
+// validRepoName matches the names repositories can be added under. A name
+// becomes the namespace of the repository's contexts, so it can't contain /.
+var validRepoName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateRepoName returns an error if name can't be used for a repository.
+func ValidateRepoName(name string) error {
+	if !validRepoName.MatchString(name) {
+		return fmt.Errorf("invalid repository name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// ContextSource tells where a context is loaded from.
+type ContextSource struct {
+	// Path is the file the context is loaded from.
+	Path string
+	// Repo is the repository the context belongs to, empty for local
+	// contexts.
+	Repo string
+	// Override is set when a local file takes the place of the repository's
+	// context.
+	Override bool
+	// Shadowed are the files of the same name that Path is used instead of,
+	// in the order they're looked up.
+	Shadowed []string
+}
+
+// String describes the source for listings: "local", the repository name,
+// or the repository name marked as overridden.
+func (s ContextSource) String() string {
+	switch {
+	case s.Repo == "":
+		return "local"
+	case s.Override:
+		return s.Repo + " (local override)"
+	}
+	return s.Repo
+}
+
+// ReposDir returns the directory context repositories are cloned into.
+func (m *Manager) ReposDir() string {
+	return filepath.Join(m.configDir, ReposSubdir)
+}
+
+// RepoDir returns the directory a context repository is cloned into.
+func (m *Manager) RepoDir(name string) string {
+	return filepath.Join(m.ReposDir(), name)
+}
+
+// RepoContextsDir returns the directory of a repository's context files.
+func (m *Manager) RepoContextsDir(repo *RepoConfig) string {
+	return filepath.Join(m.RepoDir(repo.Name), filepath.FromSlash(repo.Path))
+}
+
+// Repo returns the context repository added under name, or nil.
+func (m *Manager) Repo(name string) *RepoConfig {
+	appConfig := m.GetAppConfig()
+	if appConfig == nil {
+		return nil
+	}
+	for i := range appConfig.Repos {
+		if appConfig.Repos[i].Name == name {
+			return &appConfig.Repos[i]
+		}
+	}
+	return nil
+}
+
+// contextRepo returns the repository a context name belongs to and the name
+// of the context in it, or nil if the name isn't in a repository's namespace.
+func (m *Manager) contextRepo(name string) (*RepoConfig, string) {
+	namespace, rest, ok := strings.Cut(name, "/")
+	if !ok || rest == "" {
+		return nil, ""
+	}
+	repo := m.Repo(namespace)
+	if repo == nil {
+		return nil, ""
+	}
+	return repo, rest
+}
+
+// ContextSource returns where a context is loaded from: the first of the
+// context paths with a file of its name, then its repository. A local file
+// with a repository context's name, such as contexts/acme/prod.yaml for
+// acme/prod, overrides the repository's file.
+//
+// For a context that doesn't exist, the path is where it would be saved, or
+// its file in the repository.
+func (m *Manager) ContextSource(name string) ContextSource {
+	var files []string
+	for _, dir := range m.ContextPaths() {
+		if file := findContextFile(dir, name); file != "" {
+			files = append(files, file)
+		}
+	}
+
+	repo, rest := m.contextRepo(name)
+	if repo == nil {
+		if len(files) == 0 {
+			return ContextSource{Path: filepath.Join(m.ContextsDir(), filepath.FromSlash(name)+".yaml")}
+		}
+		return ContextSource{Path: files[0], Shadowed: files[1:]}
+	}
+
+	repoFile := findContextFile(m.RepoContextsDir(repo), rest)
+	if len(files) == 0 {
+		if repoFile == "" {
+			repoFile = filepath.Join(m.RepoContextsDir(repo), filepath.FromSlash(rest)+".yaml")
+		}
+		return ContextSource{Path: repoFile, Repo: repo.Name}
+	}
+	if repoFile != "" {
+		files = append(files, repoFile)
+	}
+	return ContextSource{Path: files[0], Repo: repo.Name, Override: true, Shadowed: files[1:]}
+}
+
+// resolveExtends returns the name of the context that name extends. A name
+// starting with ./ or ../ is relative to name's namespace, so
+// acme/payments/prod extending ../base gets acme/base. A bare name gets the
+// context of that name in the same namespace if there is one, and the
+// top-level context otherwise, so a namespace such as a repository doesn't
+// need to know where it's added.
+func (m *Manager) resolveExtends(name, extends string) (string, error) {
+	namespace := path.Dir(name)
+	if strings.HasPrefix(extends, "./") || strings.HasPrefix(extends, "../") {
+		resolved := path.Join(namespace, extends)
+		if resolved == "." || resolved == ".." || strings.HasPrefix(resolved, "../") {
+			return "", fmt.Errorf("extends '%s' is outside the contexts directory", extends)
+		}
+		return resolved, nil
+	}
+	if strings.Contains(extends, "/") || namespace == "." {
+		return extends, nil
+	}
+	if sibling := namespace + "/" + extends; m.ContextExists(sibling) {
+		return sibling, nil
+	}
+	return extends, nil
+}
+
+// listRepoContexts returns the names of the contexts in a repository, with
+// the local overrides of contexts it doesn't have.
+func (m *Manager) listRepoContexts(repo *RepoConfig) ([]string, error) {
+	dirs := []string{m.RepoContextsDir(repo)}
+	for _, dir := range m.ContextPaths() {
+		dirs = append(dirs, filepath.Join(dir, repo.Name))
+	}
+
+	var names []string
+	for _, dir := range dirs {
+		found, err := listContextFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range found {
+			names = append(names, repo.Name+"/"+name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// contextFileExts are the extensions of context files, in the order they're
+// looked up.
+var contextFileExts = []string{".yaml", ".yml"}
+
+// findContextFile returns the file of a context in dir, or "" if there's
+// none. The file of acme/payments/prod is acme/payments/prod.yaml, or .yml.
+func findContextFile(dir, name string) string {
+	for _, ext := range contextFileExts {
+		file := filepath.Join(dir, filepath.FromSlash(name)+ext)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// listContextFiles returns the names of the context files in dir and its
+// subdirectories, without their extension. Subdirectories are namespaces:
+// acme/payments/prod.yaml is acme/payments/prod. Hidden directories, such as
+// .git in a checkout, are skipped. A missing dir has none.
+func listContextFiles(dir string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if file == dir && os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if file != dir && strings.HasPrefix(entry.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		ext := filepath.Ext(file)
+		if !slices.Contains(contextFileExts, ext) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, strings.TrimSuffix(file, ext))
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contexts directory: %w", err)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// ContextFileName returns a context's name made safe to use in a file name.
+// The / of a repository's namespace is escaped, so per-context state files
+// don't need a directory for each namespace.
+func ContextFileName(name string) string {
+	return strings.ReplaceAll(name, "/", "%2F")
+}
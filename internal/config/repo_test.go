@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newRepoManager returns a manager with an "acme" repository whose contexts
+// are in its contexts/ directory, as if it had been cloned.
+func newRepoManager(t *testing.T, files map[string]string) *Manager {
+	t.Helper()
+	m := NewManagerWithDir(t.TempDir())
+	if err := m.SaveAppConfig(&AppConfig{Repos: []RepoConfig{{Name: "acme", URL: "git@example.com:acme/contexts.git", Path: "contexts"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := m.RepoContextsDir(m.Repo("acme"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestManager_RepoContexts(t *testing.T) {
+	m := newRepoManager(t, map[string]string{
+		"base.yaml": "name: base\nabstract: true\naws:\n  region: eu-west-1\n",
+		"prod.yaml": "name: prod\nextends: base\nenvironment: production\naws:\n  profile: acme-prod\n",
+	})
+	// A local context with the same name as the repository's base
+	if err := m.SaveContext(&ContextConfig{Name: "base", AWS: &AWSConfig{Region: "us-east-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveContext(&ContextConfig{Name: "mine", Extends: Extends{"acme/prod"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	prod, err := m.LoadContext("acme/prod")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if prod.Name != "acme/prod" || prod.Extends.String() != "acme/base" {
+		t.Errorf("LoadContext() name = %q, extends = %q, want the namespaced names", prod.Name, prod.Extends)
+	}
+	if prod.AWS.Profile != "acme-prod" || prod.AWS.Region != "eu-west-1" {
+		t.Errorf("LoadContext() aws = %+v, want the region of the repository's base", prod.AWS)
+	}
+
+	mine, err := m.LoadContext("mine")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if mine.AWS == nil || mine.AWS.Profile != "acme-prod" {
+		t.Errorf("local context extending acme/prod aws = %+v", mine.AWS)
+	}
+
+	names, err := m.ListContexts()
+	if err != nil {
+		t.Fatalf("ListContexts() error = %v", err)
+	}
+	if want := []string{"base", "mine", "acme/base", "acme/prod"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ListContexts() = %v, want %v", names, want)
+	}
+
+	if !m.ContextExists("acme/prod") || m.ContextExists("acme/dev") || m.ContextExists("other/prod") {
+		t.Error("ContextExists() doesn't match the repository's contexts")
+	}
+	if err := m.DeleteContext("acme/prod"); err == nil {
+		t.Error("DeleteContext() of a repository context error = nil")
+	}
+}
+
+func TestManager_RepoContextOverride(t *testing.T) {
+	m := newRepoManager(t, map[string]string{
+		"prod.yaml": "name: prod\nenvironment: production\naws:\n  profile: acme-prod\n",
+	})
+
+	source := m.ContextSource("acme/prod")
+	if source.Repo != "acme" || source.Override || source.String() != "acme" {
+		t.Errorf("ContextSource() = %+v, want the repository", source)
+	}
+
+	// Saving a repository context makes a local override
+	override := &ContextConfig{Name: "acme/prod", Environment: EnvProduction, AWS: &AWSConfig{Profile: "my-profile"}}
+	if err := m.SaveContext(override); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+	source = m.ContextSource("acme/prod")
+	if !source.Override || source.Path != filepath.Join(m.ContextsDir(), "acme", "prod.yaml") {
+		t.Errorf("ContextSource() = %+v, want the local override", source)
+	}
+
+	loaded, err := m.LoadContext("acme/prod")
+	if err != nil {
+		t.Fatalf("LoadContext() error = %v", err)
+	}
+	if loaded.AWS.Profile != "my-profile" {
+		t.Errorf("LoadContext() profile = %q, want the override's", loaded.AWS.Profile)
+	}
+
+	// The override is listed once, with the repository's contexts
+	names, _ := m.ListContexts()
+	if !reflect.DeepEqual(names, []string{"acme/prod"}) {
+		t.Errorf("ListContexts() = %v", names)
+	}
+
+	if err := m.DeleteContext("acme/prod"); err != nil {
+		t.Fatalf("DeleteContext() of the override error = %v", err)
+	}
+	if m.ContextSource("acme/prod").Override {
+		t.Error("ContextSource() still overridden after deleting the override")
+	}
+}
+
+func TestValidateRepoName(t *testing.T) {
+	for _, name := range []string{"acme", "acme-infra", "team.eu_2"} {
+		if err := ValidateRepoName(name); err != nil {
+			t.Errorf("ValidateRepoName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "acme/eu", "..", "-acme", "a b"} {
+		if err := ValidateRepoName(name); err == nil {
+			t.Errorf("ValidateRepoName(%q) error = nil", name)
+		}
+	}
+}
+
+func TestContextFileName(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+	if got := m.KubeconfigPath("acme/prod"); filepath.Dir(got) != m.StateDir() {
+		t.Errorf("KubeconfigPath() = %q, want a file in the state directory", got)
+	}
+	if got := ContextFileName("dev"); got != "dev" {
+		t.Errorf("ContextFileName() = %q, want names without a namespace unchanged", got)
+	}
+}
@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SecretFinding is a value in a context that looks like a plaintext secret.
+type SecretFinding struct {
+	// Path is the dotted path of the field, such as env.API_TOKEN.
+	Path string
+	// Rule names what the value looks like, such as aws-access-key.
+	Rule string
+	// Redacted is the start of the value, enough to find it.
+	Redacted string
+}
+
+// secretPatterns are the known token formats, checked in order.
+var secretPatterns = []struct {
+	rule    string
+	pattern *regexp.Regexp
+}{
+	{"private-key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )*PRIVATE KEY( BLOCK)?-----`)},
+	{"aws-access-key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github-token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"vault-token", regexp.MustCompile(`\b(hv[sbr]\.[A-Za-z0-9_-]{20,}|s\.[A-Za-z0-9]{24})\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{"url-credentials", regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^/\s:@]+:[^/\s@$][^/\s@]*@`)},
+}
+
+// secretFields are the fields that only ever hold secrets. Their *_env
+// counterparts name an environment variable instead.
+var secretFields = []string{"token", "setup_key", "password", "secret", "api_key", "client_secret", "auth_token"}
+
+// secretEnvWords mark an env: variable as holding a secret.
+var secretEnvWords = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "API_KEY", "APIKEY", "PRIVATE_KEY", "CREDENTIAL"}
+
+const (
+	// minEntropyLength is the shortest word checked for entropy.
+	minEntropyLength = 20
+	// minEntropyRatio is how close to the highest possible entropy a word
+	// must be to look random. Names like eu-west-1-cluster-2024 repeat
+	// characters and stay well below it.
+	minEntropyRatio = 0.85
+)
+
+// entropyWords splits a value into the words checked for entropy.
+var entropyWords = regexp.MustCompile(`[A-Za-z0-9+=_-]+`)
+
+// ScanSecrets returns the values in a context that look like plaintext
+// secrets, sorted by path. It should be given the resolved context, so
+// secrets inherited from a parent are found too. Values under secrets: are
+// references to secret stores and are only checked for known token formats.
+func ScanSecrets(cfg *ContextConfig) ([]SecretFinding, error) {
+	fields, err := FlattenContext(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []SecretFinding
+	for path, value := range fields {
+		if rule := secretRule(path, value); rule != "" {
+			findings = append(findings, SecretFinding{Path: path, Rule: rule, Redacted: redactSecret(value)})
+		}
+	}
+	slices.SortFunc(findings, func(a, b SecretFinding) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return findings, nil
+}
+
+// secretRule returns the rule a field matches, or "" if it looks harmless.
+func secretRule(path, value string) string {
+	for _, p := range secretPatterns {
+		if p.pattern.MatchString(value) {
+			return p.rule
+		}
+	}
+
+	// Variable references resolve at runtime
+	if value == "" || strings.HasPrefix(value, "$") {
+		return ""
+	}
+
+	field := path[strings.LastIndex(path, ".")+1:]
+	if slices.Contains(secretFields, field) {
+		return "secret-field"
+	}
+	if strings.HasPrefix(path, "env.") {
+		upper := strings.ToUpper(field)
+		for _, word := range secretEnvWords {
+			if strings.Contains(upper, word) {
+				return "secret-field"
+			}
+		}
+	}
+
+	if !strings.HasPrefix(path, "secrets.") {
+		for _, word := range entropyWords.FindAllString(value, -1) {
+			if len(word) >= minEntropyLength && looksRandom(word) {
+				return "high-entropy"
+			}
+		}
+	}
+	return ""
+}
+
+// looksRandom reports whether a word has the entropy of a generated secret,
+// compared to the highest a word of its length and alphabet can have. Words
+// of only letters are taken for names, however long.
+func looksRandom(word string) bool {
+	hasDigit := strings.ContainsAny(word, "0123456789")
+	hasLetter := strings.IndexFunc(word, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	}) >= 0
+	if !hasDigit || !hasLetter {
+		return false
+	}
+
+	alphabet := 64.0
+	if strings.Trim(word, "0123456789abcdefABCDEF") == "" {
+		alphabet = 16
+	}
+	maxEntropy := math.Log2(math.Min(float64(len(word)), alphabet))
+	return shannonEntropy(word) >= minEntropyRatio*maxEntropy
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	var entropy float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redactSecret keeps the first characters of a secret, so it can be found
+// without showing it.
+func redactSecret(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", 8)
+}
@@ -0,0 +1,310 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// SessionIDEnvVar holds the per-shell session ID generated by the shell hook.
+	SessionIDEnvVar = "CTX_SESSION_ID"
+	// SessionPIDEnvVar holds the PID of the shell that owns the session.
+	SessionPIDEnvVar = "CTX_SESSION_PID"
+	// SessionsSubdir is the state subdirectory holding one directory per session.
+	SessionsSubdir = "sessions"
+	// SessionFileName is the file inside a session directory describing it.
+	SessionFileName = "session.json"
+	// ResourcesLockFile is the state file locked while a session releases
+	// shared resources.
+	ResourcesLockFile = "resources.lock"
+)
+
+// validSessionID restricts session IDs to characters that are safe in a path.
+// The leading alphanumeric rules out . and .., which would resolve to the
+// sessions or state directory.
+var validSessionID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Session describes a shell that has activated a context.
+type Session struct {
+	ID          string    `json:"id"`
+	PID         int       `json:"pid"`
+	ContextName string    `json:"context_name"`
+	Environment string    `json:"environment,omitempty"`
+	Resources   []string  `json:"resources,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Shared resource keys. A resource stays up as long as at least one live
+// session holds it; only the last session to release it tears it down.
+const (
+	// ResourceHeartbeat is the ctx-cloud presence. Each session runs its own
+	// heartbeat worker; the cloud session ends when the last one leaves.
+	ResourceHeartbeat = "heartbeat"
+)
+
+// VPNResource returns the resource key for a VPN connection. VPN clients are
+// system-wide, so every context using the same VPN type shares the connection.
+func VPNResource(vpn *VPNConfig) string {
+	return "vpn:" + string(vpn.Type)
+}
+
+// TunnelsResource returns the resource key for the SSH tunnels of a context.
+func TunnelsResource(contextName string) string {
+	return "tunnels:" + contextName
+}
+
+// SecretFilesResource returns the resource key for the secret files of a context.
+func SecretFilesResource(contextName string) string {
+	return "secret-files:" + contextName
+}
+
+// SessionResources returns the shared resources a session using ctx holds.
+func SessionResources(ctx *ContextConfig) []string {
+	resources := []string{ResourceHeartbeat}
+	if ctx.VPN != nil {
+		resources = append(resources, VPNResource(ctx.VPN))
+	}
+	if len(ctx.Tunnels) > 0 {
+		resources = append(resources, TunnelsResource(ctx.Name))
+	}
+	if ctx.Secrets != nil && len(ctx.Secrets.Files) > 0 {
+		resources = append(resources, SecretFilesResource(ctx.Name))
+	}
+	return resources
+}
+
+// IsAlive reports whether the shell owning the session is still running.
+func (s *Session) IsAlive() bool {
+	if s.PID <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(s.PID)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// sessionFromEnv returns the session ID and shell PID advertised by the shell hook.
+// An ID that isn't safe to use as a path component is ignored.
+func sessionFromEnv() (string, int) {
+	id := os.Getenv(SessionIDEnvVar)
+	if !validSessionID.MatchString(id) {
+		return "", 0
+	}
+	pid, err := strconv.Atoi(os.Getenv(SessionPIDEnvVar))
+	if err != nil {
+		pid = os.Getppid()
+	}
+	return id, pid
+}
+
+// SessionID returns the session this manager reads and writes current state for.
+// An empty ID means the legacy global state files are used.
+func (m *Manager) SessionID() string {
+	return m.sessionID
+}
+
+// SessionPID returns the PID of the shell owning the manager's session, or 0
+// if there is no session.
+func (m *Manager) SessionPID() int {
+	return m.sessionPID
+}
+
+// SetSessionID scopes the current context state to the given session.
+func (m *Manager) SetSessionID(id string, pid int) error {
+	if id != "" && !validSessionID.MatchString(id) {
+		return fmt.Errorf("invalid session ID: %q", id)
+	}
+	m.sessionID = id
+	m.sessionPID = pid
+	return nil
+}
+
+// SessionsDir returns the directory holding per-session state.
+func (m *Manager) SessionsDir() string {
+	return filepath.Join(m.stateDir, SessionsSubdir)
+}
+
+// sessionStateDir returns the directory holding current.name and current.env
+// for the manager's session, or the state directory when there is no session.
+func (m *Manager) sessionStateDir() string {
+	return m.StateStore().Path(m.sessionStateFile(""))
+}
+
+// sessionStateFile returns the state store name of a per-session file.
+func (m *Manager) sessionStateFile(name string) string {
+	if m.sessionID == "" {
+		return name
+	}
+	return filepath.Join(SessionsSubdir, m.sessionID, name)
+}
+
+// CurrentEnvPath returns the env file the shell sources for the current session.
+func (m *Manager) CurrentEnvPath() string {
+	return m.StateStore().Path(m.sessionStateFile(CurrentEnvFile))
+}
+
+// saveSession records the context the manager's session is using.
+func (m *Manager) saveSession(ctx *ContextConfig) error {
+	if m.sessionID == "" {
+		return nil
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:          m.sessionID,
+		PID:         m.sessionPID,
+		ContextName: ctx.Name,
+		Environment: string(ctx.Environment),
+		Resources:   SessionResources(ctx),
+		StartedAt:   now,
+		UpdatedAt:   now,
+	}
+	lock, err := m.StateStore().Lock(m.sessionStateFile(SessionFileName))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	var existing Session
+	if err := lock.LoadJSON(&existing); err == nil {
+		session.StartedAt = existing.StartedAt
+	}
+
+	if err := lock.SaveJSON(session, 0o644); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+// loadSession reads the session file for the given session ID.
+func (m *Manager) loadSession(id string) (*Session, error) {
+	var session Session
+	if err := m.StateStore().LoadJSON(filepath.Join(SessionsSubdir, id, SessionFileName), &session); err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+	// The directory is what gets removed, whatever the file says
+	session.ID = id
+	return &session, nil
+}
+
+// ListSessions returns every recorded session, oldest first.
+// Sessions whose files can't be read are skipped.
+func (m *Manager) ListSessions() ([]*Session, error) {
+	entries, err := os.ReadDir(m.SessionsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if !entry.IsDir() || !validSessionID.MatchString(entry.Name()) {
+			continue
+		}
+		session, err := m.loadSession(entry.Name())
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartedAt.Before(sessions[j].StartedAt)
+	})
+	return sessions, nil
+}
+
+// ReapStaleSessions removes the state of sessions whose shell has exited
+// without deactivating, so they no longer hold shared resources.
+func (m *Manager) ReapStaleSessions() ([]*Session, error) {
+	sessions, err := m.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	var reaped []*Session
+	for _, session := range sessions {
+		if session.IsAlive() {
+			continue
+		}
+		// Losing the tracked time is better than keeping the session forever
+		m.closeStaleTracking(session.ID)
+		if err := os.RemoveAll(filepath.Join(m.SessionsDir(), session.ID)); err != nil {
+			return reaped, fmt.Errorf("failed to remove stale session %s: %w", session.ID, err)
+		}
+		reaped = append(reaped, session)
+	}
+	return reaped, nil
+}
+
+// LockResources takes the lock sessions hold while they count the other
+// holders of a shared resource and release it, so that two shells leaving
+// at once can't both keep it or both tear it down. The caller must call
+// Unlock.
+func (m *Manager) LockResources() (*StateLock, error) {
+	return m.StateStore().Lock(ResourcesLockFile)
+}
+
+// ReleaseResource removes a shared resource from the manager's session, so
+// that other sessions no longer count it as a holder.
+func (m *Manager) ReleaseResource(resource string) error {
+	if m.sessionID == "" {
+		return nil
+	}
+
+	lock, err := m.StateStore().Lock(m.sessionStateFile(SessionFileName))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	var session Session
+	if err := lock.LoadJSON(&session); err != nil {
+		if os.IsNotExist(err) || errors.Is(err, ErrCorruptState) {
+			return nil
+		}
+		return fmt.Errorf("failed to read session file: %w", err)
+	}
+	session.Resources = slices.DeleteFunc(session.Resources, func(r string) bool { return r == resource })
+	session.UpdatedAt = time.Now()
+	if err := lock.SaveJSON(&session, 0o644); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+// OtherResourceHolders returns the live sessions, other than this one, that
+// hold the given shared resource.
+func (m *Manager) OtherResourceHolders(resource string) ([]*Session, error) {
+	sessions, err := m.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	var holders []*Session
+	for _, session := range sessions {
+		if session.ID == m.sessionID || !session.IsAlive() {
+			continue
+		}
+		if slices.Contains(session.Resources, resource) {
+			holders = append(holders, session)
+		}
+	}
+	return holders, nil
+}
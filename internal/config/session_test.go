@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestManager_SessionsAreIndependent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{"dev", "prod"} {
+		if err := NewManagerWithDir(tmpDir).SaveContext(&ContextConfig{Name: name, Environment: EnvDevelopment}); err != nil {
+			t.Fatalf("SaveContext() error = %v", err)
+		}
+	}
+
+	shellA := NewManagerWithDir(tmpDir)
+	if err := shellA.SetSessionID("100-a", os.Getpid()); err != nil {
+		t.Fatalf("SetSessionID() error = %v", err)
+	}
+	shellB := NewManagerWithDir(tmpDir)
+	if err := shellB.SetSessionID("200-b", os.Getpid()); err != nil {
+		t.Fatalf("SetSessionID() error = %v", err)
+	}
+
+	if err := shellA.SetCurrentContext("dev"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+	if err := shellB.SetCurrentContext("prod"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+
+	if name, _ := shellA.GetCurrentContextName(); name != "dev" {
+		t.Errorf("shell A context = %q, want dev", name)
+	}
+	if name, _ := shellB.GetCurrentContextName(); name != "prod" {
+		t.Errorf("shell B context = %q, want prod", name)
+	}
+
+	// Env files are per session
+	if shellA.CurrentEnvPath() == shellB.CurrentEnvPath() {
+		t.Error("sessions share the same env file")
+	}
+	want := filepath.Join(tmpDir, StateSubdir, SessionsSubdir, "100-a", CurrentEnvFile)
+	if got := shellA.CurrentEnvPath(); got != want {
+		t.Errorf("CurrentEnvPath() = %q, want %q", got, want)
+	}
+
+	// Deactivating one shell leaves the other alone
+	if err := shellA.ClearCurrentContext(); err != nil {
+		t.Fatalf("ClearCurrentContext() error = %v", err)
+	}
+	if name, _ := shellA.GetCurrentContextName(); name != "" {
+		t.Errorf("shell A context after clear = %q, want empty", name)
+	}
+	if name, _ := shellB.GetCurrentContextName(); name != "prod" {
+		t.Errorf("shell B context after A cleared = %q, want prod", name)
+	}
+
+	// Sessions never touch the legacy global state
+	if _, err := os.Stat(filepath.Join(tmpDir, StateSubdir, CurrentNameFile)); !os.IsNotExist(err) {
+		t.Error("session switch wrote the global current.name file")
+	}
+}
+
+func TestManager_ListSessions(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManagerWithDir(tmpDir)
+
+	if err := m.SaveContext(&ContextConfig{Name: "dev", Environment: EnvDevelopment}); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+
+	sessions, err := m.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("ListSessions() = %d sessions, want 0", len(sessions))
+	}
+
+	m.SetSessionID("300-c", os.Getpid())
+	if err := m.SetCurrentContext("dev"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+
+	sessions, err = m.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("ListSessions() = %d sessions, want 1", len(sessions))
+	}
+
+	s := sessions[0]
+	if s.ID != "300-c" || s.ContextName != "dev" || s.Environment != string(EnvDevelopment) {
+		t.Errorf("ListSessions() = %+v, unexpected session", s)
+	}
+	if !s.IsAlive() {
+		t.Error("IsAlive() = false for the test process")
+	}
+}
+
+func TestManager_SetSessionID_Invalid(t *testing.T) {
+	m := NewManagerWithDir(t.TempDir())
+
+	for _, id := range []string{"../escape", "a/b", "with space", ".", "..", ".hidden"} {
+		if err := m.SetSessionID(id, 1); err == nil {
+			t.Errorf("SetSessionID(%q) expected error", id)
+		}
+	}
+}
+
+// deadPID returns the PID of a process that has already exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run helper process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestManager_OtherResourceHolders(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := &ContextConfig{
+		Name:        "shared",
+		Environment: EnvDevelopment,
+		VPN:         &VPNConfig{Type: VPNTypeWireGuard, Interface: "wg0"},
+		Tunnels:     []TunnelConfig{{Name: "db", RemoteHost: "db", RemotePort: 5432, LocalPort: 5432}},
+	}
+	if err := NewManagerWithDir(tmpDir).SaveContext(ctx); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+
+	shellA := NewManagerWithDir(tmpDir)
+	shellA.SetSessionID("1-a", os.Getpid())
+	shellB := NewManagerWithDir(tmpDir)
+	shellB.SetSessionID("2-b", os.Getpid())
+
+	for _, m := range []*Manager{shellA, shellB} {
+		if err := m.SetCurrentContext("shared"); err != nil {
+			t.Fatalf("SetCurrentContext() error = %v", err)
+		}
+	}
+
+	for _, resource := range []string{VPNResource(ctx.VPN), TunnelsResource("shared"), ResourceHeartbeat} {
+		holders, err := shellA.OtherResourceHolders(resource)
+		if err != nil {
+			t.Fatalf("OtherResourceHolders() error = %v", err)
+		}
+		if len(holders) != 1 || holders[0].ID != "2-b" {
+			t.Errorf("OtherResourceHolders(%q) = %v, want only session 2-b", resource, holders)
+		}
+	}
+
+	// Once B leaves, A is the last holder and may tear everything down
+	if err := shellB.ClearCurrentContext(); err != nil {
+		t.Fatalf("ClearCurrentContext() error = %v", err)
+	}
+	holders, _ := shellA.OtherResourceHolders(TunnelsResource("shared"))
+	if len(holders) != 0 {
+		t.Errorf("OtherResourceHolders() after B left = %d holders, want 0", len(holders))
+	}
+}
+
+func TestManager_ReapStaleSessions(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	ctx := &ContextConfig{Name: "dev", Environment: EnvDevelopment, VPN: &VPNConfig{Type: VPNTypeTailscale}}
+	if err := NewManagerWithDir(tmpDir).SaveContext(ctx); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+
+	live := NewManagerWithDir(tmpDir)
+	live.SetSessionID("1-live", os.Getpid())
+	dead := NewManagerWithDir(tmpDir)
+	dead.SetSessionID("2-dead", deadPID(t))
+
+	for _, m := range []*Manager{live, dead} {
+		if err := m.SetCurrentContext("dev"); err != nil {
+			t.Fatalf("SetCurrentContext() error = %v", err)
+		}
+	}
+
+	// A session file naming another directory only removes its own
+	forged := fmt.Sprintf(`{"id": "..", "pid": %d}`, deadPID(t))
+	if err := live.StateStore().WriteFile(filepath.Join(SessionsSubdir, "3-forged", SessionFileName), []byte(forged), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dead shell never counts as a holder, even before it is reaped
+	holders, _ := live.OtherResourceHolders(VPNResource(ctx.VPN))
+	if len(holders) != 0 {
+		t.Errorf("OtherResourceHolders() counted a dead session")
+	}
+
+	reaped, err := live.ReapStaleSessions()
+	if err != nil {
+		t.Fatalf("ReapStaleSessions() error = %v", err)
+	}
+	var reapedIDs []string
+	for _, session := range reaped {
+		reapedIDs = append(reapedIDs, session.ID)
+	}
+	slices.Sort(reapedIDs)
+	if !slices.Equal(reapedIDs, []string{"2-dead", "3-forged"}) {
+		t.Errorf("ReapStaleSessions() = %v, want 2-dead and 3-forged", reapedIDs)
+	}
+
+	sessions, _ := live.ListSessions()
+	if len(sessions) != 1 || sessions[0].ID != "1-live" {
+		t.Errorf("ListSessions() after reap = %v, want only 1-live", sessions)
+	}
+}
@@ -0,0 +1,241 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ErrCorruptState is returned when a state file exists but can't be parsed.
+// The damaged file is moved aside (with a .corrupt suffix) so the next write
+// starts from a clean slate.
+var ErrCorruptState = errors.New("corrupt state file")
+
+// StateStore reads and writes files under the state directory.
+//
+// Several shells may run ctx at the same time, so every access takes an
+// advisory lock on a sibling .lock file, and writes go to a temp file that is
+// renamed into place. Readers never see a half-written file.
+type StateStore struct {
+	dir string
+}
+
+// NewStateStore creates a state store rooted at dir.
+func NewStateStore(dir string) *StateStore {
+	return &StateStore{dir: dir}
+}
+
+// StateStore returns the state store for the manager's state directory.
+func (m *Manager) StateStore() *StateStore {
+	return NewStateStore(m.stateDir)
+}
+
+// Path returns the absolute path of a state file given its name relative to
+// the store root.
+func (s *StateStore) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// StateLock is an exclusive lock on a single state file. It lets callers
+// read, modify and write the file without another process slipping in.
+type StateLock struct {
+	path string
+	file *os.File
+}
+
+// Lock takes an exclusive lock on the named state file, blocking until it is
+// available. The caller must call Unlock.
+func (s *StateStore) Lock(name string) (*StateLock, error) {
+	return s.lock(name, syscall.LOCK_EX)
+}
+
+func (s *StateStore) lock(name string, how int) (*StateLock, error) {
+	path := s.Path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), how); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+
+	return &StateLock{path: path, file: file}, nil
+}
+
+// Unlock releases the lock.
+func (l *StateLock) Unlock() error {
+	defer l.file.Close()
+	return syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+}
+
+// ReadFile reads the locked file.
+func (l *StateLock) ReadFile() ([]byte, error) {
+	return os.ReadFile(l.path)
+}
+
+// WriteFile atomically replaces the locked file's contents.
+func (l *StateLock) WriteFile(data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), "."+filepath.Base(l.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(l.path), err)
+	}
+	return nil
+}
+
+// AppendFile appends data to the locked file, creating it if needed.
+func (l *StateLock) AppendFile(data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, perm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(l.path), err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to append to %s: %w", filepath.Base(l.path), err)
+	}
+	return file.Close()
+}
+
+// LoadJSON decodes the locked file into v. A file that exists but isn't valid
+// JSON is moved aside and ErrCorruptState is returned.
+func (l *StateLock) LoadJSON(v any) error {
+	data, err := l.ReadFile()
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 || json.Unmarshal(data, v) != nil {
+		os.Rename(l.path, l.path+".corrupt")
+		return fmt.Errorf("%w: %s", ErrCorruptState, l.path)
+	}
+	return nil
+}
+
+// SaveJSON encodes v and atomically writes it to the locked file.
+func (l *StateLock) SaveJSON(v any, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return l.WriteFile(data, perm)
+}
+
+// Remove deletes the locked file. A missing file is not an error.
+func (l *StateLock) Remove() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ReadFile reads a state file under a shared lock.
+func (s *StateStore) ReadFile(name string) ([]byte, error) {
+	// Don't create lock files (or directories) just to find nothing
+	if _, err := os.Stat(s.Path(name)); err != nil {
+		return nil, err
+	}
+
+	l, err := s.lock(name, syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+	return l.ReadFile()
+}
+
+// WriteFile atomically writes a state file under an exclusive lock.
+func (s *StateStore) WriteFile(name string, data []byte, perm os.FileMode) error {
+	l, err := s.Lock(name)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return l.WriteFile(data, perm)
+}
+
+// AppendFile appends data to a state file under an exclusive lock, creating
+// it if needed. It is meant for logs, where rewriting the file on every
+// entry would be wasteful.
+func (s *StateStore) AppendFile(name string, data []byte, perm os.FileMode) error {
+	l, err := s.Lock(name)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return l.AppendFile(data, perm)
+}
+
+// LoadJSON decodes a state file into v while holding its lock.
+func (s *StateStore) LoadJSON(name string, v any) error {
+	if _, err := os.Stat(s.Path(name)); err != nil {
+		return err
+	}
+
+	// Corrupt files are moved aside, which needs the exclusive lock
+	l, err := s.Lock(name)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return l.LoadJSON(v)
+}
+
+// SaveJSON atomically writes v as JSON under an exclusive lock.
+func (s *StateStore) SaveJSON(name string, v any, perm os.FileMode) error {
+	l, err := s.Lock(name)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return l.SaveJSON(v, perm)
+}
+
+// Remove deletes a state file under an exclusive lock.
+func (s *StateStore) Remove(name string) error {
+	if _, err := os.Stat(s.Path(name)); os.IsNotExist(err) {
+		return nil
+	}
+
+	l, err := s.Lock(name)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return l.Remove()
+}
@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStateStore_WriteAndRead(t *testing.T) {
+	store := NewStateStore(t.TempDir())
+
+	if err := store.WriteFile("nested/dir/file.txt", []byte("hello"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	data, err := store.ReadFile("nested/dir/file.txt")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("ReadFile() = %q, want %q", data, "hello")
+	}
+
+	info, err := os.Stat(store.Path("nested/dir/file.txt"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %o, want 600", info.Mode().Perm())
+	}
+
+	// No temp files are left behind
+	entries, _ := os.ReadDir(store.Path("nested/dir"))
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file left behind: %s", e.Name())
+		}
+	}
+}
+
+func TestStateStore_ReadMissing(t *testing.T) {
+	store := NewStateStore(t.TempDir())
+
+	if _, err := store.ReadFile("missing"); !os.IsNotExist(err) {
+		t.Errorf("ReadFile() error = %v, want not-exist", err)
+	}
+	var v map[string]string
+	if err := store.LoadJSON("missing.json", &v); !os.IsNotExist(err) {
+		t.Errorf("LoadJSON() error = %v, want not-exist", err)
+	}
+	if err := store.Remove("missing"); err != nil {
+		t.Errorf("Remove() error = %v, want nil", err)
+	}
+}
+
+func TestStateStore_DetectsCorruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"truncated", `{"pid": 12`},
+		{"empty", ""},
+		{"garbage", "\x00\x00\x00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStateStore(t.TempDir())
+			path := store.Path("state.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var v map[string]any
+			err := store.LoadJSON("state.json", &v)
+			if !errors.Is(err, ErrCorruptState) {
+				t.Fatalf("LoadJSON() error = %v, want ErrCorruptState", err)
+			}
+
+			// The damaged file is moved aside so the next write starts clean
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Error("corrupt file was not moved aside")
+			}
+			if _, err := os.Stat(path + ".corrupt"); err != nil {
+				t.Errorf("corrupt copy missing: %v", err)
+			}
+		})
+	}
+}
+
+func TestStateStore_ConcurrentUpdates(t *testing.T) {
+	store := NewStateStore(t.TempDir())
+
+	type counter struct {
+		Count int `json:"count"`
+	}
+
+	const workers = 50
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := store.Lock("counter.json")
+			if err != nil {
+				t.Errorf("Lock() error = %v", err)
+				return
+			}
+			defer lock.Unlock()
+
+			var c counter
+			if err := lock.LoadJSON(&c); err != nil && !os.IsNotExist(err) {
+				t.Errorf("LoadJSON() error = %v", err)
+				return
+			}
+			c.Count++
+			if err := lock.SaveJSON(&c, 0o644); err != nil {
+				t.Errorf("SaveJSON() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var c counter
+	if err := store.LoadJSON("counter.json", &c); err != nil {
+		t.Fatalf("LoadJSON() error = %v", err)
+	}
+	if c.Count != workers {
+		t.Errorf("count = %d, want %d (lost updates)", c.Count, workers)
+	}
+}
+
+func TestManager_ConcurrentActivations(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	const shells = 20
+	for i := range shells {
+		ctx := &ContextConfig{
+			Name:        fmt.Sprintf("ctx-%d", i),
+			Environment: EnvDevelopment,
+			Env:         map[string]string{"SHELL_INDEX": fmt.Sprint(i), "PADDING": strings.Repeat("x", 4096)},
+		}
+		if err := NewManagerWithDir(tmpDir).SaveContext(ctx); err != nil {
+			t.Fatalf("SaveContext() error = %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range shells {
+		wg.Add(2)
+
+		// A hooked shell with its own session
+		go func() {
+			defer wg.Done()
+			m := NewManagerWithDir(tmpDir)
+			m.SetSessionID(fmt.Sprintf("%d-s", i), os.Getpid())
+			activate(t, m, fmt.Sprintf("ctx-%d", i))
+		}()
+
+		// A shell without the hook, racing on the global state files
+		go func() {
+			defer wg.Done()
+			activate(t, NewManagerWithDir(tmpDir), fmt.Sprintf("ctx-%d", i))
+		}()
+	}
+	wg.Wait()
+
+	for i := range shells {
+		m := NewManagerWithDir(tmpDir)
+		m.SetSessionID(fmt.Sprintf("%d-s", i), os.Getpid())
+
+		name, err := m.GetCurrentContextName()
+		if err != nil {
+			t.Fatalf("GetCurrentContextName() error = %v", err)
+		}
+		if want := fmt.Sprintf("ctx-%d", i); name != want {
+			t.Errorf("session %d context = %q, want %q", i, name, want)
+		}
+
+		content, err := os.ReadFile(m.CurrentEnvPath())
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if want := fmt.Sprintf("export SHELL_INDEX=\"%d\"\n", i); !strings.Contains(string(content), want) {
+			t.Errorf("session %d env file is missing %q", i, want)
+		}
+	}
+
+	sessions, err := NewManagerWithDir(tmpDir).ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != shells {
+		t.Errorf("ListSessions() = %d sessions, want %d", len(sessions), shells)
+	}
+
+	// The global files hold exactly one complete activation, never a mix
+	global := NewManagerWithDir(tmpDir)
+	name, _ := global.GetCurrentContextName()
+	content, err := os.ReadFile(filepath.Join(tmpDir, StateSubdir, CurrentEnvFile))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if strings.Count(string(content), "export SHELL_INDEX=") != 1 {
+		t.Errorf("global env file is interleaved:\n%s", content)
+	}
+	if !strings.Contains(string(content), "export CTX_CURRENT=") || !strings.HasPrefix(name, "ctx-") {
+		t.Errorf("global state is incomplete: name=%q", name)
+	}
+}
+
+func activate(t *testing.T, m *Manager, name string) {
+	t.Helper()
+
+	if err := m.SetCurrentContext(name); err != nil {
+		t.Errorf("SetCurrentContext(%s) error = %v", name, err)
+		return
+	}
+	ctx, err := m.LoadContext(name)
+	if err != nil {
+		t.Errorf("LoadContext(%s) error = %v", name, err)
+		return
+	}
+	if err := m.WriteEnvFile(ctx); err != nil {
+		t.Errorf("WriteEnvFile(%s) error = %v", name, err)
+	}
+}
@@ -0,0 +1,256 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// TimeLogFile is the state file holding tracked time, one JSON entry per line.
+	TimeLogFile = "timelog.jsonl"
+	// TrackingFileName is the per-session file holding the interval being tracked.
+	TrackingFileName = "tracking.json"
+	// ActivityFileName is the per-session file the shell hook touches at every
+	// prompt. Its modification time is when the shell was last used.
+	ActivityFileName = "activity"
+	// DefaultIdleTimeout is how long a prompt may sit idle before the time
+	// stops counting.
+	DefaultIdleTimeout = 15 * time.Minute
+)
+
+// TimeEntry is an interval a session spent in a context.
+type TimeEntry struct {
+	Context     string    `json:"context"`
+	Environment string    `json:"environment,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Session     string    `json:"session,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end,omitzero"`
+}
+
+// Duration returns the length of the interval.
+func (e TimeEntry) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// IdleTimeout returns how long a prompt may sit idle before tracked time
+// stops counting, or 0 if time tracking is disabled.
+func (m *Manager) IdleTimeout() time.Duration {
+	appConfig := m.GetAppConfig()
+	if appConfig == nil || appConfig.TimeTracking == nil {
+		return DefaultIdleTimeout
+	}
+	if appConfig.TimeTracking.Disabled {
+		return 0
+	}
+	if appConfig.TimeTracking.IdleTimeout > 0 {
+		return time.Duration(appConfig.TimeTracking.IdleTimeout) * time.Minute
+	}
+	return DefaultIdleTimeout
+}
+
+// StartTracking starts tracking the session's time in ctx. The interval of
+// the context the session was in before, if any, is closed.
+func (m *Manager) StartTracking(ctx *ContextConfig) error {
+	if m.IdleTimeout() == 0 {
+		return nil
+	}
+
+	lock, err := m.StateStore().Lock(m.sessionStateFile(TrackingFileName))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	now := time.Now()
+	var open TimeEntry
+	if err := lock.LoadJSON(&open); err == nil {
+		// Activating the same context again keeps the interval going
+		if open.Context == ctx.Name {
+			return nil
+		}
+		open.End = now
+		if err := m.appendTimeEntry(open); err != nil {
+			return err
+		}
+	}
+
+	open = TimeEntry{
+		Context:     ctx.Name,
+		Environment: string(ctx.Environment),
+		Tags:        ctx.Tags,
+		Session:     m.sessionID,
+		Start:       now,
+	}
+	if err := lock.SaveJSON(open, 0o600); err != nil {
+		return fmt.Errorf("failed to write tracking state: %w", err)
+	}
+	return nil
+}
+
+// StopTracking closes the session's open interval, if any.
+func (m *Manager) StopTracking() error {
+	lock, err := m.StateStore().Lock(m.sessionStateFile(TrackingFileName))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	var open TimeEntry
+	if err := lock.LoadJSON(&open); err != nil {
+		if os.IsNotExist(err) || errors.Is(err, ErrCorruptState) {
+			return nil
+		}
+		return fmt.Errorf("failed to read tracking state: %w", err)
+	}
+
+	open.End = time.Now()
+	if err := m.appendTimeEntry(open); err != nil {
+		return err
+	}
+	return lock.Remove()
+}
+
+// MarkIdle removes the time between from and to from the session's open
+// interval. The shell hook calls it when a command follows a prompt that sat
+// idle for longer than the idle timeout.
+func (m *Manager) MarkIdle(from, to time.Time) error {
+	if !to.After(from) {
+		return nil
+	}
+
+	lock, err := m.StateStore().Lock(m.sessionStateFile(TrackingFileName))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	var open TimeEntry
+	if err := lock.LoadJSON(&open); err != nil {
+		if os.IsNotExist(err) || errors.Is(err, ErrCorruptState) {
+			return nil
+		}
+		return fmt.Errorf("failed to read tracking state: %w", err)
+	}
+	if !to.After(open.Start) {
+		return nil
+	}
+
+	if from.After(open.Start) {
+		closed := open
+		closed.End = from
+		if err := m.appendTimeEntry(closed); err != nil {
+			return err
+		}
+	}
+
+	open.Start = to
+	if err := lock.SaveJSON(open, 0o600); err != nil {
+		return fmt.Errorf("failed to write tracking state: %w", err)
+	}
+	return nil
+}
+
+// closeStaleTracking closes the open interval of a session whose shell
+// exited without deactivating. The interval ends when the shell was last
+// used, as recorded by the shell hook.
+func (m *Manager) closeStaleTracking(sessionID string) error {
+	dir := filepath.Join(SessionsSubdir, sessionID)
+
+	var open TimeEntry
+	if err := m.StateStore().LoadJSON(filepath.Join(dir, TrackingFileName), &open); err != nil {
+		if os.IsNotExist(err) || errors.Is(err, ErrCorruptState) {
+			return nil
+		}
+		return fmt.Errorf("failed to read tracking state: %w", err)
+	}
+
+	info, err := os.Stat(m.StateStore().Path(filepath.Join(dir, ActivityFileName)))
+	if err != nil || !info.ModTime().After(open.Start) {
+		// Nothing shows the shell was used after activating
+		return nil
+	}
+	open.End = info.ModTime()
+	return m.appendTimeEntry(open)
+}
+
+// appendTimeEntry adds a closed interval to the time log.
+func (m *Manager) appendTimeEntry(entry TimeEntry) error {
+	if entry.Duration() <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal time entry: %w", err)
+	}
+	if err := m.StateStore().AppendFile(TimeLogFile, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write time log: %w", err)
+	}
+	return nil
+}
+
+// LoadTimeEntries returns the tracked intervals overlapping [from, to),
+// clipped to that range. Intervals still open in live sessions count up to
+// now.
+func (m *Manager) LoadTimeEntries(from, to time.Time) ([]TimeEntry, error) {
+	var entries []TimeEntry
+
+	data, err := m.StateStore().ReadFile(TimeLogFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read time log: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry TimeEntry
+		// Skip lines that were cut short
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	now := time.Now()
+	sessions, err := m.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if !session.IsAlive() {
+			continue
+		}
+		var open TimeEntry
+		if m.StateStore().LoadJSON(filepath.Join(SessionsSubdir, session.ID, TrackingFileName), &open) == nil {
+			open.End = now
+			entries = append(entries, open)
+		}
+	}
+	// ctx use without the shell hook tracks outside any session
+	var open TimeEntry
+	if m.StateStore().LoadJSON(TrackingFileName, &open) == nil {
+		open.End = now
+		entries = append(entries, open)
+	}
+
+	var clipped []TimeEntry
+	for _, entry := range entries {
+		if entry.Start.Before(from) {
+			entry.Start = from
+		}
+		if entry.End.After(to) {
+			entry.End = to
+		}
+		if entry.Duration() > 0 {
+			clipped = append(clipped, entry)
+		}
+	}
+	return clipped, nil
+}
@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTrackingManager(t *testing.T, dir, sessionID string, pid int) *Manager {
+	t.Helper()
+	m := NewManagerWithDir(dir)
+	if err := m.SetSessionID(sessionID, pid); err != nil {
+		t.Fatalf("SetSessionID() error = %v", err)
+	}
+	return m
+}
+
+// readTimeLog returns the tracked intervals. The managers in these tests
+// never set a context, so their sessions' open intervals aren't included.
+func readTimeLog(t *testing.T, m *Manager) []TimeEntry {
+	t.Helper()
+	entries, err := m.LoadTimeEntries(time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("LoadTimeEntries() error = %v", err)
+	}
+	return entries
+}
+
+func TestManager_StartStopTracking(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := newTrackingManager(t, tmpDir, "1-a", os.Getpid())
+
+	dev := &ContextConfig{Name: "dev", Environment: EnvDevelopment, Tags: []string{"client:acme"}}
+	prod := &ContextConfig{Name: "prod", Environment: EnvProduction}
+
+	if err := m.StartTracking(dev); err != nil {
+		t.Fatalf("StartTracking() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	// Activating the same context again keeps the interval going
+	if err := m.StartTracking(dev); err != nil {
+		t.Fatalf("StartTracking() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := m.StartTracking(prod); err != nil {
+		t.Fatalf("StartTracking() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := m.StopTracking(); err != nil {
+		t.Fatalf("StopTracking() error = %v", err)
+	}
+
+	entries := readTimeLog(t, m)
+	if len(entries) != 2 {
+		t.Fatalf("time log = %+v, want 2 entries", entries)
+	}
+	if entries[0].Context != "dev" || entries[0].Session != "1-a" || entries[0].Tags[0] != "client:acme" {
+		t.Errorf("entries[0] = %+v", entries[0])
+	}
+	if entries[0].Duration() < 20*time.Millisecond {
+		t.Errorf("dev tracked for %v, want at least 20ms", entries[0].Duration())
+	}
+	if entries[1].Context != "prod" || entries[1].Environment != string(EnvProduction) {
+		t.Errorf("entries[1] = %+v", entries[1])
+	}
+
+	// Stopping again is a no-op
+	if err := m.StopTracking(); err != nil {
+		t.Fatalf("StopTracking() error = %v", err)
+	}
+	if entries := readTimeLog(t, m); len(entries) != 2 {
+		t.Errorf("time log has %d entries after a second stop, want 2", len(entries))
+	}
+}
+
+func TestManager_MarkIdle(t *testing.T) {
+	m := newTrackingManager(t, t.TempDir(), "1-a", os.Getpid())
+	if err := m.StartTracking(&ContextConfig{Name: "dev"}); err != nil {
+		t.Fatalf("StartTracking() error = %v", err)
+	}
+
+	// Idle before the interval started doesn't count
+	past := time.Now().Add(-time.Hour)
+	if err := m.MarkIdle(past, past.Add(time.Minute)); err != nil {
+		t.Fatalf("MarkIdle() error = %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	idleFrom := time.Now()
+	idleTo := idleFrom.Add(time.Hour)
+	if err := m.MarkIdle(idleFrom, idleTo); err != nil {
+		t.Fatalf("MarkIdle() error = %v", err)
+	}
+
+	entries := readTimeLog(t, m)
+	if len(entries) != 1 || !entries[0].End.Equal(idleFrom) {
+		t.Fatalf("entries = %+v, want one interval ending at %v", entries, idleFrom)
+	}
+
+	// The open interval restarts when the shell was used again
+	var open TimeEntry
+	if err := m.StateStore().LoadJSON(m.sessionStateFile(TrackingFileName), &open); err != nil {
+		t.Fatalf("LoadJSON() error = %v", err)
+	}
+	if !open.Start.Equal(idleTo) {
+		t.Errorf("interval restarted at %v, want %v", open.Start, idleTo)
+	}
+}
+
+func TestManager_ReapStaleSessionsClosesTracking(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := NewManagerWithDir(tmpDir).SaveContext(&ContextConfig{Name: "dev", Environment: EnvDevelopment}); err != nil {
+		t.Fatalf("SaveContext() error = %v", err)
+	}
+
+	dead := newTrackingManager(t, tmpDir, "2-dead", deadPID(t))
+	if err := dead.SetCurrentContext("dev"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+	if err := dead.StartTracking(&ContextConfig{Name: "dev"}); err != nil {
+		t.Fatalf("StartTracking() error = %v", err)
+	}
+
+	// The shell hook last touched the activity file an hour in
+	lastUsed := time.Now().Add(time.Hour).Truncate(time.Second)
+	activity := filepath.Join(dead.sessionStateDir(), ActivityFileName)
+	if err := os.WriteFile(activity, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(activity, lastUsed, lastUsed); err != nil {
+		t.Fatal(err)
+	}
+
+	live := newTrackingManager(t, tmpDir, "1-live", os.Getpid())
+	if _, err := live.ReapStaleSessions(); err != nil {
+		t.Fatalf("ReapStaleSessions() error = %v", err)
+	}
+
+	entries, _ := live.LoadTimeEntries(time.Time{}, lastUsed.Add(time.Hour))
+	if len(entries) != 1 || entries[0].Session != "2-dead" || !entries[0].End.Equal(lastUsed) {
+		t.Errorf("entries = %+v, want the dead session's interval ending at %v", entries, lastUsed)
+	}
+}
+
+func TestManager_LoadTimeEntries(t *testing.T) {
+	m := newTrackingManager(t, t.TempDir(), "1-a", os.Getpid())
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	for _, entry := range []TimeEntry{
+		{Context: "dev", Start: base, End: base.Add(2 * time.Hour)},
+		{Context: "prod", Start: base.Add(24 * time.Hour), End: base.Add(25 * time.Hour)},
+	} {
+		if err := m.appendTimeEntry(entry); err != nil {
+			t.Fatalf("appendTimeEntry() error = %v", err)
+		}
+	}
+	// A line cut short by a crash is skipped
+	if err := m.StateStore().AppendFile(TimeLogFile, []byte(`{"context":"x","sta`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := m.LoadTimeEntries(base.Add(time.Hour), base.Add(24*time.Hour+30*time.Minute))
+	if err != nil {
+		t.Fatalf("LoadTimeEntries() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v, want 2", entries)
+	}
+	if entries[0].Duration() != time.Hour || entries[1].Duration() != 30*time.Minute {
+		t.Errorf("clipped durations = %v, %v, want 1h, 30m", entries[0].Duration(), entries[1].Duration())
+	}
+}
+
+func TestManager_IdleTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *TimeTrackingConfig
+		want   time.Duration
+	}{
+		{"default", nil, DefaultIdleTimeout},
+		{"custom", &TimeTrackingConfig{IdleTimeout: 5}, 5 * time.Minute},
+		{"disabled", &TimeTrackingConfig{Disabled: true}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManagerWithDir(t.TempDir())
+			m.appConfig = &AppConfig{TimeTracking: tt.config}
+			if got := m.IdleTimeout(); got != tt.want {
+				t.Errorf("IdleTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Disabled tracking records nothing
+	m := NewManagerWithDir(t.TempDir())
+	m.appConfig = &AppConfig{TimeTracking: &TimeTrackingConfig{Disabled: true}}
+	m.StartTracking(&ContextConfig{Name: "dev"})
+	m.StopTracking()
+	if entries := readTimeLog(t, m); len(entries) != 0 {
+		t.Errorf("disabled tracking recorded %+v", entries)
+	}
+}
@@ -6,6 +6,8 @@ package config
 
 import (
 	"maps"
+	"reflect"
+	"slices"
 
 	"dario.cat/mergo"
 )
@@ -353,7 +355,7 @@ type ContextConfig struct {
 	// Deactivate behavior (overrides global config)
 	Deactivate  *DeactivateConfig `yaml:"deactivate,omitempty" mapstructure:"deactivate"`
 	Name        string            `yaml:"name" mapstructure:"name"`
-	Extends     string            `yaml:"extends,omitempty" mapstructure:"extends"` // Parent context to inherit from
+	Extends     Extends           `yaml:"extends,omitempty" mapstructure:"extends"` // Parent contexts to inherit from
 	Description string            `yaml:"description" mapstructure:"description"`
 	Environment Environment       `yaml:"environment" mapstructure:"environment"`
 	EnvColor    string            `yaml:"env_color,omitempty" mapstructure:"env_color"` // red, yellow, green, blue, cyan, magenta, white
@@ -363,6 +365,8 @@ type ContextConfig struct {
 	// Databases
 	Databases []DatabaseConfig `yaml:"databases,omitempty" mapstructure:"databases"`
 	Abstract  bool             `yaml:"abstract,omitempty" mapstructure:"abstract"` // If true, context is a template and cannot be used directly
+	// Fields the context or the contexts merged into it override or unset
+	overrides []override
 }
 
 // GetCloudProviders returns a list of configured cloud providers.
@@ -445,9 +449,10 @@ func (c *ContextConfig) IsProd() bool {
 // Values from 'other' (parent) fill in missing values in 'c' (child).
 // Deep merge: child values take precedence, parent fills in gaps.
 //
-// Note: Due to Go's type system, boolean fields cannot be "unset" - they default to false.
-// This means a parent's `true` boolean cannot be overridden to `false` by a child.
-// If you need different boolean values, don't set them in the parent/base context.
+// Zero values count as missing, so a parent's `true` is inherited over a
+// child's `false`, unless the child overrides the field with !override.
+// Fields the child unsets with null or !unset aren't inherited either.
+// Tunnels and databases are merged by name.
 func (c *ContextConfig) MergeFrom(other *ContextConfig) {
 	// Save fields that should not be inherited
 	name := c.Name
@@ -457,42 +462,48 @@ func (c *ContextConfig) MergeFrom(other *ContextConfig) {
 	childEnv := c.Env
 	childURLs := c.URLs
 
+	// Leave out what the child overrides, and the lists merged by name
+	parentValue := reflect.ValueOf(other)
+	for _, o := range c.overrides {
+		parentValue = withoutField(parentValue, o.path)
+	}
+	parent := *parentValue.Interface().(*ContextConfig)
+	c.Tunnels = mergeNamed(c.Tunnels, parent.Tunnels, func(t TunnelConfig) string { return t.Name })
+	c.Databases = mergeNamed(c.Databases, parent.Databases, func(d DatabaseConfig) string { return d.Name })
+	parent.Tunnels, parent.Databases = nil, nil
+
 	// Deep merge parent into child (fills zero values from parent)
-	mergo.Merge(c, other)
+	mergo.Merge(c, parent)
 
 	// Restore non-inherited fields
 	c.Name = name
 	c.Extends = extends
 	c.Abstract = abstract
+	c.overrides = append(slices.Clone(c.overrides), other.overrides...)
 
 	// Maps: merge with child values taking precedence
-	if len(other.Env) > 0 || len(childEnv) > 0 {
+	if len(parent.Env) > 0 || len(childEnv) > 0 {
 		merged := make(map[string]string)
-		maps.Copy(merged, other.Env)
+		maps.Copy(merged, parent.Env)
 		// child overrides
 		maps.Copy(merged, childEnv)
 		c.Env = merged
 	}
-	if len(other.URLs) > 0 || len(childURLs) > 0 {
+	if len(parent.URLs) > 0 || len(childURLs) > 0 {
 		merged := make(map[string]string)
-		maps.Copy(merged, other.URLs)
+		maps.Copy(merged, parent.URLs)
 		// child overrides
 		maps.Copy(merged, childURLs)
 		c.URLs = merged
 	}
 
-	// Tags: merge and deduplicate
-	if len(other.Tags) > 0 || len(childTags) > 0 {
-		tagSet := make(map[string]bool)
-		for _, t := range other.Tags {
-			tagSet[t] = true
-		}
-		for _, t := range childTags {
-			tagSet[t] = true
-		}
-		c.Tags = make([]string, 0, len(tagSet))
-		for t := range tagSet {
-			c.Tags = append(c.Tags, t)
+	// Tags: the parent's first, then the child's, without duplicates
+	if len(parent.Tags) > 0 || len(childTags) > 0 {
+		c.Tags = make([]string, 0, len(parent.Tags)+len(childTags))
+		for _, t := range slices.Concat(parent.Tags, childTags) {
+			if !slices.Contains(c.Tags, t) {
+				c.Tags = append(c.Tags, t)
+			}
 		}
 	}
 }
@@ -513,15 +524,33 @@ func NewDeactivateConfigDefaults() *DeactivateConfig {
 
 // AppConfig represents the main application configuration.
 type AppConfig struct {
-	Deactivate       *DeactivateConfig `yaml:"deactivate,omitempty" mapstructure:"deactivate"`
-	Cloud            *CloudConfig      `yaml:"cloud,omitempty" mapstructure:"cloud"`
-	DefaultContext   string            `yaml:"default_context" mapstructure:"default_context"`
-	PromptFormat     string            `yaml:"prompt_format" mapstructure:"prompt_format"`
-	TunnelsDir       string            `yaml:"tunnels_dir" mapstructure:"tunnels_dir"`
-	ContextsDir      string            `yaml:"contexts_dir" mapstructure:"contexts_dir"`
-	Version          int               `yaml:"version" mapstructure:"version"`
-	ShellIntegration bool              `yaml:"shell_integration" mapstructure:"shell_integration"`
-	AutoDeactivate   bool              `yaml:"auto_deactivate" mapstructure:"auto_deactivate"`
+	Deactivate       *DeactivateConfig   `yaml:"deactivate,omitempty" mapstructure:"deactivate"`
+	Cloud            *CloudConfig        `yaml:"cloud,omitempty" mapstructure:"cloud"`
+	TimeTracking     *TimeTrackingConfig `yaml:"time_tracking,omitempty" mapstructure:"time_tracking"`
+	Repos            []RepoConfig        `yaml:"repos,omitempty" mapstructure:"repos"`
+	DefaultContext   string              `yaml:"default_context" mapstructure:"default_context"`
+	PromptFormat     string              `yaml:"prompt_format" mapstructure:"prompt_format"`
+	TunnelsDir       string              `yaml:"tunnels_dir,omitempty" mapstructure:"tunnels_dir"`
+	ContextsDir      string              `yaml:"contexts_dir,omitempty" mapstructure:"contexts_dir"`
+	ContextPaths     []string            `yaml:"context_paths,omitempty" mapstructure:"context_paths"`
+	Version          int                 `yaml:"version" mapstructure:"version"`
+	ShellIntegration bool                `yaml:"shell_integration" mapstructure:"shell_integration"`
+	AutoDeactivate   bool                `yaml:"auto_deactivate" mapstructure:"auto_deactivate"`
+}
+
+// RepoConfig is a git repository of shared contexts, added with 'ctx repo add'.
+// Its contexts are named <name>/<context>.
+type RepoConfig struct {
+	Name string `yaml:"name" mapstructure:"name"`           // Namespace of the repository's contexts
+	URL  string `yaml:"url" mapstructure:"url"`             // Git URL to clone
+	Path string `yaml:"path,omitempty" mapstructure:"path"` // Directory of the context files in the repository (default: the root)
+	Ref  string `yaml:"ref,omitempty" mapstructure:"ref"`   // Branch, tag or commit to check out (default: the default branch)
+}
+
+// TimeTrackingConfig controls the local time tracking used by 'ctx report'.
+type TimeTrackingConfig struct {
+	Disabled    bool `yaml:"disabled" mapstructure:"disabled"`         // Don't record time spent in contexts
+	IdleTimeout int  `yaml:"idle_timeout" mapstructure:"idle_timeout"` // Minutes at an idle prompt before time stops counting (default: 15)
 }
 
 // CloudConfig holds ctx-cloud integration settings.
@@ -531,4 +560,23 @@ type CloudConfig struct {
 	SendAuditEvents   bool   `yaml:"send_audit_events" mapstructure:"send_audit_events"`   // Send audit events to cloud
 	SendHeartbeat     bool   `yaml:"send_heartbeat" mapstructure:"send_heartbeat"`         // Send heartbeat to cloud
 	HeartbeatInterval int    `yaml:"heartbeat_interval" mapstructure:"heartbeat_interval"` // Heartbeat interval in seconds (default: 30)
+	// SSO is set when logged in with 'ctx cloud login --sso' instead of an API key
+	SSO *CloudSSOConfig `yaml:"sso,omitempty" mapstructure:"sso"`
+	// PolicyKey is the base64 Ed25519 public key the team's policies are
+	// signed with. Team policies are only enforced when it's set.
+	PolicyKey string `yaml:"policy_key,omitempty" mapstructure:"policy_key"`
+	// CAFile, ClientCert and ClientKey are PEM files for servers behind a
+	// private CA or a gateway that requires mutual TLS
+	CAFile     string `yaml:"ca_file,omitempty" mapstructure:"ca_file"`         // CA certificates trusted besides the system ones
+	ClientCert string `yaml:"client_cert,omitempty" mapstructure:"client_cert"` // Client certificate for mutual TLS
+	ClientKey  string `yaml:"client_key,omitempty" mapstructure:"client_key"`   // Private key of the client certificate
+}
+
+// CloudSSOConfig holds the OAuth 2.0 settings of a ctx-cloud SSO login.
+type CloudSSOConfig struct {
+	Issuer        string   `yaml:"issuer" mapstructure:"issuer"`                           // OIDC issuer URL
+	ClientID      string   `yaml:"client_id" mapstructure:"client_id"`                     // OAuth client ID of the CLI
+	Scopes        []string `yaml:"scopes,omitempty" mapstructure:"scopes"`                 // Scopes requested at login
+	TokenURL      string   `yaml:"token_url" mapstructure:"token_url"`                     // Token endpoint, used to refresh tokens
+	RevocationURL string   `yaml:"revocation_url,omitempty" mapstructure:"revocation_url"` // Revocation endpoint, used on logout
 }
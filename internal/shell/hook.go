@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -40,6 +41,9 @@ type HookConfig struct {
 	ConfigDir    string
 	StateDir     string
 	PromptFormat string
+	// IdleTimeout is how many seconds a prompt may sit idle before tracked
+	// time stops counting. 0 disables time tracking.
+	IdleTimeout int
 }
 
 // convertPromptFormatToBash converts Go template syntax to bash/zsh syntax.
@@ -106,9 +110,13 @@ func GenerateHook(shellType ShellType, cfg HookConfig) (string, error) {
 	data := map[string]string{
 		"ConfigDir":    cfg.ConfigDir,
 		"StateDir":     cfg.StateDir,
-		"EnvFile":      filepath.Join(cfg.StateDir, "current.env"),
-		"NameFile":     filepath.Join(cfg.StateDir, "current.name"),
+		"EnvFile":      filepath.Join(cfg.StateDir, "sessions") + "/$CTX_SESSION_ID/current.env",
 		"PromptFormat": shellPromptFormat,
+		"ActivityFile": filepath.Join(cfg.StateDir, "sessions") + "/$CTX_SESSION_ID/activity",
+		"IdleTimeout":  "",
+	}
+	if cfg.IdleTimeout > 0 {
+		data["IdleTimeout"] = strconv.Itoa(cfg.IdleTimeout)
 	}
 
 	var buf bytes.Buffer
@@ -123,15 +131,29 @@ const bashHookTemplate = `# ctx shell integration for bash
 # Add this to your ~/.bashrc:
 #   eval "$(ctx shell-hook)"
 
+# Give this shell its own session so switching here doesn't affect other shells.
+# A shell started by 'ctx shell' keeps the session ctx created for it.
+if [[ "${CTX_SESSION_PID:-}" != "$$" ]] &&
+    ! [[ -n "${CTX_SHELL_SESSION:-}" && "$CTX_SHELL_SESSION" == "$CTX_SESSION_ID" && "$CTX_SESSION_PID" == "$PPID" ]]; then
+    export CTX_SESSION_PID=$$
+    export CTX_SESSION_ID="$$-${RANDOM}${RANDOM}"
+fi
+unset CTX_SHELL_SESSION
+
 # ctx wrapper function - captures env vars for this shell session
 ctx() {
+{{- if .IdleTimeout}}
+    # Don't count the time the prompt sat idle before this command
+    __ctx_track_command
+{{- end}}
     # Check for help flags - pass through directly
     if [[ "$*" == *"--help"* || "$*" == *"-h"* ]]; then
         command ctx "$@"
         return $?
     fi
 
-    if [[ "$1" == "use" && $# -ge 2 ]]; then
+    if [[ "$1" == "use" ]]; then
+        # Also covers the picker (bare 'ctx use') and 'ctx use -'
         # First, run the actual switch (with side effects like VPN, kubectl, etc.)
         # This also writes env vars + resolved secrets to the env file
         command ctx "$@"
@@ -140,37 +162,39 @@ ctx() {
         if [[ $exit_code -eq 0 ]]; then
             # Source the env file which includes resolved secrets
             if [[ -f "{{.EnvFile}}" ]]; then
+                # Remember values ctx is about to override so deactivate can restore them
+                eval "$(command ctx env-snapshot)"
                 source "{{.EnvFile}}"
             fi
         fi
         return $exit_code
     elif [[ "$1" == "deactivate" && $# -eq 1 ]]; then
         # Only intercept bare 'ctx deactivate', not 'ctx deactivate --export'
-        # Capture unset commands from deactivate --export
-        local unset_output
-        unset_output=$(command ctx deactivate --export)
+        # Capture restore/unset commands from deactivate --export
+        local restore_output
+        restore_output=$(command ctx deactivate --export)
 
         # Run the actual deactivate for VPN/tunnel cleanup
         command ctx deactivate
         local exit_code=$?
 
         if [[ $exit_code -eq 0 ]]; then
-            # Then unset the env vars in this shell
-            eval "$unset_output"
+            # Then restore the env vars in this shell to their pre-activation values
+            eval "$restore_output"
         fi
         return $exit_code
     elif [[ "$1" == "logout" ]]; then
-        # Capture unset commands before logout clears credentials
-        local unset_output
-        unset_output=$(command ctx deactivate --export)
+        # Capture restore/unset commands before logout clears credentials
+        local restore_output
+        restore_output=$(command ctx deactivate --export)
 
         # Run the actual logout
         command ctx "$@"
         local exit_code=$?
 
         if [[ $exit_code -eq 0 ]]; then
-            # Then unset the env vars in this shell
-            eval "$unset_output"
+            # Then restore the env vars in this shell to their pre-activation values
+            eval "$restore_output"
         fi
         return $exit_code
     else
@@ -215,9 +239,86 @@ if [[ ! "$PROMPT_COMMAND" == *"__ctx_prompt"* ]]; then
     fi
 fi
 
-# Optionally source last context for new shells (comment out if you want fresh shells)
-if [[ -z "$CTX_CURRENT" && -f "{{.EnvFile}}" ]]; then
-    source "{{.EnvFile}}"
+# Activate the context bound to the current directory by a .ctx.yaml or .ctx
+# file, and deactivate it when leaving that directory (see: ctx dir)
+__ctx_dir_hook() {
+    [[ "$PWD" == "${__ctx_dir_pwd:-}" ]] && return
+    __ctx_dir_pwd="$PWD"
+
+    local action
+    action="$(command ctx dir check)"
+    case "$action" in
+        "use "*)
+            if command ctx use --dir "${action#use }"; then
+                if [[ -f "{{.EnvFile}}" ]]; then
+                    eval "$(command ctx env-snapshot)"
+                    source "{{.EnvFile}}"
+                fi
+            elif [[ -n "${CTX_DIR:-}" ]]; then
+                # Left the previous tree, even though its replacement wasn't activated
+                ctx deactivate
+            fi
+            ;;
+        deactivate)
+            ctx deactivate
+            ;;
+    esac
+}
+
+if [[ ! "$PROMPT_COMMAND" == *"__ctx_dir_hook"* ]]; then
+    PROMPT_COMMAND="__ctx_dir_hook${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
+fi
+__ctx_dir_hook
+{{if .IdleTimeout}}
+# Time tracking (see: ctx report). Time the prompt sat idle for longer than
+# the idle timeout before a command doesn't count, and the activity file tells
+# ctx when a shell that exited without deactivating was last used.
+# printf's %(...)T needs bash 4.2, and macOS ships bash 3.2
+if (( BASH_VERSINFO[0] > 4 || (BASH_VERSINFO[0] == 4 && BASH_VERSINFO[1] >= 2) )); then
+    __ctx_now() { printf -v "$1" '%(%s)T' -1; }
+else
+    __ctx_now() { printf -v "$1" '%s' "$(date +%s)"; }
+fi
+
+__ctx_track_idle() {
+    if [[ -n "${CTX_CURRENT:-}" && -n "${__ctx_prompt_at:-}" ]] && (( $1 - __ctx_prompt_at >= {{.IdleTimeout}} )); then
+        command ctx track idle "$__ctx_prompt_at" "$1" >/dev/null 2>&1
+    fi
+}
+
+__ctx_track_command() {
+    local now
+    __ctx_now now
+    __ctx_track_idle "$now"
+    __ctx_prompt_at=$now
+}
+
+__ctx_track_prompt() {
+    if [[ -n "${CTX_CURRENT:-}" ]]; then
+        # bash has no preexec hook, but history records when the last command
+        # was entered
+        local num at _
+        read -r num at _ <<< "$(HISTTIMEFORMAT='%s ' builtin history 1)"
+        if [[ "$num" != "${__ctx_hist_num:-}" && "$at" =~ ^[0-9]+$ ]]; then
+            __ctx_track_idle "$at"
+        fi
+        __ctx_hist_num="$num"
+        : 2>/dev/null >| "{{.ActivityFile}}"
+    fi
+    __ctx_now __ctx_prompt_at
+}
+
+if [[ ! "$PROMPT_COMMAND" == *"__ctx_track_prompt"* ]]; then
+    PROMPT_COMMAND="__ctx_track_prompt${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
+fi
+{{end}}
+# Start new shells in the default context, if one is set (see: ctx default)
+if [[ -z "$CTX_CURRENT" ]]; then
+    __ctx_default="$(command ctx default 2>/dev/null)"
+    if [[ -n "$__ctx_default" ]]; then
+        ctx use "$__ctx_default"
+    fi
+    unset __ctx_default
 fi
 `
 
@@ -225,6 +326,15 @@ const zshHookTemplate = `# ctx shell integration for zsh
 # Add this to your ~/.zshrc:
 #   eval "$(ctx shell-hook)"
 
+# Give this shell its own session so switching here doesn't affect other shells.
+# A shell started by 'ctx shell' keeps the session ctx created for it.
+if [[ "${CTX_SESSION_PID:-}" != "$$" ]] &&
+    ! [[ -n "${CTX_SHELL_SESSION:-}" && "$CTX_SHELL_SESSION" == "$CTX_SESSION_ID" && "$CTX_SESSION_PID" == "$PPID" ]]; then
+    export CTX_SESSION_PID=$$
+    export CTX_SESSION_ID="$$-${RANDOM}${RANDOM}"
+fi
+unset CTX_SHELL_SESSION
+
 # ctx wrapper function - captures env vars for this shell session
 ctx() {
     # Check for help flags - pass through directly
@@ -233,7 +343,8 @@ ctx() {
         return $?
     fi
 
-    if [[ "$1" == "use" && $# -ge 2 ]]; then
+    if [[ "$1" == "use" ]]; then
+        # Also covers the picker (bare 'ctx use') and 'ctx use -'
         # First, run the actual switch (with side effects like VPN, kubectl, etc.)
         # This also writes env vars + resolved secrets to the env file
         command ctx "$@"
@@ -242,37 +353,39 @@ ctx() {
         if [[ $exit_code -eq 0 ]]; then
             # Source the env file which includes resolved secrets
             if [[ -f "{{.EnvFile}}" ]]; then
+                # Remember values ctx is about to override so deactivate can restore them
+                eval "$(command ctx env-snapshot)"
                 source "{{.EnvFile}}"
             fi
         fi
         return $exit_code
     elif [[ "$1" == "deactivate" && $# -eq 1 ]]; then
         # Only intercept bare 'ctx deactivate', not 'ctx deactivate --export'
-        # Capture unset commands from deactivate --export
-        local unset_output
-        unset_output=$(command ctx deactivate --export)
+        # Capture restore/unset commands from deactivate --export
+        local restore_output
+        restore_output=$(command ctx deactivate --export)
 
         # Run the actual deactivate for VPN/tunnel cleanup
         command ctx deactivate
         local exit_code=$?
 
         if [[ $exit_code -eq 0 ]]; then
-            # Then unset the env vars in this shell
-            eval "$unset_output"
+            # Then restore the env vars in this shell to their pre-activation values
+            eval "$restore_output"
         fi
         return $exit_code
     elif [[ "$1" == "logout" ]]; then
-        # Capture unset commands before logout clears credentials
-        local unset_output
-        unset_output=$(command ctx deactivate --export)
+        # Capture restore/unset commands before logout clears credentials
+        local restore_output
+        restore_output=$(command ctx deactivate --export)
 
         # Run the actual logout
         command ctx "$@"
         local exit_code=$?
 
         if [[ $exit_code -eq 0 ]]; then
-            # Then unset the env vars in this shell
-            eval "$unset_output"
+            # Then restore the env vars in this shell to their pre-activation values
+            eval "$restore_output"
         fi
         return $exit_code
     else
@@ -310,9 +423,65 @@ if [[ ! "$PROMPT" == *'$(__ctx_prompt)'* ]]; then
     PROMPT='$(__ctx_prompt)'"${PROMPT}"
 fi
 
-# Optionally source last context for new shells (comment out if you want fresh shells)
-if [[ -z "$CTX_CURRENT" && -f "{{.EnvFile}}" ]]; then
-    source "{{.EnvFile}}"
+# Activate the context bound to the current directory by a .ctx.yaml or .ctx
+# file, and deactivate it when leaving that directory (see: ctx dir)
+__ctx_dir_hook() {
+    [[ "$PWD" == "${__ctx_dir_pwd:-}" ]] && return
+    __ctx_dir_pwd="$PWD"
+
+    local action
+    action="$(command ctx dir check)"
+    case "$action" in
+        "use "*)
+            if command ctx use --dir "${action#use }"; then
+                if [[ -f "{{.EnvFile}}" ]]; then
+                    eval "$(command ctx env-snapshot)"
+                    source "{{.EnvFile}}"
+                fi
+            elif [[ -n "${CTX_DIR:-}" ]]; then
+                # Left the previous tree, even though its replacement wasn't activated
+                ctx deactivate
+            fi
+            ;;
+        deactivate)
+            ctx deactivate
+            ;;
+    esac
+}
+
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd __ctx_dir_hook
+__ctx_dir_hook
+{{if .IdleTimeout}}
+# Time tracking (see: ctx report). Time the prompt sat idle for longer than
+# the idle timeout before a command doesn't count, and the activity file tells
+# ctx when a shell that exited without deactivating was last used.
+zmodload zsh/datetime
+
+__ctx_track_command() {
+    if [[ -n "${CTX_CURRENT:-}" && -n "${__ctx_prompt_at:-}" ]] && (( EPOCHSECONDS - __ctx_prompt_at >= {{.IdleTimeout}} )); then
+        command ctx track idle "$__ctx_prompt_at" "$EPOCHSECONDS" >/dev/null 2>&1
+    fi
+    __ctx_prompt_at=$EPOCHSECONDS
+}
+
+__ctx_track_prompt() {
+    if [[ -n "${CTX_CURRENT:-}" ]]; then
+        : 2>/dev/null >| "{{.ActivityFile}}"
+    fi
+    __ctx_prompt_at=$EPOCHSECONDS
+}
+
+add-zsh-hook preexec __ctx_track_command
+add-zsh-hook precmd __ctx_track_prompt
+{{end}}
+# Start new shells in the default context, if one is set (see: ctx default)
+if [[ -z "$CTX_CURRENT" ]]; then
+    __ctx_default="$(command ctx default 2>/dev/null)"
+    if [[ -n "$__ctx_default" ]]; then
+        ctx use "$__ctx_default"
+    fi
+    unset __ctx_default
 fi
 `
 
@@ -320,17 +489,40 @@ const fishHookTemplate = `# ctx shell integration for fish
 # Add this to your ~/.config/fish/config.fish:
 #   ctx shell-hook | source
 
-# Helper to parse env output
+# Give this shell its own session so switching here doesn't affect other shells.
+# A shell started by 'ctx shell' keeps the session ctx created for it.
+if test "$CTX_SESSION_PID" != "$fish_pid"
+    set -l __ctx_adopt 0
+    if test -n "$CTX_SHELL_SESSION"; and test "$CTX_SHELL_SESSION" = "$CTX_SESSION_ID"
+        set -l __ctx_ppid (ps -o ppid= -p $fish_pid | string trim)
+        test "$CTX_SESSION_PID" = "$__ctx_ppid"; and set __ctx_adopt 1
+    end
+    if test $__ctx_adopt -eq 0
+        set -gx CTX_SESSION_PID $fish_pid
+        set -gx CTX_SESSION_ID "$fish_pid-"(random)(random)
+    end
+end
+set -e CTX_SHELL_SESSION
+
+# Helper to apply export/unset lines to this shell
 function __ctx_parse_env
     for line in $argv
+        if string match -q 'unset *' -- $line
+            set -e (string replace 'unset ' '' -- $line)
+            continue
+        end
         # Remove 'export ' prefix and split on '='
         set -l clean (string replace 'export ' '' -- $line)
         set -l parts (string split '=' -- $clean)
         if test (count $parts) -ge 2
             set -l var_name $parts[1]
             set -l var_value (string join '=' -- $parts[2..-1])
-            # Remove surrounding quotes
-            set var_value (string trim -c '"' -- $var_value)
+            # Remove surrounding quotes. Single-quoted values write ' as '\''
+            if string match -q "'*'" -- $var_value
+                set var_value (string replace -r "^'(.*)'\$" '$1' -- $var_value | string replace -a "'\\''" "'")
+            else
+                set var_value (string trim -c '"' -- $var_value)
+            end
             set -gx $var_name $var_value
         end
     end
@@ -344,7 +536,8 @@ function ctx --wraps=ctx --description 'Unified cloud context manager'
         return $status
     end
 
-    if test "$argv[1]" = "use"; and test (count $argv) -ge 2
+    if test "$argv[1]" = "use"
+        # Also covers the picker (bare 'ctx use') and 'ctx use -'
         # First, run the actual switch (with side effects like VPN, kubectl, etc.)
         # This also writes env vars + resolved secrets to the env file
         command ctx $argv
@@ -353,50 +546,37 @@ function ctx --wraps=ctx --description 'Unified cloud context manager'
         if test $exit_code -eq 0
             # Source the env file which includes resolved secrets
             if test -f "{{.EnvFile}}"
-                for line in (cat "{{.EnvFile}}")
-                    set -l clean (string replace 'export ' '' -- $line)
-                    set -l parts (string split '=' -- $clean)
-                    if test (count $parts) -ge 2
-                        set -l var_name $parts[1]
-                        set -l var_value (string join '=' -- $parts[2..-1])
-                        set var_value (string trim -c '"' -- $var_value)
-                        set -gx $var_name $var_value
-                    end
-                end
+                # Remember values ctx is about to override so deactivate can restore them
+                __ctx_parse_env (command ctx env-snapshot)
+                __ctx_parse_env (cat "{{.EnvFile}}")
             end
         end
         return $exit_code
     else if test "$argv[1]" = "deactivate"; and test (count $argv) -eq 1
         # Only intercept bare 'ctx deactivate', not 'ctx deactivate --export'
-        # Capture unset commands from deactivate --export
-        set -l unset_output (command ctx deactivate --export)
+        # Capture restore/unset commands from deactivate --export
+        set -l restore_output (command ctx deactivate --export)
 
         # Run the actual deactivate for VPN/tunnel cleanup
         command ctx deactivate
         set -l exit_code $status
 
         if test $exit_code -eq 0
-            # Then unset the env vars in this shell
-            for line in $unset_output
-                set -l var_name (string replace 'unset ' '' -- $line)
-                set -e $var_name
-            end
+            # Then restore the env vars in this shell to their pre-activation values
+            __ctx_parse_env $restore_output
         end
         return $exit_code
     else if test "$argv[1]" = "logout"
-        # Capture unset commands before logout clears credentials
-        set -l unset_output (command ctx deactivate --export)
+        # Capture restore/unset commands before logout clears credentials
+        set -l restore_output (command ctx deactivate --export)
 
         # Run the actual logout
         command ctx $argv
         set -l exit_code $status
 
         if test $exit_code -eq 0
-            # Then unset the env vars in this shell
-            for line in $unset_output
-                set -l var_name (string replace 'unset ' '' -- $line)
-                set -e $var_name
-            end
+            # Then restore the env vars in this shell to their pre-activation values
+            __ctx_parse_env $restore_output
         end
         return $exit_code
     else
@@ -439,17 +619,51 @@ if not functions -q __ctx_original_fish_prompt
     end
 end
 
-# Optionally source last context for new shells (comment out if you want fresh shells)
-if test -z "$CTX_CURRENT"; and test -f "{{.EnvFile}}"
-    for line in (cat "{{.EnvFile}}")
-        set -l clean (string replace 'export ' '' -- $line)
-        set -l parts (string split '=' -- $clean)
-        if test (count $parts) -ge 2
-            set -l var_name $parts[1]
-            set -l var_value (string join '=' -- $parts[2..-1])
-            set var_value (string trim -c '"' -- $var_value)
-            set -gx $var_name $var_value
+# Activate the context bound to the current directory by a .ctx.yaml or .ctx
+# file, and deactivate it when leaving that directory (see: ctx dir)
+function __ctx_dir_hook --on-variable PWD
+    set -l action (command ctx dir check)
+    if string match -q 'use *' -- "$action"
+        if command ctx use --dir (string replace 'use ' '' -- "$action")
+            if test -f "{{.EnvFile}}"
+                __ctx_parse_env (command ctx env-snapshot)
+                __ctx_parse_env (cat "{{.EnvFile}}")
+            end
+        else if test -n "$CTX_DIR"
+            # Left the previous tree, even though its replacement wasn't activated
+            ctx deactivate
+        end
+    else if test "$action" = deactivate
+        ctx deactivate
+    end
+end
+__ctx_dir_hook
+{{if .IdleTimeout}}
+# Time tracking (see: ctx report). Time the prompt sat idle for longer than
+# the idle timeout before a command doesn't count, and the activity file tells
+# ctx when a shell that exited without deactivating was last used.
+function __ctx_track_command --on-event fish_preexec
+    set -l now (date +%s)
+    if test -n "$CTX_CURRENT"; and set -q __ctx_prompt_at
+        if test (math $now - $__ctx_prompt_at) -ge {{.IdleTimeout}}
+            command ctx track idle $__ctx_prompt_at $now >/dev/null 2>&1
         end
     end
+    set -g __ctx_prompt_at $now
+end
+
+function __ctx_track_prompt --on-event fish_prompt
+    if test -n "$CTX_CURRENT"
+        echo -n 2>/dev/null >"{{.ActivityFile}}"
+    end
+    set -g __ctx_prompt_at (date +%s)
+end
+{{end}}
+# Start new shells in the default context, if one is set (see: ctx default)
+if test -z "$CTX_CURRENT"
+    set -l ctx_default (command ctx default 2>/dev/null)
+    if test -n "$ctx_default"
+        ctx use $ctx_default
+    end
 end
 `
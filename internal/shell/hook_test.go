@@ -55,8 +55,13 @@ func TestGenerateHook_Bash(t *testing.T) {
 		"# ctx shell integration for bash",
 		"ctx()",
 		"command ctx",
-		cfg.StateDir + "/current.env",
+		cfg.StateDir + "/sessions/$CTX_SESSION_ID/current.env",
+		"CTX_SESSION_ID",
+		"CTX_SHELL_SESSION",
+		"ctx dir check",
+		"ctx use --dir",
 		"--export",
+		"env-snapshot",
 		"CTX_CURRENT",
 		"__ctx_prompt",
 	}
@@ -85,8 +90,13 @@ func TestGenerateHook_Zsh(t *testing.T) {
 		"# ctx shell integration for zsh",
 		"ctx()",
 		"command ctx",
-		cfg.StateDir + "/current.env",
+		cfg.StateDir + "/sessions/$CTX_SESSION_ID/current.env",
+		"CTX_SESSION_ID",
+		"CTX_SHELL_SESSION",
+		"ctx dir check",
+		"ctx use --dir",
 		"--export",
+		"env-snapshot",
 		"CTX_CURRENT",
 		"PROMPT_SUBST",
 	}
@@ -115,8 +125,13 @@ func TestGenerateHook_Fish(t *testing.T) {
 		"# ctx shell integration for fish",
 		"function ctx",
 		"command ctx",
-		cfg.StateDir + "/current.env",
+		cfg.StateDir + "/sessions/$CTX_SESSION_ID/current.env",
+		"CTX_SESSION_ID",
+		"CTX_SHELL_SESSION",
+		"ctx dir check",
+		"ctx use --dir",
 		"--export",
+		"env-snapshot",
 		"CTX_CURRENT",
 		"fish_prompt",
 	}
@@ -128,6 +143,50 @@ func TestGenerateHook_Fish(t *testing.T) {
 	}
 }
 
+func TestGenerateHook_TimeTracking(t *testing.T) {
+	tests := []struct {
+		shell   ShellType
+		compare string
+		now     string
+	}{
+		// bash before 4.2 can't print the time itself
+		{ShellBash, ">= 900", "$(date +%s)"},
+		{ShellZsh, ">= 900", "EPOCHSECONDS"},
+		{ShellFish, "-ge 900", "(date +%s)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.shell), func(t *testing.T) {
+			cfg := HookConfig{
+				ConfigDir:    "/home/test/.config/ctx",
+				StateDir:     "/home/test/.config/ctx/state",
+				PromptFormat: "[ctx: {{.Name}}]",
+				IdleTimeout:  900,
+			}
+
+			hook, err := GenerateHook(tt.shell, cfg)
+			if err != nil {
+				t.Fatalf("GenerateHook() error = %v", err)
+			}
+			for _, expected := range []string{"ctx track idle", tt.compare, tt.now, cfg.StateDir + "/sessions/$CTX_SESSION_ID/activity"} {
+				if !strings.Contains(hook, expected) {
+					t.Errorf("hook missing %q", expected)
+				}
+			}
+
+			// Disabled time tracking leaves it out
+			cfg.IdleTimeout = 0
+			hook, err = GenerateHook(tt.shell, cfg)
+			if err != nil {
+				t.Fatalf("GenerateHook() error = %v", err)
+			}
+			if strings.Contains(hook, "__ctx_track_command") {
+				t.Error("hook tracks time with time tracking disabled")
+			}
+		})
+	}
+}
+
 func TestGenerateHook_UnsupportedShell(t *testing.T) {
 	cfg := HookConfig{
 		ConfigDir: "/home/test/.config/ctx",
@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package shell
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// SnapshotEnvVar is the environment variable the shell hook uses to carry the
+// pre-activation values of every variable ctx has overridden in that shell.
+const SnapshotEnvVar = "CTX_SAVED_ENV"
+
+// EnvSnapshot records the value each variable had before ctx first overrode it
+// in a shell. A nil value means the variable was not set at all, so restoring
+// it means unsetting it again.
+type EnvSnapshot map[string]*string
+
+// DecodeSnapshot decodes a snapshot from the value of SnapshotEnvVar.
+// An empty string yields an empty snapshot.
+func DecodeSnapshot(encoded string) (EnvSnapshot, error) {
+	snap := EnvSnapshot{}
+	if encoded == "" {
+		return snap, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode env snapshot: %w", err)
+	}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse env snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// Encode returns the snapshot in a form that is safe to store in SnapshotEnvVar.
+func (s EnvSnapshot) Encode() (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal env snapshot: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Capture records the current value of each key that is not already part of
+// the snapshot. Keys captured by an earlier activation keep their original
+// value, so the snapshot stays correct across several `ctx use` calls.
+func (s EnvSnapshot) Capture(keys []string, lookup func(string) (string, bool)) {
+	for _, key := range keys {
+		if key == SnapshotEnvVar {
+			continue
+		}
+		if _, ok := s[key]; ok {
+			continue
+		}
+		if value, ok := lookup(key); ok {
+			s[key] = &value
+		} else {
+			s[key] = nil
+		}
+	}
+}
+
+// Release removes every key not in keep from the snapshot and returns the
+// shell commands that put those variables back to their pre-activation state.
+// Passing a nil keep set releases the whole snapshot.
+func (s EnvSnapshot) Release(keep map[string]bool) []string {
+	var keys []string
+	for key := range s {
+		if !keep[key] {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if value := s[key]; value != nil {
+			// The shell evaluates the line, so $ and backticks in the old
+			// value must stay literal
+			lines = append(lines, "export "+key+"="+shellQuote(*value))
+		} else {
+			lines = append(lines, "unset "+key)
+		}
+		delete(s, key)
+	}
+	return lines
+}
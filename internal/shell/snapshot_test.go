@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestEnvSnapshot_EncodeDecode(t *testing.T) {
+	orig := "default"
+	snap := EnvSnapshot{"AWS_PROFILE": &orig, "KUBECONFIG": nil}
+
+	encoded, err := snap.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	decoded, err := DecodeSnapshot(encoded)
+	if err != nil {
+		t.Fatalf("DecodeSnapshot() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, snap) {
+		t.Errorf("DecodeSnapshot() = %v, want %v", decoded, snap)
+	}
+
+	empty, err := DecodeSnapshot("")
+	if err != nil || len(empty) != 0 {
+		t.Errorf("DecodeSnapshot(\"\") = %v, %v; want empty snapshot", empty, err)
+	}
+
+	if _, err := DecodeSnapshot("not base64!"); err == nil {
+		t.Error("DecodeSnapshot() expected error for invalid input")
+	}
+}
+
+func TestEnvSnapshot_RestoreAfterSingleUse(t *testing.T) {
+	env := map[string]string{"AWS_PROFILE": "personal", "HTTP_PROXY": "http://corp:3128"}
+
+	snap := EnvSnapshot{}
+	snap.Capture([]string{"AWS_PROFILE", "KUBECONFIG", "CTX_CURRENT"}, lookupFrom(env))
+
+	got := snap.Release(nil)
+	want := []string{
+		`export AWS_PROFILE='personal'`,
+		"unset CTX_CURRENT",
+		"unset KUBECONFIG",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Release() = %v, want %v", got, want)
+	}
+	if len(snap) != 0 {
+		t.Errorf("snapshot should be empty after full release, got %v", snap)
+	}
+}
+
+func TestEnvSnapshot_RepeatedUse(t *testing.T) {
+	env := map[string]string{"AWS_PROFILE": "personal", "HTTP_PROXY": "http://corp:3128"}
+
+	// First activation overrides AWS_PROFILE and HTTP_PROXY
+	snap := EnvSnapshot{}
+	snap.Capture([]string{"AWS_PROFILE", "HTTP_PROXY"}, lookupFrom(env))
+	env["AWS_PROFILE"] = "ctx-one"
+	env["HTTP_PROXY"] = "http://ctx-one:8080"
+
+	// Second activation overrides AWS_PROFILE and VAULT_ADDR, but not HTTP_PROXY
+	keep := map[string]bool{"AWS_PROFILE": true, "VAULT_ADDR": true}
+	restored := snap.Release(keep)
+	if want := []string{`export HTTP_PROXY='http://corp:3128'`}; !reflect.DeepEqual(restored, want) {
+		t.Errorf("Release() = %v, want %v", restored, want)
+	}
+	snap.Capture([]string{"AWS_PROFILE", "VAULT_ADDR"}, lookupFrom(env))
+
+	// Deactivate must go back to the values from before the first activation
+	got := snap.Release(nil)
+	want := []string{
+		`export AWS_PROFILE='personal'`,
+		"unset VAULT_ADDR",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Release() = %v, want %v", got, want)
+	}
+}
+
+func TestEnvSnapshot_SkipsSnapshotVar(t *testing.T) {
+	snap := EnvSnapshot{}
+	snap.Capture([]string{SnapshotEnvVar}, lookupFrom(map[string]string{SnapshotEnvVar: "x"}))
+	if len(snap) != 0 {
+		t.Errorf("Capture() should ignore %s, got %v", SnapshotEnvVar, snap)
+	}
+}
+
+func TestEnvSnapshot_ReleaseQuoting(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+
+	marker := t.TempDir() + "/ran"
+	values := []string{
+		`$HOME and ${PATH}`,
+		"`touch " + marker + "` and $(touch " + marker + ")",
+		`say "hi" and it's`,
+		`back\slash ''`,
+		"",
+	}
+	for _, value := range values {
+		snap := EnvSnapshot{"OLD_VALUE": &value}
+		lines := snap.Release(nil)
+
+		// The hooks eval the lines in bash and zsh
+		cmd := exec.Command(bash, "-c", `eval "$1"; printf %s "$OLD_VALUE"`, "bash", strings.Join(lines, "\n"))
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("bash error = %v", err)
+		}
+		if string(out) != value {
+			t.Errorf("restored %q, want %q", out, value)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("restoring a value ran a command in it")
+	}
+}
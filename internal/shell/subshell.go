@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// SubshellEnvVar is set to the session ID for a shell spawned by ctx shell.
+// The shell hook uses it to adopt that session instead of starting a new one.
+const SubshellEnvVar = "CTX_SHELL_SESSION"
+
+// SubshellConfig describes an interactive shell to spawn for a context.
+type SubshellConfig struct {
+	// Path is the shell binary, usually $SHELL.
+	Path string
+	// Marker is shown in front of the prompt unless the ctx hook already
+	// shows the context.
+	Marker string
+	// HistFile is a per-context history file. Empty keeps the user's history.
+	HistFile string
+	// HistName is the history session name fish uses instead of a file.
+	HistName string
+	// Home is the user's home directory, used to find their startup files.
+	Home string
+}
+
+// PrepareSubshell writes the startup files for the shell into dir and returns
+// the arguments to start it with and extra environment variables to set.
+// The user's own startup files are still loaded. Shells other than bash, zsh
+// and fish get the marker through PS1 only.
+func PrepareSubshell(cfg SubshellConfig, dir string) ([]string, map[string]string, error) {
+	env := make(map[string]string)
+
+	switch filepath.Base(cfg.Path) {
+	case "bash":
+		rcFile := filepath.Join(dir, "bashrc")
+		if err := writeSubshellFile(rcFile, bashSubshellTemplate, cfg, dir); err != nil {
+			return nil, nil, err
+		}
+		return []string{"--rcfile", rcFile, "-i"}, env, nil
+
+	case "zsh":
+		// zsh reads its startup files from ZDOTDIR. Ours load the user's and
+		// then put ZDOTDIR back.
+		if err := writeSubshellFile(filepath.Join(dir, ".zshenv"), zshenvSubshellTemplate, cfg, dir); err != nil {
+			return nil, nil, err
+		}
+		if err := writeSubshellFile(filepath.Join(dir, ".zshrc"), zshrcSubshellTemplate, cfg, dir); err != nil {
+			return nil, nil, err
+		}
+		env["ZDOTDIR"] = dir
+		return []string{"-i"}, env, nil
+
+	case "fish":
+		script, err := renderSubshellScript(fishSubshellTemplate, cfg, dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []string{"--init-command", script}, env, nil
+
+	default:
+		env["PS1"] = cfg.Marker + " $ "
+		if cfg.HistFile != "" {
+			env["HISTFILE"] = cfg.HistFile
+		}
+		return []string{"-i"}, env, nil
+	}
+}
+
+// writeSubshellFile renders a startup file template to path.
+func writeSubshellFile(path, tmpl string, cfg SubshellConfig, dir string) error {
+	script, err := renderSubshellScript(tmpl, cfg, dir)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		return fmt.Errorf("failed to write shell startup file: %w", err)
+	}
+	return nil
+}
+
+func renderSubshellScript(text string, cfg SubshellConfig, dir string) (string, error) {
+	tmpl, err := template.New("subshell").Funcs(template.FuncMap{"quote": shellQuote}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	zdotdir := os.Getenv("ZDOTDIR")
+	if zdotdir == "" {
+		zdotdir = cfg.Home
+	}
+
+	data := map[string]string{
+		"Marker":   cfg.Marker,
+		"HistFile": cfg.HistFile,
+		"HistName": cfg.HistName,
+		"Home":     cfg.Home,
+		"ZDOTDIR":  zdotdir,
+		"Dir":      dir,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// shellQuote single-quotes s for bash, zsh and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// FishHistoryName turns a context name into a valid fish_history session name.
+func FishHistoryName(contextName string) string {
+	return "ctx_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(contextName, "_")
+}
+
+const bashSubshellTemplate = `# Started by ctx shell
+if [[ -f {{quote (print .Home "/.bashrc")}} ]]; then
+    source {{quote (print .Home "/.bashrc")}}
+fi
+{{if .HistFile}}
+HISTFILE={{quote .HistFile}}
+history -c
+history -r
+{{end}}
+# Show the context in the prompt unless the ctx hook already does
+if ! declare -F __ctx_prompt >/dev/null; then
+    PS1={{quote .Marker}}" $PS1"
+fi
+`
+
+const zshenvSubshellTemplate = `# Started by ctx shell
+if [[ -f {{quote (print .ZDOTDIR "/.zshenv")}} ]]; then
+    source {{quote (print .ZDOTDIR "/.zshenv")}}
+fi
+ZDOTDIR={{quote .Dir}}
+`
+
+const zshrcSubshellTemplate = `# Started by ctx shell
+ZDOTDIR={{quote .ZDOTDIR}}
+if [[ -f "$ZDOTDIR/.zshrc" ]]; then
+    source "$ZDOTDIR/.zshrc"
+fi
+{{if .HistFile}}
+HISTFILE={{quote .HistFile}}
+fc -p {{quote .HistFile}}
+{{end}}
+# Show the context in the prompt unless the ctx hook already does
+if (( ! $+functions[__ctx_prompt] )); then
+    PROMPT={{quote .Marker}}" $PROMPT"
+fi
+`
+
+const fishSubshellTemplate = `# Started by ctx shell
+{{if .HistName}}set -g fish_history {{.HistName}}
+{{end}}if not functions -q __ctx_prompt
+    functions -c fish_prompt __ctx_orig_fish_prompt
+    function fish_prompt
+        echo -n {{quote .Marker}}' '
+        __ctx_orig_fish_prompt
+    end
+end
+`
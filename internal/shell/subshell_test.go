@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2026 Vedran Lebo <vedran@flyingpenguin.tech>
+// SPDX-License-Identifier: MIT
+
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareSubshell_Bash(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, ".bashrc"), []byte("export FROM_BASHRC=yes\nPS1='$ '\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := SubshellConfig{
+		Path:     bash,
+		Marker:   "[ctx: it's-dev]",
+		HistFile: filepath.Join(home, "dev_history"),
+		Home:     home,
+	}
+	args, env, err := PrepareSubshell(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("PrepareSubshell() error = %v", err)
+	}
+	if len(env) != 0 {
+		t.Errorf("PrepareSubshell() env = %v, want none for bash", env)
+	}
+
+	// The user's bashrc is still loaded, then the prompt and history are set
+	cmd := exec.Command(bash, append(args, "-c", `echo "$FROM_BASHRC|$PS1|$HISTFILE"`)...)
+	cmd.Env = []string{"HOME=" + home, "PATH=" + os.Getenv("PATH")}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("bash error = %v", err)
+	}
+	want := "yes|[ctx: it's-dev] $ |" + cfg.HistFile
+	if got := strings.TrimSpace(string(out)); got != want {
+		t.Errorf("subshell state = %q, want %q", got, want)
+	}
+}
+
+func TestPrepareSubshell_Zsh(t *testing.T) {
+	dir := t.TempDir()
+	args, env, err := PrepareSubshell(SubshellConfig{Path: "/bin/zsh", Marker: "[ctx: dev]", Home: "/home/test"}, dir)
+	if err != nil {
+		t.Fatalf("PrepareSubshell() error = %v", err)
+	}
+	if env["ZDOTDIR"] != dir {
+		t.Errorf("ZDOTDIR = %q, want %q", env["ZDOTDIR"], dir)
+	}
+	if len(args) != 1 || args[0] != "-i" {
+		t.Errorf("args = %v, want [-i]", args)
+	}
+
+	rc, err := os.ReadFile(filepath.Join(dir, ".zshrc"))
+	if err != nil {
+		t.Fatalf("ReadFile(.zshrc) error = %v", err)
+	}
+	for _, want := range []string{`source "$ZDOTDIR/.zshrc"`, `PROMPT='[ctx: dev]'" $PROMPT"`, "__ctx_prompt"} {
+		if !strings.Contains(string(rc), want) {
+			t.Errorf(".zshrc missing %q", want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".zshenv")); err != nil {
+		t.Errorf(".zshenv not written: %v", err)
+	}
+}
+
+func TestPrepareSubshell_Other(t *testing.T) {
+	args, env, err := PrepareSubshell(SubshellConfig{Path: "/bin/sh", Marker: "[ctx: dev]", HistFile: "/tmp/h"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("PrepareSubshell() error = %v", err)
+	}
+	if env["PS1"] != "[ctx: dev] $ " || env["HISTFILE"] != "/tmp/h" {
+		t.Errorf("env = %v, want PS1 and HISTFILE", env)
+	}
+	if len(args) != 1 || args[0] != "-i" {
+		t.Errorf("args = %v, want [-i]", args)
+	}
+}
+
+func TestFishHistoryName(t *testing.T) {
+	if got := FishHistoryName("acme/prod-eu.1"); got != "ctx_acme_prod_eu_1" {
+		t.Errorf("FishHistoryName() = %q, want ctx_acme_prod_eu_1", got)
+	}
+}
@@ -313,7 +313,7 @@ func (m *Manager) writeState() error {
 		return nil
 	}
 
-	statePath := filepath.Join(m.stateDir, m.contextName+".json")
+	statePath := filepath.Join(m.stateDir, config.ContextFileName(m.contextName)+".json")
 
 	state := State{
 		ContextName: m.contextName,
@@ -340,13 +340,13 @@ func (m *Manager) removeState() error {
 		return nil
 	}
 
-	statePath := filepath.Join(m.stateDir, m.contextName+".json")
+	statePath := filepath.Join(m.stateDir, config.ContextFileName(m.contextName)+".json")
 	return os.Remove(statePath)
 }
 
 // LoadState loads the state from a file.
 func LoadState(stateDir, contextName string) (*State, error) {
-	statePath := filepath.Join(stateDir, contextName+".json")
+	statePath := filepath.Join(stateDir, config.ContextFileName(contextName)+".json")
 
 	data, err := os.ReadFile(statePath)
 	if err != nil {